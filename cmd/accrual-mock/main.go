@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/denmor86/ya-gophermart/internal/accrualmock"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+func main() {
+	var (
+		address   = pflag.StringP("address", "a", "localhost:8081", "Listen address in a form host:port.")
+		script    = pflag.StringP("script", "s", "", "Path to JSON file with scripted order responses")
+		latency   = pflag.Duration("latency", 0, "Delay before each response")
+		errorRate = pflag.Float64("error-rate", 0, "Probability of 500 response, from 0 to 1")
+		rateLimit = pflag.Int("rate-limit", 0, "Requests per minute allowed, 0 - unlimited")
+		mode      = pflag.String("default", accrualmock.DefaultNotRegistered, "Behaviour for orders without script: not_registered or progress")
+		accrual   = pflag.Float64("default-accrual", 100, "Accrual for processed orders in progress mode")
+		logLevel  = pflag.StringP("log_level", "l", "info", "Log level.")
+	)
+	pflag.Parse()
+
+	if err := logger.Initialize(*logLevel); err != nil {
+		panic(fmt.Sprintf("can't initialize logger: %s ", err.Error()))
+	}
+	defer logger.Sync()
+
+	config := accrualmock.Config{
+		Latency:        *latency,
+		ErrorRate:      *errorRate,
+		RateLimit:      *rateLimit,
+		DefaultMode:    *mode,
+		DefaultAccrual: *accrual,
+	}
+	if *script != "" {
+		orders, err := accrualmock.LoadScript(*script)
+		if err != nil {
+			panic(fmt.Sprintf("can't load script: %s ", err.Error()))
+		}
+		config.Orders = orders
+	}
+
+	logger.Info("Starting accrual mock:", *address)
+	if err := http.ListenAndServe(*address, accrualmock.NewServer(config)); err != nil {
+		logger.Error("error listen server:", zap.Error(err))
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/denmor86/ya-gophermart/internal/accrual"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/storage"
+	"github.com/pkg/errors"
+)
+
+func main() {
+	// загрузка конфига
+	config := accrual.NewConfig()
+	// инициализация логгера
+	if err := logger.Initialize(config.LogLevel); err != nil {
+		panic(fmt.Sprintf("can't initialize logger: %s ", err.Error()))
+	}
+	defer logger.Sync()
+
+	database, err := storage.NewDatabase(config.DatabaseDSN)
+	if err != nil {
+		panic(fmt.Sprintf("can't create database storage: %s ", errors.Cause(err).Error()))
+	}
+	if err = accrual.Initialize(database); err != nil {
+		panic(fmt.Sprintf("can't initialize database storage: %s ", errors.Cause(err).Error()))
+	}
+	defer database.Close()
+
+	accrual.Run(config, accrual.NewStorage(database))
+}
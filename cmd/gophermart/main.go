@@ -5,6 +5,7 @@ import (
 
 	"github.com/denmor86/ya-gophermart/internal/app"
 	"github.com/denmor86/ya-gophermart/internal/config"
+	"github.com/denmor86/ya-gophermart/internal/leader"
 	"github.com/denmor86/ya-gophermart/internal/logger"
 	"github.com/denmor86/ya-gophermart/internal/storage"
 	"github.com/pkg/errors"
@@ -29,5 +30,5 @@ func main() {
 	defer database.Close()
 
 	// создание маршутизатора
-	app.Run(config, storage.NewStorage(database))
+	app.Run(config, storage.NewStorage(database), leader.NewElector(config.Leader, database))
 }
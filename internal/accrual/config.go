@@ -0,0 +1,72 @@
+package accrual
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/caarlos0/env"
+	"github.com/spf13/pflag"
+)
+
+type Arguments struct {
+	ListenAddr      string        `env:"RUN_ADDRESS" envDefault:"localhost:8081"`
+	LogLevel        string        `env:"LOG_LEVEL" envDefault:"info"`
+	DatabaseDSN     string        `env:"DATABASE_URI" envDefault:""`
+	RateLimit       int           `env:"RATE_LIMIT" envDefault:"0"`
+	BatchSize       int           `env:"PROCESSOR_BATCH_SIZE" envDefault:"50"`
+	PollInterval    time.Duration `env:"PROCESSOR_POLL_INTERVAL" envDefault:"1s"`
+	StaleTimeout    time.Duration `env:"PROCESSOR_STALE_TIMEOUT" envDefault:"1m"`
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"5s"`
+}
+
+// Config модель настроек системы расчёта начислений
+type Config struct {
+	ListenAddr      string
+	LogLevel        string
+	DatabaseDSN     string
+	RateLimit       int
+	BatchSize       int
+	PollInterval    time.Duration
+	StaleTimeout    time.Duration
+	ShutdownTimeout time.Duration
+}
+
+func NewConfig() Config {
+
+	var args Arguments
+	if err := env.Parse(&args); err != nil {
+		panic(fmt.Sprintf("Failed to parse enviroment var: %s", err.Error()))
+	}
+
+	var (
+		server    = pflag.StringP("server", "a", args.ListenAddr, "Server listen address in a form host:port.")
+		logLevel  = pflag.StringP("log_level", "l", args.LogLevel, "Log level.")
+		DSN       = pflag.StringP("dsn", "d", args.DatabaseDSN, "Database DSN")
+		rateLimit = pflag.Int("rate-limit", args.RateLimit, "Requests per minute allowed for order info, 0 - unlimited")
+	)
+	pflag.Parse()
+
+	return Config{
+		ListenAddr:      *server,
+		LogLevel:        *logLevel,
+		DatabaseDSN:     *DSN,
+		RateLimit:       *rateLimit,
+		BatchSize:       args.BatchSize,
+		PollInterval:    args.PollInterval,
+		StaleTimeout:    args.StaleTimeout,
+		ShutdownTimeout: args.ShutdownTimeout,
+	}
+}
+
+func DefaultConfig() Config {
+	return Config{
+		ListenAddr:      "localhost:8081",
+		LogLevel:        "info",
+		DatabaseDSN:     "",
+		RateLimit:       0,
+		BatchSize:       50,
+		PollInterval:    time.Second,
+		StaleTimeout:    time.Minute,
+		ShutdownTimeout: 5 * time.Second,
+	}
+}
@@ -0,0 +1,180 @@
+package accrual
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// decimalNumber - представление суммы точным числом JSON
+func decimalNumber(value decimal.Decimal) json.Number {
+	return json.Number(value.String())
+}
+
+// RegisterRewardHandler — регистрация вознаграждения за товары
+func RegisterRewardHandler(s Service) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reward Reward
+		if err := json.NewDecoder(r.Body).Decode(&reward); err != nil {
+			logger.Warn("Invalid request format:", zap.Error(err))
+			http.Error(w, "Invalid request format", http.StatusBadRequest)
+			return
+		}
+		err := s.RegisterReward(r.Context(), reward)
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrInvalidRequest):
+				http.Error(w, "Invalid request format", http.StatusBadRequest)
+			case errors.Is(err, ErrRewardExists):
+				http.Error(w, "Match key already registered", http.StatusConflict)
+			default:
+				logger.Error("Failed to register reward:", zap.Error(err))
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			}
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// RegisterOrderHandler — регистрация заказа для расчёта начисления
+func RegisterOrderHandler(s Service) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request OrderRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			logger.Warn("Invalid request format:", zap.Error(err))
+			http.Error(w, "Invalid request format", http.StatusBadRequest)
+			return
+		}
+		err := s.RegisterOrder(r.Context(), request)
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrInvalidRequest):
+				http.Error(w, "Invalid request format", http.StatusBadRequest)
+			case errors.Is(err, ErrInvalidOrderNumber):
+				http.Error(w, "Invalid order number format", http.StatusUnprocessableEntity)
+			case errors.Is(err, ErrOrderExists):
+				http.Error(w, "Order already accepted", http.StatusConflict)
+			default:
+				logger.Error("Failed to register order:", zap.Error(err))
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			}
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+// GetOrderHandler — получение информации о расчёте начисления по заказу
+func GetOrderHandler(s Service) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order, err := s.GetOrder(r.Context(), chi.URLParam(r, "number"))
+		if err != nil {
+			if errors.Is(err, ErrOrderNotFound) {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			logger.Error("Failed to get order:", zap.Error(err))
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(order); err != nil {
+			logger.Error("Failed to encode JSON response:", zap.Error(err))
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// MaxOrdersStatusBatch - максимальное количество заказов в пакетном запросе статусов,
+// клиент делит пачку заказов на запросы этого размера (client.MaxOrdersBatch)
+const MaxOrdersStatusBatch = 100
+
+// GetOrdersStatusHandler — получение информации о расчёте начислений по списку заказов
+func GetOrdersStatusHandler(s Service) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request OrdersStatusRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			logger.Warn("Invalid request format:", zap.Error(err))
+			http.Error(w, "Invalid request format", http.StatusBadRequest)
+			return
+		}
+		if len(request.Orders) > MaxOrdersStatusBatch {
+			http.Error(w, fmt.Sprintf("No more than %d orders per request allowed", MaxOrdersStatusBatch), http.StatusBadRequest)
+			return
+		}
+
+		response := OrdersStatusResponse{Orders: make([]OrderResponse, 0, len(request.Orders))}
+		for _, number := range request.Orders {
+			order, err := s.GetOrder(r.Context(), number)
+			if err != nil {
+				if errors.Is(err, ErrOrderNotFound) {
+					continue
+				}
+				logger.Error("Failed to get order:", zap.Error(err))
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			response.Orders = append(response.Orders, *order)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			logger.Error("Failed to encode JSON response:", zap.Error(err))
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// RateLimit — middleware ограничения количества запросов в минуту.
+// При превышении отвечает 429 с Retry-After до начала следующего окна.
+func RateLimit(limit int) func(http.Handler) http.Handler {
+	var (
+		mu     sync.Mutex
+		window time.Time
+		count  int
+	)
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if limit <= 0 {
+				h.ServeHTTP(w, r)
+				return
+			}
+			mu.Lock()
+			now := time.Now()
+			if now.Sub(window) >= time.Minute {
+				window = now
+				count = 0
+			}
+			count++
+			exceeded := count > limit
+			retryAfter := int((time.Minute - now.Sub(window)).Seconds()) + 1
+			remaining := max(limit-count, 0)
+			mu.Unlock()
+
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.Itoa(retryAfter))
+			if exceeded {
+				w.Header().Set("Content-Type", "text/plain")
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				w.WriteHeader(http.StatusTooManyRequests)
+				fmt.Fprintf(w, "No more than %d requests per minute allowed", limit)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE SCHEMA IF NOT EXISTS accrual;
+
+CREATE TABLE IF NOT EXISTS accrual.rewards (
+   match TEXT PRIMARY KEY NOT NULL,
+   reward DECIMAL(12, 2) NOT NULL,
+   reward_type TEXT NOT NULL,
+   created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS accrual.orders (
+   number TEXT PRIMARY KEY NOT NULL,
+   status TEXT NOT NULL,
+   accrual DECIMAL(12, 2) NOT NULL DEFAULT 0,
+   created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+   updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_accrual_orders_status ON accrual.orders (status, created_at);
+
+CREATE TABLE IF NOT EXISTS accrual.order_goods (
+   id SERIAL PRIMARY KEY,
+   order_number TEXT NOT NULL REFERENCES accrual.orders (number) ON DELETE CASCADE,
+   description TEXT NOT NULL,
+   price DECIMAL(12, 2) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_accrual_order_goods ON accrual.order_goods (order_number);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE accrual.order_goods;
+DROP TABLE accrual.orders;
+DROP TABLE accrual.rewards;
+DROP SCHEMA accrual;
+-- +goose StatementEnd
@@ -0,0 +1,131 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal\accrual\storage.go
+//
+// Generated by this command:
+//
+//	mockgen -source=internal\accrual\storage.go -destination=internal\accrual\mocks\storage_mock.go -package=mocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	accrual "github.com/denmor86/ya-gophermart/internal/accrual"
+	decimal "github.com/shopspring/decimal"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockStorage is a mock of Storage interface.
+type MockStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockStorageMockRecorder
+	isgomock struct{}
+}
+
+// MockStorageMockRecorder is the mock recorder for MockStorage.
+type MockStorageMockRecorder struct {
+	mock *MockStorage
+}
+
+// NewMockStorage creates a new mock instance.
+func NewMockStorage(ctrl *gomock.Controller) *MockStorage {
+	mock := &MockStorage{ctrl: ctrl}
+	mock.recorder = &MockStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
+	return m.recorder
+}
+
+// AddOrder mocks base method.
+func (m *MockStorage) AddOrder(ctx context.Context, order accrual.Order) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddOrder", ctx, order)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddOrder indicates an expected call of AddOrder.
+func (mr *MockStorageMockRecorder) AddOrder(ctx, order any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddOrder", reflect.TypeOf((*MockStorage)(nil).AddOrder), ctx, order)
+}
+
+// AddReward mocks base method.
+func (m *MockStorage) AddReward(ctx context.Context, reward accrual.Reward) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddReward", ctx, reward)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddReward indicates an expected call of AddReward.
+func (mr *MockStorageMockRecorder) AddReward(ctx, reward any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddReward", reflect.TypeOf((*MockStorage)(nil).AddReward), ctx, reward)
+}
+
+// ClaimOrders mocks base method.
+func (m *MockStorage) ClaimOrders(ctx context.Context, count int, stale time.Duration) ([]accrual.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimOrders", ctx, count, stale)
+	ret0, _ := ret[0].([]accrual.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimOrders indicates an expected call of ClaimOrders.
+func (mr *MockStorageMockRecorder) ClaimOrders(ctx, count, stale any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimOrders", reflect.TypeOf((*MockStorage)(nil).ClaimOrders), ctx, count, stale)
+}
+
+// GetOrder mocks base method.
+func (m *MockStorage) GetOrder(ctx context.Context, number string) (*accrual.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrder", ctx, number)
+	ret0, _ := ret[0].(*accrual.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrder indicates an expected call of GetOrder.
+func (mr *MockStorageMockRecorder) GetOrder(ctx, number any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrder", reflect.TypeOf((*MockStorage)(nil).GetOrder), ctx, number)
+}
+
+// GetRewards mocks base method.
+func (m *MockStorage) GetRewards(ctx context.Context) ([]accrual.Reward, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRewards", ctx)
+	ret0, _ := ret[0].([]accrual.Reward)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRewards indicates an expected call of GetRewards.
+func (mr *MockStorageMockRecorder) GetRewards(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRewards", reflect.TypeOf((*MockStorage)(nil).GetRewards), ctx)
+}
+
+// UpdateOrder mocks base method.
+func (m *MockStorage) UpdateOrder(ctx context.Context, number, status string, arg3 decimal.Decimal) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOrder", ctx, number, status, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateOrder indicates an expected call of UpdateOrder.
+func (mr *MockStorageMockRecorder) UpdateOrder(ctx, number, status, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrder", reflect.TypeOf((*MockStorage)(nil).UpdateOrder), ctx, number, status, arg3)
+}
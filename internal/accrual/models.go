@@ -0,0 +1,67 @@
+package accrual
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Типы вознаграждения за товар
+const (
+	RewardTypePercent = "%"
+	RewardTypePoints  = "pt"
+)
+
+// Статусы расчёта начисления
+const (
+	StatusRegistered = "REGISTERED"
+	StatusInvalid    = "INVALID"
+	StatusProcessing = "PROCESSING"
+	StatusProcessed  = "PROCESSED"
+)
+
+// Reward - модель вознаграждения за товары, содержащие ключ поиска в описании
+type Reward struct {
+	Match      string          `json:"match"`       // Ключ поиска в описании товара
+	Reward     decimal.Decimal `json:"reward"`      // Размер вознаграждения
+	RewardType string          `json:"reward_type"` // Тип вознаграждения: % от цены или баллы
+}
+
+// Good - модель товара в составе заказа
+type Good struct {
+	Description string          `json:"description"` // Описание товара
+	Price       decimal.Decimal `json:"price"`       // Цена товара
+}
+
+// OrderRequest - модель запроса регистрации заказа для расчёта
+type OrderRequest struct {
+	Order string `json:"order"`
+	Goods []Good `json:"goods"`
+}
+
+// OrderResponse - модель ответа о расчёте начисления по заказу
+type OrderResponse struct {
+	Order   string      `json:"order"`
+	Status  string      `json:"status"`
+	Accrual json.Number `json:"accrual,omitempty"`
+}
+
+// OrdersStatusRequest - модель пакетного запроса статусов заказов
+type OrdersStatusRequest struct {
+	Orders []string `json:"orders"`
+}
+
+// OrdersStatusResponse - модель ответа на пакетный запрос, незарегистрированные заказы в ответ не входят
+type OrdersStatusResponse struct {
+	Orders []OrderResponse `json:"orders"`
+}
+
+// Order - модель заказа из хранилища
+type Order struct {
+	Number    string
+	Status    string
+	Accrual   decimal.Decimal
+	Goods     []Good
+	CreatedAt time.Time
+}
@@ -0,0 +1,66 @@
+package accrual
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Processor - асинхронный расчёт начислений по зарегистрированным заказам
+type Processor struct {
+	Service   Service
+	WaitGroup sync.WaitGroup
+	QuitChan  chan struct{}
+	config    Config
+}
+
+func NewProcessor(service Service, config Config) *Processor {
+	return &Processor{
+		Service:  service,
+		QuitChan: make(chan struct{}),
+		config:   config,
+	}
+}
+
+func (p *Processor) Start(ctx context.Context) {
+	p.WaitGroup.Add(1)
+	go p.Run(ctx)
+}
+
+func (p *Processor) Stop() {
+	close(p.QuitChan)
+	p.WaitGroup.Wait()
+}
+
+func (p *Processor) Run(ctx context.Context) {
+	defer p.WaitGroup.Done()
+
+	ticker := time.NewTicker(p.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.QuitChan:
+			logger.Info("Processor stopped by quit signal")
+			return
+		case <-ctx.Done():
+			logger.Info("Processor stopped by context cancellation")
+			return
+		case <-ticker.C:
+			// обрабатываем, пока есть заказы, не дожидаясь следующего тика
+			for {
+				processed, err := p.Service.ProcessOrders(ctx, p.config.BatchSize)
+				if err != nil {
+					logger.Error("Failed to process orders:", zap.Error(err))
+					break
+				}
+				if processed < p.config.BatchSize {
+					break
+				}
+			}
+		}
+	}
+}
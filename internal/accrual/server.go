@@ -0,0 +1,66 @@
+package accrual
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/network/middleware"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// NewRouter - маршрутизатор API системы расчёта начислений
+func NewRouter(service Service, config Config) chi.Router {
+	r := chi.NewRouter()
+	r.Route("/api", func(r chi.Router) {
+		r.Use(middleware.LogHandle)
+		r.Post("/goods", RegisterRewardHandler(service))
+		r.Post("/orders", RegisterOrderHandler(service))
+		r.Group(func(r chi.Router) {
+			r.Use(RateLimit(config.RateLimit))
+			r.Get("/orders/{number}", GetOrderHandler(service))
+			r.Post("/orders/status", GetOrdersStatusHandler(service))
+		})
+	})
+	return r
+}
+
+// Run - запуск системы расчёта начислений до получения сигнала остановки
+func Run(config Config, storage Storage) {
+	service := NewService(storage, config.StaleTimeout)
+
+	server := &http.Server{
+		Addr:    config.ListenAddr,
+		Handler: NewRouter(service, config),
+	}
+
+	processor := NewProcessor(service, config)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	processor.Start(ctx)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		logger.Info("Starting accrual server config:", config)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("error listen server:", zap.Error(err))
+		}
+	}()
+
+	<-stop
+	logger.Info("Shutdown accrual server")
+	processor.Stop()
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancelShutdown()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error("error shutdown server:", zap.Error(err))
+	}
+	logger.Info("Accrual server stopped")
+}
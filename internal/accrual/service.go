@@ -0,0 +1,146 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/validators"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidRequest     = errors.New("invalid request")
+	ErrInvalidOrderNumber = errors.New("invalid order number")
+	ErrRewardExists       = errors.New("reward already registered")
+	ErrOrderExists        = errors.New("order already registered")
+	ErrOrderNotFound      = errors.New("order not registered")
+)
+
+// Service - представляет интерфейс сервиса расчёта начислений
+type Service interface {
+	RegisterReward(ctx context.Context, reward Reward) error
+	RegisterOrder(ctx context.Context, request OrderRequest) error
+	GetOrder(ctx context.Context, number string) (*OrderResponse, error)
+	ProcessOrders(ctx context.Context, count int) (int, error)
+}
+
+type Accrual struct {
+	Storage Storage
+	// StaleTimeout - время, после которого заказ в обработке считается зависшим
+	StaleTimeout time.Duration
+}
+
+// Создание сервиса
+func NewService(storage Storage, staleTimeout time.Duration) Service {
+	return &Accrual{Storage: storage, StaleTimeout: staleTimeout}
+}
+
+// RegisterReward - регистрация вознаграждения за товары
+func (s *Accrual) RegisterReward(ctx context.Context, reward Reward) error {
+	if reward.Match == "" || reward.Reward.IsNegative() {
+		return ErrInvalidRequest
+	}
+	if reward.RewardType != RewardTypePercent && reward.RewardType != RewardTypePoints {
+		return ErrInvalidRequest
+	}
+	err := s.Storage.AddReward(ctx, reward)
+	if errors.Is(err, ErrAlreadyExists) {
+		return ErrRewardExists
+	}
+	return err
+}
+
+// RegisterOrder - регистрация заказа для расчёта начисления
+func (s *Accrual) RegisterOrder(ctx context.Context, request OrderRequest) error {
+	if !validators.CheckNumber(request.Order) {
+		return ErrInvalidOrderNumber
+	}
+	for _, good := range request.Goods {
+		if good.Price.IsNegative() {
+			return ErrInvalidRequest
+		}
+	}
+	err := s.Storage.AddOrder(ctx, Order{Number: request.Order, Status: StatusRegistered, Goods: request.Goods})
+	if errors.Is(err, ErrAlreadyExists) {
+		return ErrOrderExists
+	}
+	return err
+}
+
+// GetOrder - информация о расчёте начисления по заказу
+func (s *Accrual) GetOrder(ctx context.Context, number string) (*OrderResponse, error) {
+	order, err := s.Storage.GetOrder(ctx, number)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, err
+	}
+	response := &OrderResponse{Order: order.Number, Status: order.Status}
+	if order.Status == StatusProcessed && order.Accrual.IsPositive() {
+		response.Accrual = decimalNumber(order.Accrual)
+	}
+	return response, nil
+}
+
+// ProcessOrders - расчёт начислений для пачки зарегистрированных заказов
+func (s *Accrual) ProcessOrders(ctx context.Context, count int) (int, error) {
+	orders, err := s.Storage.ClaimOrders(ctx, count, s.StaleTimeout)
+	if err != nil || len(orders) == 0 {
+		return 0, err
+	}
+	rewards, err := s.Storage.GetRewards(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for _, order := range orders {
+		status := StatusProcessed
+		accrual := decimal.Zero
+		// заказ без товаров не принимается к расчёту
+		if len(order.Goods) == 0 {
+			status = StatusInvalid
+		} else {
+			accrual = Calculate(order.Goods, rewards)
+		}
+		if err := s.Storage.UpdateOrder(ctx, order.Number, status, accrual); err != nil {
+			logger.Error("Failed to update order", order.Number, zap.Error(err))
+			continue
+		}
+		processed++
+	}
+	return processed, nil
+}
+
+// Calculate - расчёт начисления за товары заказа.
+// Для каждого товара применяется вознаграждение с самым длинным ключом поиска,
+// входящим в описание товара, товары без подходящего вознаграждения не учитываются.
+func Calculate(goods []Good, rewards []Reward) decimal.Decimal {
+	total := decimal.Zero
+	for _, good := range goods {
+		var matched *Reward
+		for i := range rewards {
+			if !strings.Contains(good.Description, rewards[i].Match) {
+				continue
+			}
+			if matched == nil || len(rewards[i].Match) > len(matched.Match) {
+				matched = &rewards[i]
+			}
+		}
+		if matched == nil {
+			continue
+		}
+		switch matched.RewardType {
+		case RewardTypePercent:
+			total = total.Add(good.Price.Mul(matched.Reward).Div(decimal.NewFromInt(100)))
+		case RewardTypePoints:
+			total = total.Add(matched.Reward)
+		}
+	}
+	return total.Round(2)
+}
@@ -0,0 +1,281 @@
+package accrual_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/accrual"
+	"github.com/denmor86/ya-gophermart/internal/accrual/mocks"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCalculate(t *testing.T) {
+	rewards := []accrual.Reward{
+		{Match: "Bork", Reward: decimal.NewFromInt(10), RewardType: accrual.RewardTypePercent},
+		{Match: "Чайник Bork", Reward: decimal.NewFromInt(15), RewardType: accrual.RewardTypePercent},
+		{Match: "LG", Reward: decimal.NewFromInt(100), RewardType: accrual.RewardTypePoints},
+	}
+
+	testCases := []struct {
+		Name            string
+		Goods           []accrual.Good
+		ExpectedAccrual decimal.Decimal
+	}{
+		{
+			Name:            "Success. Percent reward #1",
+			Goods:           []accrual.Good{{Description: "Утюг Bork", Price: decimal.NewFromInt(7000)}},
+			ExpectedAccrual: decimal.NewFromInt(700),
+		},
+		{
+			Name:            "Success. Longest match wins #2",
+			Goods:           []accrual.Good{{Description: "Чайник Bork", Price: decimal.NewFromInt(1000)}},
+			ExpectedAccrual: decimal.NewFromInt(150),
+		},
+		{
+			Name: "Success. Points and percent #3",
+			Goods: []accrual.Good{
+				{Description: "Стиральная машина LG", Price: decimal.NewFromInt(40000)},
+				{Description: "Утюг Bork", Price: decimal.RequireFromString("7299.8")},
+			},
+			ExpectedAccrual: decimal.RequireFromString("829.98"),
+		},
+		{
+			Name:            "Success. No matching reward #4",
+			Goods:           []accrual.Good{{Description: "Пылесос Dyson", Price: decimal.NewFromInt(30000)}},
+			ExpectedAccrual: decimal.Zero,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			result := accrual.Calculate(tc.Goods, rewards)
+			if !result.Equal(tc.ExpectedAccrual) {
+				t.Errorf("Expected accrual: '%v', got: '%v'", tc.ExpectedAccrual, result)
+			}
+		})
+	}
+}
+
+func TestAccrualService_RegisterOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockStorage := mocks.NewMockStorage(ctrl)
+	if err := logger.Initialize("info"); err != nil {
+		logger.Panic(err)
+	}
+
+	service := accrual.NewService(mockStorage, time.Minute)
+
+	testCases := []struct {
+		Name          string
+		Request       accrual.OrderRequest
+		SetupMocks    func()
+		ExpectedError error
+	}{
+		{
+			Name:          "Error. Invalid order number #1",
+			Request:       accrual.OrderRequest{Order: "12345"},
+			SetupMocks:    func() {},
+			ExpectedError: accrual.ErrInvalidOrderNumber,
+		},
+		{
+			Name:          "Error. Negative price #2",
+			Request:       accrual.OrderRequest{Order: "12345678903", Goods: []accrual.Good{{Description: "Bork", Price: decimal.NewFromInt(-1)}}},
+			SetupMocks:    func() {},
+			ExpectedError: accrual.ErrInvalidRequest,
+		},
+		{
+			Name:    "Error. Order already registered #3",
+			Request: accrual.OrderRequest{Order: "12345678903"},
+			SetupMocks: func() {
+				mockStorage.EXPECT().AddOrder(gomock.Any(), gomock.Any()).Return(accrual.ErrAlreadyExists)
+			},
+			ExpectedError: accrual.ErrOrderExists,
+		},
+		{
+			Name:    "Success. #4",
+			Request: accrual.OrderRequest{Order: "12345678903", Goods: []accrual.Good{{Description: "Bork", Price: decimal.NewFromInt(100)}}},
+			SetupMocks: func() {
+				mockStorage.EXPECT().AddOrder(gomock.Any(), accrual.Order{
+					Number: "12345678903",
+					Status: accrual.StatusRegistered,
+					Goods:  []accrual.Good{{Description: "Bork", Price: decimal.NewFromInt(100)}},
+				}).Return(nil)
+			},
+			ExpectedError: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.SetupMocks()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			err := service.RegisterOrder(ctx, tc.Request)
+			if !errors.Is(err, tc.ExpectedError) {
+				t.Errorf("Expected error: '%v', got: '%v'", tc.ExpectedError, err)
+			}
+		})
+	}
+}
+
+func TestAccrualService_RegisterReward(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockStorage := mocks.NewMockStorage(ctrl)
+
+	service := accrual.NewService(mockStorage, time.Minute)
+
+	testCases := []struct {
+		Name          string
+		Reward        accrual.Reward
+		SetupMocks    func()
+		ExpectedError error
+	}{
+		{
+			Name:          "Error. Unknown reward type #1",
+			Reward:        accrual.Reward{Match: "Bork", Reward: decimal.NewFromInt(10), RewardType: "usd"},
+			SetupMocks:    func() {},
+			ExpectedError: accrual.ErrInvalidRequest,
+		},
+		{
+			Name:   "Error. Match already registered #2",
+			Reward: accrual.Reward{Match: "Bork", Reward: decimal.NewFromInt(10), RewardType: accrual.RewardTypePercent},
+			SetupMocks: func() {
+				mockStorage.EXPECT().AddReward(gomock.Any(), gomock.Any()).Return(accrual.ErrAlreadyExists)
+			},
+			ExpectedError: accrual.ErrRewardExists,
+		},
+		{
+			Name:   "Success. #3",
+			Reward: accrual.Reward{Match: "Bork", Reward: decimal.NewFromInt(10), RewardType: accrual.RewardTypePoints},
+			SetupMocks: func() {
+				mockStorage.EXPECT().AddReward(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			ExpectedError: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.SetupMocks()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			err := service.RegisterReward(ctx, tc.Reward)
+			if !errors.Is(err, tc.ExpectedError) {
+				t.Errorf("Expected error: '%v', got: '%v'", tc.ExpectedError, err)
+			}
+		})
+	}
+}
+
+func TestAccrualService_ProcessOrders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockStorage := mocks.NewMockStorage(ctrl)
+
+	service := accrual.NewService(mockStorage, time.Minute)
+
+	rewards := []accrual.Reward{{Match: "Bork", Reward: decimal.NewFromInt(10), RewardType: accrual.RewardTypePercent}}
+	mockStorage.EXPECT().ClaimOrders(gomock.Any(), 10, time.Minute).Return([]accrual.Order{
+		{Number: "12345678903", Goods: []accrual.Good{{Description: "Утюг Bork", Price: decimal.NewFromInt(7000)}}},
+		{Number: "2377225624"},
+	}, nil)
+	mockStorage.EXPECT().GetRewards(gomock.Any()).Return(rewards, nil)
+	mockStorage.EXPECT().UpdateOrder(gomock.Any(), "12345678903", accrual.StatusProcessed, equalDecimal(decimal.NewFromInt(700))).Return(nil)
+	mockStorage.EXPECT().UpdateOrder(gomock.Any(), "2377225624", accrual.StatusInvalid, decimal.Zero).Return(nil)
+
+	processed, err := service.ProcessOrders(context.Background(), 10)
+	if err != nil {
+		t.Errorf("Expected no error, got: '%v'", err)
+	}
+	if processed != 2 {
+		t.Errorf("Expected processed: '%v', got: '%v'", 2, processed)
+	}
+}
+
+// equalDecimal - сравнение сумм без учёта внутреннего представления
+func equalDecimal(expected decimal.Decimal) gomock.Matcher {
+	return gomock.Cond(func(x any) bool {
+		value, ok := x.(decimal.Decimal)
+		return ok && value.Equal(expected)
+	})
+}
+
+func TestAccrualService_GetOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockStorage := mocks.NewMockStorage(ctrl)
+
+	service := accrual.NewService(mockStorage, time.Minute)
+
+	testCases := []struct {
+		Name             string
+		Number           string
+		SetupMocks       func()
+		ExpectedError    error
+		ExpectedResponse *accrual.OrderResponse
+	}{
+		{
+			Name:   "Error. Order not registered #1",
+			Number: "12345678903",
+			SetupMocks: func() {
+				mockStorage.EXPECT().GetOrder(gomock.Any(), "12345678903").Return(nil, accrual.ErrNotFound)
+			},
+			ExpectedError:    accrual.ErrOrderNotFound,
+			ExpectedResponse: nil,
+		},
+		{
+			Name:   "Success. Processed #2",
+			Number: "12345678903",
+			SetupMocks: func() {
+				mockStorage.EXPECT().GetOrder(gomock.Any(), "12345678903").Return(&accrual.Order{
+					Number:  "12345678903",
+					Status:  accrual.StatusProcessed,
+					Accrual: decimal.RequireFromString("729.98"),
+				}, nil)
+			},
+			ExpectedError:    nil,
+			ExpectedResponse: &accrual.OrderResponse{Order: "12345678903", Status: accrual.StatusProcessed, Accrual: "729.98"},
+		},
+		{
+			Name:   "Success. Registered without accrual #3",
+			Number: "12345678903",
+			SetupMocks: func() {
+				mockStorage.EXPECT().GetOrder(gomock.Any(), "12345678903").Return(&accrual.Order{
+					Number: "12345678903",
+					Status: accrual.StatusRegistered,
+				}, nil)
+			},
+			ExpectedError:    nil,
+			ExpectedResponse: &accrual.OrderResponse{Order: "12345678903", Status: accrual.StatusRegistered},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.SetupMocks()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			response, err := service.GetOrder(ctx, tc.Number)
+			if !errors.Is(err, tc.ExpectedError) {
+				t.Errorf("Expected error: '%v', got: '%v'", tc.ExpectedError, err)
+			}
+			diff := cmp.Diff(tc.ExpectedResponse, response)
+			if len(diff) != 0 {
+				t.Errorf("expected response mismatch:\n %s", diff)
+			}
+		})
+	}
+}
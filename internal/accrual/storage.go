@@ -0,0 +1,243 @@
+package accrual
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pressly/goose/v3"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+const (
+	// MigrationsTable - таблица версий миграций, отдельная от таблицы накопительной системы
+	MigrationsTable = "accrual_goose_db_version"
+
+	InsertReward = `INSERT INTO accrual.rewards (match, reward, reward_type) 
+					VALUES ($1, $2, $3) 
+					ON CONFLICT (match) DO NOTHING
+					RETURNING match;`
+	GetRewards  = `SELECT match, reward, reward_type FROM accrual.rewards ORDER BY created_at;`
+	InsertOrder = `INSERT INTO accrual.orders (number, status) 
+				   VALUES ($1, $2) 
+				   ON CONFLICT (number) DO NOTHING
+				   RETURNING number;`
+	InsertGood = `INSERT INTO accrual.order_goods (order_number, description, price) VALUES ($1, $2, $3);`
+	GetOrder   = `SELECT number, status, accrual, created_at FROM accrual.orders WHERE number = $1;`
+	// ClaimOrders - захват зарегистрированных заказов, а также зависших в обработке после сбоя
+	ClaimOrders = `UPDATE accrual.orders 
+				   SET status = 'PROCESSING', updated_at = NOW()
+				   WHERE number IN (
+				       SELECT number FROM accrual.orders
+				       WHERE status = 'REGISTERED' OR (status = 'PROCESSING' AND updated_at < NOW() - $2 * INTERVAL '1 millisecond')
+				       ORDER BY created_at
+				       LIMIT $1
+				       FOR UPDATE SKIP LOCKED
+				   )
+				   RETURNING number, created_at;`
+	GetGoods    = `SELECT order_number, description, price FROM accrual.order_goods WHERE order_number = ANY($1) ORDER BY id;`
+	UpdateOrder = `UPDATE accrual.orders SET status = $2, accrual = $3, updated_at = NOW() WHERE number = $1;`
+)
+
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrAlreadyExists = errors.New("already exists")
+)
+
+// Storage - представляет интерфейс хранилища системы расчёта начислений
+type Storage interface {
+	AddReward(ctx context.Context, reward Reward) error
+	GetRewards(ctx context.Context) ([]Reward, error)
+	AddOrder(ctx context.Context, order Order) error
+	GetOrder(ctx context.Context, number string) (*Order, error)
+	ClaimOrders(ctx context.Context, count int, stale time.Duration) ([]Order, error)
+	UpdateOrder(ctx context.Context, number string, status string, accrual decimal.Decimal) error
+}
+
+// Database - хранилище системы расчёта начислений в собственной схеме Postgres
+type Database struct {
+	DB *storage.Database
+}
+
+// Создание хранилища
+func NewStorage(db *storage.Database) Storage {
+	return &Database{DB: db}
+}
+
+//go:embed migrations/*.sql
+var embedMigrations embed.FS
+
+// Initialize - создание БД и миграция схемы системы расчёта начислений
+func Initialize(db *storage.Database) error {
+	if err := db.CreateDatabase(context.Background()); err != nil {
+		return fmt.Errorf("error create database: %w", err)
+	}
+	if err := Migration(db.DSN); err != nil {
+		return fmt.Errorf("error migrate database: %w", err)
+	}
+	return nil
+}
+
+func Migration(DatabaseDSN string) error {
+	db, err := sql.Open("pgx", DatabaseDSN)
+	if err != nil {
+		return fmt.Errorf("open db error: %w ", err)
+	}
+	defer db.Close()
+
+	goose.SetBaseFS(embedMigrations)
+	goose.SetTableName(MigrationsTable)
+
+	if err := goose.SetDialect("postgres"); err != nil {
+		return fmt.Errorf("goose set dialect error: %w ", err)
+	}
+	if err := goose.Up(db, "migrations"); err != nil {
+		return fmt.Errorf("goose run migrations error:  %w ", err)
+	}
+	return nil
+}
+
+func (s *Database) AddReward(ctx context.Context, reward Reward) error {
+	var match string
+	err := s.DB.Pool.QueryRow(ctx, InsertReward, reward.Match, reward.Reward, reward.RewardType).Scan(&match)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrAlreadyExists
+	}
+	return fmt.Errorf("failed to add reward: %w", err)
+}
+
+func (s *Database) GetRewards(ctx context.Context) ([]Reward, error) {
+	var rewards []Reward
+	rows, err := s.DB.Pool.Query(ctx, GetRewards)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rewards: %w", err)
+	}
+	for rows.Next() {
+		var reward Reward
+		if err := rows.Scan(&reward.Match, &reward.Reward, &reward.RewardType); err != nil {
+			return rewards, fmt.Errorf("failed scan reward: %w", err)
+		}
+		rewards = append(rewards, reward)
+	}
+	return rewards, rows.Err()
+}
+
+// AddOrder - регистрация заказа вместе с товарами в одной транзакции
+func (s *Database) AddOrder(ctx context.Context, order Order) error {
+	tx, err := s.DB.Pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Гарантированный откат при ошибке
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(ctx); rbErr != nil {
+				logger.Error("AddOrder. Rollback failed:", zap.Error(rbErr))
+			}
+		}
+	}()
+
+	var number string
+	err = tx.QueryRow(ctx, InsertOrder, order.Number, StatusRegistered).Scan(&number)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.Is(err, pgx.ErrNoRows) || (errors.As(err, &pgErr) && pgErr.Code == "23505") {
+			err = ErrAlreadyExists
+			return err
+		}
+		return fmt.Errorf("failed to add order: %w", err)
+	}
+
+	for _, good := range order.Goods {
+		if _, err = tx.Exec(ctx, InsertGood, order.Number, good.Description, good.Price); err != nil {
+			return fmt.Errorf("failed to add order goods: %w", err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("AddOrder. Commit failed: %w", err)
+	}
+	return nil
+}
+
+func (s *Database) GetOrder(ctx context.Context, number string) (*Order, error) {
+	var order Order
+	err := s.DB.Pool.QueryRow(ctx, GetOrder, number).Scan(&order.Number, &order.Status, &order.Accrual, &order.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+	return &order, nil
+}
+
+// ClaimOrders - захват пачки заказов на расчёт вместе с их товарами
+func (s *Database) ClaimOrders(ctx context.Context, count int, stale time.Duration) ([]Order, error) {
+	rows, err := s.DB.Pool.Query(ctx, ClaimOrders, count, stale.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim orders: %w", err)
+	}
+	var (
+		orders  []Order
+		numbers []string
+	)
+	for rows.Next() {
+		order := Order{Status: StatusProcessing}
+		if err := rows.Scan(&order.Number, &order.CreatedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed scan claimed order: %w", err)
+		}
+		orders = append(orders, order)
+		numbers = append(numbers, order.Number)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to claim orders: %w", err)
+	}
+	if len(orders) == 0 {
+		return nil, nil
+	}
+
+	goods := make(map[string][]Good)
+	rows, err = s.DB.Pool.Query(ctx, GetGoods, numbers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order goods: %w", err)
+	}
+	for rows.Next() {
+		var (
+			number string
+			good   Good
+		)
+		if err := rows.Scan(&number, &good.Description, &good.Price); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed scan order good: %w", err)
+		}
+		goods[number] = append(goods[number], good)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get order goods: %w", err)
+	}
+	for i := range orders {
+		orders[i].Goods = goods[orders[i].Number]
+	}
+	return orders, nil
+}
+
+func (s *Database) UpdateOrder(ctx context.Context, number string, status string, accrual decimal.Decimal) error {
+	if _, err := s.DB.Pool.Exec(ctx, UpdateOrder, number, status, accrual); err != nil {
+		return fmt.Errorf("failed to update order: %w", err)
+	}
+	return nil
+}
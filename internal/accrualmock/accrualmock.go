@@ -0,0 +1,281 @@
+// Package accrualmock - имитация системы расчёта начислений баллов лояльности.
+//
+// Сервер реализует GET /api/orders/{number} и пакетный POST /api/orders/status и отвечает
+// по сценариям: последовательность ответов для каждого заказа (например REGISTERED →
+// PROCESSING → PROCESSED), 204 для незарегистрированных заказов, 429 с Retry-After,
+// задержки и 5xx ошибки.
+// Может встраиваться в тесты через httptest.NewServer(accrualmock.NewServer(config)).
+package accrualmock
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/denmor86/ya-gophermart/internal/validators"
+	"github.com/go-chi/chi/v5"
+)
+
+// Поведение для заказов без сценария
+const (
+	// DefaultNotRegistered - заказ не зарегистрирован, ответ 204
+	DefaultNotRegistered = "not_registered"
+	// DefaultProgress - REGISTERED → PROCESSING → PROCESSED, заказ с неверным номером INVALID
+	DefaultProgress = "progress"
+)
+
+// MaxOrdersStatusBatch - наибольшее количество заказов в пакетном запросе, как у сервиса начислений
+const MaxOrdersStatusBatch = 100
+
+// Step - шаг сценария ответа по заказу
+type Step struct {
+	Status     string   `json:"status,omitempty"`      // Статус расчёта начисления
+	Accrual    *float64 `json:"accrual,omitempty"`     // Начисление, при отсутствии поле не выдаётся
+	Code       int      `json:"code,omitempty"`        // Код ответа, по умолчанию 200
+	RetryAfter int      `json:"retry_after,omitempty"` // Значение Retry-After для ответа 429, секунды
+}
+
+// Config - настройки имитации сервиса начислений
+type Config struct {
+	Latency        time.Duration     // Задержка перед каждым ответом
+	ErrorRate      float64           // Вероятность ответа 500, от 0 до 1
+	RateLimit      int               // Ограничение запросов в минуту, 0 - без ограничений
+	DefaultMode    string            // Поведение для заказов без сценария
+	DefaultAccrual float64           // Начисление для заказов в режиме DefaultProgress
+	Orders         map[string][]Step // Сценарии ответов по номерам заказов
+}
+
+// ScriptFile - формат файла сценариев
+type ScriptFile struct {
+	Orders map[string][]Step `json:"orders"`
+}
+
+// LoadScript - загрузка сценариев из JSON файла
+func LoadScript(path string) (map[string][]Step, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script: %w", err)
+	}
+	var script ScriptFile
+	if err := json.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("failed to parse script: %w", err)
+	}
+	return script.Orders, nil
+}
+
+// Server - имитация сервиса начислений
+type Server struct {
+	config   Config
+	router   chi.Router
+	mu       sync.Mutex
+	scripts  map[string][]Step
+	requests map[string]int
+	window   time.Time
+	inWindow int
+	random   *rand.Rand
+}
+
+// NewServer - создание имитации сервиса начислений
+func NewServer(config Config) *Server {
+	if config.DefaultMode == "" {
+		config.DefaultMode = DefaultNotRegistered
+	}
+	s := &Server{
+		config:   config,
+		scripts:  make(map[string][]Step),
+		requests: make(map[string]int),
+		random:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for number, steps := range config.Orders {
+		s.scripts[number] = steps
+	}
+	r := chi.NewRouter()
+	r.Get("/api/orders/{number}", s.handleOrder)
+	r.Post("/api/orders/status", s.handleOrdersStatus)
+	s.router = r
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, r)
+}
+
+// Script - установка сценария ответов по заказу. Последний шаг повторяется для всех следующих запросов.
+func (s *Server) Script(number string, steps ...Step) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[number] = steps
+	s.requests[number] = 0
+}
+
+// Requests - количество запросов по заказу
+func (s *Server) Requests(number string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[number]
+}
+
+func (s *Server) handleOrder(w http.ResponseWriter, r *http.Request) {
+	number := chi.URLParam(r, "number")
+
+	if s.config.Latency > 0 {
+		select {
+		case <-time.After(s.config.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if retryAfter, limited := s.takeRequest(); limited {
+		writeTooManyRequests(w, s.config.RateLimit, retryAfter)
+		return
+	}
+
+	step, ok := s.nextStep(number)
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	switch step.Code {
+	case 0, http.StatusOK:
+	case http.StatusTooManyRequests:
+		writeTooManyRequests(w, s.config.RateLimit, step.RetryAfter)
+		return
+	default:
+		w.WriteHeader(step.Code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(orderResponse{Order: number, Status: step.Status, Accrual: step.Accrual})
+}
+
+type orderResponse struct {
+	Order   string   `json:"order"`
+	Status  string   `json:"status"`
+	Accrual *float64 `json:"accrual,omitempty"`
+}
+
+// handleOrdersStatus - пакетный запрос статусов, учитывается как один запрос в ограничении частоты.
+// Незарегистрированные заказы в ответ не входят, код ошибки из сценария любого заказа
+// возвращается для всего запроса.
+func (s *Server) handleOrdersStatus(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Orders []string `json:"orders"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+	if len(request.Orders) > MaxOrdersStatusBatch {
+		http.Error(w, fmt.Sprintf("No more than %d orders per request allowed", MaxOrdersStatusBatch), http.StatusBadRequest)
+		return
+	}
+
+	if s.config.Latency > 0 {
+		select {
+		case <-time.After(s.config.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if retryAfter, limited := s.takeRequest(); limited {
+		writeTooManyRequests(w, s.config.RateLimit, retryAfter)
+		return
+	}
+
+	orders := make([]orderResponse, 0, len(request.Orders))
+	for _, number := range request.Orders {
+		step, ok := s.nextStep(number)
+		if !ok {
+			continue
+		}
+		switch step.Code {
+		case 0, http.StatusOK:
+			orders = append(orders, orderResponse{Order: number, Status: step.Status, Accrual: step.Accrual})
+		case http.StatusTooManyRequests:
+			writeTooManyRequests(w, s.config.RateLimit, step.RetryAfter)
+			return
+		default:
+			w.WriteHeader(step.Code)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(struct {
+		Orders []orderResponse `json:"orders"`
+	}{Orders: orders})
+}
+
+// takeRequest - учёт запроса в минутном окне: при превышении лимита возвращает
+// число секунд до начала следующего окна
+func (s *Server) takeRequest() (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.config.RateLimit <= 0 {
+		return 0, false
+	}
+	now := time.Now()
+	if now.Sub(s.window) >= time.Minute {
+		s.window = now
+		s.inWindow = 0
+	}
+	if s.inWindow >= s.config.RateLimit {
+		return int(time.Minute.Seconds() - now.Sub(s.window).Seconds()), true
+	}
+	s.inWindow++
+	return 0, false
+}
+
+// nextStep - следующий шаг сценария для заказа, с вероятностью ErrorRate - ответ 5xx
+func (s *Server) nextStep(number string) (Step, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempt := s.requests[number]
+	s.requests[number]++
+
+	if s.config.ErrorRate > 0 && s.random.Float64() < s.config.ErrorRate {
+		return Step{Code: http.StatusInternalServerError}, true
+	}
+
+	if steps, ok := s.scripts[number]; ok && len(steps) > 0 {
+		if attempt >= len(steps) {
+			attempt = len(steps) - 1
+		}
+		return steps[attempt], true
+	}
+
+	if s.config.DefaultMode != DefaultProgress {
+		return Step{}, false
+	}
+	if !validators.CheckNumber(number) {
+		return Step{Status: models.OrderStatusInvalid}, true
+	}
+	switch attempt {
+	case 0:
+		return Step{Status: models.OrderStatusRegistered}, true
+	case 1:
+		return Step{Status: models.OrderStatusProcessing}, true
+	default:
+		accrual := s.config.DefaultAccrual
+		return Step{Status: models.OrderStatusProcessed, Accrual: &accrual}, true
+	}
+}
+
+func writeTooManyRequests(w http.ResponseWriter, limit int, retryAfter int) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.WriteHeader(http.StatusTooManyRequests)
+	if limit > 0 {
+		fmt.Fprintf(w, "No more than %d requests per minute allowed", limit)
+	}
+}
@@ -0,0 +1,236 @@
+package accrualmock
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/google/go-cmp/cmp"
+)
+
+// reply - код и статус ответа по заказу
+type reply struct {
+	Code   int
+	Status string
+}
+
+func getOrder(t *testing.T, server *Server, number string) reply {
+	t.Helper()
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/orders/"+number, nil))
+	result := reply{Code: w.Code}
+	if w.Code == http.StatusOK {
+		var response orderResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Expected no error, got: '%v'", err)
+		}
+		result.Status = response.Status
+	}
+	return result
+}
+
+func TestServer_Order(t *testing.T) {
+	accrual := 500.0
+
+	testCases := []struct {
+		Name            string
+		Config          Config
+		Number          string
+		Steps           []Step
+		ExpectedReplies []reply
+	}{
+		{
+			Name:   "Success. Script with last step repeated #1",
+			Number: "12345678903",
+			Steps: []Step{
+				{Status: models.OrderStatusRegistered},
+				{Code: http.StatusServiceUnavailable},
+				{Status: models.OrderStatusProcessed, Accrual: &accrual},
+			},
+			ExpectedReplies: []reply{
+				{Code: http.StatusOK, Status: models.OrderStatusRegistered},
+				{Code: http.StatusServiceUnavailable},
+				{Code: http.StatusOK, Status: models.OrderStatusProcessed},
+				{Code: http.StatusOK, Status: models.OrderStatusProcessed},
+			},
+		},
+		{
+			Name:   "Success. Default progress #2",
+			Config: Config{DefaultMode: DefaultProgress, DefaultAccrual: accrual},
+			Number: "12345678903",
+			ExpectedReplies: []reply{
+				{Code: http.StatusOK, Status: models.OrderStatusRegistered},
+				{Code: http.StatusOK, Status: models.OrderStatusProcessing},
+				{Code: http.StatusOK, Status: models.OrderStatusProcessed},
+			},
+		},
+		{
+			Name:            "Success. Default progress for invalid number #3",
+			Config:          Config{DefaultMode: DefaultProgress},
+			Number:          "12345678900",
+			ExpectedReplies: []reply{{Code: http.StatusOK, Status: models.OrderStatusInvalid}},
+		},
+		{
+			Name:            "Error. Not registered order #4",
+			Number:          "12345678903",
+			ExpectedReplies: []reply{{Code: http.StatusNoContent}, {Code: http.StatusNoContent}},
+		},
+		{
+			Name:   "Error. Rate limit exceeded #5",
+			Config: Config{RateLimit: 2, DefaultMode: DefaultProgress},
+			Number: "12345678903",
+			ExpectedReplies: []reply{
+				{Code: http.StatusOK, Status: models.OrderStatusRegistered},
+				{Code: http.StatusOK, Status: models.OrderStatusProcessing},
+				{Code: http.StatusTooManyRequests},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			server := NewServer(tc.Config)
+			if tc.Steps != nil {
+				server.Script(tc.Number, tc.Steps...)
+			}
+
+			replies := make([]reply, 0, len(tc.ExpectedReplies))
+			for range tc.ExpectedReplies {
+				replies = append(replies, getOrder(t, server, tc.Number))
+			}
+
+			if diff := cmp.Diff(tc.ExpectedReplies, replies); diff != "" {
+				t.Errorf("Replies mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestServer_RateLimitHeaders(t *testing.T) {
+	server := NewServer(Config{RateLimit: 1, DefaultMode: DefaultProgress})
+	getOrder(t, server, "12345678903")
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/orders/12345678903", nil))
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status: %d, got: %d", http.StatusTooManyRequests, w.Code)
+	}
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter == "" || retryAfter == "0" {
+		t.Errorf("Expected Retry-After until next window, got: '%v'", retryAfter)
+	}
+	if body := w.Body.String(); body != "No more than 1 requests per minute allowed" {
+		t.Errorf("Unexpected body: '%v'", body)
+	}
+	// запрос, отклонённый ограничением, не продвигает сценарий
+	if requests := server.Requests("12345678903"); requests != 1 {
+		t.Errorf("Expected requests: '1', got: '%v'", requests)
+	}
+}
+
+func TestServer_OrdersStatus(t *testing.T) {
+	batch := func(count int) string {
+		orders := make([]string, 0, count)
+		for i := range count {
+			orders = append(orders, fmt.Sprintf(`"%d"`, i))
+		}
+		return `{"orders":[` + strings.Join(orders, ",") + `]}`
+	}
+
+	testCases := []struct {
+		Name           string
+		Body           string
+		Scripts        map[string][]Step
+		ExpectedStatus int
+		ExpectedOrders []orderResponse
+	}{
+		{
+			Name: "Success. Registered orders only #1",
+			Body: `{"orders":["1","2"]}`,
+			Scripts: map[string][]Step{
+				"1": {{Status: models.OrderStatusProcessing}},
+			},
+			ExpectedStatus: http.StatusOK,
+			ExpectedOrders: []orderResponse{{Order: "1", Status: models.OrderStatusProcessing}},
+		},
+		{
+			Name:           "Success. Full batch #2",
+			Body:           batch(MaxOrdersStatusBatch),
+			ExpectedStatus: http.StatusOK,
+			ExpectedOrders: []orderResponse{},
+		},
+		{
+			Name: "Error. Scripted error for whole batch #3",
+			Body: `{"orders":["1","2"]}`,
+			Scripts: map[string][]Step{
+				"1": {{Status: models.OrderStatusProcessing}},
+				"2": {{Code: http.StatusInternalServerError}},
+			},
+			ExpectedStatus: http.StatusInternalServerError,
+		},
+		{
+			Name:           "Error. Batch too large #4",
+			Body:           batch(MaxOrdersStatusBatch + 1),
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		{
+			Name:           "Error. Invalid request #5",
+			Body:           `{"orders":`,
+			ExpectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			server := NewServer(Config{Orders: tc.Scripts})
+
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/orders/status", strings.NewReader(tc.Body)))
+
+			if w.Code != tc.ExpectedStatus {
+				t.Fatalf("Expected status: %d, got: %d", tc.ExpectedStatus, w.Code)
+			}
+			if tc.ExpectedStatus != http.StatusOK {
+				return
+			}
+			var response struct {
+				Orders []orderResponse `json:"orders"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Expected no error, got: '%v'", err)
+			}
+			if diff := cmp.Diff(tc.ExpectedOrders, response.Orders); diff != "" {
+				t.Errorf("Orders mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadScript(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.json")
+	data := `{"orders":{"12345678903":[{"status":"PROCESSING"},{"code":429,"retry_after":5}]}}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+
+	orders, err := LoadScript(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	expected := map[string][]Step{
+		"12345678903": {{Status: models.OrderStatusProcessing}, {Code: http.StatusTooManyRequests, RetryAfter: 5}},
+	}
+	if diff := cmp.Diff(expected, orders); diff != "" {
+		t.Errorf("Script mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := LoadScript(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/denmor86/ya-gophermart/internal/config"
+	"github.com/denmor86/ya-gophermart/internal/leader"
 	"github.com/denmor86/ya-gophermart/internal/logger"
 	"github.com/denmor86/ya-gophermart/internal/network/router"
 	"github.com/denmor86/ya-gophermart/internal/storage"
@@ -16,19 +17,33 @@ import (
 	"go.uber.org/zap"
 )
 
-func Run(config config.Config, storage storage.Storage) {
+func Run(config config.Config, storage storage.Storage, elector leader.Elector) {
 
-	router := router.NewRouter(config, storage)
+	router := router.NewRouter(config, storage, elector)
+
+	// Создание заданий сгорания баллов, снятия просроченных резервов, пересчёта уровней и очистки ключей идемпотентности
+	expiration := worker.NewExpirationJob(router.Ledger, elector, config.Points)
+	holdRelease := worker.NewHoldReleaseJob(router.Loyalty, elector, config.Holds)
+	tierRecalc := worker.NewTierRecalcJob(router.Tiers, elector, config.Tiers)
+	idempotencyCleanup := worker.NewIdempotencyCleanupJob(router.Idempotency, elector, config.Server)
+
+	// Создание воркера
+	worker := worker.NewOrderWorker(router.Orders, storage.Listener, elector, router.Limiter, router.Breakers, config.Accrual)
+	router.Worker = worker
 
 	server := &http.Server{
 		Addr:    config.Server.ListenAddr,
 		Handler: router.HandleRouter(),
 	}
-	// Создание и запуск воркера
-	worker := worker.NewOrderWorker(router.Orders, config.Accrual)
+	// Запуск выбора лидера, воркера и периодических заданий
 	ctx, cancelWorker := context.WithCancel(context.Background())
 	defer cancelWorker()
+	elector.Start(ctx)
 	worker.Start(ctx)
+	expiration.Start(ctx)
+	holdRelease.Start(ctx)
+	tierRecalc.Start(ctx)
+	idempotencyCleanup.Start(ctx)
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
@@ -45,6 +60,15 @@ func Run(config config.Config, storage storage.Storage) {
 	<-stop
 	logger.Info("Shutdown server")
 	worker.Stop()
+	expiration.Stop()
+	holdRelease.Stop()
+	tierRecalc.Stop()
+	idempotencyCleanup.Stop()
+	elector.Stop()
+	// воркер остановлен, запись обмена с сервисом начислений завершается
+	if err := router.Close(); err != nil {
+		logger.Error("error close accrual clients:", zap.Error(err))
+	}
 
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
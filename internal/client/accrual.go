@@ -2,41 +2,90 @@ package client
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"time"
+
+	"github.com/shopspring/decimal"
+	"golang.org/x/time/rate"
 )
 
 type OrderResponse struct {
-	Order   string  `json:"order"`
-	Status  string  `json:"status"`
-	Accrual float64 `json:"accrual,omitempty"`
+	Order  string `json:"order"`
+	Status string `json:"status"`
+	// Accrual - начисление точным числом, без потерь при переводе в float64
+	Accrual json.Number `json:"accrual,omitempty"`
+	// RateLimit - ограничения, сообщённые сервисом в заголовках ответа
+	RateLimit *RateLimitConfig `json:"-"`
 }
 
 type AccrualService interface {
-	GetOrderAccrual(ctx context.Context, orderNumber string) (float64, string, error)
+	GetOrderAccrual(ctx context.Context, orderNumber string) (decimal.Decimal, string, error)
+}
+
+// BatchAccrualService - сервис начислений с пакетным запросом статусов заказов
+type BatchAccrualService interface {
+	AccrualService
+	// SupportsBatch - сервис поддерживает пакетный запрос
+	SupportsBatch() bool
+	// GetOrdersAccrual - расчёт начислений по списку заказов одним запросом
+	GetOrdersAccrual(ctx context.Context, orderNumbers []string) (map[string]OrderAccrual, error)
+}
+
+// OrderAccrual - результат расчёта начисления по заказу из пакетного запроса
+type OrderAccrual struct {
+	Accrual decimal.Decimal
+	Status  string
+	Err     error
+}
+
+// MaxOrdersBatch - наибольшее количество заказов в одном запросе POST /api/orders/status,
+// сервис начислений отклоняет запросы большего размера
+const MaxOrdersBatch = 100
+
+// OrdersStatusRequest - запрос статусов заказов POST /api/orders/status
+type OrdersStatusRequest struct {
+	Orders []string `json:"orders"`
+}
+
+// OrdersStatusResponse - ответ на пакетный запрос, незарегистрированные заказы в ответ не входят
+type OrdersStatusResponse struct {
+	Orders []OrderResponse `json:"orders"`
+	// RateLimit - ограничения, сообщённые сервисом в заголовках ответа
+	RateLimit *RateLimitConfig `json:"-"`
 }
 
+// RateLimitConfig - ограничения частоты запросов, сообщённые сервисом начислений
 type RateLimitConfig struct {
-	Limit int
-	Reset int64
+	Limit     int   // Количество запросов в минуту, 0 - не сообщено
+	Remaining int   // Оставшееся количество запросов в текущем окне, -1 - не сообщено
+	Reset     int64 // Секунд до начала следующего окна
+}
+
+// Rate - ограничение в запросах в секунду
+func (c *RateLimitConfig) Rate() rate.Limit {
+	return rate.Limit(float64(c.Limit) / 60)
 }
 
 var (
 	ErrServiceUnavailable = errors.New("accrual service unavailable")
 	ErrOrderNotRegistered = errors.New("order not registered")
+	ErrBatchNotSupported  = errors.New("batch requests not supported")
 )
 
 type RateLimitError struct {
 	RetryAfter time.Duration
+	Limit      *RateLimitConfig
 }
 
 func (e *RateLimitError) Error() string {
 	return "rate limit exceeded"
 }
 
-func NewRateLimitError(headers http.Header) *RateLimitError {
+func NewRateLimitError(headers http.Header, body []byte) *RateLimitError {
 	return &RateLimitError{
 		RetryAfter: ParseRetryAfter(headers),
+		Limit:      ParseRateLimit(headers, body),
 	}
 }
@@ -0,0 +1,232 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/config"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/sony/gobreaker"
+)
+
+// MaxBreakerEvents - количество хранимых последних событий смены состояния
+const MaxBreakerEvents = 100
+
+var ErrBreakerOpen = errors.New("accrual service circuit breaker is open")
+
+// BreakerRegistry - circuit breaker для каждого адреса сервиса начислений
+type BreakerRegistry struct {
+	config   config.AccrualConfig
+	mu       sync.Mutex
+	breakers map[string]*hostBreaker
+	events   []models.BreakerEvent
+}
+
+type hostBreaker struct {
+	breaker   *gobreaker.TwoStepCircuitBreaker
+	changedAt time.Time
+	requests  uint64
+	successes uint64
+	failures  uint64
+	rejected  uint64
+	trips     uint64
+}
+
+func NewBreakerRegistry(config config.AccrualConfig) *BreakerRegistry {
+	return &BreakerRegistry{
+		config:   config,
+		breakers: make(map[string]*hostBreaker),
+	}
+}
+
+// newBreaker - размыкание по подряд идущим ошибкам или по доле ошибок,
+// в полуоткрытом состоянии пропускается CircuitBreakerMaxRequests пробных запросов
+func (r *BreakerRegistry) newBreaker(host string) *gobreaker.TwoStepCircuitBreaker {
+	return gobreaker.NewTwoStepCircuitBreaker(gobreaker.Settings{
+		Name:        host,
+		MaxRequests: r.config.CircuitBreakerMaxRequests,
+		Interval:    r.config.CircuitBreakerInterval,
+		Timeout:     r.config.CircuitBreakerTimeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			if r.config.CircuitBreakerFailures > 0 && counts.ConsecutiveFailures >= uint32(r.config.CircuitBreakerFailures) {
+				return true
+			}
+			if r.config.CircuitBreakerFailureRatio > 0 && counts.Requests >= r.config.CircuitBreakerMinRequests {
+				return float64(counts.TotalFailures)/float64(counts.Requests) >= r.config.CircuitBreakerFailureRatio
+			}
+			return false
+		},
+		OnStateChange: r.onStateChange,
+	})
+}
+
+// onStateChange - журналирование смены состояния и запись события в последние MaxBreakerEvents
+func (r *BreakerRegistry) onStateChange(host string, from, to gobreaker.State) {
+	event := models.BreakerEvent{Host: host, From: from.String(), To: to.String(), At: time.Now()}
+	logger.Warn("Circuit breaker", host, "state changed:", event.From, "→", event.To)
+
+	r.mu.Lock()
+	if b, ok := r.breakers[host]; ok {
+		b.changedAt = event.At
+		if to == gobreaker.StateOpen {
+			b.trips++
+		}
+	}
+	r.events = append(r.events, event)
+	if len(r.events) > MaxBreakerEvents {
+		r.events = r.events[len(r.events)-MaxBreakerEvents:]
+	}
+	r.mu.Unlock()
+}
+
+// get - счётчики адреса и его текущий breaker. Reset заменяет breaker под блокировкой,
+// поэтому указатель читается здесь же, а не после её снятия
+func (r *BreakerRegistry) get(host string) (*hostBreaker, *gobreaker.TwoStepCircuitBreaker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.breakers[host]
+	if !ok {
+		b = &hostBreaker{changedAt: time.Now()}
+		r.breakers[host] = b
+		b.breaker = r.newBreaker(host)
+	}
+	return b, b.breaker
+}
+
+// Allow - разрешение запроса к сервису, done сообщает результат запроса
+func (r *BreakerRegistry) Allow(host string) (func(success bool), error) {
+	b, breaker := r.get(host)
+	done, err := breaker.Allow()
+	if err != nil {
+		r.mu.Lock()
+		b.rejected++
+		r.mu.Unlock()
+		return nil, err
+	}
+	r.mu.Lock()
+	b.requests++
+	r.mu.Unlock()
+	return func(success bool) {
+		done(success)
+		r.mu.Lock()
+		if success {
+			b.successes++
+		} else {
+			b.failures++
+		}
+		r.mu.Unlock()
+	}, nil
+}
+
+// Reset - принудительный перевод всех breaker в закрытое состояние, счётчики сохраняются
+func (r *BreakerRegistry) Reset() {
+	states := r.states()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for host, b := range r.breakers {
+		if state, ok := states[host]; ok && state != gobreaker.StateClosed {
+			r.events = append(r.events, models.BreakerEvent{Host: host, From: state.String(), To: gobreaker.StateClosed.String(), At: now})
+			b.changedAt = now
+		}
+		b.breaker = r.newBreaker(host)
+	}
+	if len(r.events) > MaxBreakerEvents {
+		r.events = r.events[len(r.events)-MaxBreakerEvents:]
+	}
+}
+
+// states - текущие состояния breaker. Запрос состояния может перевести breaker
+// из открытого в полуоткрытое и вызвать onStateChange, поэтому выполняется без блокировки.
+func (r *BreakerRegistry) states() map[string]gobreaker.State {
+	r.mu.Lock()
+	breakers := make(map[string]*gobreaker.TwoStepCircuitBreaker, len(r.breakers))
+	for host, b := range r.breakers {
+		breakers[host] = b.breaker
+	}
+	r.mu.Unlock()
+
+	states := make(map[string]gobreaker.State, len(breakers))
+	for host, breaker := range breakers {
+		states[host] = breaker.State()
+	}
+	return states
+}
+
+// State - обобщённое состояние: open, если разомкнут хотя бы один breaker
+func (r *BreakerRegistry) State() string {
+	state := gobreaker.StateClosed
+	for _, hostState := range r.states() {
+		switch hostState {
+		case gobreaker.StateOpen:
+			return hostState.String()
+		case gobreaker.StateHalfOpen:
+			state = gobreaker.StateHalfOpen
+		}
+	}
+	return state.String()
+}
+
+// Status - состояние и счётчики всех breaker с последними событиями
+func (r *BreakerRegistry) Status() models.BreakersStatus {
+	states := r.states()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status := models.BreakersStatus{
+		Breakers: make([]models.BreakerStatus, 0, len(r.breakers)),
+		Events:   append([]models.BreakerEvent{}, r.events...),
+	}
+	for host, b := range r.breakers {
+		status.Breakers = append(status.Breakers, models.BreakerStatus{
+			Host:      host,
+			State:     states[host].String(),
+			ChangedAt: b.changedAt,
+			Requests:  b.requests,
+			Successes: b.successes,
+			Failures:  b.failures,
+			Rejected:  b.rejected,
+			Trips:     b.trips,
+		})
+	}
+	sort.Slice(status.Breakers, func(i, j int) bool { return status.Breakers[i].Host < status.Breakers[j].Host })
+	return status
+}
+
+// BreakerClient - circuit breaker на каждый адрес сервиса начислений.
+// Ошибкой считаются сетевые ошибки, таймауты и ответы 5xx.
+type BreakerClient struct {
+	Client   HTTPClient
+	Registry *BreakerRegistry
+}
+
+func NewBreakerClient(client HTTPClient, registry *BreakerRegistry) *BreakerClient {
+	return &BreakerClient{Client: client, Registry: registry}
+}
+
+func (c *BreakerClient) Do(req *http.Request) (*http.Response, error) {
+	done, err := c.Registry.Allow(req.URL.Host)
+	if err != nil {
+		return nil, NewAccrualError(ErrorUnavailable, fmt.Errorf("%w: %s (%v)", ErrBreakerOpen, req.URL.Host, err))
+	}
+	resp, err := c.Client.Do(req)
+	switch {
+	case err != nil:
+		// отмена запроса вызывающим не говорит о состоянии сервиса
+		done(errors.Is(err, context.Canceled))
+	default:
+		done(resp.StatusCode < http.StatusInternalServerError)
+	}
+	return resp, err
+}
+
+func (c *BreakerClient) Close() error {
+	return Close(c.Client)
+}
@@ -0,0 +1,210 @@
+package client
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/config"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/sony/gobreaker"
+)
+
+const (
+	testHost  = "accrual-1:8080"
+	otherHost = "accrual-2:8080"
+)
+
+func testBreakerConfig() config.AccrualConfig {
+	return config.AccrualConfig{
+		CircuitBreakerTimeout:     50 * time.Millisecond,
+		CircuitBreakerFailures:    2,
+		CircuitBreakerMaxRequests: 1,
+		CircuitBreakerInterval:    time.Minute,
+	}
+}
+
+// fail - запрос к адресу, завершившийся ошибкой
+func fail(t *testing.T, r *BreakerRegistry, host string) {
+	t.Helper()
+	done, err := r.Allow(host)
+	if err != nil {
+		t.Fatalf("Expected request to be allowed, got: '%v'", err)
+	}
+	done(false)
+}
+
+func hostState(r *BreakerRegistry, host string) string {
+	for _, b := range r.Status().Breakers {
+		if b.Host == host {
+			return b.State
+		}
+	}
+	return ""
+}
+
+func TestBreakerRegistry(t *testing.T) {
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+	defer logger.Sync()
+
+	testCases := []struct {
+		Name          string
+		Run           func(t *testing.T, r *BreakerRegistry)
+		ExpectedState map[string]string
+	}{
+		{
+			Name: "Success. Opens after consecutive failures #1",
+			Run: func(t *testing.T, r *BreakerRegistry) {
+				fail(t, r, testHost)
+				fail(t, r, testHost)
+				if _, err := r.Allow(testHost); err != gobreaker.ErrOpenState {
+					t.Errorf("Expected error: '%v', got: '%v'", gobreaker.ErrOpenState, err)
+				}
+			},
+			ExpectedState: map[string]string{testHost: gobreaker.StateOpen.String()},
+		},
+		{
+			Name: "Success. Other host stays closed #2",
+			Run: func(t *testing.T, r *BreakerRegistry) {
+				fail(t, r, testHost)
+				fail(t, r, testHost)
+				done, err := r.Allow(otherHost)
+				if err != nil {
+					t.Fatalf("Expected no error, got: '%v'", err)
+				}
+				done(true)
+			},
+			ExpectedState: map[string]string{
+				testHost:  gobreaker.StateOpen.String(),
+				otherHost: gobreaker.StateClosed.String(),
+			},
+		},
+		{
+			Name: "Success. Half-open after timeout #3",
+			Run: func(t *testing.T, r *BreakerRegistry) {
+				fail(t, r, testHost)
+				fail(t, r, testHost)
+				time.Sleep(60 * time.Millisecond)
+				if state := r.State(); state != gobreaker.StateHalfOpen.String() {
+					t.Errorf("Expected state: '%v', got: '%v'", gobreaker.StateHalfOpen, state)
+				}
+				done, err := r.Allow(testHost)
+				if err != nil {
+					t.Fatalf("Expected probe request to be allowed, got: '%v'", err)
+				}
+				// пробный запрос в полуоткрытом состоянии один
+				if _, err := r.Allow(testHost); err != gobreaker.ErrTooManyRequests {
+					t.Errorf("Expected error: '%v', got: '%v'", gobreaker.ErrTooManyRequests, err)
+				}
+				done(true)
+			},
+			ExpectedState: map[string]string{testHost: gobreaker.StateClosed.String()},
+		},
+		{
+			Name: "Success. Failed probe opens again #4",
+			Run: func(t *testing.T, r *BreakerRegistry) {
+				fail(t, r, testHost)
+				fail(t, r, testHost)
+				time.Sleep(60 * time.Millisecond)
+				fail(t, r, testHost)
+			},
+			ExpectedState: map[string]string{testHost: gobreaker.StateOpen.String()},
+		},
+		{
+			Name: "Success. Reset closes every host #5",
+			Run: func(t *testing.T, r *BreakerRegistry) {
+				fail(t, r, testHost)
+				fail(t, r, testHost)
+				fail(t, r, otherHost)
+				fail(t, r, otherHost)
+				r.Reset()
+				done, err := r.Allow(testHost)
+				if err != nil {
+					t.Fatalf("Expected no error after reset, got: '%v'", err)
+				}
+				done(true)
+			},
+			ExpectedState: map[string]string{
+				testHost:  gobreaker.StateClosed.String(),
+				otherHost: gobreaker.StateClosed.String(),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := NewBreakerRegistry(testBreakerConfig())
+			tc.Run(t, r)
+			for host, expected := range tc.ExpectedState {
+				if state := hostState(r, host); state != expected {
+					t.Errorf("Expected %s state: '%v', got: '%v'", host, expected, state)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerRegistry_Counters(t *testing.T) {
+	r := NewBreakerRegistry(testBreakerConfig())
+	done, err := r.Allow(testHost)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	done(true)
+	fail(t, r, testHost)
+	fail(t, r, testHost)
+	_, _ = r.Allow(testHost)
+	r.Reset()
+
+	status := r.Status()
+	if len(status.Breakers) != 1 {
+		t.Fatalf("Expected 1 breaker, got: %d", len(status.Breakers))
+	}
+	b := status.Breakers[0]
+	if b.Requests != 3 || b.Successes != 1 || b.Failures != 2 || b.Rejected != 1 || b.Trips != 1 {
+		t.Errorf("Unexpected counters: %+v", b)
+	}
+	// closed → open и ручной сброс open → closed
+	if len(status.Events) != 2 {
+		t.Errorf("Expected 2 events, got: %+v", status.Events)
+	}
+}
+
+// TestBreakerRegistry_ConcurrentReset - запускается с -race: Reset заменяет breaker
+// одновременно с запросами
+func TestBreakerRegistry_ConcurrentReset(t *testing.T) {
+	r := NewBreakerRegistry(testBreakerConfig())
+	stop := make(chan struct{})
+	reset := make(chan struct{})
+	go func() {
+		defer close(reset)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				r.Reset()
+				_ = r.State()
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				if done, err := r.Allow(testHost); err == nil {
+					done(i%2 == 0)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(stop)
+	<-reset
+}
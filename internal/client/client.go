@@ -1,8 +1,12 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 )
 
@@ -10,6 +14,15 @@ type HTTPClient interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
+// Close - освобождение ресурсов клиента, например файла записи обмена с сервисом,
+// если клиент их удерживает
+func Close(client HTTPClient) error {
+	if closer, ok := client.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
 type Client struct {
 	baseURL    string
 	httpClient HTTPClient
@@ -22,6 +35,11 @@ func NewClient(baseURL string, client HTTPClient) *Client {
 	}
 }
 
+// Close - освобождение ресурсов HTTP клиента
+func (c *Client) Close() error {
+	return Close(c.httpClient)
+}
+
 func (c *Client) GetOrder(ctx context.Context, orderNumber string) (*OrderResponse, error) {
 	url := c.baseURL + "/api/orders/" + orderNumber
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
@@ -31,7 +49,7 @@ func (c *Client) GetOrder(ctx context.Context, orderNumber string) (*OrderRespon
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, transportError(err)
 	}
 	defer resp.Body.Close()
 
@@ -41,8 +59,46 @@ func (c *Client) GetOrder(ctx context.Context, orderNumber string) (*OrderRespon
 
 	var result OrderResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, NewAccrualError(ErrorMalformed, fmt.Errorf("%w: %v", ErrMalformedResponse, err))
+	}
+	result.RateLimit = ParseRateLimit(resp.Header, nil)
+
+	return &result, nil
+}
+
+// GetOrders - пакетный запрос статусов заказов
+func (c *Client) GetOrders(ctx context.Context, orderNumbers []string) (*OrdersStatusResponse, error) {
+	body, err := json.Marshal(OrdersStatusRequest{Orders: orderNumbers})
+	if err != nil {
+		return nil, err
+	}
+	url := c.baseURL + "/api/orders/status"
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
 		return nil, err
 	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, transportError(err)
+	}
+	defer resp.Body.Close()
+
+	var result OrdersStatusResponse
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return nil, NewAccrualError(ErrorMalformed, fmt.Errorf("%w: %v", ErrMalformedResponse, err))
+		}
+	case http.StatusNoContent:
+		// ни один заказ не зарегистрирован
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return nil, ErrBatchNotSupported
+	default:
+		return nil, HandleErrorResponse(resp)
+	}
+	result.RateLimit = ParseRateLimit(resp.Header, nil)
 
 	return &result, nil
 }
@@ -50,10 +106,22 @@ func (c *Client) GetOrder(ctx context.Context, orderNumber string) (*OrderRespon
 func HandleErrorResponse(resp *http.Response) error {
 	switch resp.StatusCode {
 	case http.StatusTooManyRequests:
-		return NewRateLimitError(resp.Header)
+		// тело ответа содержит ограничение: "No more than N requests per minute allowed"
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return NewRateLimitError(resp.Header, body)
 	case http.StatusNoContent:
 		return ErrOrderNotRegistered
 	default:
-		return ErrServiceUnavailable
+		return &AccrualError{Class: ErrorUnavailable, StatusCode: resp.StatusCode, Err: ErrServiceUnavailable}
+	}
+}
+
+// transportError - ошибка без ответа сервиса считается временной, если обёртки клиента
+// (например circuit breaker) не указали класс
+func transportError(err error) error {
+	var accrualErr *AccrualError
+	if errors.As(err, &accrualErr) {
+		return err
 	}
+	return NewAccrualError(ErrorTransient, err)
 }
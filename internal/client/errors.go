@@ -0,0 +1,72 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorClass - класс ошибки запроса к сервису начислений
+type ErrorClass string
+
+// Классы ошибок сервиса начислений
+const (
+	ErrorTransient     ErrorClass = "transient"      // Сетевая ошибка или таймаут
+	ErrorUnavailable   ErrorClass = "unavailable"    // Ответ сервиса 5xx или неожиданный код
+	ErrorRateLimited   ErrorClass = "rate_limited"   // Ответ 429
+	ErrorNotRegistered ErrorClass = "not_registered" // Ответ 204, заказ не зарегистрирован
+	ErrorMalformed     ErrorClass = "malformed"      // Тело ответа не разбирается
+	ErrorUnknownStatus ErrorClass = "unknown_status" // Неизвестный статус расчёта
+)
+
+var (
+	ErrMalformedResponse = errors.New("malformed accrual response")
+	ErrUnknownStatus     = errors.New("undefined status request")
+)
+
+// AccrualError - ошибка запроса к сервису начислений с указанием класса
+type AccrualError struct {
+	Class      ErrorClass
+	StatusCode int // Код ответа сервиса, 0 - ответ не получен
+	Err        error
+}
+
+func NewAccrualError(class ErrorClass, err error) *AccrualError {
+	return &AccrualError{Class: class, Err: err}
+}
+
+func (e *AccrualError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s: status %d", e.Err, e.StatusCode)
+	}
+	return e.Err.Error()
+}
+
+func (e *AccrualError) Unwrap() error {
+	return e.Err
+}
+
+// Classify - класс ошибки сервиса начислений, неизвестные ошибки считаются временными
+func Classify(err error) ErrorClass {
+	var (
+		accrualErr   *AccrualError
+		rateLimitErr *RateLimitError
+	)
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &accrualErr):
+		return accrualErr.Class
+	case errors.As(err, &rateLimitErr):
+		return ErrorRateLimited
+	case errors.Is(err, ErrOrderNotRegistered):
+		return ErrorNotRegistered
+	case errors.Is(err, ErrServiceUnavailable):
+		return ErrorUnavailable
+	case errors.Is(err, ErrMalformedResponse):
+		return ErrorMalformed
+	case errors.Is(err, ErrUnknownStatus):
+		return ErrorUnknownStatus
+	default:
+		return ErrorTransient
+	}
+}
@@ -3,6 +3,7 @@ package client
 import (
 	"context"
 	"net/http"
+	"regexp"
 	"strconv"
 	"sync"
 	"time"
@@ -10,14 +11,28 @@ import (
 	"golang.org/x/time/rate"
 )
 
+// Limiter - представляет интерфейс ограничения частоты запросов к сервису начислений
+type Limiter interface {
+	Wait(ctx context.Context) error
+	Limit() rate.Limit
+	Update(limit rate.Limit, burst int)
+	BlockFor(duration time.Duration)
+}
+
+// RateLimiter - ограничение частоты запросов в пределах одного экземпляра сервиса
 type RateLimiter struct {
 	limiter *rate.Limiter
 	mu      sync.Mutex
+	// learned - ограничение, полученное от сервиса начислений, восстанавливается после блокировки
+	learned rate.Limit
+	// blockedUntil - время окончания блокировки по Retry-After
+	blockedUntil time.Time
 }
 
 func NewRateLimiter() *RateLimiter {
 	return &RateLimiter{
 		limiter: rate.NewLimiter(rate.Inf, 1),
+		learned: rate.Inf,
 	}
 }
 
@@ -25,22 +40,40 @@ func (rl *RateLimiter) Wait(ctx context.Context) error {
 	return rl.limiter.Wait(ctx)
 }
 
+// Limit - текущее ограничение запросов в секунду
+func (rl *RateLimiter) Limit() rate.Limit {
+	return rl.limiter.Limit()
+}
+
+// Update - установка ограничения, во время блокировки применяется после её окончания
 func (rl *RateLimiter) Update(limit rate.Limit, burst int) {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
-	rl.limiter.SetLimit(limit)
+	rl.learned = limit
 	rl.limiter.SetBurst(burst)
+	if time.Now().Before(rl.blockedUntil) {
+		return
+	}
+	rl.limiter.SetLimit(limit)
 }
 
 func (rl *RateLimiter) BlockFor(duration time.Duration) {
 	rl.mu.Lock()
+	until := time.Now().Add(duration)
+	if until.After(rl.blockedUntil) {
+		rl.blockedUntil = until
+	}
 	rl.limiter.SetLimit(0)
 	rl.mu.Unlock()
 
 	time.AfterFunc(duration, func() {
 		rl.mu.Lock()
-		rl.limiter.SetLimit(rate.Inf)
-		rl.mu.Unlock()
+		defer rl.mu.Unlock()
+		// блокировку могли продлить повторным 429
+		if time.Now().Before(rl.blockedUntil) {
+			return
+		}
+		rl.limiter.SetLimit(rl.learned)
 	})
 }
 
@@ -60,3 +93,36 @@ func ParseRetryAfter(headers http.Header) time.Duration {
 
 	return time.Minute // fallback
 }
+
+// unixTimeThreshold - значения X-RateLimit-Reset больше порога считаются unix-временем
+const unixTimeThreshold = 1_000_000_000
+
+// rateLimitMessage - формат ответа сервиса начислений при превышении количества запросов
+var rateLimitMessage = regexp.MustCompile(`No more than (\d+) requests per minute allowed`)
+
+// ParseRateLimit - разбор ограничений сервиса начислений из заголовков X-RateLimit-* и тела ответа 429.
+// Возвращает nil, если сервис не сообщил ограничение.
+func ParseRateLimit(headers http.Header, body []byte) *RateLimitConfig {
+	config := RateLimitConfig{Remaining: -1}
+
+	if limit, err := strconv.Atoi(headers.Get("X-RateLimit-Limit")); err == nil && limit > 0 {
+		config.Limit = limit
+	} else if matches := rateLimitMessage.FindSubmatch(body); matches != nil {
+		config.Limit, _ = strconv.Atoi(string(matches[1]))
+	}
+	if remaining, err := strconv.Atoi(headers.Get("X-RateLimit-Remaining")); err == nil {
+		config.Remaining = remaining
+	}
+	if reset, err := strconv.ParseInt(headers.Get("X-RateLimit-Reset"), 10, 64); err == nil && reset > 0 {
+		// значение может быть как количеством секунд, так и unix-временем окончания окна
+		if reset > unixTimeThreshold {
+			reset = int64(time.Until(time.Unix(reset, 0)).Seconds())
+		}
+		config.Reset = reset
+	}
+
+	if config.Limit == 0 && config.Remaining < 0 {
+		return nil
+	}
+	return &config
+}
@@ -0,0 +1,116 @@
+package client
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	resetAt := time.Now().Add(30 * time.Second).Unix()
+
+	testCases := []struct {
+		Name           string
+		Headers        map[string]string
+		Body           string
+		ExpectedConfig *RateLimitConfig
+		ResetTolerance int64 // допустимое отклонение Reset, заданного unix-временем
+	}{
+		{
+			Name:           "Success. 429 body #1",
+			Body:           "No more than 60 requests per minute allowed",
+			ExpectedConfig: &RateLimitConfig{Limit: 60, Remaining: -1},
+		},
+		{
+			Name: "Success. All headers #2",
+			Headers: map[string]string{
+				"X-RateLimit-Limit":     "100",
+				"X-RateLimit-Remaining": "42",
+				"X-RateLimit-Reset":     "15",
+			},
+			ExpectedConfig: &RateLimitConfig{Limit: 100, Remaining: 42, Reset: 15},
+		},
+		{
+			Name: "Success. Header limit preferred over body #3",
+			Headers: map[string]string{
+				"X-RateLimit-Limit": "100",
+			},
+			Body:           "No more than 60 requests per minute allowed",
+			ExpectedConfig: &RateLimitConfig{Limit: 100, Remaining: -1},
+		},
+		{
+			Name: "Success. Reset as unix time #4",
+			Headers: map[string]string{
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     strconv.FormatInt(resetAt, 10),
+			},
+			ExpectedConfig: &RateLimitConfig{Remaining: 0, Reset: 30},
+			ResetTolerance: 2,
+		},
+		{
+			Name: "Success. Remaining without limit #5",
+			Headers: map[string]string{
+				"X-RateLimit-Remaining": "0",
+			},
+			ExpectedConfig: &RateLimitConfig{Remaining: 0},
+		},
+		{
+			Name: "Success. Invalid header limit falls back to body #6",
+			Headers: map[string]string{
+				"X-RateLimit-Limit": "many",
+			},
+			Body:           "No more than 10 requests per minute allowed",
+			ExpectedConfig: &RateLimitConfig{Limit: 10, Remaining: -1},
+		},
+		{
+			Name:           "Error. No limit reported #7",
+			ExpectedConfig: nil,
+		},
+		{
+			Name:           "Error. Unknown body format #8",
+			Body:           "Too Many Requests",
+			ExpectedConfig: nil,
+		},
+		{
+			Name: "Error. Invalid headers #9",
+			Headers: map[string]string{
+				"X-RateLimit-Limit":     "0",
+				"X-RateLimit-Remaining": "none",
+				"X-RateLimit-Reset":     "-5",
+			},
+			ExpectedConfig: nil,
+		},
+		{
+			Name: "Error. Invalid reset ignored #10",
+			Headers: map[string]string{
+				"X-RateLimit-Limit": "60",
+				"X-RateLimit-Reset": "soon",
+			},
+			ExpectedConfig: &RateLimitConfig{Limit: 60, Remaining: -1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			headers := make(http.Header)
+			for name, value := range tc.Headers {
+				headers.Set(name, value)
+			}
+
+			config := ParseRateLimit(headers, []byte(tc.Body))
+
+			if config != nil && tc.ExpectedConfig != nil && tc.ResetTolerance > 0 {
+				if diff := config.Reset - tc.ExpectedConfig.Reset; diff < -tc.ResetTolerance || diff > tc.ResetTolerance {
+					t.Errorf("Expected reset: %d±%d, got: %d", tc.ExpectedConfig.Reset, tc.ResetTolerance, config.Reset)
+				}
+				config.Reset = tc.ExpectedConfig.Reset
+			}
+			if diff := cmp.Diff(tc.ExpectedConfig, config); diff != "" {
+				t.Errorf("Rate limit mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
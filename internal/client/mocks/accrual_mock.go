@@ -13,6 +13,8 @@ import (
 	context "context"
 	reflect "reflect"
 
+	client "github.com/denmor86/ya-gophermart/internal/client"
+	decimal "github.com/shopspring/decimal"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -41,10 +43,10 @@ func (m *MockAccrualService) EXPECT() *MockAccrualServiceMockRecorder {
 }
 
 // GetOrderAccrual mocks base method.
-func (m *MockAccrualService) GetOrderAccrual(ctx context.Context, orderNumber string) (float64, string, error) {
+func (m *MockAccrualService) GetOrderAccrual(ctx context.Context, orderNumber string) (decimal.Decimal, string, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetOrderAccrual", ctx, orderNumber)
-	ret0, _ := ret[0].(float64)
+	ret0, _ := ret[0].(decimal.Decimal)
 	ret1, _ := ret[1].(string)
 	ret2, _ := ret[2].(error)
 	return ret0, ret1, ret2
@@ -55,3 +57,72 @@ func (mr *MockAccrualServiceMockRecorder) GetOrderAccrual(ctx, orderNumber any)
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderAccrual", reflect.TypeOf((*MockAccrualService)(nil).GetOrderAccrual), ctx, orderNumber)
 }
+
+// MockBatchAccrualService is a mock of BatchAccrualService interface.
+type MockBatchAccrualService struct {
+	ctrl     *gomock.Controller
+	recorder *MockBatchAccrualServiceMockRecorder
+	isgomock struct{}
+}
+
+// MockBatchAccrualServiceMockRecorder is the mock recorder for MockBatchAccrualService.
+type MockBatchAccrualServiceMockRecorder struct {
+	mock *MockBatchAccrualService
+}
+
+// NewMockBatchAccrualService creates a new mock instance.
+func NewMockBatchAccrualService(ctrl *gomock.Controller) *MockBatchAccrualService {
+	mock := &MockBatchAccrualService{ctrl: ctrl}
+	mock.recorder = &MockBatchAccrualServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBatchAccrualService) EXPECT() *MockBatchAccrualServiceMockRecorder {
+	return m.recorder
+}
+
+// GetOrderAccrual mocks base method.
+func (m *MockBatchAccrualService) GetOrderAccrual(ctx context.Context, orderNumber string) (decimal.Decimal, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderAccrual", ctx, orderNumber)
+	ret0, _ := ret[0].(decimal.Decimal)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetOrderAccrual indicates an expected call of GetOrderAccrual.
+func (mr *MockBatchAccrualServiceMockRecorder) GetOrderAccrual(ctx, orderNumber any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderAccrual", reflect.TypeOf((*MockBatchAccrualService)(nil).GetOrderAccrual), ctx, orderNumber)
+}
+
+// GetOrdersAccrual mocks base method.
+func (m *MockBatchAccrualService) GetOrdersAccrual(ctx context.Context, orderNumbers []string) (map[string]client.OrderAccrual, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrdersAccrual", ctx, orderNumbers)
+	ret0, _ := ret[0].(map[string]client.OrderAccrual)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrdersAccrual indicates an expected call of GetOrdersAccrual.
+func (mr *MockBatchAccrualServiceMockRecorder) GetOrdersAccrual(ctx, orderNumbers any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersAccrual", reflect.TypeOf((*MockBatchAccrualService)(nil).GetOrdersAccrual), ctx, orderNumbers)
+}
+
+// SupportsBatch mocks base method.
+func (m *MockBatchAccrualService) SupportsBatch() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SupportsBatch")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// SupportsBatch indicates an expected call of SupportsBatch.
+func (mr *MockBatchAccrualServiceMockRecorder) SupportsBatch() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SupportsBatch", reflect.TypeOf((*MockBatchAccrualService)(nil).SupportsBatch))
+}
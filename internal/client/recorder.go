@@ -0,0 +1,186 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+var ErrNoInteraction = errors.New("no recorded interaction for request")
+
+// Interaction - записанная пара запрос/ответ сервиса начислений
+type Interaction struct {
+	Method       string      `json:"method"`
+	URI          string      `json:"uri"`
+	RequestBody  string      `json:"request_body,omitempty"`
+	Status       int         `json:"status"`
+	Header       http.Header `json:"header,omitempty"`
+	ResponseBody string      `json:"response_body,omitempty"`
+}
+
+func (i *Interaction) key() string {
+	return i.Method + " " + i.URI + " " + i.RequestBody
+}
+
+// RecordingClient - запись запросов и ответов в файл, по одной паре в строке JSON
+type RecordingClient struct {
+	Client HTTPClient
+	mu     sync.Mutex
+	file   *os.File
+}
+
+func NewRecordingClient(client HTTPClient, path string) (*RecordingClient, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open record file: %w", err)
+	}
+	return &RecordingClient{Client: client, file: file}, nil
+}
+
+func (c *RecordingClient) Do(req *http.Request) (*http.Response, error) {
+	var requestBody []byte
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		requestBody = body
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		// сетевые ошибки не записываются, воспроизводятся только ответы сервиса
+		return resp, err
+	}
+	responseBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(responseBody))
+
+	interaction := Interaction{
+		Method:       req.Method,
+		URI:          req.URL.RequestURI(),
+		RequestBody:  string(requestBody),
+		Status:       resp.StatusCode,
+		Header:       resp.Header,
+		ResponseBody: string(responseBody),
+	}
+	if err := c.write(&interaction); err != nil {
+		return nil, fmt.Errorf("record interaction: %w", err)
+	}
+	return resp, nil
+}
+
+func (c *RecordingClient) write(interaction *Interaction) error {
+	line, err := json.Marshal(interaction)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.file.Write(append(line, '\n'))
+	return err
+}
+
+// Close - закрытие файла записи, вызывается при остановке сервиса
+func (c *RecordingClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.file.Close()
+}
+
+// ReplayClient - воспроизведение записанных ответов без обращения к сервису.
+// Ответы на одинаковые запросы выдаются в порядке записи, последний повторяется.
+type ReplayClient struct {
+	mu           sync.Mutex
+	interactions map[string][]Interaction
+	positions    map[string]int
+}
+
+func NewReplayClient(interactions []Interaction) *ReplayClient {
+	c := &ReplayClient{
+		interactions: make(map[string][]Interaction),
+		positions:    make(map[string]int),
+	}
+	for _, interaction := range interactions {
+		key := interaction.key()
+		c.interactions[key] = append(c.interactions[key], interaction)
+	}
+	return c
+}
+
+// LoadInteractions - чтение записанных пар запрос/ответ из файла
+func LoadInteractions(path string) ([]Interaction, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open replay file: %w", err)
+	}
+	defer file.Close()
+
+	var interactions []Interaction
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var interaction Interaction
+		if err := json.Unmarshal(scanner.Bytes(), &interaction); err != nil {
+			return nil, fmt.Errorf("parse replay file line %d: %w", line, err)
+		}
+		interactions = append(interactions, interaction)
+	}
+	return interactions, scanner.Err()
+}
+
+func (c *ReplayClient) Do(req *http.Request) (*http.Response, error) {
+	interaction := Interaction{Method: req.Method, URI: req.URL.RequestURI()}
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		interaction.RequestBody = string(body)
+	}
+	key := interaction.key()
+
+	c.mu.Lock()
+	recorded := c.interactions[key]
+	position := c.positions[key]
+	if position < len(recorded)-1 {
+		c.positions[key]++
+	}
+	c.mu.Unlock()
+
+	if len(recorded) == 0 {
+		return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, req.Method, interaction.URI)
+	}
+	reply := recorded[position]
+	header := reply.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", reply.Status, http.StatusText(reply.Status)),
+		StatusCode:    reply.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(reply.ResponseBody)),
+		ContentLength: int64(len(reply.ResponseBody)),
+		Request:       req,
+	}, nil
+}
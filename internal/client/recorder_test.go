@@ -0,0 +1,118 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+type exchange struct {
+	Method string
+	Path   string
+	Body   string
+	Status int
+	Reply  string
+}
+
+func doExchange(t *testing.T, client HTTPClient, baseURL string, e exchange) exchange {
+	t.Helper()
+	var body io.Reader
+	if e.Body != "" {
+		body = strings.NewReader(e.Body)
+	}
+	req, err := http.NewRequest(e.Method, baseURL+e.Path, body)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	defer resp.Body.Close()
+	reply, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	e.Status = resp.StatusCode
+	e.Reply = string(reply)
+	return e
+}
+
+// Записанный обмен воспроизводится без сервиса: повторы одного запроса в порядке записи,
+// после последнего записанного ответа он повторяется
+func TestRecordingClient_Replay(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		switch {
+		case r.Method == http.MethodPost:
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(bytes.ToUpper(body))
+		case n == 1:
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte("No more than 60 requests per minute allowed"))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"order":"123456","status":"PROCESSED","accrual":10}`))
+		}
+	}))
+
+	path := filepath.Join(t.TempDir(), "accrual.jsonl")
+	cfg := testHTTPClientConfig()
+	cfg.Retries = 0
+	cfg.Record = path
+	// файл записи закрывается через обёртку авторизации
+	cfg.Token = "token"
+	recorder, err := NewHTTPClient(cfg, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+
+	requests := []exchange{
+		{Method: http.MethodGet, Path: "/api/orders/123456"},
+		{Method: http.MethodGet, Path: "/api/orders/123456"},
+		{Method: http.MethodPost, Path: "/api/orders/status", Body: `{"orders":["123456"]}`},
+	}
+	var recorded []exchange
+	for _, e := range requests {
+		recorded = append(recorded, doExchange(t, recorder, server.URL, e))
+	}
+	server.Close()
+	if err := Close(recorder); err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+
+	cfg.Record = ""
+	cfg.Replay = path
+	replay, err := NewHTTPClient(cfg, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	for i, e := range requests {
+		if got := doExchange(t, replay, server.URL, e); got != recorded[i] {
+			t.Errorf("Expected replayed exchange: '%+v', got: '%+v'", recorded[i], got)
+		}
+	}
+	// последний записанный ответ на запрос повторяется
+	if got := doExchange(t, replay, server.URL, requests[0]); got != recorded[1] {
+		t.Errorf("Expected replayed exchange: '%+v', got: '%+v'", recorded[1], got)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/api/orders/999", nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	if _, err := replay.Do(req); !errors.Is(err, ErrNoInteraction) {
+		t.Errorf("Expected error: '%v', got: '%v'", ErrNoInteraction, err)
+	}
+	if calls.Load() != int32(len(requests)) {
+		t.Errorf("Expected %d requests to service, got: %d", len(requests), calls.Load())
+	}
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// LimiterBackendTimeout - таймаут обращения к общему хранилищу бюджета запросов
+const LimiterBackendTimeout = 2 * time.Second
+
+// LimitCacheTTL - время, в течение которого ограничение из общего хранилища не запрашивается повторно
+const LimitCacheTTL = 5 * time.Second
+
+// LimiterBackend - общее для реплик хранилище бюджета запросов
+type LimiterBackend interface {
+	Reserve(ctx context.Context) (time.Duration, error)
+	GetLimit(ctx context.Context) (rate.Limit, error)
+	SetLimit(ctx context.Context, limit rate.Limit, burst int) error
+	Block(ctx context.Context, duration time.Duration) error
+}
+
+// SharedRateLimiter - ограничение частоты запросов, общее для всех реплик сервиса.
+// Бюджет запросов и блокировка по Retry-After хранятся во внешнем хранилище,
+// ограничение кэшируется на LimitCacheTTL.
+type SharedRateLimiter struct {
+	Backend LimiterBackend
+
+	mu        sync.Mutex
+	limit     rate.Limit
+	known     bool
+	fetchedAt time.Time
+	now       func() time.Time
+}
+
+func NewSharedRateLimiter(backend LimiterBackend) *SharedRateLimiter {
+	return &SharedRateLimiter{Backend: backend, limit: rate.Inf, now: time.Now}
+}
+
+// Wait - ожидание свободного токена в общем бюджете
+func (rl *SharedRateLimiter) Wait(ctx context.Context) error {
+	for {
+		wait, err := rl.Backend.Reserve(ctx)
+		if err != nil {
+			return err
+		}
+		if wait <= 0 {
+			return nil
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Limit - ограничение из общего хранилища. При ошибке хранилища возвращается последнее
+// известное значение, следующий запрос к хранилищу выполняется через LimitCacheTTL
+func (rl *SharedRateLimiter) Limit() rate.Limit {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	now := rl.now()
+	if rl.known && now.Sub(rl.fetchedAt) < LimitCacheTTL {
+		return rl.limit
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), LimiterBackendTimeout)
+	defer cancel()
+	limit, err := rl.Backend.GetLimit(ctx)
+	rl.fetchedAt = now
+	rl.known = true
+	if err != nil {
+		logger.Warn("Failed to get shared rate limit, using last known:", zap.Error(err))
+		return rl.limit
+	}
+	rl.limit = limit
+	return limit
+}
+
+func (rl *SharedRateLimiter) Update(limit rate.Limit, burst int) {
+	ctx, cancel := context.WithTimeout(context.Background(), LimiterBackendTimeout)
+	defer cancel()
+	if err := rl.Backend.SetLimit(ctx, limit, burst); err != nil {
+		logger.Warn("Failed to update shared rate limit:", zap.Error(err))
+		return
+	}
+	rl.mu.Lock()
+	rl.limit = limit
+	rl.known = true
+	rl.fetchedAt = rl.now()
+	rl.mu.Unlock()
+}
+
+func (rl *SharedRateLimiter) BlockFor(duration time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), LimiterBackendTimeout)
+	defer cancel()
+	if err := rl.Backend.Block(ctx, duration); err != nil {
+		logger.Warn("Failed to block shared rate limit:", zap.Error(err))
+	}
+}
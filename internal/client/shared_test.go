@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// fakeBackend - общее хранилище с заданными ответами GetLimit
+type fakeBackend struct {
+	LimiterBackend
+	limits []rate.Limit
+	errs   []error
+	calls  int
+	set    rate.Limit
+}
+
+func (b *fakeBackend) GetLimit(ctx context.Context) (rate.Limit, error) {
+	i := b.calls
+	b.calls++
+	return b.limits[i], b.errs[i]
+}
+
+func (b *fakeBackend) SetLimit(ctx context.Context, limit rate.Limit, burst int) error {
+	b.set = limit
+	return nil
+}
+
+func TestSharedRateLimiter_Limit(t *testing.T) {
+	failure := errors.New("failed to get rate limit")
+
+	testCases := []struct {
+		Name          string
+		Limits        []rate.Limit
+		Errs          []error
+		Steps         []time.Duration // время каждого запроса от начала
+		ExpectedLimit []rate.Limit
+		ExpectedCalls int
+	}{
+		{
+			Name:          "Success. Limit cached #1",
+			Limits:        []rate.Limit{1},
+			Errs:          []error{nil},
+			Steps:         []time.Duration{0, time.Second, LimitCacheTTL - time.Millisecond},
+			ExpectedLimit: []rate.Limit{1, 1, 1},
+			ExpectedCalls: 1,
+		},
+		{
+			Name:          "Success. Limit refreshed after TTL #2",
+			Limits:        []rate.Limit{1, 2},
+			Errs:          []error{nil, nil},
+			Steps:         []time.Duration{0, LimitCacheTTL},
+			ExpectedLimit: []rate.Limit{1, 2},
+			ExpectedCalls: 2,
+		},
+		{
+			Name:          "Error. Last known limit kept on failure #3",
+			Limits:        []rate.Limit{1, 0, 3},
+			Errs:          []error{nil, failure, nil},
+			Steps:         []time.Duration{0, LimitCacheTTL, LimitCacheTTL + time.Second, 2 * LimitCacheTTL},
+			ExpectedLimit: []rate.Limit{1, 1, 1, 3},
+			ExpectedCalls: 3,
+		},
+		{
+			Name:          "Error. No limit known on failure #4",
+			Limits:        []rate.Limit{0},
+			Errs:          []error{failure},
+			Steps:         []time.Duration{0},
+			ExpectedLimit: []rate.Limit{rate.Inf},
+			ExpectedCalls: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			backend := &fakeBackend{limits: tc.Limits, errs: tc.Errs}
+			limiter := NewSharedRateLimiter(backend)
+			start := time.Now()
+			for i, step := range tc.Steps {
+				limiter.now = func() time.Time { return start.Add(step) }
+				if limit := limiter.Limit(); limit != tc.ExpectedLimit[i] {
+					t.Errorf("Step %d. Expected limit: %v, got: %v", i, tc.ExpectedLimit[i], limit)
+				}
+			}
+			if backend.calls != tc.ExpectedCalls {
+				t.Errorf("Expected backend calls: %d, got: %d", tc.ExpectedCalls, backend.calls)
+			}
+		})
+	}
+}
+
+// Установленное ограничение сразу видно без запроса к хранилищу
+func TestSharedRateLimiter_Update(t *testing.T) {
+	backend := &fakeBackend{}
+	limiter := NewSharedRateLimiter(backend)
+	limiter.Update(2, 1)
+	if limit := limiter.Limit(); limit != 2 || backend.set != 2 || backend.calls != 0 {
+		t.Errorf("Expected cached limit 2 without backend call, got: %v, set %v, calls %d", limit, backend.set, backend.calls)
+	}
+}
@@ -0,0 +1,245 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/config"
+)
+
+// Заголовки подписи запроса к сервису начислений
+const (
+	HeaderSignature = "X-Signature"
+	HeaderTimestamp = "X-Timestamp"
+)
+
+// NewHTTPClient - HTTP клиент сервиса начислений: таймауты, пул соединений, TLS,
+// повторы идемпотентных запросов, circuit breaker по адресу сервиса, авторизация,
+// запись и воспроизведение обмена с сервисом
+func NewHTTPClient(cfg config.HTTPClientConfig, breakers *BreakerRegistry) (HTTPClient, error) {
+	tlsConfig, err := NewTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: cfg.ConnectTimeout, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   cfg.ConnectTimeout,
+		ResponseHeaderTimeout: cfg.ReadTimeout,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+	}
+
+	var httpClient HTTPClient = &http.Client{
+		Transport: transport,
+		Timeout:   cfg.ConnectTimeout + cfg.ReadTimeout,
+	}
+	switch {
+	case cfg.Replay != "":
+		// ответы берутся из записи, сервис не опрашивается
+		interactions, err := LoadInteractions(cfg.Replay)
+		if err != nil {
+			return nil, err
+		}
+		httpClient = NewReplayClient(interactions)
+	case cfg.Record != "":
+		// записывается каждая попытка запроса, чтобы повторы воспроизводились так же
+		recorder, err := NewRecordingClient(httpClient, cfg.Record)
+		if err != nil {
+			return nil, err
+		}
+		httpClient = recorder
+	}
+	if cfg.Retries > 0 {
+		httpClient = NewRetryClient(httpClient, cfg.Retries, cfg.RetryDelay, cfg.RetryMaxDelay)
+	}
+	if breakers != nil {
+		// повторы одного запроса учитываются breaker как один запрос
+		httpClient = NewBreakerClient(httpClient, breakers)
+	}
+	if cfg.Token != "" || cfg.HMACSecret != "" {
+		httpClient = NewSigningClient(httpClient, cfg.Token, cfg.HMACSecret)
+	}
+	return httpClient, nil
+}
+
+// NewTLSConfig - настройки TLS: собственный удостоверяющий центр и клиентский сертификат (mTLS)
+func NewTLSConfig(cfg config.HTTPClientConfig) (*tls.Config, error) {
+	if cfg.CAFile == "" && cfg.CertFile == "" && cfg.KeyFile == "" {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// RetryClient - повтор идемпотентных запросов при сетевых ошибках и ответах 5xx
+// с экспоненциальной задержкой и случайным разбросом
+type RetryClient struct {
+	Client   HTTPClient
+	Retries  int
+	Delay    time.Duration
+	MaxDelay time.Duration
+}
+
+func NewRetryClient(client HTTPClient, retries int, delay time.Duration, maxDelay time.Duration) *RetryClient {
+	return &RetryClient{Client: client, Retries: retries, Delay: delay, MaxDelay: maxDelay}
+}
+
+func (c *RetryClient) Do(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return c.Client.Do(req)
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := c.Client.Do(req)
+		if attempt >= c.Retries || !retryable(req.Context(), resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			// соединение возвращается в пул только после вычитывания тела
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+		timer := time.NewTimer(c.backoff(attempt))
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *RetryClient) Close() error {
+	return Close(c.Client)
+}
+
+// backoff - задержка перед повтором: случайное значение до Delay*2^attempt, не более MaxDelay
+func (c *RetryClient) backoff(attempt int) time.Duration {
+	delay := c.Delay << attempt
+	if delay <= 0 || (c.MaxDelay > 0 && delay > c.MaxDelay) {
+		delay = c.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+func retryable(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	return resp.StatusCode >= http.StatusInternalServerError
+}
+
+// SigningClient - авторизация запросов bearer токеном и/или HMAC подписью
+type SigningClient struct {
+	Client HTTPClient
+	Token  string
+	Secret []byte
+	now    func() time.Time
+}
+
+func NewSigningClient(client HTTPClient, token string, secret string) *SigningClient {
+	return &SigningClient{Client: client, Token: token, Secret: []byte(secret), now: time.Now}
+}
+
+func (c *SigningClient) Do(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if len(c.Secret) > 0 {
+		body, err := readBody(req)
+		if err != nil {
+			return nil, fmt.Errorf("read request body: %w", err)
+		}
+		timestamp := strconv.FormatInt(c.now().Unix(), 10)
+		req.Header.Set(HeaderTimestamp, timestamp)
+		req.Header.Set(HeaderSignature, Sign(c.Secret, req.Method, req.URL.RequestURI(), timestamp, body))
+	}
+	return c.Client.Do(req)
+}
+
+func (c *SigningClient) Close() error {
+	return Close(c.Client)
+}
+
+// Sign - HMAC-SHA256 подпись запроса: метод, путь с параметрами, время отправки и SHA-256 тела
+func Sign(secret []byte, method string, uri string, timestamp string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(method + "\n" + uri + "\n" + timestamp + "\n" + hex.EncodeToString(bodyHash[:])))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature - проверка подписи запроса на стороне сервиса начислений.
+// Тело запроса вычитывается и подменяется копией для обработчика.
+func VerifySignature(secret []byte, r *http.Request) bool {
+	timestamp := r.Header.Get(HeaderTimestamp)
+	if timestamp == "" {
+		return false
+	}
+	body, err := readBody(r)
+	if err != nil {
+		return false
+	}
+	expected := Sign(secret, r.Method, r.URL.RequestURI(), timestamp, body)
+	return hmac.Equal([]byte(r.Header.Get(HeaderSignature)), []byte(expected))
+}
+
+// readBody - тело запроса для подписи, запрос получает копию тела для отправки и повторов
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
@@ -0,0 +1,401 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/config"
+)
+
+func testHTTPClientConfig() config.HTTPClientConfig {
+	return config.HTTPClientConfig{
+		ConnectTimeout:  time.Second,
+		ReadTimeout:     time.Second,
+		MaxIdleConns:    10,
+		MaxConnsPerHost: 10,
+		IdleConnTimeout: time.Minute,
+		Retries:         2,
+		RetryDelay:      time.Millisecond,
+		RetryMaxDelay:   5 * time.Millisecond,
+	}
+}
+
+func TestHTTPClient_Retries(t *testing.T) {
+	testCases := []struct {
+		Name             string
+		Method           string
+		Statuses         []int // ответы сервера по порядку, последний повторяется
+		ExpectedStatus   int
+		ExpectedRequests int32
+	}{
+		{
+			Name:             "Success. Retries exhausted on 5xx #1",
+			Method:           http.MethodGet,
+			Statuses:         []int{http.StatusServiceUnavailable},
+			ExpectedStatus:   http.StatusServiceUnavailable,
+			ExpectedRequests: 3,
+		},
+		{
+			Name:             "Success. Retry succeeds #2",
+			Method:           http.MethodGet,
+			Statuses:         []int{http.StatusInternalServerError, http.StatusOK},
+			ExpectedStatus:   http.StatusOK,
+			ExpectedRequests: 2,
+		},
+		{
+			Name:             "Success. Too many requests not retried #3",
+			Method:           http.MethodGet,
+			Statuses:         []int{http.StatusTooManyRequests},
+			ExpectedStatus:   http.StatusTooManyRequests,
+			ExpectedRequests: 1,
+		},
+		{
+			Name:             "Success. Not registered order not retried #4",
+			Method:           http.MethodGet,
+			Statuses:         []int{http.StatusNoContent},
+			ExpectedStatus:   http.StatusNoContent,
+			ExpectedRequests: 1,
+		},
+		{
+			Name:             "Success. Client error not retried #5",
+			Method:           http.MethodGet,
+			Statuses:         []int{http.StatusBadRequest},
+			ExpectedStatus:   http.StatusBadRequest,
+			ExpectedRequests: 1,
+		},
+		{
+			Name:             "Success. Non-idempotent request not retried #6",
+			Method:           http.MethodPost,
+			Statuses:         []int{http.StatusServiceUnavailable},
+			ExpectedStatus:   http.StatusServiceUnavailable,
+			ExpectedRequests: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(requests.Add(1))
+				w.WriteHeader(tc.Statuses[min(n, len(tc.Statuses))-1])
+			}))
+			defer server.Close()
+
+			client, err := NewHTTPClient(testHTTPClientConfig(), nil)
+			if err != nil {
+				t.Fatalf("Expected no error, got: '%v'", err)
+			}
+			req, err := http.NewRequest(tc.Method, server.URL+"/api/orders/123456", nil)
+			if err != nil {
+				t.Fatalf("Expected no error, got: '%v'", err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Expected no error, got: '%v'", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tc.ExpectedStatus {
+				t.Errorf("Expected status: %d, got: %d", tc.ExpectedStatus, resp.StatusCode)
+			}
+			if got := requests.Load(); got != tc.ExpectedRequests {
+				t.Errorf("Expected requests: %d, got: %d", tc.ExpectedRequests, got)
+			}
+		})
+	}
+}
+
+func TestHTTPClient_Signature(t *testing.T) {
+	const (
+		token  = "token"
+		secret = "secret"
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !VerifySignature([]byte(secret), r) {
+			http.Error(w, "bad signature", http.StatusForbidden)
+			return
+		}
+		// обработчик получает тело после проверки подписи
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	cfg := testHTTPClientConfig()
+	cfg.Token = token
+	cfg.HMACSecret = secret
+	client, err := NewHTTPClient(cfg, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	// подменяет тело уже подписанного запроса
+	tampering := NewSigningClient(clientFunc(func(req *http.Request) (*http.Response, error) {
+		req.Body = io.NopCloser(strings.NewReader(`{"orders":["999"]}`))
+		req.ContentLength = int64(len(`{"orders":["999"]}`))
+		return server.Client().Do(req)
+	}), token, secret)
+
+	testCases := []struct {
+		Name           string
+		Client         HTTPClient
+		Method         string
+		Path           string
+		Body           string
+		ExpectedStatus int
+	}{
+		{Name: "Success. GET with query #1", Client: client, Method: http.MethodGet, Path: "/api/orders/123456?batch=1", ExpectedStatus: http.StatusOK},
+		{Name: "Success. POST body signed #2", Client: client, Method: http.MethodPost, Path: "/api/orders/status", Body: `{"orders":["123456"]}`, ExpectedStatus: http.StatusOK},
+		{Name: "Error. POST body changed after signing #3", Client: tampering, Method: http.MethodPost, Path: "/api/orders/status", Body: `{"orders":["123456"]}`, ExpectedStatus: http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			var body io.Reader
+			if tc.Body != "" {
+				body = strings.NewReader(tc.Body)
+			}
+			req, err := http.NewRequest(tc.Method, server.URL+tc.Path, body)
+			if err != nil {
+				t.Fatalf("Expected no error, got: '%v'", err)
+			}
+			resp, err := tc.Client.Do(req)
+			if err != nil {
+				t.Fatalf("Expected no error, got: '%v'", err)
+			}
+			defer resp.Body.Close()
+			reply, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tc.ExpectedStatus {
+				t.Errorf("Expected status: %d, got: %d", tc.ExpectedStatus, resp.StatusCode)
+			}
+			if tc.ExpectedStatus == http.StatusOK && string(reply) != tc.Body {
+				t.Errorf("Expected body: '%s', got: '%s'", tc.Body, reply)
+			}
+			// подпись добавляется к копии запроса
+			if req.Header.Get(HeaderSignature) != "" {
+				t.Error("Expected original request to stay unsigned")
+			}
+		})
+	}
+}
+
+// clientFunc - HTTP клиент из функции
+type clientFunc func(req *http.Request) (*http.Response, error)
+
+func (f clientFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// testCertificate - сертификат, подписанный parent (или самоподписанный), в PEM
+type testCertificate struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newTestCertificate(t *testing.T, template *x509.Certificate, parent *testCertificate) *testCertificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	return &testCertificate{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func writeTestFile(t *testing.T, dir string, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	return path
+}
+
+func TestHTTPClient_ClientCertificate(t *testing.T) {
+	ca := newTestCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	serverCert := newTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "accrual"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	clientCert := newTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "gophermart"},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+
+	serverPair, err := tls.X509KeyPair(serverCert.certPEM, serverCert.keyPEM)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 || r.TLS.PeerCertificates[0].Subject.CommonName != "gophermart" {
+			http.Error(w, "unexpected client certificate", http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverPair},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	dir := t.TempDir()
+	caFile := writeTestFile(t, dir, "ca.pem", ca.certPEM)
+	certFile := writeTestFile(t, dir, "client.pem", clientCert.certPEM)
+	keyFile := writeTestFile(t, dir, "client.key", clientCert.keyPEM)
+
+	testCases := []struct {
+		Name          string
+		CertFile      string
+		KeyFile       string
+		ExpectedError bool
+	}{
+		{
+			Name:     "Success. Client certificate sent #1",
+			CertFile: certFile,
+			KeyFile:  keyFile,
+		},
+		{
+			Name:          "Error. No client certificate #2",
+			ExpectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			cfg := testHTTPClientConfig()
+			cfg.Retries = 0
+			cfg.CAFile = caFile
+			cfg.CertFile = tc.CertFile
+			cfg.KeyFile = tc.KeyFile
+			client, err := NewHTTPClient(cfg, nil)
+			if err != nil {
+				t.Fatalf("Expected no error, got: '%v'", err)
+			}
+			req, err := http.NewRequest(http.MethodGet, server.URL+"/api/orders/123456", nil)
+			if err != nil {
+				t.Fatalf("Expected no error, got: '%v'", err)
+			}
+			resp, err := client.Do(req)
+			if tc.ExpectedError {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatal("Expected TLS error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: '%v'", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("Expected status: %d, got: %d", http.StatusOK, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	invalid := writeTestFile(t, dir, "invalid.pem", []byte("not a certificate"))
+
+	testCases := []struct {
+		Name          string
+		Config        config.HTTPClientConfig
+		ExpectedError string
+	}{
+		{
+			Name:   "Success. TLS not configured #1",
+			Config: config.HTTPClientConfig{},
+		},
+		{
+			Name:          "Error. Missing CA file #2",
+			Config:        config.HTTPClientConfig{CAFile: filepath.Join(dir, "missing.pem")},
+			ExpectedError: "read CA file",
+		},
+		{
+			Name:          "Error. No certificates in CA file #3",
+			Config:        config.HTTPClientConfig{CAFile: invalid},
+			ExpectedError: "no certificates in CA file",
+		},
+		{
+			Name:          "Error. Invalid client certificate #4",
+			Config:        config.HTTPClientConfig{CertFile: invalid, KeyFile: invalid},
+			ExpectedError: "load client certificate",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tlsConfig, err := NewTLSConfig(tc.Config)
+			if tc.ExpectedError == "" {
+				if err != nil || tlsConfig != nil {
+					t.Errorf("Expected no TLS config, got: '%v' and '%v'", tlsConfig, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.ExpectedError) {
+				t.Errorf("Expected error containing: '%v', got: '%v'", tc.ExpectedError, err)
+			}
+		})
+	}
+}
@@ -1,48 +1,253 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/caarlos0/env"
+	"github.com/shopspring/decimal"
 	"github.com/spf13/pflag"
 )
 
+// Типы провайдеров начислений
+const (
+	ProviderHTTP   = "http"
+	ProviderRules  = "rules"
+	ProviderStatic = "static"
+)
+
+// Реализации ограничения частоты запросов к сервису начислений
+const (
+	LimiterMemory   = "memory"
+	LimiterPostgres = "postgres"
+)
+
 type Arguments struct {
 	ListenAddr             string        `env:"RUN_ADDRESS" envDefault:"localhost:8080"`
 	LogLevel               string        `env:"LOG_LEVEL" envDefault:"info"`
 	DatabaseDSN            string        `env:"DATABASE_URI" envDefault:""`
 	JWTSecret              string        `env:"JWT_SECRET" envDefault:"secret"`
+	AdminToken             string        `env:"ADMIN_TOKEN" envDefault:""`
+	IdempotencyTTL         time.Duration `env:"IDEMPOTENCY_TTL" envDefault:"24h"`
+	IdempotencyLockTimeout time.Duration `env:"IDEMPOTENCY_LOCK_TIMEOUT" envDefault:"30s"`
+	IdempotencyCleanup     time.Duration `env:"IDEMPOTENCY_CLEANUP_INTERVAL" envDefault:"1h"`
+	IdempotencyBatch       int           `env:"IDEMPOTENCY_CLEANUP_BATCH" envDefault:"1000"`
 	AccrualAddr            string        `env:"ACCRUAL_SYSTEM_ADDRESS" envDefault:"http://localhost:8081"`
+	AccrualLimiter         string        `env:"ACCRUAL_LIMITER" envDefault:"memory"`
+	AccrualProvidersFile   string        `env:"ACCRUAL_PROVIDERS_FILE" envDefault:""`
+	AccrualBatch           bool          `env:"ACCRUAL_BATCH" envDefault:"false"`
+	AccrualConnectTimeout  time.Duration `env:"ACCRUAL_CONNECT_TIMEOUT" envDefault:"3s"`
+	AccrualReadTimeout     time.Duration `env:"ACCRUAL_READ_TIMEOUT" envDefault:"5s"`
+	AccrualMaxIdleConns    int           `env:"ACCRUAL_MAX_IDLE_CONNS" envDefault:"100"`
+	AccrualMaxConnsPerHost int           `env:"ACCRUAL_MAX_CONNS_PER_HOST" envDefault:"10"`
+	AccrualIdleConnTimeout time.Duration `env:"ACCRUAL_IDLE_CONN_TIMEOUT" envDefault:"90s"`
+	AccrualRetries         int           `env:"ACCRUAL_RETRIES" envDefault:"2"`
+	AccrualRetryDelay      time.Duration `env:"ACCRUAL_RETRY_DELAY" envDefault:"100ms"`
+	AccrualRetryMaxDelay   time.Duration `env:"ACCRUAL_RETRY_MAX_DELAY" envDefault:"2s"`
+	AccrualCAFile          string        `env:"ACCRUAL_CA_FILE" envDefault:""`
+	AccrualCertFile        string        `env:"ACCRUAL_CERT_FILE" envDefault:""`
+	AccrualKeyFile         string        `env:"ACCRUAL_KEY_FILE" envDefault:""`
+	AccrualToken           string        `env:"ACCRUAL_TOKEN" envDefault:""`
+	AccrualHMACSecret      string        `env:"ACCRUAL_HMAC_SECRET" envDefault:""`
+	AccrualRecord          string        `env:"ACCRUAL_RECORD" envDefault:""`
+	AccrualReplay          string        `env:"ACCRUAL_REPLAY" envDefault:""`
 	BatchSize              int           `env:"WORKER_BATCH_SIZE" envDefault:"10"`
 	PollInterval           time.Duration `env:"WORKER_POLL_INTERVAL" envDefault:"5s"`
 	ProcessingTimeout      time.Duration `env:"WORKER_PROCESSING_TIMEOUT" envDefault:"10s"`
 	CircuitBreakerTimeout  time.Duration `env:"WORKER_BREAKER_TIMEOUT" envDefault:"30s"`
 	CircuitBreakerFailures int           `env:"WORKER_BREAKER_FAILURES" envDefault:"5"`
+	CircuitBreakerRequests uint32        `env:"WORKER_BREAKER_MAX_REQUESTS" envDefault:"1"`
+	CircuitBreakerRatio    float64       `env:"WORKER_BREAKER_FAILURE_RATIO" envDefault:"0"`
+	CircuitBreakerMinimum  uint32        `env:"WORKER_BREAKER_MIN_REQUESTS" envDefault:"10"`
+	CircuitBreakerInterval time.Duration `env:"WORKER_BREAKER_INTERVAL" envDefault:"60s"`
+	PointsTTL              time.Duration `env:"POINTS_TTL" envDefault:"8760h"`
+	PointsExpiryNotice     time.Duration `env:"POINTS_EXPIRY_NOTICE" envDefault:"720h"`
+	PointsExpireInterval   time.Duration `env:"POINTS_EXPIRE_INTERVAL" envDefault:"1h"`
+	PointsExpireBatch      int           `env:"POINTS_EXPIRE_BATCH" envDefault:"100"`
+	HoldTTL                time.Duration `env:"HOLD_TTL" envDefault:"15m"`
+	HoldReleaseInterval    time.Duration `env:"HOLD_RELEASE_INTERVAL" envDefault:"1m"`
+	HoldReleaseBatch       int           `env:"HOLD_RELEASE_BATCH" envDefault:"100"`
+	TransferMinAmount      float64       `env:"TRANSFER_MIN_AMOUNT" envDefault:"1"`
+	TransferMaxAmount      float64       `env:"TRANSFER_MAX_AMOUNT" envDefault:"0"`
+	TransferDailyLimit     float64       `env:"TRANSFER_DAILY_LIMIT" envDefault:"0"`
+	TransferFeePercent     float64       `env:"TRANSFER_FEE_PERCENT" envDefault:"0"`
+	TransferFeeFixed       float64       `env:"TRANSFER_FEE_FIXED" envDefault:"0"`
+	Tiers                  string        `env:"TIERS" envDefault:""`
+	TiersWindow            time.Duration `env:"TIERS_WINDOW" envDefault:"8760h"`
+	TiersRecalcInterval    time.Duration `env:"TIERS_RECALC_INTERVAL" envDefault:"24h"`
+	ReferrerBonus          float64       `env:"REFERRAL_REFERRER_BONUS" envDefault:"0"`
+	ReferredBonus          float64       `env:"REFERRAL_REFERRED_BONUS" envDefault:"0"`
+	ReferralMinAccrual     float64       `env:"REFERRAL_MIN_ACCRUAL" envDefault:"1"`
+	ReferralMaxReferrals   int           `env:"REFERRAL_MAX_REFERRALS" envDefault:"50"`
+	LeaderElection         bool          `env:"LEADER_ELECTION" envDefault:"false"`
+	LeaderLockKey          int64         `env:"LEADER_LOCK_KEY" envDefault:"7301"`
+	LeaderCheckInterval    time.Duration `env:"LEADER_CHECK_INTERVAL" envDefault:"5s"`
 }
 
 // ServerConfig модель настроек сервера
 type ServerConfig struct {
-	ListenAddr  string
-	LogLevel    string
-	JWTSecret   string
-	DatabaseDSN string
+	ListenAddr             string
+	LogLevel               string
+	JWTSecret              string
+	AdminToken             string
+	DatabaseDSN            string
+	IdempotencyTTL         time.Duration // Время хранения ответа по ключу Idempotency-Key
+	IdempotencyLockTimeout time.Duration // Время, после которого ключ незавершённого запроса занимается повтором
+	IdempotencyCleanup     time.Duration // Период запуска задания удаления ключей старше IdempotencyTTL
+	IdempotencyBatch       int           // Количество ключей, удаляемых за один проход задания
+}
+
+// AccrualRuleConfig модель правила встроенного провайдера начислений
+type AccrualRuleConfig struct {
+	Pattern string          `json:"pattern"` // Регулярное выражение для номера заказа
+	Status  string          `json:"status"`  // Статус расчёта начисления
+	Accrual decimal.Decimal `json:"accrual"` // Начисление по заказу
+}
+
+// AccrualOrderConfig модель ответа провайдера начислений с фиксированной таблицей заказов
+type AccrualOrderConfig struct {
+	Status  string          `json:"status"`
+	Accrual decimal.Decimal `json:"accrual"`
+}
+
+// ProviderConfig модель настроек провайдера начислений
+type ProviderConfig struct {
+	Name      string                        `json:"name"`
+	Type      string                        `json:"type"`                // http, rules или static
+	Address   string                        `json:"address,omitempty"`   // Адрес сервиса для типа http
+	Rules     []AccrualRuleConfig           `json:"rules,omitempty"`     // Правила для типа rules
+	Orders    map[string]AccrualOrderConfig `json:"orders,omitempty"`    // Таблица заказов для типа static
+	Prefixes  []string                      `json:"prefixes,omitempty"`  // Префиксы номеров заказов, направляемых провайдеру
+	Users     []string                      `json:"users,omitempty"`     // Логины пользователей, чьи заказы направляются провайдеру
+	Merchants []string                      `json:"merchants,omitempty"` // Продавцы, чьи заказы направляются провайдеру
+	Default   bool                          `json:"default,omitempty"`   // Провайдер по умолчанию вместо ACCRUAL_SYSTEM_ADDRESS
+	Token     string                        `json:"token,omitempty"`     // Bearer токен для типа http вместо ACCRUAL_TOKEN
+	Secret    string                        `json:"secret,omitempty"`    // Ключ HMAC подписи для типа http вместо ACCRUAL_HMAC_SECRET
+	Batch     bool                          `json:"batch,omitempty"`     // Сервис типа http поддерживает POST /api/orders/status
+}
+
+// HTTPClientConfig модель настроек HTTP клиента сервиса начислений
+type HTTPClientConfig struct {
+	ConnectTimeout  time.Duration // Таймаут установки соединения, включая TLS
+	ReadTimeout     time.Duration // Таймаут получения ответа на запрос
+	MaxIdleConns    int           // Размер пула простаивающих соединений
+	MaxConnsPerHost int           // Ограничение соединений к одному сервису
+	IdleConnTimeout time.Duration // Время жизни простаивающего соединения
+	Retries         int           // Количество повторов GET запроса при сетевой ошибке и 5xx
+	RetryDelay      time.Duration // Начальная задержка перед повтором
+	RetryMaxDelay   time.Duration // Максимальная задержка перед повтором
+	CAFile          string        // Сертификат удостоверяющего центра сервиса
+	CertFile        string        // Клиентский сертификат для mTLS
+	KeyFile         string        // Ключ клиентского сертификата для mTLS
+	Token           string        // Bearer токен авторизации
+	HMACSecret      string        // Ключ HMAC подписи запросов
+	Record          string        // Файл записи запросов и ответов сервиса
+	Replay          string        // Файл с записью, ответы из которого воспроизводятся вместо запросов к сервису
 }
 
 // AccrualConfig модель настроек работы с сервисом  расчёта начислений баллов лояльности
 type AccrualConfig struct {
-	AccrualAddr            string
-	BatchSize              int
-	PollInterval           time.Duration
-	ProcessingTimeout      time.Duration
-	CircuitBreakerTimeout  time.Duration
-	CircuitBreakerFailures int
+	AccrualAddr                string
+	Limiter                    string
+	Providers                  []ProviderConfig
+	Batch                      bool
+	HTTP                       HTTPClientConfig
+	BatchSize                  int
+	PollInterval               time.Duration
+	ProcessingTimeout          time.Duration
+	CircuitBreakerTimeout      time.Duration // Время в разомкнутом состоянии до пробных запросов
+	CircuitBreakerFailures     int           // Подряд идущих ошибок для размыкания, 0 - не используется
+	CircuitBreakerMaxRequests  uint32        // Пробных запросов в полуоткрытом состоянии
+	CircuitBreakerFailureRatio float64       // Доля ошибок для размыкания, 0 - не используется
+	CircuitBreakerMinRequests  uint32        // Минимум запросов для расчёта доли ошибок
+	CircuitBreakerInterval     time.Duration // Период сброса счётчиков в замкнутом состоянии
+}
+
+// LeaderConfig модель настроек выбора лидера среди реплик сервиса
+type LeaderConfig struct {
+	Enabled       bool
+	LockKey       int64
+	CheckInterval time.Duration
+}
+
+// PointsConfig модель настроек сгорания баллов
+type PointsConfig struct {
+	TTL            time.Duration // Срок жизни начисленных баллов, 0 - баллы не сгорают
+	ExpiryNotice   time.Duration // Срок до сгорания, с которого баллы показываются в балансе как сгорающие
+	ExpireInterval time.Duration // Период запуска задания сгорания баллов
+	ExpireBatch    int           // Количество партий баллов, сгорающих за один проход задания
+}
+
+// HoldsConfig модель настроек резервирования баллов под списание
+type HoldsConfig struct {
+	TTL             time.Duration // Время, через которое незавершённый резерв снимается автоматически
+	ReleaseInterval time.Duration // Период запуска задания снятия просроченных резервов
+	ReleaseBatch    int           // Количество резервов, снимаемых за один проход задания
+}
+
+// TransfersConfig модель настроек переводов баллов между пользователями, нулевой лимит - без ограничения
+type TransfersConfig struct {
+	MinAmount  decimal.Decimal // Минимальная сумма перевода
+	MaxAmount  decimal.Decimal // Максимальная сумма перевода
+	DailyLimit decimal.Decimal // Сумма переводов пользователя за последние сутки
+	FeePercent decimal.Decimal // Комиссия в процентах от суммы перевода
+	FeeFixed   decimal.Decimal // Фиксированная часть комиссии
+}
+
+// TierConfig модель уровня участника программы лояльности
+type TierConfig struct {
+	Name       string          // Название уровня
+	Threshold  decimal.Decimal // Сумма начислений за период, с которой присваивается уровень
+	Multiplier decimal.Decimal // Множитель начислений по заказам
+}
+
+// TiersConfig модель настроек уровней участников, уровни упорядочены по возрастанию порога.
+// Без заданных уровней (по умолчанию) надбавки к начислениям не начисляются
+type TiersConfig struct {
+	Tiers          []TierConfig
+	Window         time.Duration // Период, за который суммируются начисления
+	RecalcInterval time.Duration // Период запуска задания пересчёта уровней
+}
+
+// Multiplier множитель начислений уровня, без уровня - 1
+func (c TiersConfig) Multiplier(tier string) decimal.Decimal {
+	for _, t := range c.Tiers {
+		if t.Name == tier {
+			return t.Multiplier
+		}
+	}
+	return decimal.NewFromInt(1)
+}
+
+// ReferralsConfig модель настроек реферальной программы. Условия фиксируются при регистрации приглашённого.
+// По умолчанию бонусы нулевые и программа выключена.
+//
+// Проверок личности приглашённого (адрес, устройство, платёжные данные) нет: пользователь может
+// пригласить сам себя с другой учётной записи. От накрутки защищают только условия выплаты:
+// бонусы начисляются за первый обработанный заказ приглашённого с начислением не меньше MinAccrual,
+// то есть за покупку, подтверждённую сервисом начислений, и не более чем за MaxReferrals приглашений.
+// Перед включением программы эти параметры нужно выбрать с учётом размера бонусов.
+type ReferralsConfig struct {
+	ReferrerBonus decimal.Decimal // Бонус пригласившему за первый обработанный заказ приглашённого
+	ReferredBonus decimal.Decimal // Бонус приглашённому за его первый обработанный заказ
+	MinAccrual    decimal.Decimal // Минимальное начисление по первому заказу для получения бонусов
+	MaxReferrals  int             // Приглашения пользователя, за которые начисляется бонус, 0 - без ограничения
 }
 
 // Config модель настроек сервиса
 type Config struct {
-	Server  ServerConfig
-	Accrual AccrualConfig
+	Server    ServerConfig
+	Accrual   AccrualConfig
+	Leader    LeaderConfig
+	Points    PointsConfig
+	Holds     HoldsConfig
+	Transfers TransfersConfig
+	Tiers     TiersConfig
+	Referrals ReferralsConfig
 }
 
 func NewConfig() Config {
@@ -58,23 +263,98 @@ func NewConfig() Config {
 		DSN      = pflag.StringP("dsn", "d", args.DatabaseDSN, "Database DSN")
 		secret   = pflag.StringP("secret", "s", args.JWTSecret, "Secret to JWT")
 		accrual  = pflag.StringP("accurual", "r", args.AccrualAddr, "Accurual listen address in a form host:port.")
+		election = pflag.Bool("leader-election", args.LeaderElection, "Enable leader election between replicas")
+		record   = pflag.String("accrual-record", args.AccrualRecord, "Record accrual service traffic to file")
+		replay   = pflag.String("accrual-replay", args.AccrualReplay, "Replay accrual service responses from recorded file")
 	)
 	pflag.Parse()
 
+	providers, err := LoadProviders(args.AccrualProvidersFile)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to load accrual providers: %s", err.Error()))
+	}
+
+	tiers, err := ParseTiers(args.Tiers)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to parse tiers: %s", err.Error()))
+	}
+
 	return Config{
 		Server: ServerConfig{
-			ListenAddr:  *server,
-			LogLevel:    *logLevel,
-			DatabaseDSN: *DSN,
-			JWTSecret:   *secret,
+			ListenAddr:             *server,
+			LogLevel:               *logLevel,
+			DatabaseDSN:            *DSN,
+			JWTSecret:              *secret,
+			AdminToken:             args.AdminToken,
+			IdempotencyTTL:         args.IdempotencyTTL,
+			IdempotencyLockTimeout: args.IdempotencyLockTimeout,
+			IdempotencyCleanup:     args.IdempotencyCleanup,
+			IdempotencyBatch:       args.IdempotencyBatch,
 		},
 		Accrual: AccrualConfig{
-			AccrualAddr:            *accrual,
-			BatchSize:              args.BatchSize,
-			PollInterval:           args.PollInterval,
-			ProcessingTimeout:      args.ProcessingTimeout,
-			CircuitBreakerTimeout:  args.CircuitBreakerTimeout,
-			CircuitBreakerFailures: args.CircuitBreakerFailures,
+			AccrualAddr: *accrual,
+			Limiter:     args.AccrualLimiter,
+			Providers:   providers,
+			HTTP: HTTPClientConfig{
+				ConnectTimeout:  args.AccrualConnectTimeout,
+				ReadTimeout:     args.AccrualReadTimeout,
+				MaxIdleConns:    args.AccrualMaxIdleConns,
+				MaxConnsPerHost: args.AccrualMaxConnsPerHost,
+				IdleConnTimeout: args.AccrualIdleConnTimeout,
+				Retries:         args.AccrualRetries,
+				RetryDelay:      args.AccrualRetryDelay,
+				RetryMaxDelay:   args.AccrualRetryMaxDelay,
+				CAFile:          args.AccrualCAFile,
+				CertFile:        args.AccrualCertFile,
+				KeyFile:         args.AccrualKeyFile,
+				Token:           args.AccrualToken,
+				HMACSecret:      args.AccrualHMACSecret,
+				Record:          *record,
+				Replay:          *replay,
+			},
+			BatchSize:                  args.BatchSize,
+			PollInterval:               args.PollInterval,
+			ProcessingTimeout:          args.ProcessingTimeout,
+			CircuitBreakerTimeout:      args.CircuitBreakerTimeout,
+			CircuitBreakerFailures:     args.CircuitBreakerFailures,
+			CircuitBreakerMaxRequests:  args.CircuitBreakerRequests,
+			CircuitBreakerFailureRatio: args.CircuitBreakerRatio,
+			CircuitBreakerMinRequests:  args.CircuitBreakerMinimum,
+			CircuitBreakerInterval:     args.CircuitBreakerInterval,
+		},
+		Leader: LeaderConfig{
+			Enabled:       *election,
+			LockKey:       args.LeaderLockKey,
+			CheckInterval: args.LeaderCheckInterval,
+		},
+		Points: PointsConfig{
+			TTL:            args.PointsTTL,
+			ExpiryNotice:   args.PointsExpiryNotice,
+			ExpireInterval: args.PointsExpireInterval,
+			ExpireBatch:    args.PointsExpireBatch,
+		},
+		Holds: HoldsConfig{
+			TTL:             args.HoldTTL,
+			ReleaseInterval: args.HoldReleaseInterval,
+			ReleaseBatch:    args.HoldReleaseBatch,
+		},
+		Transfers: TransfersConfig{
+			MinAmount:  decimal.NewFromFloat(args.TransferMinAmount),
+			MaxAmount:  decimal.NewFromFloat(args.TransferMaxAmount),
+			DailyLimit: decimal.NewFromFloat(args.TransferDailyLimit),
+			FeePercent: decimal.NewFromFloat(args.TransferFeePercent),
+			FeeFixed:   decimal.NewFromFloat(args.TransferFeeFixed),
+		},
+		Tiers: TiersConfig{
+			Tiers:          tiers,
+			Window:         args.TiersWindow,
+			RecalcInterval: args.TiersRecalcInterval,
+		},
+		Referrals: ReferralsConfig{
+			ReferrerBonus: decimal.NewFromFloat(args.ReferrerBonus),
+			ReferredBonus: decimal.NewFromFloat(args.ReferredBonus),
+			MinAccrual:    decimal.NewFromFloat(args.ReferralMinAccrual),
+			MaxReferrals:  args.ReferralMaxReferrals,
 		},
 	}
 }
@@ -82,18 +362,112 @@ func NewConfig() Config {
 func DefaultConfig() Config {
 	return Config{
 		Server: ServerConfig{
-			ListenAddr:  "localhost:8080",
-			LogLevel:    "info",
-			DatabaseDSN: "",
-			JWTSecret:   "secret",
+			ListenAddr:             "localhost:8080",
+			LogLevel:               "info",
+			DatabaseDSN:            "",
+			JWTSecret:              "secret",
+			AdminToken:             "",
+			IdempotencyTTL:         24 * time.Hour,
+			IdempotencyLockTimeout: 30 * time.Second,
+			IdempotencyCleanup:     time.Hour,
+			IdempotencyBatch:       1000,
 		},
 		Accrual: AccrualConfig{
-			AccrualAddr:            ":8081",
-			BatchSize:              10,
-			PollInterval:           5 * time.Second,
-			ProcessingTimeout:      10 * time.Second,
-			CircuitBreakerTimeout:  30 * time.Second,
-			CircuitBreakerFailures: 5,
+			AccrualAddr: ":8081",
+			Limiter:     LimiterMemory,
+			HTTP: HTTPClientConfig{
+				ConnectTimeout:  3 * time.Second,
+				ReadTimeout:     5 * time.Second,
+				MaxIdleConns:    100,
+				MaxConnsPerHost: 10,
+				IdleConnTimeout: 90 * time.Second,
+				Retries:         2,
+				RetryDelay:      100 * time.Millisecond,
+				RetryMaxDelay:   2 * time.Second,
+			},
+			BatchSize:                 10,
+			PollInterval:              5 * time.Second,
+			ProcessingTimeout:         10 * time.Second,
+			CircuitBreakerTimeout:     30 * time.Second,
+			CircuitBreakerFailures:    5,
+			CircuitBreakerMaxRequests: 1,
+			CircuitBreakerMinRequests: 10,
+			CircuitBreakerInterval:    60 * time.Second,
+		},
+		Leader: LeaderConfig{
+			Enabled:       false,
+			LockKey:       7301,
+			CheckInterval: 5 * time.Second,
+		},
+		Points: PointsConfig{
+			TTL:            365 * 24 * time.Hour,
+			ExpiryNotice:   30 * 24 * time.Hour,
+			ExpireInterval: time.Hour,
+			ExpireBatch:    100,
+		},
+		Holds: HoldsConfig{
+			TTL:             15 * time.Minute,
+			ReleaseInterval: time.Minute,
+			ReleaseBatch:    100,
 		},
+		Transfers: TransfersConfig{
+			MinAmount:  decimal.NewFromInt(1),
+			MaxAmount:  decimal.Zero,
+			DailyLimit: decimal.Zero,
+			FeePercent: decimal.Zero,
+			FeeFixed:   decimal.Zero,
+		},
+		Tiers: TiersConfig{
+			Window:         365 * 24 * time.Hour,
+			RecalcInterval: 24 * time.Hour,
+		},
+		Referrals: ReferralsConfig{
+			MinAccrual:   decimal.NewFromInt(1),
+			MaxReferrals: 50,
+		},
+	}
+}
+
+// LoadProviders - загрузка настроек провайдеров начислений из JSON файла
+func LoadProviders(path string) ([]ProviderConfig, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Providers []ProviderConfig `json:"providers"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	return file.Providers, nil
+}
+
+// ParseTiers - разбор уровней участников из строки вида "SILVER:1000:1.1,GOLD:5000:1.25".
+// Пустая строка - уровни не используются.
+func ParseTiers(spec string) ([]TierConfig, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	var tiers []TierConfig
+	for _, item := range strings.Split(spec, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ":")
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid tier %q, expected NAME:THRESHOLD:MULTIPLIER", item)
+		}
+		threshold, err := decimal.NewFromString(parts[1])
+		if err != nil || threshold.IsNegative() {
+			return nil, fmt.Errorf("invalid threshold of tier %s", parts[0])
+		}
+		multiplier, err := decimal.NewFromString(parts[2])
+		if err != nil || !multiplier.IsPositive() {
+			return nil, fmt.Errorf("invalid multiplier of tier %s", parts[0])
+		}
+		tiers = append(tiers, TierConfig{Name: parts[0], Threshold: threshold, Multiplier: multiplier})
 	}
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].Threshold.LessThan(tiers[j].Threshold) })
+	return tiers, nil
 }
@@ -0,0 +1,194 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/config"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/denmor86/ya-gophermart/internal/storage"
+	"go.uber.org/zap"
+)
+
+// Elector - представляет интерфейс выбора лидера среди реплик сервиса
+type Elector interface {
+	Start(ctx context.Context)
+	Stop()
+	IsLeader() bool
+	Status() models.LeaderStatus
+}
+
+// NewElector - создание механизма выбора лидера согласно настройкам.
+// При выключенных выборах экземпляр всегда считается лидером.
+func NewElector(config config.LeaderConfig, db *storage.Database) Elector {
+	if !config.Enabled {
+		return NewSingle()
+	}
+	return NewLockElector(storage.NewAdvisoryLock(db, config.LockKey), config.CheckInterval)
+}
+
+// InstanceID - идентификатор экземпляра сервиса
+func InstanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// Single - единственный экземпляр, выборы не проводятся
+type Single struct {
+	id    string
+	since time.Time
+}
+
+// Создание единственного экземпляра
+func NewSingle() Elector {
+	return &Single{id: InstanceID(), since: time.Now()}
+}
+
+func (s *Single) Start(ctx context.Context) {}
+
+func (s *Single) Stop() {}
+
+func (s *Single) IsLeader() bool {
+	return true
+}
+
+func (s *Single) Status() models.LeaderStatus {
+	return models.LeaderStatus{
+		Enabled:    false,
+		InstanceID: s.id,
+		Leader:     true,
+		Since:      s.since.Format(time.RFC3339),
+	}
+}
+
+// LockElector - выбор лидера через блокировку в БД.
+// Лидером становится реплика, захватившая блокировку; при потере соединения
+// блокировка освобождается и её забирает одна из оставшихся реплик.
+type LockElector struct {
+	Lock      storage.LeaderLock
+	Interval  time.Duration
+	WaitGroup sync.WaitGroup
+	QuitChan  chan struct{}
+
+	id        string
+	mu        sync.RWMutex
+	leader    bool
+	since     time.Time
+	lastCheck time.Time
+}
+
+// Создание механизма выбора лидера
+func NewLockElector(lock storage.LeaderLock, interval time.Duration) *LockElector {
+	return &LockElector{
+		Lock:     lock,
+		Interval: interval,
+		QuitChan: make(chan struct{}),
+		id:       InstanceID(),
+	}
+}
+
+func (e *LockElector) Start(ctx context.Context) {
+	e.WaitGroup.Add(1)
+	go e.Run(ctx)
+}
+
+// Stop - остановка и освобождение блокировки, чтобы другая реплика сразу подхватила лидерство
+func (e *LockElector) Stop() {
+	close(e.QuitChan)
+	e.WaitGroup.Wait()
+}
+
+func (e *LockElector) Run(ctx context.Context) {
+	defer e.WaitGroup.Done()
+	defer e.resign()
+
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+
+	e.elect(ctx)
+	for {
+		select {
+		case <-e.QuitChan:
+			logger.Info("LockElector stopped by quit signal")
+			return
+		case <-ctx.Done():
+			logger.Info("LockElector stopped by context cancellation")
+			return
+		case <-ticker.C:
+			e.elect(ctx)
+		}
+	}
+}
+
+func (e *LockElector) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.leader
+}
+
+func (e *LockElector) Status() models.LeaderStatus {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	status := models.LeaderStatus{
+		Enabled:    true,
+		InstanceID: e.id,
+		Leader:     e.leader,
+	}
+	if e.leader {
+		status.Since = e.since.Format(time.RFC3339)
+	}
+	if !e.lastCheck.IsZero() {
+		status.LastCheck = e.lastCheck.Format(time.RFC3339)
+	}
+	return status
+}
+
+// elect - проверка удерживаемой блокировки либо попытка её захватить
+func (e *LockElector) elect(ctx context.Context) {
+	checkCtx, cancel := context.WithTimeout(ctx, e.Interval)
+	defer cancel()
+
+	if e.IsLeader() {
+		err := e.Lock.Check(checkCtx)
+		e.setState(err == nil)
+		if err != nil {
+			logger.Warn("Leadership lost:", zap.Error(err))
+		}
+		return
+	}
+
+	locked, err := e.Lock.TryLock(checkCtx)
+	if err != nil {
+		logger.Warn("Failed to try leadership lock:", zap.Error(err))
+	}
+	e.setState(locked)
+	if locked {
+		logger.Info("Instance became leader", e.id)
+	}
+}
+
+func (e *LockElector) setState(leader bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if leader && !e.leader {
+		e.since = time.Now()
+	}
+	e.leader = leader
+	e.lastCheck = time.Now()
+}
+
+func (e *LockElector) resign() {
+	ctx, cancel := context.WithTimeout(context.Background(), e.Interval)
+	defer cancel()
+	if err := e.Lock.Unlock(ctx); err != nil {
+		logger.Warn("Failed to release leadership lock:", zap.Error(err))
+	}
+	e.setState(false)
+}
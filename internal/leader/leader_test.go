@@ -0,0 +1,140 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/config"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/storage/mocks"
+	"go.uber.org/mock/gomock"
+)
+
+func TestLockElector_Elect(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockLock := mocks.NewMockLeaderLock(ctrl)
+
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+
+	testCases := []struct {
+		Name           string
+		SetupMocks     func()
+		Rounds         int
+		ExpectedLeader bool
+	}{
+		{
+			Name: "Success. Acquire lock #1",
+			SetupMocks: func() {
+				mockLock.EXPECT().TryLock(gomock.Any()).Return(true, nil)
+			},
+			Rounds:         1,
+			ExpectedLeader: true,
+		},
+		{
+			Name: "Success. Lock held by another replica #2",
+			SetupMocks: func() {
+				mockLock.EXPECT().TryLock(gomock.Any()).Return(false, nil)
+			},
+			Rounds:         1,
+			ExpectedLeader: false,
+		},
+		{
+			Name: "Error. Lock failure #3",
+			SetupMocks: func() {
+				mockLock.EXPECT().TryLock(gomock.Any()).Return(false, errors.New("connection refused"))
+			},
+			Rounds:         1,
+			ExpectedLeader: false,
+		},
+		{
+			Name: "Success. Keep leadership #4",
+			SetupMocks: func() {
+				gomock.InOrder(
+					mockLock.EXPECT().TryLock(gomock.Any()).Return(true, nil),
+					mockLock.EXPECT().Check(gomock.Any()).Return(nil).Times(2),
+				)
+			},
+			Rounds:         3,
+			ExpectedLeader: true,
+		},
+		{
+			Name: "Error. Leadership lost #5",
+			SetupMocks: func() {
+				gomock.InOrder(
+					mockLock.EXPECT().TryLock(gomock.Any()).Return(true, nil),
+					mockLock.EXPECT().Check(gomock.Any()).Return(errors.New("conn closed")),
+				)
+			},
+			Rounds:         2,
+			ExpectedLeader: false,
+		},
+		{
+			Name: "Success. Re-acquire after loss #6",
+			SetupMocks: func() {
+				gomock.InOrder(
+					mockLock.EXPECT().TryLock(gomock.Any()).Return(true, nil),
+					mockLock.EXPECT().Check(gomock.Any()).Return(errors.New("conn closed")),
+					mockLock.EXPECT().TryLock(gomock.Any()).Return(false, nil),
+					mockLock.EXPECT().TryLock(gomock.Any()).Return(true, nil),
+				)
+			},
+			Rounds:         4,
+			ExpectedLeader: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.SetupMocks()
+
+			elector := NewLockElector(mockLock, time.Second)
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			for i := 0; i < tc.Rounds; i++ {
+				elector.elect(ctx)
+			}
+
+			if leader := elector.IsLeader(); leader != tc.ExpectedLeader {
+				t.Errorf("Expected leader: %v, got: %v", tc.ExpectedLeader, leader)
+			}
+			status := elector.Status()
+			if !status.Enabled || status.LastCheck == "" {
+				t.Errorf("Expected enabled status with last check, got: %+v", status)
+			}
+			if (status.Since != "") != tc.ExpectedLeader {
+				t.Errorf("Expected since only for leader, got: %+v", status)
+			}
+		})
+	}
+}
+
+func TestLockElector_StopReleasesLock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockLock := mocks.NewMockLeaderLock(ctrl)
+
+	acquired := make(chan struct{})
+	gomock.InOrder(
+		mockLock.EXPECT().TryLock(gomock.Any()).DoAndReturn(func(ctx context.Context) (bool, error) {
+			close(acquired)
+			return true, nil
+		}),
+		mockLock.EXPECT().Unlock(gomock.Any()).Return(nil),
+	)
+
+	elector := NewLockElector(mockLock, time.Hour)
+	elector.Start(context.Background())
+	<-acquired
+	elector.Stop()
+
+	if elector.IsLeader() {
+		t.Error("Expected leadership to be released on stop")
+	}
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// BreakerStatus - модель состояния и счётчиков circuit breaker сервиса начислений
+type BreakerStatus struct {
+	Host      string    `json:"host"`       // Адрес сервиса начислений
+	State     string    `json:"state"`      // Состояние: closed, half-open или open
+	ChangedAt time.Time `json:"changed_at"` // Время последней смены состояния
+	Requests  uint64    `json:"requests"`   // Запросов, пропущенных к сервису
+	Successes uint64    `json:"successes"`  // Успешных запросов
+	Failures  uint64    `json:"failures"`   // Запросов с сетевой ошибкой или ответом 5xx
+	Rejected  uint64    `json:"rejected"`   // Запросов, отклонённых разомкнутым breaker
+	Trips     uint64    `json:"trips"`      // Количество размыканий
+}
+
+// BreakerEvent - модель события смены состояния circuit breaker
+type BreakerEvent struct {
+	Host string    `json:"host"`
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	At   time.Time `json:"at"`
+}
+
+// BreakersStatus - модель состояния всех circuit breaker с последними событиями
+type BreakersStatus struct {
+	Breakers []BreakerStatus `json:"breakers"`
+	Events   []BreakerEvent  `json:"events"`
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// CampaignConditions - условия участия заказа в кампании, пустое условие не проверяется
+type CampaignConditions struct {
+	FirstOrder bool            `json:"first_order"` // Только первый обработанный заказ пользователя
+	MinAccrual decimal.Decimal `json:"min_accrual"` // Минимальное начисление по заказу
+	Tier       string          `json:"tier"`        // Уровень участника
+}
+
+// CampaignBonus - формула бонуса кампании: процент от начисления по заказу и фиксированная сумма
+type CampaignBonus struct {
+	Percent decimal.Decimal `json:"percent"`
+	Fixed   decimal.Decimal `json:"fixed"`
+	Max     decimal.Decimal `json:"max"` // Ограничение бонуса, 0 - без ограничения
+}
+
+// Campaign - промо-кампания, начисляющая бонус по заказам, обработанным в период её действия
+type Campaign struct {
+	ID         int64
+	Name       string
+	StartsAt   time.Time
+	EndsAt     time.Time // Нулевое время - кампания бессрочная
+	Active     bool
+	Conditions CampaignConditions
+	Bonus      CampaignBonus
+	CreatedAt  time.Time
+}
+
+// CampaignOrder - данные обработанного заказа для проверки условий кампании
+type CampaignOrder struct {
+	Accrual    decimal.Decimal
+	Tier       string
+	FirstOrder bool
+}
+
+// Award - бонус кампании по заказу, 0 - заказ не участвует в кампании
+func (c Campaign) Award(order CampaignOrder) decimal.Decimal {
+	if c.Conditions.FirstOrder && !order.FirstOrder {
+		return decimal.Zero
+	}
+	if order.Accrual.LessThan(c.Conditions.MinAccrual) {
+		return decimal.Zero
+	}
+	if c.Conditions.Tier != "" && c.Conditions.Tier != order.Tier {
+		return decimal.Zero
+	}
+	bonus := order.Accrual.Mul(c.Bonus.Percent).Div(decimal.NewFromInt(100)).Add(c.Bonus.Fixed).Round(2)
+	if c.Bonus.Max.IsPositive() && bonus.GreaterThan(c.Bonus.Max) {
+		bonus = c.Bonus.Max
+	}
+	return bonus
+}
+
+// CampaignRequest - модель запроса создания или изменения кампании
+type CampaignRequest struct {
+	Name       string             `json:"name"`
+	StartsAt   time.Time          `json:"starts_at"`
+	EndsAt     *time.Time         `json:"ends_at,omitempty"`
+	Conditions CampaignConditions `json:"conditions"`
+	Bonus      CampaignBonus      `json:"bonus"`
+}
+
+// CampaignResponse - модель кампании для выдачи
+type CampaignResponse struct {
+	ID         int64              `json:"id"`
+	Name       string             `json:"name"`
+	StartsAt   string             `json:"starts_at"`
+	EndsAt     string             `json:"ends_at,omitempty"`
+	Active     bool               `json:"active"`
+	Conditions CampaignConditions `json:"conditions"`
+	Bonus      CampaignBonus      `json:"bonus"`
+	CreatedAt  string             `json:"created_at"`
+}
@@ -0,0 +1,8 @@
+package models
+
+// IdempotentResponse - сохранённый ответ на запрос с ключом Idempotency-Key
+type IdempotentResponse struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
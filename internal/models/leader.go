@@ -0,0 +1,10 @@
+package models
+
+// LeaderStatus - модель состояния выбора лидера для выдачи
+type LeaderStatus struct {
+	Enabled    bool   `json:"enabled"`              // Включены ли выборы лидера
+	InstanceID string `json:"instance_id"`          // Идентификатор экземпляра сервиса
+	Leader     bool   `json:"leader"`               // Является ли экземпляр лидером
+	Since      string `json:"since,omitempty"`      // Время получения лидерства
+	LastCheck  string `json:"last_check,omitempty"` // Время последней проверки блокировки
+}
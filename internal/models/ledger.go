@@ -0,0 +1,127 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Виды записей журнала баллов
+const (
+	LedgerAccrual    = "ACCRUAL"    // Начисление по заказу
+	LedgerWithdrawal = "WITHDRAWAL" // Списание в счёт заказа
+	LedgerAdjustment = "ADJUSTMENT" // Корректировка администратором
+	LedgerReversal   = "REVERSAL"   // Отмена ранее сделанной записи
+	LedgerExpiration = "EXPIRATION" // Сгорание баллов
+	LedgerTransfer   = "TRANSFER"   // Перевод баллов другому пользователю или от него
+	LedgerFee        = "FEE"        // Комиссия за перевод
+	LedgerCampaign   = "CAMPAIGN"   // Бонус промо-кампании по заказу
+	LedgerReferral   = "REFERRAL"   // Бонус реферальной программы
+	LedgerTier       = "TIER"       // Надбавка уровня участника к начислению по заказу
+)
+
+// LedgerEntry - запись журнала движения баллов пользователя.
+// Журнал только дополняется, баланс пользователя равен сумме его записей.
+type LedgerEntry struct {
+	ID          int64
+	UserID      string
+	Kind        string
+	Amount      decimal.Decimal // Положительная сумма - зачисление, отрицательная - списание
+	OrderNumber string
+	ReversalOf  int64 // Отменённая запись, 0 - не отмена
+	CampaignID  int64 // Кампания, начислившая бонус, 0 - не бонус кампании
+	Comment     string
+	CreatedAt   time.Time
+}
+
+// LedgerEntryResponse - модель записи журнала баллов для выдачи
+type LedgerEntryResponse struct {
+	ID          int64       `json:"id"`
+	UserID      string      `json:"user_id"`
+	Kind        string      `json:"kind"`
+	Amount      json.Number `json:"amount"`
+	OrderNumber string      `json:"order,omitempty"`
+	ReversalOf  int64       `json:"reversal_of,omitempty"`
+	CampaignID  int64       `json:"campaign_id,omitempty"`
+	Comment     string      `json:"comment,omitempty"`
+	CreatedAt   string      `json:"created_at"`
+}
+
+// AdjustmentRequest - модель запроса корректировки баланса пользователя администратором
+type AdjustmentRequest struct {
+	Login   string          `json:"login"`
+	Amount  decimal.Decimal `json:"amount"`
+	Comment string          `json:"comment"`
+}
+
+// ReversalRequest - модель запроса отмены записи журнала
+type ReversalRequest struct {
+	Comment string `json:"comment"`
+}
+
+// LedgerMismatch - расхождение кэшированного баланса пользователя с суммой записей журнала
+type LedgerMismatch struct {
+	UserID  string          `json:"user_id"`
+	Login   string          `json:"login"`
+	Balance decimal.Decimal `json:"balance"`
+	Ledger  decimal.Decimal `json:"ledger"`
+}
+
+// LedgerCheck - результат проверки согласованности балансов с журналом
+type LedgerCheck struct {
+	Consistent bool             `json:"consistent"`
+	Mismatches []LedgerMismatch `json:"mismatches"`
+}
+
+// BalanceHistoryFilter - параметры выборки истории баланса, нулевое время - без ограничения
+type BalanceHistoryFilter struct {
+	From   time.Time // Начало периода включительно
+	To     time.Time // Конец периода не включительно
+	Limit  int
+	Offset int
+}
+
+// BalanceHistoryEntry - движение баллов с балансом после него
+type BalanceHistoryEntry struct {
+	ID          int64
+	Kind        string
+	Amount      decimal.Decimal
+	Balance     decimal.Decimal
+	OrderNumber string
+	Comment     string
+	CreatedAt   time.Time
+}
+
+// BalanceHistoryResponse - модель движения баллов для выдачи
+type BalanceHistoryResponse struct {
+	ID          int64       `json:"id"`
+	Kind        string      `json:"kind"`
+	Amount      json.Number `json:"amount"`
+	Balance     json.Number `json:"balance"`
+	OrderNumber string      `json:"order,omitempty"`
+	Comment     string      `json:"comment,omitempty"`
+	CreatedAt   string      `json:"created_at"`
+}
+
+// PointLot - партия начисленных баллов. Списания расходуют партии начиная с самых старых,
+// неизрасходованный остаток сгорает по истечении срока жизни баллов.
+type PointLot struct {
+	ID        int64
+	UserID    string
+	Amount    decimal.Decimal // Начисленная сумма
+	Remaining decimal.Decimal // Неизрасходованный остаток
+	CreatedAt time.Time
+}
+
+// ExpiringPoints - баллы, которые сгорят в указанное время
+type ExpiringPoints struct {
+	Amount    decimal.Decimal
+	ExpiresAt time.Time
+}
+
+// ExpiringPointsResponse - модель сгорающих баллов для выдачи
+type ExpiringPointsResponse struct {
+	Amount    json.Number `json:"amount"`
+	ExpiresAt string      `json:"expires_at"`
+}
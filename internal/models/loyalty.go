@@ -1,15 +1,16 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/shopspring/decimal"
 )
 
-// WithdrawalRequest - модель запроса списания баллов за заказ
+// WithdrawalRequest - модель запроса списания баллов за заказ, сумма принимается числом или строкой без потери точности
 type WithdrawalRequest struct {
-	OrderNumber string  `json:"order"`
-	Withdrawn   float64 `json:"sum"`
+	OrderNumber string          `json:"order"`
+	Withdrawn   decimal.Decimal `json:"sum"`
 }
 
 // WithdrawalData - модель хранения данных по начислениям баллов по заказам
@@ -22,7 +23,37 @@ type WithdrawalData struct {
 
 // WithdrawalResponse — структура ответа о выводе средств
 type WithdrawalResponse struct {
-	Order       string  `json:"order"`
-	Sum         float64 `json:"sum"`
-	ProcessedAt string  `json:"processed_at"`
+	Order       string      `json:"order"`
+	Sum         json.Number `json:"sum"`
+	ProcessedAt string      `json:"processed_at"`
+}
+
+// Состояния резерва баллов под списание
+const (
+	HoldHeld     = "HELD"     // Баллы зарезервированы
+	HoldCaptured = "CAPTURED" // Резерв списан
+	HoldReleased = "RELEASED" // Резерв снят
+	HoldExpired  = "EXPIRED"  // Резерв снят по истечении времени
+)
+
+// HoldData - модель резерва баллов под списание за заказ.
+// Зарезервированные баллы недоступны для других списаний до списания или снятия резерва.
+type HoldData struct {
+	ID          int64
+	UserID      string
+	OrderNumber string
+	Amount      decimal.Decimal
+	Status      string
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// HoldResponse - модель резерва баллов для выдачи
+type HoldResponse struct {
+	ID        int64       `json:"id"`
+	Order     string      `json:"order"`
+	Sum       json.Number `json:"sum"`
+	Status    string      `json:"status"`
+	CreatedAt string      `json:"created_at"`
+	ExpiresAt string      `json:"expires_at"`
 }
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/shopspring/decimal"
@@ -17,10 +18,36 @@ const (
 
 // OrderResponse - модель заказа пользователя для выдачи
 type OrderResponse struct {
-	Number     string  `json:"number"`
-	Status     string  `json:"status"`
-	Accrual    float64 `json:"accrual,omitempty"`
-	UploadedAt string  `json:"uploaded_at"`
+	Number     string      `json:"number"`
+	Status     string      `json:"status"`
+	Accrual    json.Number `json:"accrual,omitempty"`
+	UploadedAt string      `json:"uploaded_at"`
+}
+
+// RequeueRequest - модель запроса повторной постановки заказа в очередь обработки
+type RequeueRequest struct {
+	Priority int `json:"priority"`
+}
+
+// UserPriorityRequest - модель запроса изменения приоритета пользователя в очереди обработки
+type UserPriorityRequest struct {
+	Priority int `json:"priority"`
+}
+
+// OrderFailure - модель ошибки запроса начисления по заказу
+type OrderFailure struct {
+	Class       string        // Класс ошибки сервиса начислений
+	Detail      string        // Текст ошибки
+	MaxAttempts int           // Подряд идущих неудачных запросов до перевода в конечный статус
+	FinalStatus string        // Конечный статус заказа
+	Backoff     time.Duration // Отсрочка следующей попытки, удваивается с каждой ошибкой
+	MaxBackoff  time.Duration // Наибольшая отсрочка
+}
+
+// OrderOwner - модель владельца заказа для выбора провайдера начислений
+type OrderOwner struct {
+	Login    string // Логин пользователя, загрузившего заказ
+	Merchant string // Продавец, указанный при загрузке заказа
 }
 
 // Order - модель заказа пользователя
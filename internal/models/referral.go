@@ -0,0 +1,54 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	ReferralPending  = "PENDING"  // Ожидается первый обработанный заказ приглашённого
+	ReferralRewarded = "REWARDED" // Бонусы начислены обоим пользователям
+	ReferralRejected = "REJECTED" // Первый заказ не прошёл условия программы
+	ReferralLimited  = "LIMITED"  // Превышено число приглашений с бонусом
+)
+
+// ReferralTerms - код приглашения и условия программы на момент регистрации приглашённого
+type ReferralTerms struct {
+	Code          string
+	ReferrerBonus decimal.Decimal
+	ReferredBonus decimal.Decimal
+	MinAccrual    decimal.Decimal
+	MaxReferrals  int // 0 - без ограничения
+}
+
+// ReferralData - модель приглашённого пользователя из хранилища
+type ReferralData struct {
+	Login       string
+	Status      string
+	Bonus       decimal.Decimal // Бонус пригласившему
+	CreatedAt   time.Time
+	CompletedAt time.Time // Нулевое время - приглашение не завершено
+}
+
+// Referrals - код приглашения пользователя и приглашённые по нему
+type Referrals struct {
+	Code      string
+	Referrals []ReferralData
+}
+
+// ReferralResponse - модель приглашённого пользователя для выдачи
+type ReferralResponse struct {
+	Login       string      `json:"login"`
+	Status      string      `json:"status"`
+	Bonus       json.Number `json:"bonus"`
+	CreatedAt   string      `json:"created_at"`
+	CompletedAt string      `json:"completed_at,omitempty"`
+}
+
+// ReferralsResponse - модель приглашений пользователя для выдачи
+type ReferralsResponse struct {
+	Code      string             `json:"code"`
+	Referrals []ReferralResponse `json:"referrals"`
+}
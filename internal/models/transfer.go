@@ -0,0 +1,39 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// TransferRequest - модель запроса перевода баллов другому пользователю
+type TransferRequest struct {
+	To      string          `json:"to"`
+	Amount  decimal.Decimal `json:"sum"`
+	Comment string          `json:"comment"`
+}
+
+// TransferData - модель перевода баллов между пользователями.
+// Отправитель платит сумму перевода и комиссию, получатель получает сумму перевода.
+type TransferData struct {
+	ID         int64
+	FromUserID string
+	ToUserID   string
+	FromLogin  string
+	ToLogin    string
+	Amount     decimal.Decimal
+	Fee        decimal.Decimal
+	Comment    string
+	CreatedAt  time.Time
+}
+
+// TransferResponse - модель перевода баллов для выдачи
+type TransferResponse struct {
+	ID        int64       `json:"id"`
+	To        string      `json:"to"`
+	Sum       json.Number `json:"sum"`
+	Fee       json.Number `json:"fee"`
+	Comment   string      `json:"comment,omitempty"`
+	CreatedAt string      `json:"created_at"`
+}
@@ -1,11 +1,16 @@
 package models
 
-import "github.com/shopspring/decimal"
+import (
+	"encoding/json"
+
+	"github.com/shopspring/decimal"
+)
 
 // UserRequest - модель для регистрации и аутентификации пользователя, приходит извне
 type UserRequest struct {
-	Login    string `json:"login"`
-	Password string `json:"password"`
+	Login        string `json:"login"`
+	Password     string `json:"password"`
+	ReferralCode string `json:"referral_code,omitempty"` // Код приглашения другого пользователя
 }
 
 // UserData - модель пользователя из хранищища
@@ -14,10 +19,29 @@ type UserData struct {
 	Login        string
 	PasswordHash string
 	Balance      decimal.Decimal
+	ReferralCode string // Код приглашения пользователя
 }
 
 // UserBalance - модель баланса пользователя
 type UserBalance struct {
-	Current   float64 `json:"current"`   // Текущий баланс пользователя
-	Withdrawn float64 `json:"withdrawn"` // Общая сумма выведенных средств
+	Current   decimal.Decimal  // Доступный баланс пользователя без зарезервированных баллов
+	Held      decimal.Decimal  // Баллы, зарезервированные под незавершённые списания
+	Tier      string           // Уровень участника, пусто - без уровня
+	Withdrawn decimal.Decimal  // Общая сумма выведенных средств
+	Expiring  []ExpiringPoints // Баллы, которые скоро сгорят
+}
+
+// UserBalanceResponse - модель баланса пользователя для выдачи
+type UserBalanceResponse struct {
+	Current   json.Number              `json:"current"`            // Доступный баланс пользователя
+	Held      json.Number              `json:"held"`               // Зарезервированные баллы
+	Tier      string                   `json:"tier,omitempty"`     // Уровень участника
+	Withdrawn json.Number              `json:"withdrawn"`          // Общая сумма выведенных средств
+	Expiring  []ExpiringPointsResponse `json:"expiring,omitempty"` // Баллы, которые скоро сгорят
+}
+
+// Tier - уровень участника с порогом суммы начислений за период
+type Tier struct {
+	Name      string
+	Threshold decimal.Decimal
 }
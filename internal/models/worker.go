@@ -0,0 +1,20 @@
+package models
+
+// Состояния воркера обработки заказов
+const (
+	WorkerStateRunning  = "running"
+	WorkerStatePaused   = "paused"
+	WorkerStateDraining = "draining"
+	WorkerStateDrained  = "drained"
+)
+
+// WorkerStatus - модель состояния воркера обработки заказов для выдачи
+type WorkerStatus struct {
+	State       string          `json:"state"`                   // Состояние воркера: running, paused, draining или drained
+	Leader      bool            `json:"leader"`                  // Выполняет ли экземпляр опрос сервиса начислений
+	Breaker     string          `json:"breaker"`                 // Обобщённое состояние circuit breaker: open, если разомкнут хотя бы один
+	Breakers    []BreakerStatus `json:"breakers"`                // Состояние circuit breaker по адресам сервисов начислений
+	LastBatchAt string          `json:"last_batch_at,omitempty"` // Время последней обработки пачки заказов
+	Queue       map[string]int  `json:"queue"`                   // Количество заказов по статусам
+	RateLimit   *float64        `json:"rate_limit"`              // Ограничение запросов в секунду, null - без ограничений
+}
@@ -3,7 +3,9 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/denmor86/ya-gophermart/internal/helpers"
@@ -11,12 +13,11 @@ import (
 	"github.com/denmor86/ya-gophermart/internal/models"
 	"github.com/denmor86/ya-gophermart/internal/services"
 	"github.com/denmor86/ya-gophermart/internal/validators"
-	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 )
 
-// GetOrdersHandler — получение списка покупок пользователя
-func GetUserBalanceHandler(l services.LoyaltyService) http.HandlerFunc {
+// GetUserBalanceHandler — получение баланса пользователя
+func GetUserBalanceHandler(l services.LoyaltyService, money MoneyFormat) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// получение данных о пользователе
 		username, err := helpers.GetUsername(r.Context())
@@ -25,15 +26,97 @@ func GetUserBalanceHandler(l services.LoyaltyService) http.HandlerFunc {
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
-		balance, err := l.GetBalance(r.Context(), username)
+		// баланс на момент времени, по умолчанию текущий
+		var balance *models.UserBalance
+		if value := r.URL.Query().Get("at"); value != "" {
+			at, parseErr := time.Parse(time.RFC3339, value)
+			if parseErr != nil {
+				http.Error(w, "Invalid at parameter", http.StatusBadRequest)
+				return
+			}
+			balance, err = l.GetBalanceAt(r.Context(), username, at)
+		} else {
+			balance, err = l.GetBalance(r.Context(), username)
+		}
 		if err != nil {
 			logger.Error("Failed to get user balance:", zap.Error(err))
 			http.Error(w, "Server Error", http.StatusInternalServerError)
 			return
 		}
 
+		response := models.UserBalanceResponse{
+			Current:   money(balance.Current),
+			Held:      money(balance.Held),
+			Tier:      balance.Tier,
+			Withdrawn: money(balance.Withdrawn),
+		}
+		for _, expiring := range balance.Expiring {
+			response.Expiring = append(response.Expiring, models.ExpiringPointsResponse{
+				Amount:    money(expiring.Amount),
+				ExpiresAt: expiring.ExpiresAt.Format(time.RFC3339),
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(response)
+		if err != nil {
+			logger.Error("Failed to encode JSON response:", zap.Error(err))
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// Размер страницы истории баланса
+const (
+	DefaultHistoryLimit = 100
+	MaxHistoryLimit     = 1000
+)
+
+// GetBalanceHistoryHandler — получение движений баллов пользователя с балансом после каждого движения.
+// Параметры: from и to (RFC3339) - период, limit и offset - страница.
+func GetBalanceHistoryHandler(l services.LoyaltyService, money MoneyFormat) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// получение данных о пользователе
+		username, err := helpers.GetUsername(r.Context())
+		if err != nil {
+			logger.Warn("Failed to get username:", zap.Error(err))
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		filter, err := parseHistoryFilter(r)
+		if err != nil {
+			logger.Warn("Invalid history parameters:", zap.Error(err))
+			http.Error(w, "Invalid history parameters", http.StatusBadRequest)
+			return
+		}
+		history, err := l.GetBalanceHistory(r.Context(), username, filter)
+		if err != nil {
+			logger.Error("Failed to get balance history:", zap.Error(err))
+			http.Error(w, "Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		if len(history) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		response := make([]models.BalanceHistoryResponse, 0, len(history))
+		for _, entry := range history {
+			response = append(response, models.BalanceHistoryResponse{
+				ID:          entry.ID,
+				Kind:        entry.Kind,
+				Amount:      money(entry.Amount),
+				Balance:     money(entry.Balance),
+				OrderNumber: entry.OrderNumber,
+				Comment:     entry.Comment,
+				CreatedAt:   entry.CreatedAt.Format(time.RFC3339),
+			})
+		}
+
 		w.Header().Set("Content-Type", "application/json")
-		err = json.NewEncoder(w).Encode(balance)
+		err = json.NewEncoder(w).Encode(response)
 		if err != nil {
 			logger.Error("Failed to encode JSON response:", zap.Error(err))
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -42,6 +125,33 @@ func GetUserBalanceHandler(l services.LoyaltyService) http.HandlerFunc {
 	})
 }
 
+func parseHistoryFilter(r *http.Request) (models.BalanceHistoryFilter, error) {
+	query := r.URL.Query()
+	filter := models.BalanceHistoryFilter{Limit: DefaultHistoryLimit}
+	var err error
+	if value := query.Get("from"); value != "" {
+		if filter.From, err = time.Parse(time.RFC3339, value); err != nil {
+			return filter, fmt.Errorf("from: %w", err)
+		}
+	}
+	if value := query.Get("to"); value != "" {
+		if filter.To, err = time.Parse(time.RFC3339, value); err != nil {
+			return filter, fmt.Errorf("to: %w", err)
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		if filter.Limit, err = strconv.Atoi(value); err != nil || filter.Limit <= 0 || filter.Limit > MaxHistoryLimit {
+			return filter, fmt.Errorf("limit must be from 1 to %d", MaxHistoryLimit)
+		}
+	}
+	if value := query.Get("offset"); value != "" {
+		if filter.Offset, err = strconv.Atoi(value); err != nil || filter.Offset < 0 {
+			return filter, errors.New("offset must be non-negative")
+		}
+	}
+	return filter, nil
+}
+
 // WithdrawHandler — Запрос на списание средств
 func WithdrawHandler(l services.LoyaltyService) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -63,11 +173,15 @@ func WithdrawHandler(l services.LoyaltyService) http.HandlerFunc {
 			http.Error(w, "Invalid order number format", http.StatusUnprocessableEntity)
 			return
 		}
-		err = l.ProcessWithdraw(r.Context(), username, req.OrderNumber, decimal.NewFromFloat(req.Withdrawn))
+		err = l.ProcessWithdraw(r.Context(), username, req.OrderNumber, req.Withdrawn)
 		if err != nil {
 			switch {
 			case errors.Is(err, services.ErrInsufficientFunds):
 				http.Error(w, "Insufficient funds", http.StatusPaymentRequired)
+			case errors.Is(err, services.ErrWithdrawalAlreadyExists):
+				http.Error(w, "Order already paid with points", http.StatusConflict)
+			case errors.Is(err, services.ErrInvalidWithdrawalAmount):
+				http.Error(w, "Invalid withdrawal amount", http.StatusUnprocessableEntity)
 			default:
 				logger.Error("Failed to process withdrawal:", zap.Error(err))
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -80,7 +194,7 @@ func WithdrawHandler(l services.LoyaltyService) http.HandlerFunc {
 }
 
 // GetWithdrawHandler — получение информации о выводе средств с накопительного счёта пользователем.
-func GetWithdrawHandler(l services.LoyaltyService) http.HandlerFunc {
+func GetWithdrawHandler(l services.LoyaltyService, money MoneyFormat) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// получение данных о пользователе
 		username, err := helpers.GetUsername(r.Context())
@@ -103,10 +217,9 @@ func GetWithdrawHandler(l services.LoyaltyService) http.HandlerFunc {
 
 		var response []models.WithdrawalResponse
 		for _, w := range withdrawals {
-			floatAmount, _ := w.Amount.Float64()
 			item := models.WithdrawalResponse{
 				Order:       w.OrderNumber,
-				Sum:         floatAmount,
+				Sum:         money(w.Amount),
 				ProcessedAt: w.ProcessedAt.Format(time.RFC3339),
 			}
 			response = append(response, item)
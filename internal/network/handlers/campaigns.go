@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/denmor86/ya-gophermart/internal/services"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// GetCampaignsHandler — список промо-кампаний
+func GetCampaignsHandler(c services.CampaignService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		campaigns, err := c.GetCampaigns(r.Context())
+		if err != nil {
+			logger.Error("Failed to get campaigns:", zap.Error(err))
+			http.Error(w, "Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		response := make([]models.CampaignResponse, 0, len(campaigns))
+		for _, campaign := range campaigns {
+			response = append(response, campaignResponse(campaign))
+		}
+		writeJSON(w, http.StatusOK, response)
+	})
+}
+
+// AddCampaignHandler — создание промо-кампании
+func AddCampaignHandler(c services.CampaignService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req models.CampaignRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			logger.Warn("Invalid request format:", zap.Error(err))
+			http.Error(w, "Invalid request format", http.StatusBadRequest)
+			return
+		}
+
+		campaign, err := c.AddCampaign(r.Context(), req)
+		if err != nil {
+			writeCampaignError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, campaignResponse(*campaign))
+	})
+}
+
+// UpdateCampaignHandler — изменение промо-кампании
+func UpdateCampaignHandler(c services.CampaignService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid campaign id", http.StatusBadRequest)
+			return
+		}
+		var req models.CampaignRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			logger.Warn("Invalid request format:", zap.Error(err))
+			http.Error(w, "Invalid request format", http.StatusBadRequest)
+			return
+		}
+
+		campaign, err := c.UpdateCampaign(r.Context(), id, req)
+		if err != nil {
+			writeCampaignError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, campaignResponse(*campaign))
+	})
+}
+
+// DeactivateCampaignHandler — остановка промо-кампании
+func DeactivateCampaignHandler(c services.CampaignService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid campaign id", http.StatusBadRequest)
+			return
+		}
+		if err := c.DeactivateCampaign(r.Context(), id); err != nil {
+			writeCampaignError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func writeCampaignError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidCampaign):
+		http.Error(w, "Invalid campaign", http.StatusUnprocessableEntity)
+	case errors.Is(err, services.ErrCampaignNotFound):
+		http.Error(w, "Campaign not found", http.StatusNotFound)
+	default:
+		logger.Error("Failed to process campaign:", zap.Error(err))
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+	}
+}
+
+func campaignResponse(campaign models.Campaign) models.CampaignResponse {
+	response := models.CampaignResponse{
+		ID:         campaign.ID,
+		Name:       campaign.Name,
+		StartsAt:   campaign.StartsAt.Format(time.RFC3339),
+		Active:     campaign.Active,
+		Conditions: campaign.Conditions,
+		Bonus:      campaign.Bonus,
+		CreatedAt:  campaign.CreatedAt.Format(time.RFC3339),
+	}
+	if !campaign.EndsAt.IsZero() {
+		response.EndsAt = campaign.EndsAt.Format(time.RFC3339)
+	}
+	return response
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/helpers"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/denmor86/ya-gophermart/internal/services"
+	"github.com/denmor86/ya-gophermart/internal/validators"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// PlaceHoldHandler — резервирование баллов под списание за заказ
+func PlaceHoldHandler(l services.LoyaltyService, money MoneyFormat) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// получение данных о пользователе
+		username, err := helpers.GetUsername(r.Context())
+		if err != nil {
+			logger.Warn("Failed to get username:", zap.Error(err))
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		var req models.WithdrawalRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			logger.Warn("Invalid request format:", zap.Error(err))
+			http.Error(w, "Invalid request format", http.StatusBadRequest)
+			return
+		}
+		if !validators.CheckNumber(req.OrderNumber) {
+			logger.Warn("Invalid order number format", req.OrderNumber)
+			http.Error(w, "Invalid order number format", http.StatusUnprocessableEntity)
+			return
+		}
+		hold, err := l.PlaceHold(r.Context(), username, req.OrderNumber, req.Withdrawn)
+		if err != nil {
+			writeHoldError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, holdResponse(*hold, money))
+	})
+}
+
+// CaptureHoldHandler — списание зарезервированных баллов
+func CaptureHoldHandler(l services.LoyaltyService, money MoneyFormat) http.HandlerFunc {
+	return completeHoldHandler(l.CaptureHold, money)
+}
+
+// ReleaseHoldHandler — снятие резерва баллов
+func ReleaseHoldHandler(l services.LoyaltyService, money MoneyFormat) http.HandlerFunc {
+	return completeHoldHandler(l.ReleaseHold, money)
+}
+
+func completeHoldHandler(complete func(ctx context.Context, login string, id int64) (*models.HoldData, error), money MoneyFormat) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// получение данных о пользователе
+		username, err := helpers.GetUsername(r.Context())
+		if err != nil {
+			logger.Warn("Failed to get username:", zap.Error(err))
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid hold id", http.StatusBadRequest)
+			return
+		}
+		hold, err := complete(r.Context(), username, id)
+		if err != nil {
+			writeHoldError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, holdResponse(*hold, money))
+	})
+}
+
+func writeHoldError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInsufficientFunds):
+		http.Error(w, "Insufficient funds", http.StatusPaymentRequired)
+	case errors.Is(err, services.ErrWithdrawalAlreadyExists):
+		http.Error(w, "Order already paid with points", http.StatusConflict)
+	case errors.Is(err, services.ErrInvalidWithdrawalAmount):
+		http.Error(w, "Invalid withdrawal amount", http.StatusUnprocessableEntity)
+	case errors.Is(err, services.ErrHoldNotFound):
+		http.Error(w, "Hold not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrHoldNotActive):
+		http.Error(w, "Hold is not active", http.StatusConflict)
+	default:
+		logger.Error("Failed to process hold:", zap.Error(err))
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+func holdResponse(hold models.HoldData, money MoneyFormat) models.HoldResponse {
+	return models.HoldResponse{
+		ID:        hold.ID,
+		Order:     hold.OrderNumber,
+		Sum:       money(hold.Amount),
+		Status:    hold.Status,
+		CreatedAt: hold.CreatedAt.Format(time.RFC3339),
+		ExpiresAt: hold.ExpiresAt.Format(time.RFC3339),
+	}
+}
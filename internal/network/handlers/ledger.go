@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/denmor86/ya-gophermart/internal/services"
+	"github.com/denmor86/ya-gophermart/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// GetUserLedgerHandler — получение журнала баллов пользователя
+func GetUserLedgerHandler(l services.LedgerService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entries, err := l.GetLedger(r.Context(), chi.URLParam(r, "login"))
+		if err != nil {
+			switch {
+			case errors.Is(err, storage.ErrUserNotFound):
+				http.Error(w, "User not found", http.StatusNotFound)
+			default:
+				logger.Error("Failed to get ledger:", zap.Error(err))
+				http.Error(w, "Server Error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		response := make([]models.LedgerEntryResponse, 0, len(entries))
+		for _, entry := range entries {
+			response = append(response, ledgerEntryResponse(entry))
+		}
+		writeJSON(w, http.StatusOK, response)
+	})
+}
+
+// AddAdjustmentHandler — корректировка баланса пользователя записью журнала
+func AddAdjustmentHandler(l services.LedgerService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req models.AdjustmentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			logger.Warn("Invalid request format:", zap.Error(err))
+			http.Error(w, "Invalid request format", http.StatusBadRequest)
+			return
+		}
+
+		entry, err := l.AddAdjustment(r.Context(), req.Login, req.Amount, req.Comment)
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrInvalidAdjustmentAmount):
+				http.Error(w, "Invalid adjustment amount", http.StatusUnprocessableEntity)
+			case errors.Is(err, services.ErrInsufficientFunds):
+				http.Error(w, "Insufficient funds", http.StatusConflict)
+			case errors.Is(err, storage.ErrUserNotFound):
+				http.Error(w, "User not found", http.StatusNotFound)
+			default:
+				logger.Error("Failed to add adjustment:", zap.Error(err))
+				http.Error(w, "Server Error", http.StatusInternalServerError)
+			}
+			return
+		}
+		writeJSON(w, http.StatusCreated, ledgerEntryResponse(*entry))
+	})
+}
+
+// ReverseLedgerEntryHandler — отмена записи журнала встречной записью
+func ReverseLedgerEntryHandler(l services.LedgerService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid ledger entry id", http.StatusBadRequest)
+			return
+		}
+
+		// тело запроса необязательно
+		var req models.ReversalRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			logger.Warn("Invalid request format:", zap.Error(err))
+			http.Error(w, "Invalid request format", http.StatusBadRequest)
+			return
+		}
+
+		entry, err := l.ReverseEntry(r.Context(), id, req.Comment)
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrLedgerEntryNotFound):
+				http.Error(w, "Ledger entry not found", http.StatusNotFound)
+			case errors.Is(err, services.ErrLedgerEntryReversed):
+				http.Error(w, "Ledger entry already reversed", http.StatusConflict)
+			case errors.Is(err, services.ErrInsufficientFunds):
+				http.Error(w, "Insufficient funds", http.StatusConflict)
+			default:
+				logger.Error("Failed to reverse ledger entry:", zap.Error(err))
+				http.Error(w, "Server Error", http.StatusInternalServerError)
+			}
+			return
+		}
+		writeJSON(w, http.StatusCreated, ledgerEntryResponse(*entry))
+	})
+}
+
+// CheckLedgerHandler — проверка совпадения балансов пользователей с журналом
+func CheckLedgerHandler(l services.LedgerService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		check, err := l.CheckLedger(r.Context())
+		if err != nil {
+			http.Error(w, "Server Error", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, check)
+	})
+}
+
+// RebuildBalancesHandler — пересчёт балансов пользователей по журналу
+func RebuildBalancesHandler(l services.LedgerService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count, err := l.RebuildBalances(r.Context())
+		if err != nil {
+			http.Error(w, "Server Error", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, struct {
+			Updated int64 `json:"updated"`
+		}{Updated: count})
+	})
+}
+
+// RecalculateTiersHandler — внеплановый пересчёт уровней пользователей
+func RecalculateTiersHandler(t services.TierService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count, err := t.RecalculateTiers(r.Context(), time.Now())
+		if err != nil {
+			http.Error(w, "Server Error", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, struct {
+			Updated int64 `json:"updated"`
+		}{Updated: count})
+	})
+}
+
+func ledgerEntryResponse(entry models.LedgerEntry) models.LedgerEntryResponse {
+	return models.LedgerEntryResponse{
+		ID:          entry.ID,
+		UserID:      entry.UserID,
+		Kind:        entry.Kind,
+		Amount:      MoneyExact(entry.Amount),
+		OrderNumber: entry.OrderNumber,
+		ReversalOf:  entry.ReversalOf,
+		CampaignID:  entry.CampaignID,
+		Comment:     entry.Comment,
+		CreatedAt:   entry.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		logger.Error("Failed to encode JSON response:", zap.Error(err))
+	}
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/shopspring/decimal"
+)
+
+// MoneyFormat - представление денежной суммы в ответах API
+type MoneyFormat func(value decimal.Decimal) json.Number
+
+// MoneyFloat - сумма числом двойной точности, как в первой версии API
+func MoneyFloat(value decimal.Decimal) json.Number {
+	return json.Number(strconv.FormatFloat(value.InexactFloat64(), 'f', -1, 64))
+}
+
+// MoneyExact - точная десятичная запись суммы, API /api/v2
+func MoneyExact(value decimal.Decimal) json.Number {
+	return json.Number(value.String())
+}
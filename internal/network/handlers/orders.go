@@ -12,11 +12,20 @@ import (
 	"github.com/denmor86/ya-gophermart/internal/logger"
 	"github.com/denmor86/ya-gophermart/internal/models"
 	"github.com/denmor86/ya-gophermart/internal/services"
+	"github.com/denmor86/ya-gophermart/internal/storage"
 	"github.com/denmor86/ya-gophermart/internal/validators"
+	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 )
 
-// OrdersHandler — обработчик совершения покупки пользователем
+// Продавец заказа, необязательный заголовок запроса загрузки заказа
+const (
+	MerchantHeader    = "X-Merchant"
+	MaxMerchantLength = 255
+)
+
+// OrdersHandler — обработчик совершения покупки пользователем.
+// Продавец из заголовка X-Merchant используется для выбора провайдера начислений.
 func OrdersHandler(o services.OrdersService) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// получение данных о пользователе
@@ -41,7 +50,14 @@ func OrdersHandler(o services.OrdersService) http.HandlerFunc {
 			return
 		}
 
-		err = o.AddOrder(r.Context(), username, orderNumber)
+		merchant := strings.TrimSpace(r.Header.Get(MerchantHeader))
+		if len(merchant) > MaxMerchantLength {
+			logger.Warn("Invalid merchant", merchant)
+			http.Error(w, "Invalid merchant", http.StatusBadRequest)
+			return
+		}
+
+		err = o.AddOrder(r.Context(), username, orderNumber, merchant)
 		if err != nil {
 			switch {
 			case errors.Is(err, services.ErrOrderAlreadyUploaded):
@@ -61,7 +77,7 @@ func OrdersHandler(o services.OrdersService) http.HandlerFunc {
 }
 
 // GetOrdersHandler — получение списка покупок пользователя
-func GetOrdersHandler(o services.OrdersService) http.HandlerFunc {
+func GetOrdersHandler(o services.OrdersService, money MoneyFormat) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// получение данных о пользователе
 		username, err := helpers.GetUsername(r.Context())
@@ -88,9 +104,8 @@ func GetOrdersHandler(o services.OrdersService) http.HandlerFunc {
 				Status:     order.Status,
 				UploadedAt: order.UploadedAt.Format(time.RFC3339),
 			}
-			if order.Status == models.OrderStatusProcessed {
-				value, _ := order.Accrual.Float64()
-				item.Accrual = value
+			if order.Status == models.OrderStatusProcessed && !order.Accrual.IsZero() {
+				item.Accrual = money(order.Accrual)
 			}
 			response = append(response, item)
 		}
@@ -104,3 +119,57 @@ func GetOrdersHandler(o services.OrdersService) http.HandlerFunc {
 		}
 	})
 }
+
+// RequeueOrderHandler — повторная постановка заказа в очередь обработки
+func RequeueOrderHandler(o services.OrdersService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		orderNumber := chi.URLParam(r, "number")
+
+		// тело запроса необязательно, по умолчанию приоритет нулевой
+		var req models.RequeueRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			logger.Warn("Invalid request format:", zap.Error(err))
+			http.Error(w, "Invalid request format", http.StatusBadRequest)
+			return
+		}
+
+		err := o.RequeueOrder(r.Context(), orderNumber, req.Priority)
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrOrderNotFound):
+				http.Error(w, "Order not found", http.StatusNotFound)
+			case errors.Is(err, services.ErrOrderFinalized):
+				http.Error(w, "Order is in final status", http.StatusConflict)
+			default:
+				logger.Error("Failed to requeue order:", zap.Error(err))
+				http.Error(w, "Server Error", http.StatusInternalServerError)
+			}
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+// SetUserPriorityHandler — изменение приоритета пользователя в очереди обработки заказов
+func SetUserPriorityHandler(o services.OrdersService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req models.UserPriorityRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			logger.Warn("Invalid request format:", zap.Error(err))
+			http.Error(w, "Invalid request format", http.StatusBadRequest)
+			return
+		}
+
+		err := o.SetUserPriority(r.Context(), chi.URLParam(r, "login"), req.Priority)
+		if err != nil {
+			switch {
+			case errors.Is(err, storage.ErrUserNotFound):
+				http.Error(w, "User not found", http.StatusNotFound)
+			default:
+				http.Error(w, "Server Error", http.StatusInternalServerError)
+			}
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
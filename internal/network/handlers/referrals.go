@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/helpers"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/denmor86/ya-gophermart/internal/services"
+	"go.uber.org/zap"
+)
+
+// GetReferralsHandler — код приглашения пользователя и приглашённые им пользователи со статусом приглашения
+func GetReferralsHandler(s services.ReferralService, money MoneyFormat) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// получение данных о пользователе
+		username, err := helpers.GetUsername(r.Context())
+		if err != nil {
+			logger.Warn("Failed to get username:", zap.Error(err))
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		referrals, err := s.GetReferrals(r.Context(), username)
+		if err != nil {
+			logger.Error("Failed to get user referrals:", zap.Error(err))
+			http.Error(w, "Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		response := models.ReferralsResponse{
+			Code:      referrals.Code,
+			Referrals: make([]models.ReferralResponse, 0, len(referrals.Referrals)),
+		}
+		for _, referral := range referrals.Referrals {
+			item := models.ReferralResponse{
+				Login:     referral.Login,
+				Status:    referral.Status,
+				Bonus:     money(referral.Bonus),
+				CreatedAt: referral.CreatedAt.Format(time.RFC3339),
+			}
+			if !referral.CompletedAt.IsZero() {
+				item.CompletedAt = referral.CompletedAt.Format(time.RFC3339)
+			}
+			response.Referrals = append(response.Referrals, item)
+		}
+		writeJSON(w, http.StatusOK, response)
+	})
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/denmor86/ya-gophermart/internal/leader"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"go.uber.org/zap"
+)
+
+// GetLeaderStatusHandler — получение состояния выбора лидера для текущего экземпляра
+func GetLeaderStatusHandler(e leader.Elector) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(e.Status())
+		if err != nil {
+			logger.Error("Failed to encode JSON response:", zap.Error(err))
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	})
+}
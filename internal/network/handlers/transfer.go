@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/helpers"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/denmor86/ya-gophermart/internal/services"
+	"go.uber.org/zap"
+)
+
+// TransferHandler — перевод баллов другому пользователю
+func TransferHandler(t services.TransferService, money MoneyFormat) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// получение данных о пользователе
+		username, err := helpers.GetUsername(r.Context())
+		if err != nil {
+			logger.Warn("Failed to get username:", zap.Error(err))
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		var req models.TransferRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.To == "" {
+			logger.Warn("Invalid request format:", zap.Error(err))
+			http.Error(w, "Invalid request format", http.StatusBadRequest)
+			return
+		}
+
+		transfer, err := t.Transfer(r.Context(), username, req.To, req.Amount, req.Comment)
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrInsufficientFunds):
+				http.Error(w, "Insufficient funds", http.StatusPaymentRequired)
+			case errors.Is(err, services.ErrRecipientNotFound):
+				http.Error(w, "Recipient not found", http.StatusNotFound)
+			case errors.Is(err, services.ErrInvalidTransferAmount):
+				http.Error(w, "Invalid transfer amount", http.StatusUnprocessableEntity)
+			case errors.Is(err, services.ErrTransferLimitExceeded):
+				http.Error(w, "Transfer limit exceeded", http.StatusUnprocessableEntity)
+			case errors.Is(err, services.ErrSelfTransfer):
+				http.Error(w, "Transfer to self", http.StatusUnprocessableEntity)
+			default:
+				logger.Error("Failed to transfer points:", zap.Error(err))
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		writeJSON(w, http.StatusOK, models.TransferResponse{
+			ID:        transfer.ID,
+			To:        transfer.ToLogin,
+			Sum:       money(transfer.Amount),
+			Fee:       money(transfer.Fee),
+			Comment:   transfer.Comment,
+			CreatedAt: transfer.CreatedAt.Format(time.RFC3339),
+		})
+	})
+}
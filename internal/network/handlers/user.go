@@ -28,6 +28,9 @@ func RegisterUserHandler(i services.IdentityService) http.HandlerFunc {
 			if errors.Is(err, services.ErrUserAlreadyExists) {
 				logger.Warn("Error register user:", user.Login)
 				http.Error(w, "login already exist", http.StatusConflict)
+			} else if errors.Is(err, services.ErrInvalidReferralCode) {
+				// код приглашения не найден
+				http.Error(w, "Invalid referral code", http.StatusUnprocessableEntity)
 			} else {
 				// ошибка регистрации
 				logger.Error("Error register user:", zap.Error(err))
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/worker"
+	"go.uber.org/zap"
+)
+
+// GetWorkerStatusHandler — получение состояния воркера обработки заказов
+func GetWorkerStatusHandler(c worker.Controller) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status, err := c.Status(r.Context())
+		if err != nil {
+			logger.Error("Failed to get worker status:", zap.Error(err))
+			http.Error(w, "Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(status)
+		if err != nil {
+			logger.Error("Failed to encode JSON response:", zap.Error(err))
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// GetBreakersHandler — получение состояния circuit breaker сервисов начислений и последних смен состояний
+func GetBreakersHandler(c worker.Controller) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(c.Breakers())
+		if err != nil {
+			logger.Error("Failed to encode JSON response:", zap.Error(err))
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// PauseWorkerHandler — приостановка опроса сервиса начислений
+func PauseWorkerHandler(c worker.Controller) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Pause()
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// ResumeWorkerHandler — возобновление опроса сервиса начислений
+func ResumeWorkerHandler(c worker.Controller) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Resume()
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// DrainTimeout - наибольшее время ожидания завершения обработки захваченных заказов
+const DrainTimeout = 2 * time.Minute
+
+// DrainWorkerHandler — остановка захвата заказов и ожидание обработки захваченных.
+// Возвращает состояние воркера после завершения, захват возобновляется командой resume
+func DrainWorkerHandler(c worker.Controller) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), DrainTimeout)
+		defer cancel()
+
+		status, err := c.Drain(ctx)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				logger.Warn("Worker drain timed out:", zap.Error(err))
+				http.Error(w, "Drain timed out, orders still in flight", http.StatusGatewayTimeout)
+				return
+			}
+			logger.Error("Failed to drain worker:", zap.Error(err))
+			http.Error(w, "Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(status)
+		if err != nil {
+			logger.Error("Failed to encode JSON response:", zap.Error(err))
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// TriggerWorkerHandler — немедленный запуск обработки пачки заказов
+func TriggerWorkerHandler(c worker.Controller) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Trigger()
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+// ResetBreakerHandler — принудительный сброс circuit breaker
+func ResetBreakerHandler(c worker.Controller) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.ResetBreaker()
+		w.WriteHeader(http.StatusOK)
+	})
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/denmor86/ya-gophermart/internal/config"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/denmor86/ya-gophermart/internal/worker/mocks"
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/mock/gomock"
+)
+
+func TestWorkerHandlers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockWorker := mocks.NewMockController(ctrl)
+
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+
+	status := &models.WorkerStatus{
+		State:   models.WorkerStateRunning,
+		Leader:  true,
+		Breaker: "closed",
+		Queue:   map[string]int{models.OrderStatusNew: 2},
+	}
+	drained := &models.WorkerStatus{
+		State:   models.WorkerStateDrained,
+		Leader:  true,
+		Breaker: "closed",
+		Queue:   map[string]int{models.OrderStatusNew: 2},
+	}
+	breakers := models.BreakersStatus{
+		Breakers: []models.BreakerStatus{{Host: "accrual:8080", State: "open", Trips: 1}},
+		Events:   []models.BreakerEvent{{Host: "accrual:8080", From: "closed", To: "open"}},
+	}
+
+	testCases := []struct {
+		Name             string
+		Handler          http.HandlerFunc
+		Method           string
+		SetupMocks       func()
+		ExpectedCode     int
+		ExpectedStatus   *models.WorkerStatus
+		ExpectedBreakers *models.BreakersStatus
+	}{
+		{
+			Name:    "Success. Status #1",
+			Handler: GetWorkerStatusHandler(mockWorker),
+			Method:  http.MethodGet,
+			SetupMocks: func() {
+				mockWorker.EXPECT().Status(gomock.Any()).Return(status, nil)
+			},
+			ExpectedCode:   http.StatusOK,
+			ExpectedStatus: status,
+		},
+		{
+			Name:    "Error. Status failure #2",
+			Handler: GetWorkerStatusHandler(mockWorker),
+			Method:  http.MethodGet,
+			SetupMocks: func() {
+				mockWorker.EXPECT().Status(gomock.Any()).Return(nil, errors.New("failed to count orders"))
+			},
+			ExpectedCode: http.StatusInternalServerError,
+		},
+		{
+			Name:    "Success. Pause #3",
+			Handler: PauseWorkerHandler(mockWorker),
+			Method:  http.MethodPost,
+			SetupMocks: func() {
+				mockWorker.EXPECT().Pause()
+			},
+			ExpectedCode: http.StatusOK,
+		},
+		{
+			Name:    "Success. Resume #4",
+			Handler: ResumeWorkerHandler(mockWorker),
+			Method:  http.MethodPost,
+			SetupMocks: func() {
+				mockWorker.EXPECT().Resume()
+			},
+			ExpectedCode: http.StatusOK,
+		},
+		{
+			Name:    "Success. Trigger #5",
+			Handler: TriggerWorkerHandler(mockWorker),
+			Method:  http.MethodPost,
+			SetupMocks: func() {
+				mockWorker.EXPECT().Trigger()
+			},
+			ExpectedCode: http.StatusAccepted,
+		},
+		{
+			Name:    "Success. Breakers #6",
+			Handler: GetBreakersHandler(mockWorker),
+			Method:  http.MethodGet,
+			SetupMocks: func() {
+				mockWorker.EXPECT().Breakers().Return(breakers)
+			},
+			ExpectedCode:     http.StatusOK,
+			ExpectedBreakers: &breakers,
+		},
+		{
+			Name:    "Success. Breaker reset #7",
+			Handler: ResetBreakerHandler(mockWorker),
+			Method:  http.MethodPost,
+			SetupMocks: func() {
+				mockWorker.EXPECT().ResetBreaker()
+			},
+			ExpectedCode: http.StatusOK,
+		},
+		{
+			Name:    "Success. Drain #8",
+			Handler: DrainWorkerHandler(mockWorker),
+			Method:  http.MethodPost,
+			SetupMocks: func() {
+				mockWorker.EXPECT().Drain(gomock.Any()).Return(drained, nil)
+			},
+			ExpectedCode:   http.StatusOK,
+			ExpectedStatus: drained,
+		},
+		{
+			Name:    "Error. Drain timed out #9",
+			Handler: DrainWorkerHandler(mockWorker),
+			Method:  http.MethodPost,
+			SetupMocks: func() {
+				mockWorker.EXPECT().Drain(gomock.Any()).Return(nil, context.DeadlineExceeded)
+			},
+			ExpectedCode: http.StatusGatewayTimeout,
+		},
+		{
+			Name:    "Error. Drain failure #10",
+			Handler: DrainWorkerHandler(mockWorker),
+			Method:  http.MethodPost,
+			SetupMocks: func() {
+				mockWorker.EXPECT().Drain(gomock.Any()).Return(nil, errors.New("failed to count orders"))
+			},
+			ExpectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.SetupMocks()
+
+			req := httptest.NewRequest(tc.Method, "/api/admin/worker", nil)
+			rec := httptest.NewRecorder()
+			tc.Handler(rec, req)
+
+			if rec.Code != tc.ExpectedCode {
+				t.Errorf("Expected status code: %d, got: %d", tc.ExpectedCode, rec.Code)
+			}
+			if tc.ExpectedStatus != nil {
+				var got models.WorkerStatus
+				if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+					t.Fatalf("Expected no error, got: '%v'", err)
+				}
+				if diff := cmp.Diff(*tc.ExpectedStatus, got); diff != "" {
+					t.Errorf("Status mismatch (-want +got):\n%s", diff)
+				}
+			}
+			if tc.ExpectedBreakers != nil {
+				var got models.BreakersStatus
+				if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+					t.Fatalf("Expected no error, got: '%v'", err)
+				}
+				if diff := cmp.Diff(*tc.ExpectedBreakers, got); diff != "" {
+					t.Errorf("Breakers mismatch (-want +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
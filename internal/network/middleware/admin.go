@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/denmor86/ya-gophermart/internal/logger"
+)
+
+// AdminTokenHeader - заголовок с токеном доступа к административному API
+const AdminTokenHeader = "X-Admin-Token"
+
+// AdminAuth — middleware проверки токена доступа к административному API.
+func AdminAuth(token string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			provided := r.Header.Get(AdminTokenHeader)
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				logger.Warn("Unauthorized admin request", r.RequestURI)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
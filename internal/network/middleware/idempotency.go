@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/denmor86/ya-gophermart/internal/helpers"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/denmor86/ya-gophermart/internal/services"
+	"go.uber.org/zap"
+)
+
+const (
+	// IdempotencyKeyHeader - заголовок с ключом идемпотентности запроса
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader - заголовок ответа, повторённого по ключу идемпотентности
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// Реализация http.ResponseWriter с сохранением ответа
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recordingResponseWriter) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *recordingResponseWriter) WriteHeader(statusCode int) {
+	if r.status == 0 {
+		r.status = statusCode
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Idempotency — middleware повторного выполнения запросов с заголовком Idempotency-Key.
+// Ответ на запрос сохраняется, повтор того же запроса с тем же ключом получает сохранённый ответ
+// без повторного выполнения. Ответы 5xx не сохраняются, такой запрос можно повторить.
+// Если ответ не удалось сохранить или ключ освободить, ключ занимается повтором после истечения блокировки.
+// Запросы без заголовка выполняются как обычно.
+func Idempotency(s services.IdempotencyService) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				h.ServeHTTP(w, r)
+				return
+			}
+			username, err := helpers.GetUsername(r.Context())
+			if err != nil {
+				logger.Warn("Failed to get username:", zap.Error(err))
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, "Invalid body format", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			// запрос определяется методом, путём и телом
+			request := append([]byte(r.Method+" "+r.URL.Path+"\n"), body...)
+			stored, err := s.Begin(r.Context(), username, key, request)
+			if err != nil {
+				switch {
+				case errors.Is(err, services.ErrInvalidIdempotencyKey):
+					http.Error(w, "Invalid idempotency key", http.StatusBadRequest)
+				case errors.Is(err, services.ErrIdempotencyKeyReused):
+					http.Error(w, "Idempotency key reused with different request", http.StatusUnprocessableEntity)
+				case errors.Is(err, services.ErrRequestInProgress):
+					http.Error(w, "Request with idempotency key in progress", http.StatusConflict)
+				default:
+					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				}
+				return
+			}
+			if stored != nil {
+				if stored.ContentType != "" {
+					w.Header().Set("Content-Type", stored.ContentType)
+				}
+				w.Header().Set(IdempotentReplayedHeader, "true")
+				w.WriteHeader(stored.Status)
+				_, _ = w.Write(stored.Body)
+				return
+			}
+
+			rw := &recordingResponseWriter{ResponseWriter: w}
+			h.ServeHTTP(rw, r)
+			if rw.status == 0 {
+				rw.status = http.StatusOK
+			}
+
+			// результат сохраняется и при разрыве соединения клиентом
+			ctx := context.WithoutCancel(r.Context())
+			if rw.status >= http.StatusInternalServerError {
+				if err := s.Release(ctx, username, key); err != nil {
+					logger.Warn("Idempotency key", key, "of", username, "stays locked until lock timeout:", zap.Error(err))
+				}
+				return
+			}
+			err = s.Complete(ctx, username, key, models.IdempotentResponse{
+				Status:      rw.status,
+				ContentType: w.Header().Get("Content-Type"),
+				Body:        rw.body.Bytes(),
+			})
+			if err != nil {
+				// ответ уже отправлен, повтор после истечения блокировки выполнит запрос заново
+				logger.Error("Failed to store response for idempotency key", key, "of", username, "request may be executed again:", zap.Error(err))
+			}
+		})
+	}
+}
@@ -1,11 +1,16 @@
 package router
 
 import (
+	"fmt"
+
+	"github.com/denmor86/ya-gophermart/internal/client"
 	"github.com/denmor86/ya-gophermart/internal/config"
+	"github.com/denmor86/ya-gophermart/internal/leader"
 	"github.com/denmor86/ya-gophermart/internal/network/handlers"
 	"github.com/denmor86/ya-gophermart/internal/network/middleware"
 	"github.com/denmor86/ya-gophermart/internal/services"
 	"github.com/denmor86/ya-gophermart/internal/storage"
+	"github.com/denmor86/ya-gophermart/internal/worker"
 	"github.com/go-chi/chi/v5"
 	chi_middleware "github.com/go-chi/chi/v5/middleware"
 
@@ -13,42 +18,140 @@ import (
 )
 
 type Router struct {
-	Config    config.Config
-	Indentity services.IdentityService
-	Orders    services.OrdersService
-	Loyalty   services.LoyaltyService
+	Config      config.Config
+	Indentity   services.IdentityService
+	Orders      services.OrdersService
+	Loyalty     services.LoyaltyService
+	Ledger      services.LedgerService
+	Transfers   services.TransferService
+	Tiers       services.TierService
+	Campaigns   services.CampaignService
+	Referrals   services.ReferralService
+	Idempotency services.IdempotencyService
+	Accrual     client.AccrualService
+	Leader      leader.Elector
+	Limiter     client.Limiter
+	Breakers    *client.BreakerRegistry
+	Worker      worker.Controller
 }
 
-func NewRouter(config config.Config, storage storage.Storage) *Router {
+func NewRouter(config config.Config, storage storage.Storage, elector leader.Elector) *Router {
+	limiter := NewLimiter(config.Accrual, storage, "")
+	providerLimiter := func(name string) client.Limiter {
+		return NewLimiter(config.Accrual, storage, name)
+	}
+	breakers := client.NewBreakerRegistry(config.Accrual)
+	accrual, err := services.NewAccrualProviders(config.Accrual, limiter, providerLimiter, breakers, storage.Orders)
+	if err != nil {
+		panic(fmt.Sprintf("can't create accrual providers: %s", err.Error()))
+	}
 	return &Router{
-		Config:    config,
-		Indentity: services.NewIdentity(config.Server.JWTSecret, storage.Users),
-		Orders:    services.NewOrders(services.NewAccrualService(config.Accrual.AccrualAddr), storage.Orders, storage.Users),
-		Loyalty:   services.NewLoyalty(storage.Loyaltys, storage.Users),
+		Config:      config,
+		Indentity:   services.NewIdentity(config.Server.JWTSecret, storage.Users, config.Referrals),
+		Orders:      services.NewOrders(accrual, storage.Orders, storage.Users, config.Tiers),
+		Loyalty:     services.NewLoyalty(storage.Loyaltys, storage.Users, storage.Ledger, config.Points, config.Holds),
+		Ledger:      services.NewLedger(storage.Ledger, storage.Users, config.Points),
+		Transfers:   services.NewTransfer(storage.Transfers, storage.Users, config.Transfers),
+		Tiers:       services.NewTiers(storage.Users, config.Tiers),
+		Campaigns:   services.NewCampaigns(storage.Campaigns, config.Tiers),
+		Referrals:   services.NewReferrals(storage.Users),
+		Idempotency: services.NewIdempotency(storage.Idempotency, config.Server.IdempotencyTTL, config.Server.IdempotencyLockTimeout),
+		Accrual:     accrual,
+		Leader:      elector,
+		Limiter:     limiter,
+		Breakers:    breakers,
 	}
 }
 
+// NewLimiter - создание ограничения частоты запросов к сервису начислений согласно настройкам.
+// provider - имя дополнительного провайдера начислений, у каждого свой общий бюджет запросов,
+// пустое имя - сервис по адресу ACCRUAL_SYSTEM_ADDRESS
+func NewLimiter(cfg config.AccrualConfig, s storage.Storage, provider string) client.Limiter {
+	if cfg.Limiter != config.LimiterPostgres {
+		return client.NewRateLimiter()
+	}
+	rateLimits := s.RateLimits
+	if provider != "" {
+		rateLimits = rateLimits.Bucket(storage.AccrualRateLimit + ":" + provider)
+	}
+	return client.NewSharedRateLimiter(rateLimits)
+}
+
+// Close - освобождение ресурсов клиентов сервисов начислений при остановке
+func (router *Router) Close() error {
+	return services.CloseAccrual(router.Accrual)
+}
+
 func (router *Router) HandleRouter() chi.Router {
-	ja := router.Indentity.GetTokenAuth()
-	compressMiddleware := chi_middleware.Compress(5, "gzip", "deflate")
 	r := chi.NewRouter()
 	r.Route("/api", func(r chi.Router) {
 		r.Use(middleware.LogHandle)
-		r.Route("/user", func(r chi.Router) {
-			r.Post("/register", handlers.RegisterUserHandler(router.Indentity))
-			r.Post("/login", handlers.AuthenticateUserHandle(router.Indentity))
-			r.Group(func(r chi.Router) {
-				r.Use(jwtauth.Verifier(ja))
-				r.Use(jwtauth.Authenticator(ja))
-				r.Post("/orders", handlers.OrdersHandler(router.Orders))
-				r.With(compressMiddleware).Get("/orders", handlers.GetOrdersHandler(router.Orders))
-				r.Route("/balance", func(r chi.Router) {
-					r.With(compressMiddleware).Get("/", handlers.GetUserBalanceHandler(router.Loyalty))
-					r.Post("/withdraw", handlers.WithdrawHandler(router.Loyalty))
+		r.Get("/status/leader", handlers.GetLeaderStatusHandler(router.Leader))
+		r.Route("/user", router.userRoutes(handlers.MoneyFloat))
+		// вторая версия API выдаёт денежные суммы точной десятичной записью
+		r.Route("/v2/user", router.userRoutes(handlers.MoneyExact))
+		// административное API доступно только при заданном токене
+		if router.Config.Server.AdminToken != "" {
+			r.Route("/admin", func(r chi.Router) {
+				r.Use(middleware.AdminAuth(router.Config.Server.AdminToken))
+				if router.Worker != nil {
+					r.Route("/worker", func(r chi.Router) {
+						r.Get("/", handlers.GetWorkerStatusHandler(router.Worker))
+						r.Post("/pause", handlers.PauseWorkerHandler(router.Worker))
+						r.Post("/resume", handlers.ResumeWorkerHandler(router.Worker))
+						r.Post("/drain", handlers.DrainWorkerHandler(router.Worker))
+						r.Post("/trigger", handlers.TriggerWorkerHandler(router.Worker))
+						r.Get("/breakers", handlers.GetBreakersHandler(router.Worker))
+						r.Post("/breaker/reset", handlers.ResetBreakerHandler(router.Worker))
+					})
+				}
+				r.Post("/orders/{number}/requeue", handlers.RequeueOrderHandler(router.Orders))
+				r.Route("/ledger", func(r chi.Router) {
+					r.Get("/check", handlers.CheckLedgerHandler(router.Ledger))
+					r.Post("/rebuild", handlers.RebuildBalancesHandler(router.Ledger))
+					r.Post("/adjustments", handlers.AddAdjustmentHandler(router.Ledger))
+					r.Post("/{id}/reverse", handlers.ReverseLedgerEntryHandler(router.Ledger))
+				})
+				r.Get("/users/{login}/ledger", handlers.GetUserLedgerHandler(router.Ledger))
+				r.Put("/users/{login}/priority", handlers.SetUserPriorityHandler(router.Orders))
+				r.Post("/tiers/recalculate", handlers.RecalculateTiersHandler(router.Tiers))
+				r.Route("/campaigns", func(r chi.Router) {
+					r.Get("/", handlers.GetCampaignsHandler(router.Campaigns))
+					r.Post("/", handlers.AddCampaignHandler(router.Campaigns))
+					r.Put("/{id}", handlers.UpdateCampaignHandler(router.Campaigns))
+					r.Delete("/{id}", handlers.DeactivateCampaignHandler(router.Campaigns))
 				})
-				r.With(compressMiddleware).Get("/withdrawals", handlers.GetWithdrawHandler(router.Loyalty))
 			})
-		})
+		}
 	})
 	return r
 }
+
+// userRoutes - маршруты пользователя с заданным представлением денежных сумм
+func (router *Router) userRoutes(money handlers.MoneyFormat) func(r chi.Router) {
+	ja := router.Indentity.GetTokenAuth()
+	compressMiddleware := chi_middleware.Compress(5, "gzip", "deflate")
+	return func(r chi.Router) {
+		r.Post("/register", handlers.RegisterUserHandler(router.Indentity))
+		r.Post("/login", handlers.AuthenticateUserHandle(router.Indentity))
+		r.Group(func(r chi.Router) {
+			r.Use(jwtauth.Verifier(ja))
+			r.Use(jwtauth.Authenticator(ja))
+			r.Post("/orders", handlers.OrdersHandler(router.Orders))
+			r.With(compressMiddleware).Get("/orders", handlers.GetOrdersHandler(router.Orders, money))
+			r.Route("/balance", func(r chi.Router) {
+				r.With(compressMiddleware).Get("/", handlers.GetUserBalanceHandler(router.Loyalty, money))
+				r.With(middleware.Idempotency(router.Idempotency)).Post("/withdraw", handlers.WithdrawHandler(router.Loyalty))
+				r.With(middleware.Idempotency(router.Idempotency)).Post("/transfer", handlers.TransferHandler(router.Transfers, money))
+				r.With(compressMiddleware).Get("/history", handlers.GetBalanceHistoryHandler(router.Loyalty, money))
+				r.Route("/holds", func(r chi.Router) {
+					r.With(middleware.Idempotency(router.Idempotency)).Post("/", handlers.PlaceHoldHandler(router.Loyalty, money))
+					r.With(middleware.Idempotency(router.Idempotency)).Post("/{id}/capture", handlers.CaptureHoldHandler(router.Loyalty, money))
+					r.With(middleware.Idempotency(router.Idempotency)).Post("/{id}/release", handlers.ReleaseHoldHandler(router.Loyalty, money))
+				})
+			})
+			r.With(compressMiddleware).Get("/withdrawals", handlers.GetWithdrawHandler(router.Loyalty, money))
+			r.Get("/referrals", handlers.GetReferralsHandler(router.Referrals, money))
+		})
+	}
+}
@@ -2,29 +2,37 @@ package services
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
+	"maps"
+	"sync/atomic"
+	"time"
 
 	"github.com/denmor86/ya-gophermart/internal/client"
 	"github.com/denmor86/ya-gophermart/internal/logger"
 	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/shopspring/decimal"
 )
 
 type AccrualService struct {
 	Client  *client.Client
-	Limiter *client.RateLimiter
+	Limiter client.Limiter
+	batch   atomic.Bool
 }
 
-func NewAccrualService(baseURL string) client.AccrualService {
-	return &AccrualService{
-		Client:  client.NewClient(baseURL, &http.Client{}),
-		Limiter: client.NewRateLimiter(),
+func NewAccrualService(baseURL string, httpClient client.HTTPClient, limiter client.Limiter, batch bool) client.BatchAccrualService {
+	service := &AccrualService{
+		Client:  client.NewClient(baseURL, httpClient),
+		Limiter: limiter,
 	}
+	service.batch.Store(batch)
+	return service
 }
 
-func (s *AccrualService) GetOrderAccrual(ctx context.Context, orderNumber string) (float64, string, error) {
+func (s *AccrualService) GetOrderAccrual(ctx context.Context, orderNumber string) (decimal.Decimal, string, error) {
 	if err := s.Limiter.Wait(ctx); err != nil {
-		return 0, "", err
+		return decimal.Zero, "", limiterError(err)
 	}
 
 	resp, err := s.Client.GetOrder(ctx, orderNumber)
@@ -32,18 +40,148 @@ func (s *AccrualService) GetOrderAccrual(ctx context.Context, orderNumber string
 		// проверка большого количеста запросов
 		if rateLimitErr, ok := err.(*client.RateLimitError); ok {
 			logger.Warn("Too many requests to accrual service:", orderNumber)
+			s.updateLimit(rateLimitErr.Limit)
 			s.Limiter.BlockFor(rateLimitErr.RetryAfter)
-			return 0, models.OrderStatusProcessing, nil
+			// 429 записывается на заказ как ошибка класса rate_limited
+			return decimal.Zero, models.OrderStatusInvalid, rateLimitErr
 		}
-		return 0, models.OrderStatusInvalid, err
+		return decimal.Zero, models.OrderStatusInvalid, err
 	}
+	s.updateLimit(resp.RateLimit)
+
 	// проверяем возможные статусы
-	if resp.Status != models.OrderStatusRegistered &&
-		resp.Status != models.OrderStatusProcessing &&
-		resp.Status != models.OrderStatusInvalid &&
-		resp.Status != models.OrderStatusProcessed {
+	if !isKnownStatus(resp.Status) {
 		logger.Error("Undefined status request:", resp.Status)
-		return 0, "", fmt.Errorf("undefined status request %s", resp.Status)
+		return decimal.Zero, "", unknownStatusError(resp.Status)
+	}
+	accrual, err := parseAccrual(resp.Accrual)
+	if err != nil {
+		return decimal.Zero, "", err
+	}
+	return accrual, resp.Status, nil
+}
+
+// Close - освобождение ресурсов HTTP клиента сервиса
+func (s *AccrualService) Close() error {
+	return s.Client.Close()
+}
+
+// SupportsBatch - пакетный запрос включен в настройках и не отклонён сервисом
+func (s *AccrualService) SupportsBatch() bool {
+	return s.batch.Load()
+}
+
+// GetOrdersAccrual - расчёт начислений по списку заказов запросами POST /api/orders/status
+// не более client.MaxOrdersBatch заказов в каждом. Заказы, отсутствующие в ответе, не зарегистрированы в сервисе.
+// Ошибка первого запроса возвращается целиком, ошибка следующих записывается на заказы этого запроса.
+func (s *AccrualService) GetOrdersAccrual(ctx context.Context, orderNumbers []string) (map[string]client.OrderAccrual, error) {
+	results := make(map[string]client.OrderAccrual, len(orderNumbers))
+	for start := 0; start < len(orderNumbers); start += client.MaxOrdersBatch {
+		chunk := orderNumbers[start:min(start+client.MaxOrdersBatch, len(orderNumbers))]
+		chunkResults, err := s.getOrdersChunk(ctx, chunk)
+		if err == nil {
+			maps.Copy(results, chunkResults)
+			continue
+		}
+		if start == 0 {
+			return nil, err
+		}
+		for _, number := range chunk {
+			results[number] = client.OrderAccrual{Status: models.OrderStatusInvalid, Err: err}
+		}
+	}
+	return results, nil
+}
+
+// getOrdersChunk - один пакетный запрос статусов
+func (s *AccrualService) getOrdersChunk(ctx context.Context, orderNumbers []string) (map[string]client.OrderAccrual, error) {
+	if err := s.Limiter.Wait(ctx); err != nil {
+		return nil, limiterError(err)
 	}
-	return resp.Accrual, resp.Status, nil
+
+	results := make(map[string]client.OrderAccrual, len(orderNumbers))
+	resp, err := s.Client.GetOrders(ctx, orderNumbers)
+	if err != nil {
+		// проверка большого количеста запросов
+		if rateLimitErr, ok := err.(*client.RateLimitError); ok {
+			logger.Warn("Too many requests to accrual service:", len(orderNumbers), "orders")
+			s.updateLimit(rateLimitErr.Limit)
+			s.Limiter.BlockFor(rateLimitErr.RetryAfter)
+			return nil, rateLimitErr
+		}
+		if errors.Is(err, client.ErrBatchNotSupported) {
+			logger.Warn("Accrual service does not support batch requests, switching to per-order requests")
+			s.batch.Store(false)
+		}
+		return nil, err
+	}
+	s.updateLimit(resp.RateLimit)
+
+	for _, number := range orderNumbers {
+		results[number] = client.OrderAccrual{Status: models.OrderStatusInvalid, Err: client.ErrOrderNotRegistered}
+	}
+	for _, order := range resp.Orders {
+		if _, ok := results[order.Order]; !ok {
+			continue
+		}
+		// проверяем возможные статусы
+		if !isKnownStatus(order.Status) {
+			logger.Error("Undefined status request:", order.Status)
+			results[order.Order] = client.OrderAccrual{Err: unknownStatusError(order.Status)}
+			continue
+		}
+		accrual, err := parseAccrual(order.Accrual)
+		if err != nil {
+			results[order.Order] = client.OrderAccrual{Err: err}
+			continue
+		}
+		results[order.Order] = client.OrderAccrual{Accrual: accrual, Status: order.Status}
+	}
+	return results, nil
+}
+
+// updateLimit - запоминаем ограничение, сообщённое сервисом начислений,
+// чтобы не упираться в 429 при следующих запросах
+func (s *AccrualService) updateLimit(limit *client.RateLimitConfig) {
+	if limit == nil {
+		return
+	}
+	if limit.Limit > 0 && s.Limiter.Limit() != limit.Rate() {
+		logger.Info("Accrual service rate limit learned, requests per minute:", limit.Limit)
+		s.Limiter.Update(limit.Rate(), 1)
+	}
+	// окно исчерпано: ждём его окончания, не дожидаясь 429
+	if limit.Remaining == 0 && limit.Reset > 0 {
+		s.Limiter.BlockFor(time.Duration(limit.Reset) * time.Second)
+	}
+}
+
+// isKnownStatus - проверка статуса расчёта начисления
+func isKnownStatus(status string) bool {
+	return status == models.OrderStatusRegistered ||
+		status == models.OrderStatusProcessing ||
+		status == models.OrderStatusInvalid ||
+		status == models.OrderStatusProcessed
+}
+
+// parseAccrual - начисление из ответа сервиса, отсутствующее начисление равно нулю
+func parseAccrual(accrual json.Number) (decimal.Decimal, error) {
+	if accrual == "" {
+		return decimal.Zero, nil
+	}
+	value, err := decimal.NewFromString(accrual.String())
+	if err != nil {
+		return decimal.Zero, client.NewAccrualError(client.ErrorMalformed, fmt.Errorf("%w: accrual %q", client.ErrMalformedResponse, accrual))
+	}
+	return value, nil
+}
+
+// limiterError - ожидание ограничителя частоты запросов не дождалось токена до отмены запроса,
+// ошибка относится к классу rate_limited, а не к сетевым ошибкам сервиса
+func limiterError(err error) error {
+	return client.NewAccrualError(client.ErrorRateLimited, fmt.Errorf("accrual rate limiter: %w", err))
+}
+
+func unknownStatusError(status string) error {
+	return client.NewAccrualError(client.ErrorUnknownStatus, fmt.Errorf("%w %s", client.ErrUnknownStatus, status))
 }
@@ -7,16 +7,23 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/denmor86/ya-gophermart/internal/accrualmock"
 	"github.com/denmor86/ya-gophermart/internal/client"
 	mocks "github.com/denmor86/ya-gophermart/internal/client/mocks"
 	"github.com/denmor86/ya-gophermart/internal/config"
 	"github.com/denmor86/ya-gophermart/internal/logger"
 	"github.com/denmor86/ya-gophermart/internal/models"
+	storageMocks "github.com/denmor86/ya-gophermart/internal/storage/mocks"
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
 	"go.uber.org/mock/gomock"
+	"golang.org/x/time/rate"
 )
 
 func TestGetOrderAccrual(t *testing.T) {
@@ -34,9 +41,10 @@ func TestGetOrderAccrual(t *testing.T) {
 		TestName        string
 		SetupMocks      func()
 		OrderNumber     string
-		ExpectedAccrual float64
+		ExpectedAccrual decimal.Decimal
 		ExpectedStatus  string
 		ExpectedError   error
+		ExpectedClass   client.ErrorClass
 	}{
 		{
 			TestName: "Success. Order processed #1",
@@ -50,7 +58,7 @@ func TestGetOrderAccrual(t *testing.T) {
 				}, nil)
 			},
 			OrderNumber:     "123456",
-			ExpectedAccrual: 100.5,
+			ExpectedAccrual: decimal.RequireFromString("100.5"),
 			ExpectedStatus:  models.OrderStatusProcessed,
 			ExpectedError:   nil,
 		},
@@ -66,9 +74,10 @@ func TestGetOrderAccrual(t *testing.T) {
 				}, nil)
 			},
 			OrderNumber:     "000000",
-			ExpectedAccrual: 0,
+			ExpectedAccrual: decimal.Zero,
 			ExpectedStatus:  models.OrderStatusInvalid,
 			ExpectedError:   client.ErrOrderNotRegistered,
+			ExpectedClass:   client.ErrorNotRegistered,
 		},
 		{
 			TestName: "Error. Too many requests #3",
@@ -84,9 +93,10 @@ func TestGetOrderAccrual(t *testing.T) {
 				}, nil)
 			},
 			OrderNumber:     "654321",
-			ExpectedAccrual: 0,
-			ExpectedStatus:  models.OrderStatusProcessing,
-			ExpectedError:   nil,
+			ExpectedAccrual: decimal.Zero,
+			ExpectedStatus:  models.OrderStatusInvalid,
+			ExpectedError:   errors.New("rate limit exceeded"),
+			ExpectedClass:   client.ErrorRateLimited,
 		},
 		{
 			TestName: "Error. Accrual service error #4",
@@ -99,9 +109,10 @@ func TestGetOrderAccrual(t *testing.T) {
 				}, nil)
 			},
 			OrderNumber:     "123123",
-			ExpectedAccrual: 0,
+			ExpectedAccrual: decimal.Zero,
 			ExpectedStatus:  models.OrderStatusInvalid,
 			ExpectedError:   client.ErrServiceUnavailable,
+			ExpectedClass:   client.ErrorUnavailable,
 		},
 		{
 			TestName: "Error. Invalid order status #5",
@@ -114,9 +125,10 @@ func TestGetOrderAccrual(t *testing.T) {
 				}, nil)
 			},
 			OrderNumber:     "999999",
-			ExpectedAccrual: 0,
+			ExpectedAccrual: decimal.Zero,
 			ExpectedStatus:  "",
 			ExpectedError:   errors.New("undefined status request UNKNOWN"),
+			ExpectedClass:   client.ErrorUnknownStatus,
 		},
 		{
 			TestName: "Error. Failed decode response #6",
@@ -130,9 +142,10 @@ func TestGetOrderAccrual(t *testing.T) {
 				}, nil)
 			},
 			OrderNumber:     "invalid",
-			ExpectedAccrual: 0,
+			ExpectedAccrual: decimal.Zero,
 			ExpectedStatus:  "",
 			ExpectedError:   fmt.Errorf("undefined status request %s", ""),
+			ExpectedClass:   client.ErrorUnknownStatus,
 		},
 		{
 			TestName: "Error. Invalid URL request #7",
@@ -146,9 +159,37 @@ func TestGetOrderAccrual(t *testing.T) {
 				}, nil)
 			},
 			OrderNumber:     "failure",
-			ExpectedAccrual: 0,
+			ExpectedAccrual: decimal.Zero,
 			ExpectedStatus:  models.OrderStatusInvalid,
 			ExpectedError:   client.ErrServiceUnavailable,
+			ExpectedClass:   client.ErrorUnavailable,
+		},
+		{
+			TestName: "Error. Network failure #8",
+			SetupMocks: func() {
+				mockHTTPClient.EXPECT().Do(gomock.Any()).Return(nil, errors.New("connection refused"))
+			},
+			OrderNumber:     "123123",
+			ExpectedAccrual: decimal.Zero,
+			ExpectedStatus:  models.OrderStatusInvalid,
+			ExpectedError:   errors.New("connection refused"),
+			ExpectedClass:   client.ErrorTransient,
+		},
+		{
+			TestName: "Success. Exact accrual #9",
+			SetupMocks: func() {
+				mockHTTPClient.EXPECT().Do(gomock.Any()).Return(&http.Response{
+					Status:        "200 OK",
+					StatusCode:    http.StatusOK,
+					Body:          io.NopCloser(bytes.NewBufferString(`{"order":"123456","status":"PROCESSED","accrual":90071992547409.93}`)),
+					ContentLength: int64(len(`{"order":"123456","status":"PROCESSED","accrual":90071992547409.93}`)),
+					Header:        make(http.Header),
+				}, nil)
+			},
+			OrderNumber:     "123456",
+			ExpectedAccrual: decimal.RequireFromString("90071992547409.93"),
+			ExpectedStatus:  models.OrderStatusProcessed,
+			ExpectedError:   nil,
 		},
 	}
 
@@ -166,7 +207,7 @@ func TestGetOrderAccrual(t *testing.T) {
 
 			accrual, status, err := service.GetOrderAccrual(ctx, tc.OrderNumber)
 
-			if accrual != tc.ExpectedAccrual {
+			if !accrual.Equal(tc.ExpectedAccrual) {
 				t.Errorf("Expected accrual: '%v', got: '%v'", tc.ExpectedAccrual, accrual)
 			}
 			if status != tc.ExpectedStatus {
@@ -181,6 +222,609 @@ func TestGetOrderAccrual(t *testing.T) {
 			} else if err != nil {
 				t.Errorf("Expected no error, got: '%v'", err)
 			}
+			if class := client.Classify(err); class != tc.ExpectedClass {
+				t.Errorf("Expected error class: '%v', got: '%v'", tc.ExpectedClass, class)
+			}
+		})
+	}
+}
+
+func TestGetOrderAccrual_SharedLimiter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockHTTPClient := mocks.NewMockHTTPClient(ctrl)
+	mockBackend := storageMocks.NewMockRateLimitStorage(ctrl)
+
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+	defer logger.Sync()
+
+	testCases := []struct {
+		TestName       string
+		SetupMocks     func()
+		ExpectedStatus string
+		ExpectedError  error
+	}{
+		{
+			TestName: "Success. Wait for shared budget #1",
+			SetupMocks: func() {
+				gomock.InOrder(
+					mockBackend.EXPECT().Reserve(gomock.Any()).Return(10*time.Millisecond, nil),
+					mockBackend.EXPECT().Reserve(gomock.Any()).Return(time.Duration(0), nil),
+				)
+				mockHTTPClient.EXPECT().Do(gomock.Any()).Return(&http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(bytes.NewBufferString(`{"order":"123456","status":"PROCESSED","accrual":10}`)),
+					Header:     make(http.Header),
+				}, nil)
+			},
+			ExpectedStatus: models.OrderStatusProcessed,
+			ExpectedError:  nil,
+		},
+		{
+			TestName: "Success. Retry-After blocks all replicas #2",
+			SetupMocks: func() {
+				mockBackend.EXPECT().Reserve(gomock.Any()).Return(time.Duration(0), nil)
+				mockHTTPClient.EXPECT().Do(gomock.Any()).Return(&http.Response{
+					StatusCode: http.StatusTooManyRequests,
+					Body:       io.NopCloser(bytes.NewBufferString("")),
+					Header:     http.Header{"Retry-After": []string{"30"}},
+				}, nil)
+				mockBackend.EXPECT().Block(gomock.Any(), 30*time.Second).Return(nil)
+			},
+			ExpectedStatus: models.OrderStatusInvalid,
+			ExpectedError:  errors.New("rate limit exceeded"),
+		},
+		{
+			TestName: "Error. Shared budget unavailable #3",
+			SetupMocks: func() {
+				mockBackend.EXPECT().Reserve(gomock.Any()).Return(time.Duration(0), errors.New("failed to lock rate limit"))
+			},
+			ExpectedStatus: "",
+			ExpectedError:  errors.New("failed to lock rate limit"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.TestName, func(t *testing.T) {
+			tc.SetupMocks()
+
+			service := &AccrualService{
+				Client:  client.NewClient("", mockHTTPClient),
+				Limiter: client.NewSharedRateLimiter(mockBackend),
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			_, status, err := service.GetOrderAccrual(ctx, "123456")
+
+			if status != tc.ExpectedStatus {
+				t.Errorf("Expected status: '%v', got: '%v'", tc.ExpectedStatus, status)
+			}
+			if tc.ExpectedError != nil {
+				if err == nil {
+					t.Errorf("Expected error: '%v', got: nil", tc.ExpectedError)
+				} else if !strings.Contains(err.Error(), tc.ExpectedError.Error()) {
+					t.Errorf("Expected error containing: '%v', got '%v'", tc.ExpectedError.Error(), err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("Expected no error, got: '%v'", err)
+			}
+			// ответ 429 и ожидание ограничителя не расходуют попытки сетевых ошибок
+			if class := client.Classify(err); tc.ExpectedError != nil && class != client.ErrorRateLimited {
+				t.Errorf("Expected error class: '%v', got: '%v'", client.ErrorRateLimited, class)
+			}
+		})
+	}
+}
+
+func TestGetOrderAccrual_LearnRateLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockHTTPClient := mocks.NewMockHTTPClient(ctrl)
+
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+	defer logger.Sync()
+
+	testCases := []struct {
+		TestName      string
+		SetupMocks    func()
+		ExpectedLimit rate.Limit
+	}{
+		{
+			TestName: "Success. Limit from 429 body #1",
+			SetupMocks: func() {
+				mockHTTPClient.EXPECT().Do(gomock.Any()).Return(&http.Response{
+					StatusCode: http.StatusTooManyRequests,
+					Body:       io.NopCloser(bytes.NewBufferString("No more than 60 requests per minute allowed")),
+					Header:     http.Header{"Retry-After": []string{"0"}},
+				}, nil)
+			},
+			ExpectedLimit: rate.Limit(1),
+		},
+		{
+			TestName: "Success. Limit from headers #2",
+			SetupMocks: func() {
+				mockHTTPClient.EXPECT().Do(gomock.Any()).Return(&http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(bytes.NewBufferString(`{"order":"123456","status":"PROCESSING"}`)),
+					Header:     http.Header{"X-Ratelimit-Limit": []string{"120"}, "X-Ratelimit-Remaining": []string{"100"}},
+				}, nil)
+			},
+			ExpectedLimit: rate.Limit(2),
+		},
+		{
+			TestName: "Success. No limit reported #3",
+			SetupMocks: func() {
+				mockHTTPClient.EXPECT().Do(gomock.Any()).Return(&http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(bytes.NewBufferString(`{"order":"123456","status":"PROCESSING"}`)),
+					Header:     make(http.Header),
+				}, nil)
+			},
+			ExpectedLimit: rate.Inf,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.TestName, func(t *testing.T) {
+			tc.SetupMocks()
+
+			limiter := client.NewRateLimiter()
+			service := &AccrualService{
+				Client:  client.NewClient("", mockHTTPClient),
+				Limiter: limiter,
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			if _, _, err := service.GetOrderAccrual(ctx, "123456"); err != nil && client.Classify(err) != client.ErrorRateLimited {
+				t.Errorf("Expected no error, got: '%v'", err)
+			}
+
+			// после окончания блокировки должно остаться выученное ограничение
+			deadline := time.Now().Add(time.Second)
+			for limiter.Limit() != tc.ExpectedLimit && time.Now().Before(deadline) {
+				time.Sleep(5 * time.Millisecond)
+			}
+			if limiter.Limit() != tc.ExpectedLimit {
+				t.Errorf("Expected limit: '%v', got: '%v'", tc.ExpectedLimit, limiter.Limit())
+			}
+		})
+	}
+}
+
+func TestGetOrderAccrual_FakeService(t *testing.T) {
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+	defer logger.Sync()
+
+	accrual := 729.98
+	fake := accrualmock.NewServer(accrualmock.Config{DefaultMode: accrualmock.DefaultNotRegistered})
+	fake.Script("12345678903",
+		accrualmock.Step{Status: models.OrderStatusRegistered},
+		accrualmock.Step{Status: models.OrderStatusProcessing},
+		accrualmock.Step{Status: models.OrderStatusProcessed, Accrual: &accrual},
+	)
+	fake.Script("2377225624", accrualmock.Step{Status: models.OrderStatusInvalid})
+	fake.Script("49927398716", accrualmock.Step{Code: http.StatusTooManyRequests, RetryAfter: 0})
+	fake.Script("79927398713", accrualmock.Step{Code: http.StatusServiceUnavailable})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	testCases := []struct {
+		TestName        string
+		OrderNumber     string
+		ExpectedAccrual decimal.Decimal
+		ExpectedStatus  string
+		ExpectedError   error
+		ExpectedClass   client.ErrorClass
+	}{
+		{TestName: "Success. Registered #1", OrderNumber: "12345678903", ExpectedStatus: models.OrderStatusRegistered},
+		{TestName: "Success. Processing #2", OrderNumber: "12345678903", ExpectedStatus: models.OrderStatusProcessing},
+		{TestName: "Success. Processed #3", OrderNumber: "12345678903", ExpectedAccrual: decimal.RequireFromString("729.98"), ExpectedStatus: models.OrderStatusProcessed},
+		{TestName: "Success. Invalid #4", OrderNumber: "2377225624", ExpectedStatus: models.OrderStatusInvalid},
+		{TestName: "Error. Not registered #5", OrderNumber: "4561261212345467", ExpectedStatus: models.OrderStatusInvalid, ExpectedError: client.ErrOrderNotRegistered},
+		{TestName: "Error. Too many requests #6", OrderNumber: "49927398716", ExpectedStatus: models.OrderStatusInvalid, ExpectedClass: client.ErrorRateLimited},
+		{TestName: "Error. Service unavailable #7", OrderNumber: "79927398713", ExpectedStatus: models.OrderStatusInvalid, ExpectedError: client.ErrServiceUnavailable},
+	}
+
+	service := &AccrualService{
+		Client:  client.NewClient(server.URL, server.Client()),
+		Limiter: client.NewRateLimiter(),
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.TestName, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			accrual, status, err := service.GetOrderAccrual(ctx, tc.OrderNumber)
+
+			if !accrual.Equal(tc.ExpectedAccrual) {
+				t.Errorf("Expected accrual: '%v', got: '%v'", tc.ExpectedAccrual, accrual)
+			}
+			if status != tc.ExpectedStatus {
+				t.Errorf("Expected status: '%v', got: '%v'", tc.ExpectedStatus, status)
+			}
+			if tc.ExpectedClass != "" {
+				if class := client.Classify(err); class != tc.ExpectedClass {
+					t.Errorf("Expected error class: '%v', got: '%v'", tc.ExpectedClass, class)
+				}
+			} else if !errors.Is(err, tc.ExpectedError) {
+				t.Errorf("Expected error: '%v', got: '%v'", tc.ExpectedError, err)
+			}
+		})
+	}
+}
+
+func TestGetOrderAccrual_HardenedClient(t *testing.T) {
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+	defer logger.Sync()
+
+	accrual := 500.0
+	fake := accrualmock.NewServer(accrualmock.Config{DefaultMode: accrualmock.DefaultNotRegistered})
+	fake.Script("12345678903",
+		accrualmock.Step{Code: http.StatusServiceUnavailable},
+		accrualmock.Step{Code: http.StatusBadGateway},
+		accrualmock.Step{Status: models.OrderStatusProcessed, Accrual: &accrual},
+	)
+	fake.Script("79927398713", accrualmock.Step{Code: http.StatusInternalServerError})
+
+	const (
+		token  = "token"
+		secret = "secret"
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token || !client.VerifySignature([]byte(secret), r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	httpConfig := config.Accrual.HTTP
+	httpConfig.Retries = 2
+	httpConfig.RetryDelay = time.Millisecond
+	httpConfig.RetryMaxDelay = 5 * time.Millisecond
+	httpConfig.Token = token
+	httpConfig.HMACSecret = secret
+	httpClient, err := client.NewHTTPClient(httpConfig, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	service := NewAccrualService(server.URL, httpClient, client.NewRateLimiter(), false)
+
+	testCases := []struct {
+		TestName         string
+		OrderNumber      string
+		ExpectedAccrual  decimal.Decimal
+		ExpectedStatus   string
+		ExpectedError    error
+		ExpectedRequests int
+	}{
+		{TestName: "Success. Retry 5xx #1", OrderNumber: "12345678903", ExpectedAccrual: decimal.RequireFromString("500"), ExpectedStatus: models.OrderStatusProcessed, ExpectedRequests: 3},
+		{TestName: "Error. Retries exhausted #2", OrderNumber: "79927398713", ExpectedStatus: models.OrderStatusInvalid, ExpectedError: client.ErrServiceUnavailable, ExpectedRequests: 3},
+		{TestName: "Error. Not registered without retry #3", OrderNumber: "4561261212345467", ExpectedStatus: models.OrderStatusInvalid, ExpectedError: client.ErrOrderNotRegistered, ExpectedRequests: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.TestName, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			accrual, status, err := service.GetOrderAccrual(ctx, tc.OrderNumber)
+
+			if !accrual.Equal(tc.ExpectedAccrual) {
+				t.Errorf("Expected accrual: '%v', got: '%v'", tc.ExpectedAccrual, accrual)
+			}
+			if status != tc.ExpectedStatus {
+				t.Errorf("Expected status: '%v', got: '%v'", tc.ExpectedStatus, status)
+			}
+			if !errors.Is(err, tc.ExpectedError) {
+				t.Errorf("Expected error: '%v', got: '%v'", tc.ExpectedError, err)
+			}
+			if requests := fake.Requests(tc.OrderNumber); requests != tc.ExpectedRequests {
+				t.Errorf("Expected requests: '%v', got: '%v'", tc.ExpectedRequests, requests)
+			}
+		})
+	}
+}
+
+func TestGetOrdersAccrual_FakeService(t *testing.T) {
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+	defer logger.Sync()
+
+	accrual := 729.98
+	fake := accrualmock.NewServer(accrualmock.Config{DefaultMode: accrualmock.DefaultNotRegistered})
+	fake.Script("12345678903", accrualmock.Step{Status: models.OrderStatusProcessed, Accrual: &accrual})
+	fake.Script("2377225624", accrualmock.Step{Status: models.OrderStatusInvalid})
+	fake.Script("49927398716", accrualmock.Step{Status: "UNKNOWN"})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	service := NewAccrualService(server.URL, server.Client(), client.NewRateLimiter(), true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	results, err := service.GetOrdersAccrual(ctx, []string{"12345678903", "2377225624", "4561261212345467", "49927398716"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+
+	testCases := []struct {
+		TestName        string
+		OrderNumber     string
+		ExpectedAccrual decimal.Decimal
+		ExpectedStatus  string
+		ExpectedError   bool
+	}{
+		{TestName: "Success. Processed #1", OrderNumber: "12345678903", ExpectedAccrual: decimal.RequireFromString("729.98"), ExpectedStatus: models.OrderStatusProcessed},
+		{TestName: "Success. Invalid #2", OrderNumber: "2377225624", ExpectedStatus: models.OrderStatusInvalid},
+		{TestName: "Error. Not registered #3", OrderNumber: "4561261212345467", ExpectedStatus: models.OrderStatusInvalid, ExpectedError: true},
+		{TestName: "Error. Undefined status #4", OrderNumber: "49927398716", ExpectedError: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.TestName, func(t *testing.T) {
+			result := results[tc.OrderNumber]
+			if !result.Accrual.Equal(tc.ExpectedAccrual) {
+				t.Errorf("Expected accrual: '%v', got: '%v'", tc.ExpectedAccrual, result.Accrual)
+			}
+			if result.Status != tc.ExpectedStatus {
+				t.Errorf("Expected status: '%v', got: '%v'", tc.ExpectedStatus, result.Status)
+			}
+			if (result.Err != nil) != tc.ExpectedError {
+				t.Errorf("Expected error: '%v', got: '%v'", tc.ExpectedError, result.Err)
+			}
+			if requests := fake.Requests(tc.OrderNumber); requests != 1 {
+				t.Errorf("Expected requests: '1', got: '%v'", requests)
+			}
 		})
 	}
+
+	// сервис без пакетного запроса отключает его после первого ответа 404
+	legacy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer legacy.Close()
+
+	service = NewAccrualService(legacy.URL, legacy.Client(), client.NewRateLimiter(), true)
+	if _, err := service.GetOrdersAccrual(ctx, []string{"12345678903"}); !errors.Is(err, client.ErrBatchNotSupported) {
+		t.Errorf("Expected error: '%v', got: '%v'", client.ErrBatchNotSupported, err)
+	}
+	if service.SupportsBatch() {
+		t.Errorf("Expected batch to be disabled")
+	}
+}
+
+// Пачка больше ограничения сервиса делится на запросы, ошибка запроса записывается только на его заказы
+func TestGetOrdersAccrual_Chunks(t *testing.T) {
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+	defer logger.Sync()
+
+	fake := accrualmock.NewServer(accrualmock.Config{DefaultMode: accrualmock.DefaultNotRegistered})
+	numbers := make([]string, 0, 2*client.MaxOrdersBatch+50)
+	for i := 0; i < cap(numbers); i++ {
+		number := fmt.Sprintf("%d", 1000000+i)
+		numbers = append(numbers, number)
+		if i < 2*client.MaxOrdersBatch {
+			fake.Script(number, accrualmock.Step{Status: models.OrderStatusProcessed})
+		}
+	}
+	// третий запрос отклоняется сервисом
+	failing := numbers[2*client.MaxOrdersBatch]
+	fake.Script(failing, accrualmock.Step{Code: http.StatusServiceUnavailable})
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		fake.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	service := NewAccrualService(server.URL, server.Client(), client.NewRateLimiter(), true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	results, err := service.GetOrdersAccrual(ctx, numbers)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	if requests.Load() != 3 {
+		t.Errorf("Expected requests: '3', got: '%v'", requests.Load())
+	}
+	for i, number := range numbers {
+		result := results[number]
+		if i < 2*client.MaxOrdersBatch {
+			if result.Err != nil || result.Status != models.OrderStatusProcessed {
+				t.Errorf("Expected order %s processed, got: '%+v'", number, result)
+			}
+			continue
+		}
+		if class := client.Classify(result.Err); class != client.ErrorUnavailable {
+			t.Errorf("Expected order %s error class: '%v', got: '%v'", number, client.ErrorUnavailable, class)
+		}
+	}
+}
+
+func TestGetOrderAccrual_RecordReplay(t *testing.T) {
+	cfg := config.DefaultConfig()
+	if err := logger.Initialize(cfg.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+	defer logger.Sync()
+
+	accrual := 729.98
+	fake := accrualmock.NewServer(accrualmock.Config{DefaultMode: accrualmock.DefaultNotRegistered})
+	fake.Script("12345678903",
+		accrualmock.Step{Status: models.OrderStatusRegistered},
+		accrualmock.Step{Code: http.StatusServiceUnavailable},
+		accrualmock.Step{Status: models.OrderStatusProcessed, Accrual: &accrual},
+	)
+	fake.Script("2377225624", accrualmock.Step{Status: models.OrderStatusInvalid})
+	server := httptest.NewServer(fake)
+
+	type result struct {
+		Accrual decimal.Decimal
+		Status  string
+		Err     error
+	}
+	requests := []string{"12345678903", "12345678903", "2377225624", "4561261212345467"}
+	run := func(httpConfig config.HTTPClientConfig, baseURL string) []result {
+		httpClient, err := client.NewHTTPClient(httpConfig, nil)
+		if err != nil {
+			t.Fatalf("Expected no error, got: '%v'", err)
+		}
+		service := NewAccrualService(baseURL, httpClient, client.NewRateLimiter(), false)
+		var results []result
+		for _, number := range requests {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			accrual, status, err := service.GetOrderAccrual(ctx, number)
+			cancel()
+			results = append(results, result{Accrual: accrual, Status: status, Err: err})
+		}
+		return results
+	}
+
+	httpConfig := cfg.Accrual.HTTP
+	httpConfig.RetryDelay = time.Millisecond
+	httpConfig.Record = t.TempDir() + "/accrual.jsonl"
+	recorded := run(httpConfig, server.URL)
+	server.Close()
+
+	// воспроизведение без сервиса: те же ответы, включая повтор после 503
+	httpConfig.Replay, httpConfig.Record = httpConfig.Record, ""
+	replayed := run(httpConfig, server.URL)
+
+	expected := []result{
+		{Status: models.OrderStatusRegistered},
+		{Accrual: decimal.RequireFromString("729.98"), Status: models.OrderStatusProcessed},
+		{Status: models.OrderStatusInvalid},
+		{Status: models.OrderStatusInvalid, Err: client.ErrOrderNotRegistered},
+	}
+	for i := range expected {
+		for name, results := range map[string][]result{"recorded": recorded, "replayed": replayed} {
+			got := results[i]
+			if !got.Accrual.Equal(expected[i].Accrual) || got.Status != expected[i].Status || !errors.Is(got.Err, expected[i].Err) {
+				t.Errorf("%s request #%d: expected '%+v', got: '%+v'", name, i+1, expected[i], got)
+			}
+		}
+	}
+
+	httpClient, err := client.NewHTTPClient(httpConfig, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	service := NewAccrualService(server.URL, httpClient, client.NewRateLimiter(), false)
+	if _, _, err := service.GetOrderAccrual(context.Background(), "79927398713"); !errors.Is(err, client.ErrNoInteraction) {
+		t.Errorf("Expected error: '%v', got: '%v'", client.ErrNoInteraction, err)
+	}
+}
+
+func TestGetOrderAccrual_BreakerPerHost(t *testing.T) {
+	cfg := config.DefaultConfig()
+	if err := logger.Initialize(cfg.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+	defer logger.Sync()
+
+	accrual := 100.0
+	failing := accrualmock.NewServer(accrualmock.Config{})
+	failing.Script("12345678903",
+		accrualmock.Step{Code: http.StatusServiceUnavailable},
+		accrualmock.Step{Code: http.StatusServiceUnavailable},
+		accrualmock.Step{Status: models.OrderStatusProcessed, Accrual: &accrual},
+	)
+	failingServer := httptest.NewServer(failing)
+	defer failingServer.Close()
+
+	healthy := accrualmock.NewServer(accrualmock.Config{})
+	healthy.Script("2377225624", accrualmock.Step{Status: models.OrderStatusProcessed, Accrual: &accrual})
+	healthyServer := httptest.NewServer(healthy)
+	defer healthyServer.Close()
+
+	cfg.Accrual.CircuitBreakerFailures = 2
+	cfg.Accrual.CircuitBreakerTimeout = 50 * time.Millisecond
+	cfg.Accrual.HTTP.Retries = 0
+	breakers := client.NewBreakerRegistry(cfg.Accrual)
+
+	httpClient, err := client.NewHTTPClient(cfg.Accrual.HTTP, breakers)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	failingService := NewAccrualService(failingServer.URL, httpClient, client.NewRateLimiter(), false)
+	healthyService := NewAccrualService(healthyServer.URL, httpClient, client.NewRateLimiter(), false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// две ошибки 503 размыкают breaker, третий запрос до сервиса не доходит
+	for range 3 {
+		if _, _, err := failingService.GetOrderAccrual(ctx, "12345678903"); client.Classify(err) != client.ErrorUnavailable {
+			t.Errorf("Expected error class: '%v', got: '%v'", client.ErrorUnavailable, err)
+		}
+	}
+	if requests := failing.Requests("12345678903"); requests != 2 {
+		t.Errorf("Expected requests: '2', got: '%v'", requests)
+	}
+	if _, _, err := failingService.GetOrderAccrual(ctx, "12345678903"); !errors.Is(err, client.ErrBreakerOpen) {
+		t.Errorf("Expected error: '%v', got: '%v'", client.ErrBreakerOpen, err)
+	}
+	if breakers.State() != "open" {
+		t.Errorf("Expected state: 'open', got: '%v'", breakers.State())
+	}
+
+	// breaker другого сервиса не затронут
+	if _, status, err := healthyService.GetOrderAccrual(ctx, "2377225624"); err != nil || status != models.OrderStatusProcessed {
+		t.Errorf("Expected processed order, got status: '%v', error: '%v'", status, err)
+	}
+
+	// после таймаута пробный запрос в полуоткрытом состоянии замыкает breaker
+	time.Sleep(2 * cfg.Accrual.CircuitBreakerTimeout)
+	if _, status, err := failingService.GetOrderAccrual(ctx, "12345678903"); err != nil || status != models.OrderStatusProcessed {
+		t.Errorf("Expected processed order, got status: '%v', error: '%v'", status, err)
+	}
+
+	expected := []string{"closed → open", "open → half-open", "half-open → closed"}
+	status := breakers.Status()
+	transitions := make([]string, 0, len(status.Events))
+	for _, event := range status.Events {
+		transitions = append(transitions, event.From+" → "+event.To)
+	}
+	if diff := cmp.Diff(expected, transitions); diff != "" {
+		t.Errorf("Transitions mismatch (-want +got):\n%s", diff)
+	}
+
+	if len(status.Breakers) != 2 {
+		t.Fatalf("Expected breakers: '2', got: '%v'", len(status.Breakers))
+	}
+	for _, breaker := range status.Breakers {
+		if breaker.Host != strings.TrimPrefix(failingServer.URL, "http://") {
+			continue
+		}
+		if breaker.State != "closed" || breaker.Trips != 1 || breaker.Failures != 2 || breaker.Successes != 1 || breaker.Rejected != 2 {
+			t.Errorf("Unexpected breaker status: '%+v'", breaker)
+		}
+	}
 }
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/denmor86/ya-gophermart/internal/config"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/denmor86/ya-gophermart/internal/storage"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidCampaign  = errors.New("invalid campaign")
+	ErrCampaignNotFound = errors.New("campaign not found")
+)
+
+type CampaignService interface {
+	AddCampaign(ctx context.Context, request models.CampaignRequest) (*models.Campaign, error)
+	UpdateCampaign(ctx context.Context, id int64, request models.CampaignRequest) (*models.Campaign, error)
+	DeactivateCampaign(ctx context.Context, id int64) error
+	GetCampaigns(ctx context.Context) ([]models.Campaign, error)
+}
+
+type Campaigns struct {
+	CampaignsStorage storage.CampaignsStorage
+	Tiers            config.TiersConfig
+}
+
+// Создание сервиса
+func NewCampaigns(campaigns storage.CampaignsStorage, tiers config.TiersConfig) CampaignService {
+	return &Campaigns{CampaignsStorage: campaigns, Tiers: tiers}
+}
+
+// campaign проверка запроса и создание по нему кампании
+func (s *Campaigns) campaign(request models.CampaignRequest) (models.Campaign, error) {
+	campaign := models.Campaign{
+		Name:       strings.TrimSpace(request.Name),
+		StartsAt:   request.StartsAt,
+		Conditions: request.Conditions,
+		Bonus:      request.Bonus,
+	}
+	if request.EndsAt != nil {
+		campaign.EndsAt = *request.EndsAt
+	}
+	if campaign.Name == "" || campaign.StartsAt.IsZero() {
+		return campaign, ErrInvalidCampaign
+	}
+	if !campaign.EndsAt.IsZero() && !campaign.EndsAt.After(campaign.StartsAt) {
+		return campaign, ErrInvalidCampaign
+	}
+	// суммы и процент неотрицательны и не точнее копеек
+	for _, value := range []decimal.Decimal{
+		campaign.Conditions.MinAccrual, campaign.Bonus.Percent, campaign.Bonus.Fixed, campaign.Bonus.Max,
+	} {
+		if value.IsNegative() || !value.Equal(value.Round(2)) {
+			return campaign, ErrInvalidCampaign
+		}
+	}
+	// кампания без бонуса ничего не начислит
+	if !campaign.Bonus.Percent.IsPositive() && !campaign.Bonus.Fixed.IsPositive() {
+		return campaign, ErrInvalidCampaign
+	}
+	if tier := campaign.Conditions.Tier; tier != "" {
+		known := false
+		for _, t := range s.Tiers.Tiers {
+			known = known || t.Name == tier
+		}
+		if !known {
+			return campaign, ErrInvalidCampaign
+		}
+	}
+	return campaign, nil
+}
+
+// AddCampaign создание кампании
+func (s *Campaigns) AddCampaign(ctx context.Context, request models.CampaignRequest) (*models.Campaign, error) {
+	campaign, err := s.campaign(request)
+	if err != nil {
+		return nil, err
+	}
+	created, err := s.CampaignsStorage.AddCampaign(ctx, campaign)
+	if err != nil {
+		logger.Error("Failed to add campaign", zap.Error(err))
+		return nil, err
+	}
+	logger.Info("Campaign created:", created.ID, created.Name)
+	return created, nil
+}
+
+// UpdateCampaign изменение кампании, бонусы по уже обработанным заказам не пересчитываются
+func (s *Campaigns) UpdateCampaign(ctx context.Context, id int64, request models.CampaignRequest) (*models.Campaign, error) {
+	campaign, err := s.campaign(request)
+	if err != nil {
+		return nil, err
+	}
+	campaign.ID = id
+	updated, err := s.CampaignsStorage.UpdateCampaign(ctx, campaign)
+	switch {
+	case errors.Is(err, storage.ErrCampaignNotFound):
+		return nil, ErrCampaignNotFound
+	case err != nil:
+		logger.Error("Failed to update campaign", zap.Error(err))
+		return nil, err
+	}
+	logger.Info("Campaign updated:", updated.ID, updated.Name)
+	return updated, nil
+}
+
+// DeactivateCampaign остановка кампании
+func (s *Campaigns) DeactivateCampaign(ctx context.Context, id int64) error {
+	err := s.CampaignsStorage.DeactivateCampaign(ctx, id)
+	switch {
+	case errors.Is(err, storage.ErrCampaignNotFound):
+		return ErrCampaignNotFound
+	case err != nil:
+		logger.Error("Failed to deactivate campaign", zap.Error(err))
+		return err
+	}
+	logger.Info("Campaign deactivated:", id)
+	return nil
+}
+
+// GetCampaigns список кампаний
+func (s *Campaigns) GetCampaigns(ctx context.Context) ([]models.Campaign, error) {
+	campaigns, err := s.CampaignsStorage.GetCampaigns(ctx)
+	if err != nil {
+		logger.Error("Failed to get campaigns", zap.Error(err))
+		return nil, err
+	}
+	return campaigns, nil
+}
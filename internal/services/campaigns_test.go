@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/config"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/denmor86/ya-gophermart/internal/storage"
+	"github.com/denmor86/ya-gophermart/internal/storage/mocks"
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCampaignService_AddCampaign(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockCampaigns := mocks.NewMockCampaignsStorage(ctrl)
+
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+
+	campaigns := NewCampaigns(mockCampaigns, testTiersConfig())
+	startsAt := time.Date(2025, 6, 28, 0, 0, 0, 0, time.UTC)
+	endsAt := startsAt.Add(48 * time.Hour)
+	weekend := models.CampaignRequest{
+		Name:     " Double points weekend ",
+		StartsAt: startsAt,
+		EndsAt:   &endsAt,
+		Bonus:    models.CampaignBonus{Percent: decimal.NewFromInt(100)},
+	}
+	with := func(change func(r *models.CampaignRequest)) models.CampaignRequest {
+		request := weekend
+		change(&request)
+		return request
+	}
+
+	testCases := []struct {
+		Name             string
+		Request          models.CampaignRequest
+		SetupMocks       func()
+		ExpectedError    error
+		ExpectedCampaign *models.Campaign
+	}{
+		{
+			Name:    "Success. Time window #1",
+			Request: weekend,
+			SetupMocks: func() {
+				mockCampaigns.EXPECT().AddCampaign(gomock.Any(), models.Campaign{
+					Name:     "Double points weekend",
+					StartsAt: startsAt,
+					EndsAt:   endsAt,
+					Bonus:    models.CampaignBonus{Percent: decimal.NewFromInt(100)},
+				}).DoAndReturn(func(_ context.Context, c models.Campaign) (*models.Campaign, error) {
+					c.ID = 1
+					c.Active = true
+					return &c, nil
+				})
+			},
+			ExpectedCampaign: &models.Campaign{
+				ID:       1,
+				Name:     "Double points weekend",
+				StartsAt: startsAt,
+				EndsAt:   endsAt,
+				Active:   true,
+				Bonus:    models.CampaignBonus{Percent: decimal.NewFromInt(100)},
+			},
+		},
+		{
+			Name: "Success. Open-ended first order bonus for tier #2",
+			Request: models.CampaignRequest{
+				Name:       "First order",
+				StartsAt:   startsAt,
+				Conditions: models.CampaignConditions{FirstOrder: true, Tier: "GOLD"},
+				Bonus:      models.CampaignBonus{Fixed: decimal.NewFromInt(100)},
+			},
+			SetupMocks: func() {
+				mockCampaigns.EXPECT().AddCampaign(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, c models.Campaign) (*models.Campaign, error) {
+					c.ID = 2
+					return &c, nil
+				})
+			},
+			ExpectedCampaign: &models.Campaign{
+				ID:         2,
+				Name:       "First order",
+				StartsAt:   startsAt,
+				Conditions: models.CampaignConditions{FirstOrder: true, Tier: "GOLD"},
+				Bonus:      models.CampaignBonus{Fixed: decimal.NewFromInt(100)},
+			},
+		},
+		{
+			Name:          "Error. Empty name #3",
+			Request:       with(func(r *models.CampaignRequest) { r.Name = " " }),
+			SetupMocks:    func() {},
+			ExpectedError: ErrInvalidCampaign,
+		},
+		{
+			Name:          "Error. Ends before start #4",
+			Request:       with(func(r *models.CampaignRequest) { r.EndsAt = &startsAt }),
+			SetupMocks:    func() {},
+			ExpectedError: ErrInvalidCampaign,
+		},
+		{
+			Name:          "Error. No bonus #5",
+			Request:       with(func(r *models.CampaignRequest) { r.Bonus = models.CampaignBonus{Max: decimal.NewFromInt(10)} }),
+			SetupMocks:    func() {},
+			ExpectedError: ErrInvalidCampaign,
+		},
+		{
+			Name:          "Error. Negative bonus #6",
+			Request:       with(func(r *models.CampaignRequest) { r.Bonus.Fixed = decimal.NewFromInt(-5) }),
+			SetupMocks:    func() {},
+			ExpectedError: ErrInvalidCampaign,
+		},
+		{
+			Name:          "Error. Bonus finer than cents #7",
+			Request:       with(func(r *models.CampaignRequest) { r.Bonus.Fixed = decimal.RequireFromString("0.001") }),
+			SetupMocks:    func() {},
+			ExpectedError: ErrInvalidCampaign,
+		},
+		{
+			Name:          "Error. Unknown tier #8",
+			Request:       with(func(r *models.CampaignRequest) { r.Conditions.Tier = "DIAMOND" }),
+			SetupMocks:    func() {},
+			ExpectedError: ErrInvalidCampaign,
+		},
+		{
+			Name:    "Error. Storage failure #9",
+			Request: weekend,
+			SetupMocks: func() {
+				mockCampaigns.EXPECT().AddCampaign(gomock.Any(), gomock.Any()).Return(nil, errors.New("failed to insert campaign"))
+			},
+			ExpectedError: errors.New("failed to insert campaign"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.SetupMocks()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			campaign, err := campaigns.AddCampaign(ctx, tc.Request)
+
+			if err != nil && tc.ExpectedError == nil {
+				t.Errorf("Expected no error, got: '%v'", err)
+			} else if err == nil && tc.ExpectedError != nil {
+				t.Errorf("Expected error, got none")
+			} else if err != nil && err.Error() != tc.ExpectedError.Error() {
+				t.Errorf("Expected error '%v', got: '%v'", tc.ExpectedError, err)
+			}
+			if diff := cmp.Diff(tc.ExpectedCampaign, campaign); diff != "" {
+				t.Errorf("Campaign mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCampaignService_UpdateCampaign(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockCampaigns := mocks.NewMockCampaignsStorage(ctrl)
+
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+
+	campaigns := NewCampaigns(mockCampaigns, testTiersConfig())
+	request := models.CampaignRequest{
+		Name:     "First order",
+		StartsAt: time.Date(2025, 6, 28, 0, 0, 0, 0, time.UTC),
+		Bonus:    models.CampaignBonus{Fixed: decimal.NewFromInt(100)},
+	}
+
+	testCases := []struct {
+		Name          string
+		ID            int64
+		SetupMocks    func()
+		ExpectedError error
+	}{
+		{
+			Name: "Success. #1",
+			ID:   3,
+			SetupMocks: func() {
+				mockCampaigns.EXPECT().UpdateCampaign(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, c models.Campaign) (*models.Campaign, error) {
+					if c.ID != 3 {
+						t.Errorf("Expected campaign 3, got: %d", c.ID)
+					}
+					return &c, nil
+				})
+			},
+		},
+		{
+			Name: "Error. Not found #2",
+			ID:   4,
+			SetupMocks: func() {
+				mockCampaigns.EXPECT().UpdateCampaign(gomock.Any(), gomock.Any()).Return(nil, storage.ErrCampaignNotFound)
+			},
+			ExpectedError: ErrCampaignNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.SetupMocks()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			_, err := campaigns.UpdateCampaign(ctx, tc.ID, request)
+
+			if err != nil && tc.ExpectedError == nil {
+				t.Errorf("Expected no error, got: '%v'", err)
+			} else if err == nil && tc.ExpectedError != nil {
+				t.Errorf("Expected error, got none")
+			} else if err != nil && err.Error() != tc.ExpectedError.Error() {
+				t.Errorf("Expected error '%v', got: '%v'", tc.ExpectedError, err)
+			}
+		})
+	}
+}
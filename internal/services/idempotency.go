@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/denmor86/ya-gophermart/internal/storage"
+	"go.uber.org/zap"
+)
+
+// MaxIdempotencyKeyLength - максимальная длина ключа Idempotency-Key
+const MaxIdempotencyKeyLength = 255
+
+var (
+	ErrInvalidIdempotencyKey = errors.New("invalid idempotency key")
+	ErrIdempotencyKeyReused  = errors.New("idempotency key reused with different request")
+	ErrRequestInProgress     = errors.New("request with idempotency key in progress")
+)
+
+type IdempotencyService interface {
+	Begin(ctx context.Context, scope string, key string, request []byte) (*models.IdempotentResponse, error)
+	Complete(ctx context.Context, scope string, key string, response models.IdempotentResponse) error
+	Release(ctx context.Context, scope string, key string) error
+	DeleteExpired(ctx context.Context, limit int) (int, error)
+}
+
+type Idempotency struct {
+	IdempotencyStorage storage.IdempotencyStorage
+	TTL                time.Duration
+	LockTimeout        time.Duration
+}
+
+// Создание сервиса
+func NewIdempotency(idempotency storage.IdempotencyStorage, ttl time.Duration, lockTimeout time.Duration) IdempotencyService {
+	return &Idempotency{IdempotencyStorage: idempotency, TTL: ttl, LockTimeout: lockTimeout}
+}
+
+// Begin занимает ключ запросом пользователя. Возвращает nil, если запрос нужно выполнить,
+// и сохранённый ответ, если такой же запрос с этим ключом уже выполнен.
+// Ключ незавершённого запроса занимается повтором после LockTimeout.
+func (s *Idempotency) Begin(ctx context.Context, scope string, key string, request []byte) (*models.IdempotentResponse, error) {
+	if key == "" || len(key) > MaxIdempotencyKeyLength {
+		return nil, ErrInvalidIdempotencyKey
+	}
+
+	hash := sha256.Sum256(request)
+	response, err := s.IdempotencyStorage.BeginRequest(ctx, scope, key, hex.EncodeToString(hash[:]), s.TTL, s.LockTimeout)
+	switch {
+	case errors.Is(err, storage.ErrIdempotencyKeyReused):
+		return nil, ErrIdempotencyKeyReused
+	case errors.Is(err, storage.ErrRequestInProgress):
+		return nil, ErrRequestInProgress
+	case err != nil:
+		logger.Error("Failed to begin idempotent request", zap.Error(err))
+		return nil, err
+	}
+	return response, nil
+}
+
+// Complete сохраняет ответ для повторов запроса с ключом
+func (s *Idempotency) Complete(ctx context.Context, scope string, key string, response models.IdempotentResponse) error {
+	if err := s.IdempotencyStorage.CompleteRequest(ctx, scope, key, response); err != nil {
+		logger.Error("Failed to complete idempotent request", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// Release освобождает ключ после неуспешного выполнения, запрос можно повторить
+func (s *Idempotency) Release(ctx context.Context, scope string, key string) error {
+	if err := s.IdempotencyStorage.ReleaseRequest(ctx, scope, key); err != nil {
+		logger.Error("Failed to release idempotent request", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// DeleteExpired удаление ключей старше TTL, возвращает количество удалённых ключей
+func (s *Idempotency) DeleteExpired(ctx context.Context, limit int) (int, error) {
+	deleted, err := s.IdempotencyStorage.DeleteExpiredKeys(ctx, s.TTL, limit)
+	if err != nil {
+		logger.Error("Failed to delete expired idempotency keys", zap.Error(err))
+		return deleted, err
+	}
+	if deleted > 0 {
+		logger.Info("Expired idempotency keys deleted", deleted)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/config"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/denmor86/ya-gophermart/internal/storage"
+	"github.com/denmor86/ya-gophermart/internal/storage/mocks"
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/mock/gomock"
+)
+
+func TestIdempotencyService_Begin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockIdempotency := mocks.NewMockIdempotencyStorage(ctrl)
+
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+
+	idempotency := NewIdempotency(mockIdempotency, config.Server.IdempotencyTTL, config.Server.IdempotencyLockTimeout)
+
+	request := []byte(`POST /api/user/balance/withdraw
+{"order":"2377225624","sum":751}`)
+	sum := sha256.Sum256(request)
+	hash := hex.EncodeToString(sum[:])
+	stored := &models.IdempotentResponse{Status: 200, ContentType: "text/plain"}
+
+	testCases := []struct {
+		Name             string
+		Key              string
+		SetupMocks       func()
+		ExpectedError    error
+		ExpectedResponse *models.IdempotentResponse
+	}{
+		{
+			Name:          "Error. Empty key #1",
+			Key:           "",
+			SetupMocks:    func() {},
+			ExpectedError: ErrInvalidIdempotencyKey,
+		},
+		{
+			Name:          "Error. Too long key #2",
+			Key:           strings.Repeat("k", MaxIdempotencyKeyLength+1),
+			SetupMocks:    func() {},
+			ExpectedError: ErrInvalidIdempotencyKey,
+		},
+		{
+			Name: "Success. First request #3",
+			Key:  "key-1",
+			SetupMocks: func() {
+				mockIdempotency.EXPECT().BeginRequest(gomock.Any(), "mda", "key-1", hash, 24*time.Hour, 30*time.Second).Return(nil, nil)
+			},
+		},
+		{
+			Name: "Success. Replayed request #4",
+			Key:  "key-2",
+			SetupMocks: func() {
+				mockIdempotency.EXPECT().BeginRequest(gomock.Any(), "mda", "key-2", hash, 24*time.Hour, 30*time.Second).Return(stored, nil)
+			},
+			ExpectedResponse: stored,
+		},
+		{
+			Name: "Error. Key reused #5",
+			Key:  "key-3",
+			SetupMocks: func() {
+				mockIdempotency.EXPECT().BeginRequest(gomock.Any(), "mda", "key-3", hash, 24*time.Hour, 30*time.Second).Return(nil, storage.ErrIdempotencyKeyReused)
+			},
+			ExpectedError: ErrIdempotencyKeyReused,
+		},
+		{
+			Name: "Error. Request in progress #6",
+			Key:  "key-4",
+			SetupMocks: func() {
+				mockIdempotency.EXPECT().BeginRequest(gomock.Any(), "mda", "key-4", hash, 24*time.Hour, 30*time.Second).Return(nil, storage.ErrRequestInProgress)
+			},
+			ExpectedError: ErrRequestInProgress,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.SetupMocks()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			response, err := idempotency.Begin(ctx, "mda", tc.Key, request)
+
+			if !errors.Is(err, tc.ExpectedError) {
+				t.Errorf("Expected error '%v', got: '%v'", tc.ExpectedError, err)
+			}
+			diff := cmp.Diff(tc.ExpectedResponse, response)
+			if len(diff) != 0 {
+				t.Errorf("expected response mismatch:\n %s", diff)
+			}
+		})
+	}
+}
+
+func TestIdempotencyService_DeleteExpired(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockIdempotency := mocks.NewMockIdempotencyStorage(ctrl)
+
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+
+	idempotency := NewIdempotency(mockIdempotency, config.Server.IdempotencyTTL, config.Server.IdempotencyLockTimeout)
+	errStorage := errors.New("storage error")
+
+	testCases := []struct {
+		Name            string
+		SetupMocks      func()
+		ExpectedError   error
+		ExpectedDeleted int
+	}{
+		{
+			Name: "Success. Keys deleted #1",
+			SetupMocks: func() {
+				mockIdempotency.EXPECT().DeleteExpiredKeys(gomock.Any(), 24*time.Hour, 100).Return(42, nil)
+			},
+			ExpectedDeleted: 42,
+		},
+		{
+			Name: "Success. Nothing to delete #2",
+			SetupMocks: func() {
+				mockIdempotency.EXPECT().DeleteExpiredKeys(gomock.Any(), 24*time.Hour, 100).Return(0, nil)
+			},
+		},
+		{
+			Name: "Error. Storage error #3",
+			SetupMocks: func() {
+				mockIdempotency.EXPECT().DeleteExpiredKeys(gomock.Any(), 24*time.Hour, 100).Return(0, errStorage)
+			},
+			ExpectedError: errStorage,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.SetupMocks()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			deleted, err := idempotency.DeleteExpired(ctx, 100)
+
+			if !errors.Is(err, tc.ExpectedError) {
+				t.Errorf("Expected error '%v', got: '%v'", tc.ExpectedError, err)
+			}
+			if deleted != tc.ExpectedDeleted {
+				t.Errorf("Expected deleted: '%v', got: '%v'", tc.ExpectedDeleted, deleted)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"time"
 
+	"github.com/denmor86/ya-gophermart/internal/config"
 	"github.com/denmor86/ya-gophermart/internal/logger"
 	"github.com/denmor86/ya-gophermart/internal/models"
 	"github.com/denmor86/ya-gophermart/internal/storage"
@@ -14,7 +15,8 @@ import (
 )
 
 var (
-	ErrUserAlreadyExists = errors.New("user already exists")
+	ErrUserAlreadyExists   = errors.New("user already exists")
+	ErrInvalidReferralCode = errors.New("invalid referral code")
 )
 
 const (
@@ -30,17 +32,19 @@ type IdentityService interface {
 }
 
 type Identity struct {
-	JWTAuth *jwtauth.JWTAuth
-	Storage storage.UsersStorage
+	JWTAuth   *jwtauth.JWTAuth
+	Storage   storage.UsersStorage
+	Referrals config.ReferralsConfig
 }
 
 // Создание сервиса
-func NewIdentity(JWTSecret string, storage storage.UsersStorage) IdentityService {
+func NewIdentity(JWTSecret string, storage storage.UsersStorage, referrals config.ReferralsConfig) IdentityService {
 	tokenAuth := jwtauth.New(TokenSecterAlgo, []byte(JWTSecret), nil)
-	return &Identity{JWTAuth: tokenAuth, Storage: storage}
+	return &Identity{JWTAuth: tokenAuth, Storage: storage, Referrals: referrals}
 }
 
-// Регистрация нового пользователя.
+// Регистрация нового пользователя. С кодом приглашения пользователь регистрируется приглашённым
+// на текущих условиях реферальной программы.
 func (i *Identity) RegisterUser(context context.Context, user models.UserRequest) error {
 	logger.Info("Register user:", user.Login)
 
@@ -56,7 +60,22 @@ func (i *Identity) RegisterUser(context context.Context, user models.UserRequest
 		return err
 	}
 
-	err = i.Storage.AddUser(context, user.Login, string(hashedPassword))
+	var referral *models.ReferralTerms
+	if user.ReferralCode != "" {
+		referral = &models.ReferralTerms{
+			Code:          user.ReferralCode,
+			ReferrerBonus: i.Referrals.ReferrerBonus,
+			ReferredBonus: i.Referrals.ReferredBonus,
+			MinAccrual:    i.Referrals.MinAccrual,
+			MaxReferrals:  i.Referrals.MaxReferrals,
+		}
+	}
+
+	err = i.Storage.AddUser(context, user.Login, string(hashedPassword), referral)
+	if errors.Is(err, storage.ErrReferralCodeNotFound) {
+		logger.Warn("Unknown referral code", user.ReferralCode)
+		return ErrInvalidReferralCode
+	}
 	if err != nil {
 		logger.Error("Error registering user", user.Login, zap.Error(err))
 		return err
@@ -13,6 +13,7 @@ import (
 	"github.com/denmor86/ya-gophermart/internal/storage/mocks"
 	"golang.org/x/crypto/bcrypt"
 
+	"github.com/shopspring/decimal"
 	"go.uber.org/mock/gomock"
 )
 
@@ -23,7 +24,7 @@ func TestNewIdentityService(t *testing.T) {
 		mockUsers := mocks.NewMockUsersStorage(ctrl)
 
 		config := config.DefaultConfig()
-		identity := NewIdentity(config.Server.JWTSecret, mockUsers)
+		identity := NewIdentity(config.Server.JWTSecret, mockUsers, config.Referrals)
 		baseService, ok := identity.(*Identity)
 		if !ok {
 			t.Fatalf("Expected *Identity, got: '%T'", identity)
@@ -46,6 +47,9 @@ func TestRegisterUser(t *testing.T) {
 	if err := logger.Initialize(config.Server.LogLevel); err != nil {
 		logger.Panic(err)
 	}
+	// по умолчанию программа выключена, условия задаются явно
+	config.Referrals.ReferrerBonus = decimal.NewFromInt(100)
+	config.Referrals.ReferredBonus = decimal.NewFromInt(50)
 
 	testCases := []struct {
 		TestName      string
@@ -57,7 +61,7 @@ func TestRegisterUser(t *testing.T) {
 			TestName: "Success. Register user #1",
 			SetupMocks: func() {
 				mockUsers.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(nil, nil)
-				mockUsers.EXPECT().AddUser(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				mockUsers.EXPECT().AddUser(gomock.Any(), gomock.Any(), gomock.Any(), (*models.ReferralTerms)(nil)).Return(nil)
 			},
 			ExpectedError: nil,
 			User:          models.UserRequest{Login: "mda", Password: "test_pass"},
@@ -74,18 +78,42 @@ func TestRegisterUser(t *testing.T) {
 			TestName: "Error. Register user undefined error #3",
 			SetupMocks: func() {
 				mockUsers.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(nil, nil)
-				mockUsers.EXPECT().AddUser(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("failed to add user"))
+				mockUsers.EXPECT().AddUser(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("failed to add user"))
 			},
 			ExpectedError: errors.New("failed to add user"),
 			User:          models.UserRequest{Login: "mda", Password: "test_pass"},
 		},
+		{
+			TestName: "Success. Register referred user #4",
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(nil, nil)
+				mockUsers.EXPECT().AddUser(gomock.Any(), "mda", gomock.Any(), &models.ReferralTerms{
+					Code:          "ABCDEF1234",
+					ReferrerBonus: config.Referrals.ReferrerBonus,
+					ReferredBonus: config.Referrals.ReferredBonus,
+					MinAccrual:    config.Referrals.MinAccrual,
+					MaxReferrals:  config.Referrals.MaxReferrals,
+				}).Return(nil)
+			},
+			ExpectedError: nil,
+			User:          models.UserRequest{Login: "mda", Password: "test_pass", ReferralCode: "ABCDEF1234"},
+		},
+		{
+			TestName: "Error. Unknown referral code #5",
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(nil, nil)
+				mockUsers.EXPECT().AddUser(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(storage.ErrReferralCodeNotFound)
+			},
+			ExpectedError: ErrInvalidReferralCode,
+			User:          models.UserRequest{Login: "mda", Password: "test_pass", ReferralCode: "UNKNOWN"},
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.TestName, func(t *testing.T) {
 			tc.SetupMocks()
 
-			identity := NewIdentity(config.Server.JWTSecret, mockUsers)
+			identity := NewIdentity(config.Server.JWTSecret, mockUsers, config.Referrals)
 
 			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 			defer cancel()
@@ -154,7 +182,7 @@ func TestAuthenticateUser(t *testing.T) {
 		t.Run(tc.TestName, func(t *testing.T) {
 			mockStorage.EXPECT().GetUser(gomock.Any(), gomock.Any()).DoAndReturn(tc.mockReturn)
 
-			identity := NewIdentity(config.Server.JWTSecret, mockStorage)
+			identity := NewIdentity(config.Server.JWTSecret, mockStorage, config.Referrals)
 
 			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 			defer cancel()
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/config"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/denmor86/ya-gophermart/internal/storage"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidAdjustmentAmount = errors.New("invalid adjustment amount")
+	ErrLedgerEntryNotFound     = errors.New("ledger entry not found")
+	ErrLedgerEntryReversed     = errors.New("ledger entry already reversed")
+)
+
+type LedgerService interface {
+	GetLedger(ctx context.Context, login string) ([]models.LedgerEntry, error)
+	AddAdjustment(ctx context.Context, login string, amount decimal.Decimal, comment string) (*models.LedgerEntry, error)
+	ReverseEntry(ctx context.Context, id int64, comment string) (*models.LedgerEntry, error)
+	CheckLedger(ctx context.Context) (*models.LedgerCheck, error)
+	RebuildBalances(ctx context.Context) (int64, error)
+	ExpirePoints(ctx context.Context, now time.Time) (int, error)
+}
+
+type Ledger struct {
+	LedgerStorage storage.LedgerStorage
+	UsersStorage  storage.UsersStorage
+	Points        config.PointsConfig
+}
+
+// Создание сервиса
+func NewLedger(ledger storage.LedgerStorage, users storage.UsersStorage, points config.PointsConfig) LedgerService {
+	return &Ledger{LedgerStorage: ledger, UsersStorage: users, Points: points}
+}
+
+// GetLedger возвращает записи журнала баллов пользователя
+func (s *Ledger) GetLedger(ctx context.Context, login string) ([]models.LedgerEntry, error) {
+	user, err := s.UsersStorage.GetUser(ctx, login)
+	if err != nil {
+		logger.Warn("Failed to get user", login, zap.Error(err))
+		return nil, err
+	}
+	return s.LedgerStorage.GetLedger(ctx, user.UserID)
+}
+
+// AddAdjustment корректировка баланса пользователя администратором
+func (s *Ledger) AddAdjustment(ctx context.Context, login string, amount decimal.Decimal, comment string) (*models.LedgerEntry, error) {
+	if amount.IsZero() {
+		return nil, ErrInvalidAdjustmentAmount
+	}
+	user, err := s.UsersStorage.GetUser(ctx, login)
+	if err != nil {
+		logger.Warn("Failed to get user", login, zap.Error(err))
+		return nil, err
+	}
+	entry, err := s.LedgerStorage.AddLedgerEntry(ctx, models.LedgerEntry{
+		UserID:  user.UserID,
+		Kind:    models.LedgerAdjustment,
+		Amount:  amount,
+		Comment: comment,
+	})
+	if errors.Is(err, storage.ErrInsufficientFunds) {
+		return nil, ErrInsufficientFunds
+	}
+	if err != nil {
+		logger.Error("Failed to add adjustment", zap.Error(err))
+		return nil, err
+	}
+	logger.Info("Balance adjusted", login, amount.String())
+	return entry, nil
+}
+
+// ReverseEntry отмена записи журнала встречной записью
+func (s *Ledger) ReverseEntry(ctx context.Context, id int64, comment string) (*models.LedgerEntry, error) {
+	entry, err := s.LedgerStorage.ReverseLedgerEntry(ctx, id, comment)
+	switch {
+	case errors.Is(err, storage.ErrLedgerEntryNotFound):
+		return nil, ErrLedgerEntryNotFound
+	case errors.Is(err, storage.ErrAlreadyExists):
+		return nil, ErrLedgerEntryReversed
+	case errors.Is(err, storage.ErrInsufficientFunds):
+		return nil, ErrInsufficientFunds
+	case err != nil:
+		logger.Error("Failed to reverse ledger entry", zap.Error(err))
+		return nil, err
+	}
+	return entry, nil
+}
+
+// CheckLedger проверка совпадения балансов пользователей с суммой записей журнала
+func (s *Ledger) CheckLedger(ctx context.Context) (*models.LedgerCheck, error) {
+	mismatches, err := s.LedgerStorage.CheckLedger(ctx)
+	if err != nil {
+		logger.Error("Failed to check ledger", zap.Error(err))
+		return nil, err
+	}
+	for _, mismatch := range mismatches {
+		logger.Warn("Balance mismatch", mismatch.Login, mismatch.Balance.String(), mismatch.Ledger.String())
+	}
+	if mismatches == nil {
+		mismatches = []models.LedgerMismatch{}
+	}
+	return &models.LedgerCheck{Consistent: len(mismatches) == 0, Mismatches: mismatches}, nil
+}
+
+// RebuildBalances пересчёт балансов пользователей по журналу
+func (s *Ledger) RebuildBalances(ctx context.Context) (int64, error) {
+	count, err := s.LedgerStorage.RebuildBalances(ctx)
+	if err != nil {
+		logger.Error("Failed to rebuild balances", zap.Error(err))
+		return 0, err
+	}
+	logger.Info("Balances rebuilt from ledger", count)
+	return count, nil
+}
+
+// ExpirePoints сгорание баллов, начисленных раньше срока жизни баллов, возвращает количество сгоревших партий
+func (s *Ledger) ExpirePoints(ctx context.Context, now time.Time) (int, error) {
+	if s.Points.TTL <= 0 {
+		return 0, nil
+	}
+	expired, err := s.LedgerStorage.ExpirePoints(ctx, now.Add(-s.Points.TTL), s.Points.ExpireBatch)
+	if err != nil {
+		logger.Error("Failed to expire points", zap.Error(err))
+		return expired, err
+	}
+	if expired > 0 {
+		logger.Info("Points expired", expired)
+	}
+	return expired, nil
+}
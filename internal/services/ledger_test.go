@@ -0,0 +1,304 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/config"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/denmor86/ya-gophermart/internal/storage"
+	"github.com/denmor86/ya-gophermart/internal/storage/mocks"
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
+	"go.uber.org/mock/gomock"
+)
+
+func TestLedgerService_AddAdjustment(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockLedger := mocks.NewMockLedgerStorage(ctrl)
+	mockUsers := mocks.NewMockUsersStorage(ctrl)
+
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+
+	ledger := NewLedger(mockLedger, mockUsers, config.Points)
+
+	testCases := []struct {
+		Name          string
+		Login         string
+		Amount        decimal.Decimal
+		SetupMocks    func()
+		ExpectedError error
+		ExpectedEntry *models.LedgerEntry
+	}{
+		{
+			Name:          "Error. Zero amount #1",
+			Login:         "mda",
+			Amount:        decimal.Zero,
+			SetupMocks:    func() {},
+			ExpectedError: ErrInvalidAdjustmentAmount,
+		},
+		{
+			Name:   "Error. User not found #2",
+			Login:  "mda",
+			Amount: decimal.NewFromInt(10),
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(nil, storage.ErrUserNotFound)
+			},
+			ExpectedError: storage.ErrUserNotFound,
+		},
+		{
+			Name:   "Success. Credit #3",
+			Login:  "mda",
+			Amount: decimal.RequireFromString("729.98"),
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(&models.UserData{UserID: "1"}, nil)
+				mockLedger.EXPECT().AddLedgerEntry(gomock.Any(), models.LedgerEntry{
+					UserID:  "1",
+					Kind:    models.LedgerAdjustment,
+					Amount:  decimal.RequireFromString("729.98"),
+					Comment: "manual",
+				}).Return(&models.LedgerEntry{ID: 7, UserID: "1", Kind: models.LedgerAdjustment, Amount: decimal.RequireFromString("729.98"), Comment: "manual"}, nil)
+			},
+			ExpectedEntry: &models.LedgerEntry{ID: 7, UserID: "1", Kind: models.LedgerAdjustment, Amount: decimal.RequireFromString("729.98"), Comment: "manual"},
+		},
+		{
+			Name:   "Error. Storage failure #4",
+			Login:  "mda",
+			Amount: decimal.NewFromInt(-5),
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(&models.UserData{UserID: "1"}, nil)
+				mockLedger.EXPECT().AddLedgerEntry(gomock.Any(), gomock.Any()).Return(nil, errors.New("insert ledger entry"))
+			},
+			ExpectedError: errors.New("insert ledger entry"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.SetupMocks()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			entry, err := ledger.AddAdjustment(ctx, tc.Login, tc.Amount, "manual")
+
+			if err != nil && tc.ExpectedError == nil {
+				t.Errorf("Expected no error, got: '%v'", err)
+			} else if err == nil && tc.ExpectedError != nil {
+				t.Errorf("Expected error, got none")
+			} else if err != nil && err.Error() != tc.ExpectedError.Error() {
+				t.Errorf("Expected error '%v', got: '%v'", tc.ExpectedError, err)
+			}
+			diff := cmp.Diff(tc.ExpectedEntry, entry)
+			if len(diff) != 0 {
+				t.Errorf("expected entry mismatch:\n %s", diff)
+			}
+		})
+	}
+}
+
+func TestLedgerService_ReverseEntry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockLedger := mocks.NewMockLedgerStorage(ctrl)
+	mockUsers := mocks.NewMockUsersStorage(ctrl)
+
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+
+	ledger := NewLedger(mockLedger, mockUsers, config.Points)
+
+	testCases := []struct {
+		Name          string
+		ID            int64
+		SetupMocks    func()
+		ExpectedError error
+	}{
+		{
+			Name: "Error. Entry not found #1",
+			ID:   1,
+			SetupMocks: func() {
+				mockLedger.EXPECT().ReverseLedgerEntry(gomock.Any(), int64(1), "").Return(nil, storage.ErrLedgerEntryNotFound)
+			},
+			ExpectedError: ErrLedgerEntryNotFound,
+		},
+		{
+			Name: "Error. Already reversed #2",
+			ID:   2,
+			SetupMocks: func() {
+				mockLedger.EXPECT().ReverseLedgerEntry(gomock.Any(), int64(2), "").Return(nil, storage.ErrAlreadyExists)
+			},
+			ExpectedError: ErrLedgerEntryReversed,
+		},
+		{
+			Name: "Success. #3",
+			ID:   3,
+			SetupMocks: func() {
+				mockLedger.EXPECT().ReverseLedgerEntry(gomock.Any(), int64(3), "").Return(&models.LedgerEntry{ID: 4, Kind: models.LedgerReversal, ReversalOf: 3}, nil)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.SetupMocks()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			entry, err := ledger.ReverseEntry(ctx, tc.ID, "")
+
+			if !errors.Is(err, tc.ExpectedError) {
+				t.Errorf("Expected error '%v', got: '%v'", tc.ExpectedError, err)
+			}
+			if tc.ExpectedError == nil && (entry == nil || entry.ReversalOf != tc.ID) {
+				t.Errorf("Expected reversal of %d, got: '%+v'", tc.ID, entry)
+			}
+		})
+	}
+}
+
+func TestLedgerService_CheckLedger(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockLedger := mocks.NewMockLedgerStorage(ctrl)
+	mockUsers := mocks.NewMockUsersStorage(ctrl)
+
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+
+	ledger := NewLedger(mockLedger, mockUsers, config.Points)
+
+	mismatch := models.LedgerMismatch{UserID: "1", Login: "mda", Balance: decimal.NewFromInt(10), Ledger: decimal.NewFromInt(5)}
+
+	testCases := []struct {
+		Name          string
+		SetupMocks    func()
+		ExpectedError error
+		ExpectedCheck *models.LedgerCheck
+	}{
+		{
+			Name: "Success. Consistent #1",
+			SetupMocks: func() {
+				mockLedger.EXPECT().CheckLedger(gomock.Any()).Return(nil, nil)
+			},
+			ExpectedCheck: &models.LedgerCheck{Consistent: true, Mismatches: []models.LedgerMismatch{}},
+		},
+		{
+			Name: "Success. Mismatch #2",
+			SetupMocks: func() {
+				mockLedger.EXPECT().CheckLedger(gomock.Any()).Return([]models.LedgerMismatch{mismatch}, nil)
+			},
+			ExpectedCheck: &models.LedgerCheck{Consistent: false, Mismatches: []models.LedgerMismatch{mismatch}},
+		},
+		{
+			Name: "Error. Storage failure #3",
+			SetupMocks: func() {
+				mockLedger.EXPECT().CheckLedger(gomock.Any()).Return(nil, errors.New("failed to check ledger"))
+			},
+			ExpectedError: errors.New("failed to check ledger"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.SetupMocks()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			check, err := ledger.CheckLedger(ctx)
+
+			if err != nil && tc.ExpectedError == nil {
+				t.Errorf("Expected no error, got: '%v'", err)
+			} else if err == nil && tc.ExpectedError != nil {
+				t.Errorf("Expected error, got none")
+			} else if err != nil && err.Error() != tc.ExpectedError.Error() {
+				t.Errorf("Expected error '%v', got: '%v'", tc.ExpectedError, err)
+			}
+			diff := cmp.Diff(tc.ExpectedCheck, check)
+			if len(diff) != 0 {
+				t.Errorf("expected check mismatch:\n %s", diff)
+			}
+		})
+	}
+}
+
+func TestLedgerService_ExpirePoints(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockLedger := mocks.NewMockLedgerStorage(ctrl)
+	mockUsers := mocks.NewMockUsersStorage(ctrl)
+
+	cfg := config.DefaultConfig()
+	if err := logger.Initialize(cfg.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+
+	now := time.Date(2025, 6, 22, 10, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		Name            string
+		Points          func() config.PointsConfig
+		SetupMocks      func()
+		ExpectedError   error
+		ExpectedExpired int
+	}{
+		{
+			Name:       "Success. Expiration disabled #1",
+			Points:     func() config.PointsConfig { return config.PointsConfig{} },
+			SetupMocks: func() {},
+		},
+		{
+			Name:   "Success. Lots expired #2",
+			Points: func() config.PointsConfig { return cfg.Points },
+			SetupMocks: func() {
+				mockLedger.EXPECT().ExpirePoints(gomock.Any(), now.Add(-cfg.Points.TTL), cfg.Points.ExpireBatch).Return(3, nil)
+			},
+			ExpectedExpired: 3,
+		},
+		{
+			Name:   "Error. Storage failure #3",
+			Points: func() config.PointsConfig { return cfg.Points },
+			SetupMocks: func() {
+				mockLedger.EXPECT().ExpirePoints(gomock.Any(), now.Add(-cfg.Points.TTL), cfg.Points.ExpireBatch).Return(1, errors.New("expire point lot"))
+			},
+			ExpectedError:   errors.New("expire point lot"),
+			ExpectedExpired: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.SetupMocks()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			ledger := NewLedger(mockLedger, mockUsers, tc.Points())
+			expired, err := ledger.ExpirePoints(ctx, now)
+
+			if err != nil && tc.ExpectedError == nil {
+				t.Errorf("Expected no error, got: '%v'", err)
+			} else if err == nil && tc.ExpectedError != nil {
+				t.Errorf("Expected error, got none")
+			} else if err != nil && err.Error() != tc.ExpectedError.Error() {
+				t.Errorf("Expected error '%v', got: '%v'", tc.ExpectedError, err)
+			}
+			if expired != tc.ExpectedExpired {
+				t.Errorf("Expected %d expired lots, got: %d", tc.ExpectedExpired, expired)
+			}
+		})
+	}
+}
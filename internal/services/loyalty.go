@@ -3,7 +3,9 @@ package services
 import (
 	"context"
 	"errors"
+	"time"
 
+	"github.com/denmor86/ya-gophermart/internal/config"
 	"github.com/denmor86/ya-gophermart/internal/logger"
 	"github.com/denmor86/ya-gophermart/internal/models"
 	"github.com/denmor86/ya-gophermart/internal/storage"
@@ -14,22 +16,34 @@ import (
 var (
 	ErrInsufficientFunds       = errors.New("insufficient funds for withdrawal")
 	ErrInvalidWithdrawalAmount = errors.New("invalid withdrawal amount")
+	ErrWithdrawalAlreadyExists = errors.New("order already paid with points")
+	ErrHoldNotFound            = errors.New("hold not found")
+	ErrHoldNotActive           = errors.New("hold is not active")
 )
 
 type LoyaltyService interface {
 	GetBalance(ctx context.Context, login string) (*models.UserBalance, error)
+	GetBalanceAt(ctx context.Context, login string, at time.Time) (*models.UserBalance, error)
+	GetBalanceHistory(ctx context.Context, login string, filter models.BalanceHistoryFilter) ([]models.BalanceHistoryEntry, error)
 	GetWithdrawals(ctx context.Context, login string) ([]models.WithdrawalData, error)
 	ProcessWithdraw(ctx context.Context, login string, order string, sum decimal.Decimal) error
+	PlaceHold(ctx context.Context, login string, order string, sum decimal.Decimal) (*models.HoldData, error)
+	CaptureHold(ctx context.Context, login string, id int64) (*models.HoldData, error)
+	ReleaseHold(ctx context.Context, login string, id int64) (*models.HoldData, error)
+	ReleaseExpiredHolds(ctx context.Context) (int, error)
 }
 
 type Loyalty struct {
 	LoyaltysStorage storage.LoyaltysStorage
 	UsersStorage    storage.UsersStorage
+	LedgerStorage   storage.LedgerStorage
+	Points          config.PointsConfig
+	Holds           config.HoldsConfig
 }
 
 // Создание сервиса
-func NewLoyalty(loyaltys storage.LoyaltysStorage, users storage.UsersStorage) LoyaltyService {
-	return &Loyalty{LoyaltysStorage: loyaltys, UsersStorage: users}
+func NewLoyalty(loyaltys storage.LoyaltysStorage, users storage.UsersStorage, ledger storage.LedgerStorage, points config.PointsConfig, holds config.HoldsConfig) LoyaltyService {
+	return &Loyalty{LoyaltysStorage: loyaltys, UsersStorage: users, LedgerStorage: ledger, Points: points, Holds: holds}
 }
 
 // GetBalance возващает баланс баллов пользователя
@@ -41,9 +55,64 @@ func (s *Loyalty) GetBalance(ctx context.Context, login string) (*models.UserBal
 		return nil, err
 	}
 
+	// Баллы, которые сгорят в течение срока предупреждения
+	if s.Points.TTL <= 0 || s.Points.ExpiryNotice <= 0 {
+		return userBalance, nil
+	}
+	user, err := s.UsersStorage.GetUser(ctx, login)
+	if err != nil {
+		logger.Error("Failed to get user", zap.Error(err))
+		return nil, err
+	}
+	lots, err := s.LedgerStorage.GetPointLots(ctx, user.UserID, time.Now().Add(s.Points.ExpiryNotice-s.Points.TTL))
+	if err != nil {
+		logger.Error("Failed to get point lots", zap.Error(err))
+		return nil, err
+	}
+	for _, lot := range lots {
+		userBalance.Expiring = append(userBalance.Expiring, models.ExpiringPoints{
+			Amount:    lot.Remaining,
+			ExpiresAt: lot.CreatedAt.Add(s.Points.TTL),
+		})
+	}
+
+	return userBalance, nil
+}
+
+// GetBalanceAt возвращает баланс баллов пользователя на момент времени
+func (s *Loyalty) GetBalanceAt(ctx context.Context, login string, at time.Time) (*models.UserBalance, error) {
+	user, err := s.UsersStorage.GetUser(ctx, login)
+	if err != nil {
+		logger.Error("Failed to get user", zap.Error(err))
+		return nil, err
+	}
+
+	userBalance, err := s.LedgerStorage.GetBalanceAt(ctx, user.UserID, at)
+	if err != nil {
+		logger.Error("Failed to get user balance", zap.Error(err))
+		return nil, err
+	}
+
 	return userBalance, nil
 }
 
+// GetBalanceHistory возвращает движения баллов пользователя за период с балансом после каждого движения
+func (s *Loyalty) GetBalanceHistory(ctx context.Context, login string, filter models.BalanceHistoryFilter) ([]models.BalanceHistoryEntry, error) {
+	user, err := s.UsersStorage.GetUser(ctx, login)
+	if err != nil {
+		logger.Error("Failed to get user", zap.Error(err))
+		return nil, err
+	}
+
+	history, err := s.LedgerStorage.GetBalanceHistory(ctx, user.UserID, filter)
+	if err != nil {
+		logger.Error("Failed to get balance history", zap.Error(err))
+		return nil, err
+	}
+
+	return history, nil
+}
+
 // GetLoyalty возвращает список всех выводов средств пользователя по его логину
 func (s *Loyalty) GetWithdrawals(ctx context.Context, login string) ([]models.WithdrawalData, error) {
 	user, err := s.UsersStorage.GetUser(ctx, login)
@@ -74,22 +143,115 @@ func (s *Loyalty) ProcessWithdraw(ctx context.Context, login string, orderNumber
 		return err
 	}
 
-	// Проверка на отрицательную сумму при выводе средств
-	if sum.LessThan(decimal.Zero) {
+	// Сумма списания должна быть положительной
+	if !sum.IsPositive() {
 		return ErrInvalidWithdrawalAmount
 	}
 
-	// Проверяем, достаточно ли средств для вывода
-	if user.Balance.LessThan(sum) {
+	withdrawal := models.WithdrawalData{
+		OrderNumber: orderNumber,
+		UserID:      user.UserID,
+		Amount:      sum,
+	}
+
+	// Проверка баланса и списание выполняются хранилищем атомарно,
+	// поэтому одновременные списания не уводят баланс в минус
+	err = s.LoyaltysStorage.AddWithdrawal(ctx, withdrawal)
+	switch {
+	case errors.Is(err, storage.ErrInsufficientFunds):
 		return ErrInsufficientFunds
+	case errors.Is(err, storage.ErrAlreadyExists):
+		return ErrWithdrawalAlreadyExists
 	}
+	return err
+}
 
-	withdrawal := models.WithdrawalData{
-		OrderNumber: orderNumber,
+// PlaceHold резервирование баллов под списание за заказ.
+// Резерв списывается или снимается системой оформления заказа, незавершённый резерв снимается по истечении времени.
+func (s *Loyalty) PlaceHold(ctx context.Context, login string, orderNumber string, sum decimal.Decimal) (*models.HoldData, error) {
+	user, err := s.UsersStorage.GetUser(ctx, login)
+	if err != nil {
+		logger.Error("Failed to get user", zap.Error(err))
+		return nil, err
+	}
+
+	// Сумма резерва должна быть положительной
+	if !sum.IsPositive() {
+		return nil, ErrInvalidWithdrawalAmount
+	}
+
+	hold, err := s.LoyaltysStorage.AddHold(ctx, models.HoldData{
 		UserID:      user.UserID,
+		OrderNumber: orderNumber,
 		Amount:      sum,
+	}, s.Holds.TTL)
+	switch {
+	case errors.Is(err, storage.ErrInsufficientFunds):
+		return nil, ErrInsufficientFunds
+	case errors.Is(err, storage.ErrAlreadyExists):
+		return nil, ErrWithdrawalAlreadyExists
+	case err != nil:
+		logger.Error("Failed to place hold", zap.Error(err))
+		return nil, err
+	}
+	return hold, nil
+}
+
+// CaptureHold списание зарезервированных баллов
+func (s *Loyalty) CaptureHold(ctx context.Context, login string, id int64) (*models.HoldData, error) {
+	user, err := s.UsersStorage.GetUser(ctx, login)
+	if err != nil {
+		logger.Error("Failed to get user", zap.Error(err))
+		return nil, err
 	}
 
-	// Добавляем информацию о выводе и обновляем баланс пользователя
-	return s.LoyaltysStorage.AddWithdrawal(ctx, withdrawal)
+	hold, err := s.LoyaltysStorage.CaptureHold(ctx, user.UserID, id)
+	if err != nil {
+		return nil, holdError(err, "Failed to capture hold")
+	}
+	return hold, nil
+}
+
+// ReleaseHold снятие резерва баллов
+func (s *Loyalty) ReleaseHold(ctx context.Context, login string, id int64) (*models.HoldData, error) {
+	user, err := s.UsersStorage.GetUser(ctx, login)
+	if err != nil {
+		logger.Error("Failed to get user", zap.Error(err))
+		return nil, err
+	}
+
+	hold, err := s.LoyaltysStorage.ReleaseHold(ctx, user.UserID, id)
+	if err != nil {
+		return nil, holdError(err, "Failed to release hold")
+	}
+	return hold, nil
+}
+
+// ReleaseExpiredHolds снятие просроченных резервов, возвращает количество снятых резервов
+func (s *Loyalty) ReleaseExpiredHolds(ctx context.Context) (int, error) {
+	released, err := s.LoyaltysStorage.ReleaseExpiredHolds(ctx, s.Holds.ReleaseBatch)
+	if err != nil {
+		logger.Error("Failed to release expired holds", zap.Error(err))
+		return released, err
+	}
+	if released > 0 {
+		logger.Info("Expired holds released", released)
+	}
+	return released, nil
+}
+
+// holdError - ошибка сервиса по ошибке хранилища при завершении резерва
+func holdError(err error, message string) error {
+	switch {
+	case errors.Is(err, storage.ErrHoldNotFound):
+		return ErrHoldNotFound
+	case errors.Is(err, storage.ErrHoldNotActive):
+		return ErrHoldNotActive
+	case errors.Is(err, storage.ErrAlreadyExists):
+		return ErrWithdrawalAlreadyExists
+	case errors.Is(err, storage.ErrInsufficientFunds):
+		return ErrInsufficientFunds
+	}
+	logger.Error(message, zap.Error(err))
+	return err
 }
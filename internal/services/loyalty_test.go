@@ -21,13 +21,15 @@ func TestLoyaltyService_GetBalance(t *testing.T) {
 	defer ctrl.Finish()
 	mockLoyaltys := mocks.NewMockLoyaltysStorage(ctrl)
 	mockUsers := mocks.NewMockUsersStorage(ctrl)
+	mockLedger := mocks.NewMockLedgerStorage(ctrl)
 
 	config := config.DefaultConfig()
 	if err := logger.Initialize(config.Server.LogLevel); err != nil {
 		logger.Panic(err)
 	}
 
-	loyalty := NewLoyalty(mockLoyaltys, mockUsers)
+	loyalty := NewLoyalty(mockLoyaltys, mockUsers, mockLedger, config.Points, config.Holds)
+	lotCreatedAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
 
 	testCases := []struct {
 		Name            string
@@ -58,10 +60,44 @@ func TestLoyaltyService_GetBalance(t *testing.T) {
 			Name:  "Success. #3",
 			Login: "mda",
 			SetupMocks: func() {
-				mockUsers.EXPECT().GetUserBalance(gomock.Any(), "mda").Return(&models.UserBalance{Current: 10, Withdrawn: 5}, nil)
+				mockUsers.EXPECT().GetUserBalance(gomock.Any(), "mda").Return(&models.UserBalance{Current: decimal.NewFromInt(10), Withdrawn: decimal.NewFromInt(5)}, nil)
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(&models.UserData{UserID: "1"}, nil)
+				mockLedger.EXPECT().GetPointLots(gomock.Any(), "1", gomock.Any()).Return(nil, nil)
 			},
 			ExpectedError:   nil,
-			ExpectedBalance: &models.UserBalance{Current: 10, Withdrawn: 5},
+			ExpectedBalance: &models.UserBalance{Current: decimal.NewFromInt(10), Withdrawn: decimal.NewFromInt(5)},
+		},
+		{
+			Name:  "Success. Expiring points #4",
+			Login: "mda",
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUserBalance(gomock.Any(), "mda").Return(&models.UserBalance{Current: decimal.NewFromInt(10), Withdrawn: decimal.NewFromInt(5)}, nil)
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(&models.UserData{UserID: "1"}, nil)
+				mockLedger.EXPECT().GetPointLots(gomock.Any(), "1", gomock.Any()).Return([]models.PointLot{
+					{ID: 1, UserID: "1", Amount: decimal.NewFromInt(8), Remaining: decimal.NewFromInt(3), CreatedAt: lotCreatedAt},
+					{ID: 2, UserID: "1", Amount: decimal.NewFromInt(4), Remaining: decimal.NewFromInt(4), CreatedAt: lotCreatedAt.Add(time.Hour)},
+				}, nil)
+			},
+			ExpectedError: nil,
+			ExpectedBalance: &models.UserBalance{
+				Current:   decimal.NewFromInt(10),
+				Withdrawn: decimal.NewFromInt(5),
+				Expiring: []models.ExpiringPoints{
+					{Amount: decimal.NewFromInt(3), ExpiresAt: lotCreatedAt.Add(config.Points.TTL)},
+					{Amount: decimal.NewFromInt(4), ExpiresAt: lotCreatedAt.Add(config.Points.TTL + time.Hour)},
+				},
+			},
+		},
+		{
+			Name:  "Error. Failed get point lots #5",
+			Login: "mda",
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUserBalance(gomock.Any(), "mda").Return(&models.UserBalance{Current: decimal.NewFromInt(10), Withdrawn: decimal.NewFromInt(5)}, nil)
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(&models.UserData{UserID: "1"}, nil)
+				mockLedger.EXPECT().GetPointLots(gomock.Any(), "1", gomock.Any()).Return(nil, errors.New("failed to get point lots"))
+			},
+			ExpectedError:   errors.New("failed to get point lots"),
+			ExpectedBalance: nil,
 		},
 	}
 
@@ -94,13 +130,14 @@ func TestLoyaltyService_GetWithdrawals(t *testing.T) {
 	defer ctrl.Finish()
 	mockLoyaltys := mocks.NewMockLoyaltysStorage(ctrl)
 	mockUsers := mocks.NewMockUsersStorage(ctrl)
+	mockLedger := mocks.NewMockLedgerStorage(ctrl)
 
 	config := config.DefaultConfig()
 	if err := logger.Initialize(config.Server.LogLevel); err != nil {
 		logger.Panic(err)
 	}
 
-	loyalty := NewLoyalty(mockLoyaltys, mockUsers)
+	loyalty := NewLoyalty(mockLoyaltys, mockUsers, mockLedger, config.Points, config.Holds)
 
 	testCases := []struct {
 		Name                string
@@ -175,13 +212,14 @@ func TestLoyaltyService_ProcessWithdraw(t *testing.T) {
 	defer ctrl.Finish()
 	mockLoyaltys := mocks.NewMockLoyaltysStorage(ctrl)
 	mockUsers := mocks.NewMockUsersStorage(ctrl)
+	mockLedger := mocks.NewMockLedgerStorage(ctrl)
 
 	config := config.DefaultConfig()
 	if err := logger.Initialize(config.Server.LogLevel); err != nil {
 		logger.Panic(err)
 	}
 
-	loyalty := NewLoyalty(mockLoyaltys, mockUsers)
+	loyalty := NewLoyalty(mockLoyaltys, mockUsers, mockLedger, config.Points, config.Holds)
 
 	testCases := []struct {
 		Name          string
@@ -216,12 +254,14 @@ func TestLoyaltyService_ProcessWithdraw(t *testing.T) {
 			Sum:    decimal.NewFromInt(11),
 			SetupMocks: func() {
 				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(&models.UserData{UserID: "1", Balance: decimal.NewFromInt(10)}, nil)
+				mockLoyaltys.EXPECT().AddWithdrawal(gomock.Any(), gomock.Any()).Return(storage.ErrInsufficientFunds)
 			},
 			ExpectedError: ErrInsufficientFunds,
 		},
 		{
 			Name:  "Error. Failed add withdrawals #4",
 			Login: "mda",
+			Sum:   decimal.NewFromInt(5),
 			SetupMocks: func() {
 				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(&models.UserData{UserID: "1", Balance: decimal.NewFromInt(10)}, nil)
 				mockLoyaltys.EXPECT().AddWithdrawal(gomock.Any(), gomock.Any()).Return(errors.New("failed to get orders"))
@@ -231,12 +271,34 @@ func TestLoyaltyService_ProcessWithdraw(t *testing.T) {
 		{
 			Name:  "Success. #5",
 			Login: "mda",
+			Sum:   decimal.NewFromInt(5),
 			SetupMocks: func() {
 				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(&models.UserData{UserID: "1", Balance: decimal.NewFromInt(10)}, nil)
 				mockLoyaltys.EXPECT().AddWithdrawal(gomock.Any(), gomock.Any()).Return(nil)
 			},
 			ExpectedError: nil,
 		},
+		{
+			Name:   "Error. Zero withdrawal amount #6",
+			Login:  "mda",
+			Number: "1",
+			Sum:    decimal.Zero,
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(&models.UserData{UserID: "1", Balance: decimal.NewFromInt(10)}, nil)
+			},
+			ExpectedError: ErrInvalidWithdrawalAmount,
+		},
+		{
+			Name:   "Error. Order already paid #7",
+			Login:  "mda",
+			Number: "1",
+			Sum:    decimal.NewFromInt(5),
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(&models.UserData{UserID: "1", Balance: decimal.NewFromInt(10)}, nil)
+				mockLoyaltys.EXPECT().AddWithdrawal(gomock.Any(), gomock.Any()).Return(storage.ErrAlreadyExists)
+			},
+			ExpectedError: ErrWithdrawalAlreadyExists,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -258,3 +320,358 @@ func TestLoyaltyService_ProcessWithdraw(t *testing.T) {
 		})
 	}
 }
+
+func TestLoyaltyService_GetBalanceHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockLoyaltys := mocks.NewMockLoyaltysStorage(ctrl)
+	mockUsers := mocks.NewMockUsersStorage(ctrl)
+	mockLedger := mocks.NewMockLedgerStorage(ctrl)
+
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+
+	loyalty := NewLoyalty(mockLoyaltys, mockUsers, mockLedger, config.Points, config.Holds)
+
+	filter := models.BalanceHistoryFilter{From: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), Limit: 2}
+	history := []models.BalanceHistoryEntry{
+		{ID: 1, Kind: models.LedgerAccrual, Amount: decimal.RequireFromString("729.98"), Balance: decimal.RequireFromString("729.98"), OrderNumber: "12345678903"},
+		{ID: 2, Kind: models.LedgerWithdrawal, Amount: decimal.RequireFromString("-29.98"), Balance: decimal.NewFromInt(700), OrderNumber: "2377225624"},
+	}
+
+	testCases := []struct {
+		Name            string
+		Login           string
+		SetupMocks      func()
+		ExpectedError   error
+		ExpectedHistory []models.BalanceHistoryEntry
+	}{
+		{
+			Name:  "Error. User not found #1",
+			Login: "mda",
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(nil, storage.ErrUserNotFound)
+			},
+			ExpectedError: storage.ErrUserNotFound,
+		},
+		{
+			Name:  "Error. Failed get history #2",
+			Login: "mda",
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(&models.UserData{UserID: "1"}, nil)
+				mockLedger.EXPECT().GetBalanceHistory(gomock.Any(), "1", filter).Return(nil, errors.New("failed to get balance history"))
+			},
+			ExpectedError: errors.New("failed to get balance history"),
+		},
+		{
+			Name:  "Success. #3",
+			Login: "mda",
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(&models.UserData{UserID: "1"}, nil)
+				mockLedger.EXPECT().GetBalanceHistory(gomock.Any(), "1", filter).Return(history, nil)
+			},
+			ExpectedHistory: history,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.SetupMocks()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			result, err := loyalty.GetBalanceHistory(ctx, tc.Login, filter)
+
+			if err != nil && tc.ExpectedError == nil {
+				t.Errorf("Expected no error, got: '%v'", err)
+			} else if err == nil && tc.ExpectedError != nil {
+				t.Errorf("Expected error, got none")
+			} else if err != nil && err.Error() != tc.ExpectedError.Error() {
+				t.Errorf("Expected error '%v', got: '%v'", tc.ExpectedError, err)
+			}
+			diff := cmp.Diff(tc.ExpectedHistory, result)
+			if len(diff) != 0 {
+				t.Errorf("expected history mismatch:\n %s", diff)
+			}
+		})
+	}
+}
+
+func TestLoyaltyService_GetBalanceAt(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockLoyaltys := mocks.NewMockLoyaltysStorage(ctrl)
+	mockUsers := mocks.NewMockUsersStorage(ctrl)
+	mockLedger := mocks.NewMockLedgerStorage(ctrl)
+
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+
+	loyalty := NewLoyalty(mockLoyaltys, mockUsers, mockLedger, config.Points, config.Holds)
+
+	at := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		Name            string
+		Login           string
+		SetupMocks      func()
+		ExpectedError   error
+		ExpectedBalance *models.UserBalance
+	}{
+		{
+			Name:  "Error. User not found #1",
+			Login: "mda",
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(nil, storage.ErrUserNotFound)
+			},
+			ExpectedError: storage.ErrUserNotFound,
+		},
+		{
+			Name:  "Success. #2",
+			Login: "mda",
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(&models.UserData{UserID: "1"}, nil)
+				mockLedger.EXPECT().GetBalanceAt(gomock.Any(), "1", at).Return(&models.UserBalance{Current: decimal.NewFromInt(700), Withdrawn: decimal.RequireFromString("29.98")}, nil)
+			},
+			ExpectedBalance: &models.UserBalance{Current: decimal.NewFromInt(700), Withdrawn: decimal.RequireFromString("29.98")},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.SetupMocks()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			balance, err := loyalty.GetBalanceAt(ctx, tc.Login, at)
+
+			if !errors.Is(err, tc.ExpectedError) {
+				t.Errorf("Expected error '%v', got: '%v'", tc.ExpectedError, err)
+			}
+			diff := cmp.Diff(tc.ExpectedBalance, balance)
+			if len(diff) != 0 {
+				t.Errorf("expected balance mismatch:\n %s", diff)
+			}
+		})
+	}
+}
+
+func TestLoyaltyService_PlaceHold(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockLoyaltys := mocks.NewMockLoyaltysStorage(ctrl)
+	mockUsers := mocks.NewMockUsersStorage(ctrl)
+	mockLedger := mocks.NewMockLedgerStorage(ctrl)
+
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+
+	loyalty := NewLoyalty(mockLoyaltys, mockUsers, mockLedger, config.Points, config.Holds)
+	createdAt := time.Date(2025, 6, 23, 10, 0, 0, 0, time.UTC)
+	hold := models.HoldData{
+		ID:          1,
+		UserID:      "1",
+		OrderNumber: "2377225624",
+		Amount:      decimal.NewFromInt(5),
+		Status:      models.HoldHeld,
+		CreatedAt:   createdAt,
+		ExpiresAt:   createdAt.Add(config.Holds.TTL),
+	}
+
+	testCases := []struct {
+		Name          string
+		Login         string
+		Number        string
+		Sum           decimal.Decimal
+		SetupMocks    func()
+		ExpectedError error
+		ExpectedHold  *models.HoldData
+	}{
+		{
+			Name:  "Error. User not found #1",
+			Login: "mda",
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(nil, storage.ErrUserNotFound)
+			},
+			ExpectedError: storage.ErrUserNotFound,
+		},
+		{
+			Name:   "Error. Invalid hold amount #2",
+			Login:  "mda",
+			Number: "2377225624",
+			Sum:    decimal.Zero,
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(&models.UserData{UserID: "1"}, nil)
+			},
+			ExpectedError: ErrInvalidWithdrawalAmount,
+		},
+		{
+			Name:   "Error. Insufficient funds #3",
+			Login:  "mda",
+			Number: "2377225624",
+			Sum:    decimal.NewFromInt(11),
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(&models.UserData{UserID: "1"}, nil)
+				mockLoyaltys.EXPECT().AddHold(gomock.Any(), gomock.Any(), config.Holds.TTL).Return(nil, storage.ErrInsufficientFunds)
+			},
+			ExpectedError: ErrInsufficientFunds,
+		},
+		{
+			Name:   "Error. Order already paid #4",
+			Login:  "mda",
+			Number: "2377225624",
+			Sum:    decimal.NewFromInt(5),
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(&models.UserData{UserID: "1"}, nil)
+				mockLoyaltys.EXPECT().AddHold(gomock.Any(), gomock.Any(), config.Holds.TTL).Return(nil, storage.ErrAlreadyExists)
+			},
+			ExpectedError: ErrWithdrawalAlreadyExists,
+		},
+		{
+			Name:   "Success. #5",
+			Login:  "mda",
+			Number: "2377225624",
+			Sum:    decimal.NewFromInt(5),
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(&models.UserData{UserID: "1"}, nil)
+				mockLoyaltys.EXPECT().AddHold(gomock.Any(), models.HoldData{
+					UserID:      "1",
+					OrderNumber: "2377225624",
+					Amount:      decimal.NewFromInt(5),
+				}, config.Holds.TTL).Return(&hold, nil)
+			},
+			ExpectedHold: &hold,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.SetupMocks()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			result, err := loyalty.PlaceHold(ctx, tc.Login, tc.Number, tc.Sum)
+
+			if err != nil && tc.ExpectedError == nil {
+				t.Errorf("Expected no error, got: '%v'", err)
+			} else if err == nil && tc.ExpectedError != nil {
+				t.Errorf("Expected error, got none")
+			} else if err != nil && err.Error() != tc.ExpectedError.Error() {
+				t.Errorf("Expected error '%v', got: '%v'", tc.ExpectedError, err)
+			}
+			diff := cmp.Diff(tc.ExpectedHold, result)
+			if len(diff) != 0 {
+				t.Errorf("expected hold mismatch:\n %s", diff)
+			}
+		})
+	}
+}
+
+func TestLoyaltyService_CompleteHold(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockLoyaltys := mocks.NewMockLoyaltysStorage(ctrl)
+	mockUsers := mocks.NewMockUsersStorage(ctrl)
+	mockLedger := mocks.NewMockLedgerStorage(ctrl)
+
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+
+	loyalty := NewLoyalty(mockLoyaltys, mockUsers, mockLedger, config.Points, config.Holds)
+	captured := models.HoldData{ID: 1, UserID: "1", OrderNumber: "2377225624", Amount: decimal.NewFromInt(5), Status: models.HoldCaptured}
+	released := models.HoldData{ID: 1, UserID: "1", OrderNumber: "2377225624", Amount: decimal.NewFromInt(5), Status: models.HoldReleased}
+
+	testCases := []struct {
+		Name          string
+		Capture       bool
+		SetupMocks    func()
+		ExpectedError error
+		ExpectedHold  *models.HoldData
+	}{
+		{
+			Name:    "Success. Capture #1",
+			Capture: true,
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(&models.UserData{UserID: "1"}, nil)
+				mockLoyaltys.EXPECT().CaptureHold(gomock.Any(), "1", int64(1)).Return(&captured, nil)
+			},
+			ExpectedHold: &captured,
+		},
+		{
+			Name:    "Error. Capture other user hold #2",
+			Capture: true,
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(&models.UserData{UserID: "1"}, nil)
+				mockLoyaltys.EXPECT().CaptureHold(gomock.Any(), "1", int64(1)).Return(nil, storage.ErrHoldNotFound)
+			},
+			ExpectedError: ErrHoldNotFound,
+		},
+		{
+			Name:    "Error. Capture released hold #3",
+			Capture: true,
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(&models.UserData{UserID: "1"}, nil)
+				mockLoyaltys.EXPECT().CaptureHold(gomock.Any(), "1", int64(1)).Return(nil, storage.ErrHoldNotActive)
+			},
+			ExpectedError: ErrHoldNotActive,
+		},
+		{
+			Name: "Success. Release #4",
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(&models.UserData{UserID: "1"}, nil)
+				mockLoyaltys.EXPECT().ReleaseHold(gomock.Any(), "1", int64(1)).Return(&released, nil)
+			},
+			ExpectedHold: &released,
+		},
+		{
+			Name: "Error. Release failure #5",
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(&models.UserData{UserID: "1"}, nil)
+				mockLoyaltys.EXPECT().ReleaseHold(gomock.Any(), "1", int64(1)).Return(nil, errors.New("release balance"))
+			},
+			ExpectedError: errors.New("release balance"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.SetupMocks()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			var (
+				result *models.HoldData
+				err    error
+			)
+			if tc.Capture {
+				result, err = loyalty.CaptureHold(ctx, "mda", 1)
+			} else {
+				result, err = loyalty.ReleaseHold(ctx, "mda", 1)
+			}
+
+			if err != nil && tc.ExpectedError == nil {
+				t.Errorf("Expected no error, got: '%v'", err)
+			} else if err == nil && tc.ExpectedError != nil {
+				t.Errorf("Expected error, got none")
+			} else if err != nil && err.Error() != tc.ExpectedError.Error() {
+				t.Errorf("Expected error '%v', got: '%v'", tc.ExpectedError, err)
+			}
+			diff := cmp.Diff(tc.ExpectedHold, result)
+			if len(diff) != 0 {
+				t.Errorf("expected hold mismatch:\n %s", diff)
+			}
+		})
+	}
+}
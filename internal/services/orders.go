@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/denmor86/ya-gophermart/internal/client"
+	"github.com/denmor86/ya-gophermart/internal/config"
 	"github.com/denmor86/ya-gophermart/internal/logger"
 	"github.com/denmor86/ya-gophermart/internal/models"
 	"github.com/denmor86/ya-gophermart/internal/storage"
@@ -18,29 +19,36 @@ var (
 	ErrOrderAlreadyUploaded   = errors.New("order already uploaded by this user")
 	ErrOrderNotFound          = errors.New("order not found")
 	ErrOrderUploadedByAnother = errors.New("order already uploaded by another user")
+	ErrOrderFinalized         = errors.New("order is in final status")
 )
 
 // OrdersService - представляет интерфейс для работы с сервисом заказов
 type OrdersService interface {
-	AddOrder(ctx context.Context, login string, number string) error
+	AddOrder(ctx context.Context, login string, number string, merchant string) error
 	GetOrders(ctx context.Context, login string) ([]models.OrderData, error)
-	ClaimOrdersForProcessing(ctx context.Context, count int) ([]string, error)
+	ClaimOrdersForProcessing(ctx context.Context, count int, lease time.Duration) ([]string, error)
 	ProcessOrder(ctx context.Context, number string) error
+	ProcessOrders(ctx context.Context, numbers []string, timeout time.Duration) error
+	GetQueueStats(ctx context.Context) (map[string]int, error)
+	RequeueOrder(ctx context.Context, number string, priority int) error
+	SetUserPriority(ctx context.Context, login string, priority int) error
 }
 
 type Orders struct {
 	OrdersStorage storage.OrdersStorage
 	UsersStorage  storage.UsersStorage
 	Accrual       client.AccrualService
+	Tiers         config.TiersConfig
 }
 
 // Создание сервиса
-func NewOrders(accrual client.AccrualService, orders storage.OrdersStorage, users storage.UsersStorage) OrdersService {
-	return &Orders{OrdersStorage: orders, UsersStorage: users, Accrual: accrual}
+func NewOrders(accrual client.AccrualService, orders storage.OrdersStorage, users storage.UsersStorage, tiers config.TiersConfig) OrdersService {
+	return &Orders{OrdersStorage: orders, UsersStorage: users, Accrual: accrual, Tiers: tiers}
 }
 
 // AddOrder - добавляет новый заказ, проверяя, не был ли он уже добавлен другим пользователем.
-func (s *Orders) AddOrder(ctx context.Context, login string, number string) error {
+// Продавец заказа необязателен и используется для выбора провайдера начислений.
+func (s *Orders) AddOrder(ctx context.Context, login string, number string, merchant string) error {
 	// Получаем пользователя по логину
 	user, err := s.UsersStorage.GetUser(ctx, login)
 	if err != nil {
@@ -64,7 +72,7 @@ func (s *Orders) AddOrder(ctx context.Context, login string, number string) erro
 	}
 
 	// Добавление заказа
-	err = s.OrdersStorage.AddOrder(ctx, number, user.UserID, time.Now())
+	err = s.OrdersStorage.AddOrder(ctx, number, user.UserID, merchant, time.Now())
 	if err != nil {
 		return err
 	}
@@ -87,19 +95,169 @@ func (s *Orders) GetOrders(ctx context.Context, login string) ([]models.OrderDat
 	return orders, nil
 }
 
-// ClaimOrdersForProcessing - сформировать список номеров заказов из находящихся в статусе 'NEW' и установить им статус 'PROCESSING'
-func (s *Orders) ClaimOrdersForProcessing(ctx context.Context, count int) ([]string, error) {
-	return s.OrdersStorage.ClaimOrdersForProcessing(ctx, count)
+// ClaimOrdersForProcessing - сформировать список номеров заказов из находящихся в статусе 'NEW' и установить им статус 'PROCESSING'.
+// Захваченные заказы не выдаются повторно в течение lease
+func (s *Orders) ClaimOrdersForProcessing(ctx context.Context, count int, lease time.Duration) ([]string, error) {
+	return s.OrdersStorage.ClaimOrdersForProcessing(ctx, count, lease)
+}
+
+// AccrualDecision - решение по ошибке запроса начисления
+type AccrualDecision struct {
+	MaxAttempts int           // Подряд идущих неудачных запросов до перевода в FinalStatus
+	FinalStatus string        // Конечный статус заказа
+	Backoff     time.Duration // Отсрочка после первой ошибки, удваивается с каждой следующей
+}
+
+// MaxRetryBackoff - наибольшая отсрочка повторного запроса начисления
+var MaxRetryBackoff = time.Hour
+
+// AccrualDecisions - таблица решений по классам ошибок сервиса начислений.
+// Сетевые ошибки, ответы 5xx и 429 повторяются дольше, незарегистрированный заказ и
+// некорректные ответы быстрее становятся конечным статусом. Неудачные запросы подряд
+// считаются по всем классам, после исчерпания попыток заказ отклоняется и может быть
+// повторно поставлен в очередь администратором.
+var AccrualDecisions = map[client.ErrorClass]AccrualDecision{
+	client.ErrorTransient:     {MaxAttempts: 20, FinalStatus: models.OrderStatusInvalid, Backoff: 5 * time.Second},
+	client.ErrorUnavailable:   {MaxAttempts: 20, FinalStatus: models.OrderStatusInvalid, Backoff: 10 * time.Second},
+	client.ErrorRateLimited:   {MaxAttempts: 20, FinalStatus: models.OrderStatusInvalid, Backoff: 10 * time.Second},
+	client.ErrorNotRegistered: {MaxAttempts: 5, FinalStatus: models.OrderStatusInvalid, Backoff: 30 * time.Second},
+	client.ErrorMalformed:     {MaxAttempts: 3, FinalStatus: models.OrderStatusInvalid, Backoff: 30 * time.Second},
+	client.ErrorUnknownStatus: {MaxAttempts: 3, FinalStatus: models.OrderStatusInvalid, Backoff: 30 * time.Second},
 }
 
 // ProcessOrder - обработка заказа, запрос начисления вознаграждений
 func (s *Orders) ProcessOrder(ctx context.Context, number string) error {
 	accrual, status, err := s.Accrual.GetOrderAccrual(ctx, number)
 	if err != nil {
-		logger.Warn("Failed to get order", number, "accrual. Error:", zap.Error(err))
-		// оставляем статус PROCESSING, изменяем количество попыток запросов
-		status = models.OrderStatusProcessing
+		return s.recordFailure(ctx, number, err)
+	}
+	bonus, err := s.tierBonus(ctx, number, status, accrual)
+	if err != nil {
+		return err
+	}
+	// устанавливаем статус, количество баллов и обновляем баланс баллов пользователя
+	return s.OrdersStorage.UpdateOrderAndBalance(ctx, number, status, accrual, bonus)
+}
+
+// tierBonus - надбавка уровня владельца заказа к начислению. Заказ хранит начисление
+// сервиса начислений, надбавка зачисляется отдельной записью журнала
+func (s *Orders) tierBonus(ctx context.Context, number string, status string, accrual decimal.Decimal) (decimal.Decimal, error) {
+	if len(s.Tiers.Tiers) == 0 || status != models.OrderStatusProcessed || !accrual.IsPositive() {
+		return decimal.Zero, nil
+	}
+	tier, err := s.OrdersStorage.GetOrderTier(ctx, number)
+	if err != nil {
+		logger.Error("Failed to get order tier", number, zap.Error(err))
+		return decimal.Zero, err
+	}
+	multiplier := s.Tiers.Multiplier(tier)
+	if !multiplier.GreaterThan(decimal.NewFromInt(1)) {
+		return decimal.Zero, nil
+	}
+	return accrual.Mul(multiplier).Round(2).Sub(accrual), nil
+}
+
+// recordFailure - запись ошибки на заказе по таблице решений
+func (s *Orders) recordFailure(ctx context.Context, number string, err error) error {
+	class := client.Classify(err)
+	decision := AccrualDecisions[class]
+	logger.Warn("Failed to get order", number, "accrual. Class:", string(class), "Error:", zap.Error(err))
+
+	status, recordErr := s.OrdersStorage.RecordOrderFailure(ctx, number, models.OrderFailure{
+		Class:       string(class),
+		Detail:      err.Error(),
+		MaxAttempts: decision.MaxAttempts,
+		FinalStatus: decision.FinalStatus,
+		Backoff:     decision.Backoff,
+		MaxBackoff:  MaxRetryBackoff,
+	})
+	if recordErr != nil {
+		return recordErr
+	}
+	if status == decision.FinalStatus {
+		logger.Warn("Order", number, "moved to final status", status, "after", decision.MaxAttempts, "failed attempts. Last class:", string(class))
+	}
+	return nil
+}
+
+// ProcessOrders - обработка заказов пакетными запросами к сервису начислений.
+// timeout ограничивает запрос каждого заказа и запись результата каждого заказа.
+// Если сервис не поддерживает пакетный запрос, возвращает client.ErrBatchNotSupported.
+func (s *Orders) ProcessOrders(ctx context.Context, numbers []string, timeout time.Duration) error {
+	batch, ok := s.Accrual.(client.BatchAccrualService)
+	if !ok || !batch.SupportsBatch() {
+		return client.ErrBatchNotSupported
+	}
+	// маршрутизатор провайдеров опрашивает провайдеров без пакетного запроса по одному заказу,
+	// поэтому запросу отводится таймаут на каждый заказ, общее время ограничено арендой заказов
+	requestCtx, cancel := context.WithTimeout(ctx, timeout*time.Duration(len(numbers)))
+	results, err := batch.GetOrdersAccrual(requestCtx, numbers)
+	cancel()
+	if errors.Is(err, client.ErrBatchNotSupported) {
+		return err
+	}
+
+	var lastErr error
+	for _, number := range numbers {
+		result, ok := results[number]
+		switch {
+		case err != nil:
+			// ошибка всего запроса записывается на каждый заказ
+			result.Err = err
+		case !ok:
+			result.Err = client.ErrOrderNotRegistered
+		}
+
+		updateErr := s.applyResult(ctx, number, result, timeout)
+		if updateErr != nil {
+			logger.Error("Failed to update order", number, "Error:", zap.Error(updateErr))
+			lastErr = updateErr
+		}
+	}
+	return lastErr
+}
+
+// applyResult - запись результата расчёта начисления по заказу со своим таймаутом
+func (s *Orders) applyResult(ctx context.Context, number string, result client.OrderAccrual, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if result.Err != nil {
+		return s.recordFailure(ctx, number, result.Err)
+	}
+	bonus, err := s.tierBonus(ctx, number, result.Status, result.Accrual)
+	if err != nil {
+		return err
 	}
 	// устанавливаем статус, количество баллов и обновляем баланс баллов пользователя
-	return s.OrdersStorage.UpdateOrderAndBalance(ctx, number, status, decimal.NewFromFloat(accrual))
+	return s.OrdersStorage.UpdateOrderAndBalance(ctx, number, result.Status, result.Accrual, bonus)
+}
+
+// GetQueueStats - количество заказов по статусам обработки
+func (s *Orders) GetQueueStats(ctx context.Context) (map[string]int, error) {
+	return s.OrdersStorage.CountOrdersByStatus(ctx)
+}
+
+// RequeueOrder - повторная постановка заказа в очередь обработки с приоритетом.
+// Заказ в конечном статусе повторно не обрабатывается
+func (s *Orders) RequeueOrder(ctx context.Context, number string, priority int) error {
+	err := s.OrdersStorage.RequeueOrder(ctx, number, priority)
+	switch {
+	case errors.Is(err, storage.ErrOrderNotFound):
+		return ErrOrderNotFound
+	case errors.Is(err, storage.ErrOrderFinalized):
+		return ErrOrderFinalized
+	}
+	return err
+}
+
+// SetUserPriority - приоритет пользователя в очереди обработки (VIP), действует на заказы,
+// загруженные после изменения. Приоритет отдельного заказа задаётся повторной постановкой в очередь
+func (s *Orders) SetUserPriority(ctx context.Context, login string, priority int) error {
+	if err := s.UsersStorage.SetUserPriority(ctx, login, priority); err != nil {
+		logger.Error("Failed to set user priority", login, zap.Error(err))
+		return err
+	}
+	logger.Info("User", login, "priority set to", priority)
+	return nil
 }
@@ -31,12 +31,13 @@ func TestOrderService_AddOrder(t *testing.T) {
 		logger.Panic(err)
 	}
 
-	orders := NewOrders(mockAccrual, mockOrders, mockUsers)
+	orders := NewOrders(mockAccrual, mockOrders, mockUsers, config.Tiers)
 
 	testCases := []struct {
 		TestName      string
 		Login         string
 		OrderNumber   string
+		Merchant      string
 		SetupMocks    func()
 		ExpectedError error
 	}{
@@ -83,10 +84,11 @@ func TestOrderService_AddOrder(t *testing.T) {
 			TestName:    "Success. Order not found #5",
 			Login:       "mda",
 			OrderNumber: "123456789",
+			Merchant:    "shop",
 			SetupMocks: func() {
 				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(&models.UserData{UserID: "1"}, nil)
 				mockOrders.EXPECT().GetOrder(gomock.Any(), "123456789").Return(nil, storage.ErrOrderNotFound)
-				mockOrders.EXPECT().AddOrder(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				mockOrders.EXPECT().AddOrder(gomock.Any(), "123456789", "1", "shop", gomock.Any()).Return(nil)
 			},
 			ExpectedError: nil,
 		},
@@ -97,7 +99,7 @@ func TestOrderService_AddOrder(t *testing.T) {
 			SetupMocks: func() {
 				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(&models.UserData{UserID: "1"}, nil)
 				mockOrders.EXPECT().GetOrder(gomock.Any(), "123456789").Return(nil, storage.ErrOrderNotFound)
-				mockOrders.EXPECT().AddOrder(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("failed to add order"))
+				mockOrders.EXPECT().AddOrder(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("failed to add order"))
 			},
 			ExpectedError: errors.New("failed to add order"),
 		},
@@ -110,7 +112,7 @@ func TestOrderService_AddOrder(t *testing.T) {
 			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 			defer cancel()
 
-			err := orders.AddOrder(ctx, tc.Login, tc.OrderNumber)
+			err := orders.AddOrder(ctx, tc.Login, tc.OrderNumber, tc.Merchant)
 
 			if err != nil && tc.ExpectedError == nil {
 				t.Errorf("Expected no error, got '%v'", err)
@@ -134,7 +136,7 @@ func TestOrderService_GetOrders(t *testing.T) {
 		logger.Panic(err)
 	}
 
-	orders := NewOrders(mockAccrual, mockOrders, mockUsers)
+	orders := NewOrders(mockAccrual, mockOrders, mockUsers, config.Tiers)
 
 	testCases := []struct {
 		Name           string
@@ -215,7 +217,7 @@ func TestOrderService_ClaimOrdersForProcessing(t *testing.T) {
 		logger.Panic(err)
 	}
 
-	orders := NewOrders(mockAccrual, mockOrders, mockUsers)
+	orders := NewOrders(mockAccrual, mockOrders, mockUsers, config.Tiers)
 
 	testCases := []struct {
 		Name                 string
@@ -228,7 +230,7 @@ func TestOrderService_ClaimOrdersForProcessing(t *testing.T) {
 			Name: "Error. User not found #1",
 			Size: -1,
 			SetupMocks: func() {
-				mockOrders.EXPECT().ClaimOrdersForProcessing(gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("failed to get processing orders"))
+				mockOrders.EXPECT().ClaimOrdersForProcessing(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("failed to get processing orders"))
 			},
 			ExpectedError:        fmt.Errorf("failed to get processing orders"),
 			ExpectedOrderNumbers: nil,
@@ -237,7 +239,7 @@ func TestOrderService_ClaimOrdersForProcessing(t *testing.T) {
 			Name: "Success. #2",
 			Size: 1,
 			SetupMocks: func() {
-				mockOrders.EXPECT().ClaimOrdersForProcessing(gomock.Any(), gomock.Any()).Return([]string{"123456789", "987654321"}, nil)
+				mockOrders.EXPECT().ClaimOrdersForProcessing(gomock.Any(), gomock.Any(), gomock.Any()).Return([]string{"123456789", "987654321"}, nil)
 			},
 			ExpectedError:        nil,
 			ExpectedOrderNumbers: []string{"123456789", "987654321"},
@@ -251,7 +253,7 @@ func TestOrderService_ClaimOrdersForProcessing(t *testing.T) {
 			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 			defer cancel()
 
-			orders, err := orders.ClaimOrdersForProcessing(ctx, tc.Size)
+			orders, err := orders.ClaimOrdersForProcessing(ctx, tc.Size, time.Minute)
 
 			if err != nil && tc.ExpectedError == nil {
 				t.Errorf("Expected no error, got: '%v'", err)
@@ -279,7 +281,7 @@ func TestOrderService_ProcessOrder(t *testing.T) {
 		logger.Panic(err)
 	}
 
-	orders := NewOrders(mockAccrual, mockOrders, mockUsers)
+	orders := NewOrders(mockAccrual, mockOrders, mockUsers, testTiersConfig())
 
 	testCases := []struct {
 		Name          string
@@ -291,8 +293,15 @@ func TestOrderService_ProcessOrder(t *testing.T) {
 			Name:   "Error. Order not found #1",
 			Number: "3124124151",
 			SetupMocks: func() {
-				mockAccrual.EXPECT().GetOrderAccrual(gomock.Any(), gomock.Any()).Return(float64(0), models.OrderStatusInvalid, client.ErrOrderNotRegistered)
-				mockOrders.EXPECT().UpdateOrderAndBalance(gomock.Any(), gomock.Any(), models.OrderStatusProcessing, decimal.NewFromFloat(0)).Return(nil)
+				mockAccrual.EXPECT().GetOrderAccrual(gomock.Any(), gomock.Any()).Return(decimal.Zero, models.OrderStatusInvalid, client.ErrOrderNotRegistered)
+				mockOrders.EXPECT().RecordOrderFailure(gomock.Any(), "3124124151", models.OrderFailure{
+					Class:       string(client.ErrorNotRegistered),
+					Detail:      client.ErrOrderNotRegistered.Error(),
+					MaxAttempts: 5,
+					FinalStatus: models.OrderStatusInvalid,
+					Backoff:     30 * time.Second,
+					MaxBackoff:  time.Hour,
+				}).Return(models.OrderStatusProcessing, nil)
 			},
 			ExpectedError: nil,
 		},
@@ -300,8 +309,9 @@ func TestOrderService_ProcessOrder(t *testing.T) {
 			Name:   "Success. #2",
 			Number: "123456789",
 			SetupMocks: func() {
-				mockAccrual.EXPECT().GetOrderAccrual(gomock.Any(), gomock.Any()).Return(float64(50), models.OrderStatusProcessed, nil)
-				mockOrders.EXPECT().UpdateOrderAndBalance(gomock.Any(), gomock.Any(), models.OrderStatusProcessed, decimal.NewFromFloat(50)).Return(nil)
+				mockAccrual.EXPECT().GetOrderAccrual(gomock.Any(), gomock.Any()).Return(decimal.NewFromInt(50), models.OrderStatusProcessed, nil)
+				mockOrders.EXPECT().GetOrderTier(gomock.Any(), "123456789").Return("", nil)
+				mockOrders.EXPECT().UpdateOrderAndBalance(gomock.Any(), gomock.Any(), models.OrderStatusProcessed, decimal.NewFromInt(50), decimal.Zero).Return(nil)
 			},
 			ExpectedError: nil,
 		},
@@ -309,8 +319,8 @@ func TestOrderService_ProcessOrder(t *testing.T) {
 			Name:   "Failed to update order status. #3",
 			Number: "123456789",
 			SetupMocks: func() {
-				mockAccrual.EXPECT().GetOrderAccrual(gomock.Any(), gomock.Any()).Return(float64(0), models.OrderStatusInvalid, nil)
-				mockOrders.EXPECT().UpdateOrderAndBalance(gomock.Any(), gomock.Any(), models.OrderStatusInvalid, decimal.NewFromFloat(0)).Return(fmt.Errorf("failed to update order status: invalid"))
+				mockAccrual.EXPECT().GetOrderAccrual(gomock.Any(), gomock.Any()).Return(decimal.Zero, models.OrderStatusInvalid, nil)
+				mockOrders.EXPECT().UpdateOrderAndBalance(gomock.Any(), gomock.Any(), models.OrderStatusInvalid, decimal.Zero, decimal.Zero).Return(fmt.Errorf("failed to update order status: invalid"))
 			},
 			ExpectedError: fmt.Errorf("failed to update order status: invalid"),
 		},
@@ -318,11 +328,102 @@ func TestOrderService_ProcessOrder(t *testing.T) {
 			Name:   "Failed to update user balance: . #4",
 			Number: "123456789",
 			SetupMocks: func() {
-				mockAccrual.EXPECT().GetOrderAccrual(gomock.Any(), gomock.Any()).Return(float64(50), models.OrderStatusProcessed, nil)
-				mockOrders.EXPECT().UpdateOrderAndBalance(gomock.Any(), gomock.Any(), models.OrderStatusProcessed, decimal.NewFromFloat(50)).Return(fmt.Errorf("failed to update user balance: user not found"))
+				mockAccrual.EXPECT().GetOrderAccrual(gomock.Any(), gomock.Any()).Return(decimal.NewFromInt(50), models.OrderStatusProcessed, nil)
+				mockOrders.EXPECT().GetOrderTier(gomock.Any(), "123456789").Return("", nil)
+				mockOrders.EXPECT().UpdateOrderAndBalance(gomock.Any(), gomock.Any(), models.OrderStatusProcessed, decimal.NewFromInt(50), decimal.Zero).Return(fmt.Errorf("failed to update user balance: user not found"))
 			},
 			ExpectedError: fmt.Errorf("failed to update user balance: user not found"),
 		},
+		{
+			Name:   "Success. Timeout retried with backoff #5",
+			Number: "123456789",
+			SetupMocks: func() {
+				timeout := client.NewAccrualError(client.ErrorTransient, context.DeadlineExceeded)
+				mockAccrual.EXPECT().GetOrderAccrual(gomock.Any(), gomock.Any()).Return(decimal.Zero, models.OrderStatusInvalid, timeout)
+				mockOrders.EXPECT().RecordOrderFailure(gomock.Any(), "123456789", models.OrderFailure{
+					Class:       string(client.ErrorTransient),
+					Detail:      context.DeadlineExceeded.Error(),
+					MaxAttempts: 20,
+					FinalStatus: models.OrderStatusInvalid,
+					Backoff:     5 * time.Second,
+					MaxBackoff:  time.Hour,
+				}).Return(models.OrderStatusProcessing, nil)
+			},
+			ExpectedError: nil,
+		},
+		{
+			Name:   "Success. Service unavailable becomes final after retries #6",
+			Number: "123456789",
+			SetupMocks: func() {
+				unavailable := &client.AccrualError{Class: client.ErrorUnavailable, StatusCode: 503, Err: client.ErrServiceUnavailable}
+				mockAccrual.EXPECT().GetOrderAccrual(gomock.Any(), gomock.Any()).Return(decimal.Zero, models.OrderStatusInvalid, unavailable)
+				mockOrders.EXPECT().RecordOrderFailure(gomock.Any(), "123456789", gomock.Cond(func(failure models.OrderFailure) bool {
+					return failure.Class == string(client.ErrorUnavailable) && failure.MaxAttempts > 0 && failure.Backoff > 0
+				})).Return(models.OrderStatusInvalid, nil)
+			},
+			ExpectedError: nil,
+		},
+		{
+			Name:   "Success. Unknown status becomes final #7",
+			Number: "123456789",
+			SetupMocks: func() {
+				unknown := client.NewAccrualError(client.ErrorUnknownStatus, fmt.Errorf("%w %s", client.ErrUnknownStatus, "UNKNOWN"))
+				mockAccrual.EXPECT().GetOrderAccrual(gomock.Any(), gomock.Any()).Return(decimal.Zero, "", unknown)
+				mockOrders.EXPECT().RecordOrderFailure(gomock.Any(), "123456789", models.OrderFailure{
+					Class:       string(client.ErrorUnknownStatus),
+					Detail:      "undefined status request UNKNOWN",
+					MaxAttempts: 3,
+					FinalStatus: models.OrderStatusInvalid,
+					Backoff:     30 * time.Second,
+					MaxBackoff:  time.Hour,
+				}).Return(models.OrderStatusInvalid, nil)
+			},
+			ExpectedError: nil,
+		},
+		{
+			Name:   "Failed to record order failure #8",
+			Number: "123456789",
+			SetupMocks: func() {
+				mockAccrual.EXPECT().GetOrderAccrual(gomock.Any(), gomock.Any()).Return(decimal.Zero, models.OrderStatusInvalid, client.ErrOrderNotRegistered)
+				mockOrders.EXPECT().RecordOrderFailure(gomock.Any(), "123456789", gomock.Any()).Return("", fmt.Errorf("failed to record order failure: order not found"))
+			},
+			ExpectedError: fmt.Errorf("failed to record order failure: order not found"),
+		},
+		{
+			Name:   "Success. Tier bonus booked separately #9",
+			Number: "123456789",
+			SetupMocks: func() {
+				mockAccrual.EXPECT().GetOrderAccrual(gomock.Any(), gomock.Any()).Return(decimal.RequireFromString("33.33"), models.OrderStatusProcessed, nil)
+				mockOrders.EXPECT().GetOrderTier(gomock.Any(), "123456789").Return("GOLD", nil)
+				mockOrders.EXPECT().UpdateOrderAndBalance(gomock.Any(), "123456789", models.OrderStatusProcessed, decimal.RequireFromString("33.33"), decimal.RequireFromString("8.33")).Return(nil)
+			},
+			ExpectedError: nil,
+		},
+		{
+			Name:   "Failed to get order tier #10",
+			Number: "123456789",
+			SetupMocks: func() {
+				mockAccrual.EXPECT().GetOrderAccrual(gomock.Any(), gomock.Any()).Return(decimal.NewFromInt(50), models.OrderStatusProcessed, nil)
+				mockOrders.EXPECT().GetOrderTier(gomock.Any(), "123456789").Return("", fmt.Errorf("failed to get order tier: timeout"))
+			},
+			ExpectedError: fmt.Errorf("failed to get order tier: timeout"),
+		},
+		{
+			Name:   "Success. Too many requests recorded as rate limited #11",
+			Number: "123456789",
+			SetupMocks: func() {
+				mockAccrual.EXPECT().GetOrderAccrual(gomock.Any(), gomock.Any()).Return(decimal.Zero, models.OrderStatusInvalid, &client.RateLimitError{RetryAfter: time.Minute})
+				mockOrders.EXPECT().RecordOrderFailure(gomock.Any(), "123456789", models.OrderFailure{
+					Class:       string(client.ErrorRateLimited),
+					Detail:      "rate limit exceeded",
+					MaxAttempts: 20,
+					FinalStatus: models.OrderStatusInvalid,
+					Backoff:     10 * time.Second,
+					MaxBackoff:  time.Hour,
+				}).Return(models.OrderStatusProcessing, nil)
+			},
+			ExpectedError: nil,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -344,3 +445,333 @@ func TestOrderService_ProcessOrder(t *testing.T) {
 		})
 	}
 }
+
+func TestOrderService_ProcessOrders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockOrders := storageMocks.NewMockOrdersStorage(ctrl)
+	mockUsers := storageMocks.NewMockUsersStorage(ctrl)
+	mockAccrual := clientMocks.NewMockBatchAccrualService(ctrl)
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+
+	orders := NewOrders(mockAccrual, mockOrders, mockUsers, config.Tiers)
+
+	testCases := []struct {
+		Name          string
+		Numbers       []string
+		SetupMocks    func()
+		ExpectedError error
+	}{
+		{
+			Name:    "Error. Batch not supported #1",
+			Numbers: []string{"123456789"},
+			SetupMocks: func() {
+				mockAccrual.EXPECT().SupportsBatch().Return(false)
+			},
+			ExpectedError: client.ErrBatchNotSupported,
+		},
+		{
+			Name:    "Success. #2",
+			Numbers: []string{"123456789", "3124124151"},
+			SetupMocks: func() {
+				mockAccrual.EXPECT().SupportsBatch().Return(true)
+				mockAccrual.EXPECT().GetOrdersAccrual(gomock.Any(), []string{"123456789", "3124124151"}).Return(map[string]client.OrderAccrual{
+					"123456789":  {Accrual: decimal.NewFromInt(50), Status: models.OrderStatusProcessed},
+					"3124124151": {Status: models.OrderStatusInvalid, Err: client.ErrOrderNotRegistered},
+				}, nil)
+				mockOrders.EXPECT().UpdateOrderAndBalance(gomock.Any(), "123456789", models.OrderStatusProcessed, decimal.NewFromInt(50), decimal.Zero).Return(nil)
+				mockOrders.EXPECT().RecordOrderFailure(gomock.Any(), "3124124151", gomock.Cond(func(failure models.OrderFailure) bool {
+					return failure.Class == string(client.ErrorNotRegistered)
+				})).Return(models.OrderStatusProcessing, nil)
+			},
+			ExpectedError: nil,
+		},
+		{
+			Name:    "Success. Service unavailable #3",
+			Numbers: []string{"123456789"},
+			SetupMocks: func() {
+				mockAccrual.EXPECT().SupportsBatch().Return(true)
+				mockAccrual.EXPECT().GetOrdersAccrual(gomock.Any(), gomock.Any()).Return(nil, client.ErrServiceUnavailable)
+				mockOrders.EXPECT().RecordOrderFailure(gomock.Any(), "123456789", gomock.Cond(func(failure models.OrderFailure) bool {
+					return failure.Class == string(client.ErrorUnavailable)
+				})).Return(models.OrderStatusProcessing, nil)
+			},
+			ExpectedError: nil,
+		},
+		{
+			Name:    "Error. Batch rejected by service #4",
+			Numbers: []string{"123456789"},
+			SetupMocks: func() {
+				mockAccrual.EXPECT().SupportsBatch().Return(true)
+				mockAccrual.EXPECT().GetOrdersAccrual(gomock.Any(), gomock.Any()).Return(nil, client.ErrBatchNotSupported)
+			},
+			ExpectedError: client.ErrBatchNotSupported,
+		},
+		{
+			Name:    "Failed to update order status. #5",
+			Numbers: []string{"123456789"},
+			SetupMocks: func() {
+				mockAccrual.EXPECT().SupportsBatch().Return(true)
+				mockAccrual.EXPECT().GetOrdersAccrual(gomock.Any(), gomock.Any()).Return(map[string]client.OrderAccrual{
+					"123456789": {Accrual: decimal.NewFromInt(50), Status: models.OrderStatusProcessed},
+				}, nil)
+				mockOrders.EXPECT().UpdateOrderAndBalance(gomock.Any(), "123456789", models.OrderStatusProcessed, decimal.NewFromInt(50), decimal.Zero).Return(fmt.Errorf("failed to update order status: processed"))
+			},
+			ExpectedError: fmt.Errorf("failed to update order status: processed"),
+		},
+		{
+			Name:    "Success. Too many requests recorded on each order #6",
+			Numbers: []string{"123456789", "3124124151"},
+			SetupMocks: func() {
+				mockAccrual.EXPECT().SupportsBatch().Return(true)
+				mockAccrual.EXPECT().GetOrdersAccrual(gomock.Any(), gomock.Any()).Return(nil, &client.RateLimitError{RetryAfter: time.Minute})
+				for _, number := range []string{"123456789", "3124124151"} {
+					mockOrders.EXPECT().RecordOrderFailure(gomock.Any(), number, gomock.Cond(func(failure models.OrderFailure) bool {
+						return failure.Class == string(client.ErrorRateLimited)
+					})).Return(models.OrderStatusProcessing, nil)
+				}
+			},
+			ExpectedError: nil,
+		},
+		{
+			Name:    "Success. Each update has own timeout #7",
+			Numbers: []string{"123456789"},
+			SetupMocks: func() {
+				mockAccrual.EXPECT().SupportsBatch().Return(true)
+				mockAccrual.EXPECT().GetOrdersAccrual(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, numbers []string) (map[string]client.OrderAccrual, error) {
+					// запрос занимает почти весь свой таймаут, запись получает новый
+					time.Sleep(600 * time.Millisecond)
+					return map[string]client.OrderAccrual{"123456789": {Status: models.OrderStatusProcessing}}, nil
+				})
+				mockOrders.EXPECT().UpdateOrderAndBalance(gomock.Any(), "123456789", models.OrderStatusProcessing, gomock.Any(), gomock.Any()).DoAndReturn(
+					func(ctx context.Context, number string, status string, accrual decimal.Decimal, bonus decimal.Decimal) error {
+						if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) < 900*time.Millisecond {
+							return fmt.Errorf("update without own timeout")
+						}
+						return nil
+					})
+			},
+			ExpectedError: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.SetupMocks()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			err := orders.ProcessOrders(ctx, tc.Numbers, time.Second)
+
+			if err != nil && tc.ExpectedError == nil {
+				t.Errorf("Expected no error, got: '%v'", err)
+			} else if err == nil && tc.ExpectedError != nil {
+				t.Errorf("Expected error, got none")
+			} else if err != nil && err.Error() != tc.ExpectedError.Error() {
+				t.Errorf("Expected error '%v', got: '%v'", tc.ExpectedError, err)
+			}
+		})
+	}
+}
+
+func TestOrderService_GetQueueStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockOrders := storageMocks.NewMockOrdersStorage(ctrl)
+	mockUsers := storageMocks.NewMockUsersStorage(ctrl)
+	mockAccrual := clientMocks.NewMockAccrualService(ctrl)
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+
+	orders := NewOrders(mockAccrual, mockOrders, mockUsers, config.Tiers)
+
+	testCases := []struct {
+		Name          string
+		SetupMocks    func()
+		ExpectedError error
+		ExpectedStats map[string]int
+	}{
+		{
+			Name: "Error. Failed count orders #1",
+			SetupMocks: func() {
+				mockOrders.EXPECT().CountOrdersByStatus(gomock.Any()).Return(nil, fmt.Errorf("failed to count orders"))
+			},
+			ExpectedError: fmt.Errorf("failed to count orders"),
+			ExpectedStats: nil,
+		},
+		{
+			Name: "Success. #2",
+			SetupMocks: func() {
+				mockOrders.EXPECT().CountOrdersByStatus(gomock.Any()).Return(map[string]int{
+					models.OrderStatusNew:       3,
+					models.OrderStatusProcessed: 7,
+				}, nil)
+			},
+			ExpectedError: nil,
+			ExpectedStats: map[string]int{
+				models.OrderStatusNew:       3,
+				models.OrderStatusProcessed: 7,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.SetupMocks()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			stats, err := orders.GetQueueStats(ctx)
+
+			if err != nil && tc.ExpectedError == nil {
+				t.Errorf("Expected no error, got: '%v'", err)
+			} else if err == nil && tc.ExpectedError != nil {
+				t.Errorf("Expected error, got none")
+			} else if err != nil && err.Error() != tc.ExpectedError.Error() {
+				t.Errorf("Expected error '%v', got: '%v'", tc.ExpectedError, err)
+			}
+			diff := cmp.Diff(tc.ExpectedStats, stats)
+			if len(diff) != 0 {
+				t.Errorf("expected queue stats mismatch:\n %s", diff)
+			}
+		})
+	}
+}
+
+func TestOrderService_RequeueOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockOrders := storageMocks.NewMockOrdersStorage(ctrl)
+	mockUsers := storageMocks.NewMockUsersStorage(ctrl)
+	mockAccrual := clientMocks.NewMockAccrualService(ctrl)
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+
+	orders := NewOrders(mockAccrual, mockOrders, mockUsers, config.Tiers)
+
+	testCases := []struct {
+		Name          string
+		Number        string
+		Priority      int
+		SetupMocks    func()
+		ExpectedError error
+	}{
+		{
+			Name:     "Error. Order not found #1",
+			Number:   "123456789",
+			Priority: 10,
+			SetupMocks: func() {
+				mockOrders.EXPECT().RequeueOrder(gomock.Any(), "123456789", 10).Return(storage.ErrOrderNotFound)
+			},
+			ExpectedError: ErrOrderNotFound,
+		},
+		{
+			Name:     "Error. Failed requeue order #2",
+			Number:   "123456789",
+			Priority: 0,
+			SetupMocks: func() {
+				mockOrders.EXPECT().RequeueOrder(gomock.Any(), "123456789", 0).Return(fmt.Errorf("failed to requeue order"))
+			},
+			ExpectedError: fmt.Errorf("failed to requeue order"),
+		},
+		{
+			Name:     "Success. #3",
+			Number:   "123456789",
+			Priority: 5,
+			SetupMocks: func() {
+				mockOrders.EXPECT().RequeueOrder(gomock.Any(), "123456789", 5).Return(nil)
+			},
+			ExpectedError: nil,
+		},
+		{
+			Name:     "Error. Order in final status #4",
+			Number:   "123456789",
+			Priority: 5,
+			SetupMocks: func() {
+				mockOrders.EXPECT().RequeueOrder(gomock.Any(), "123456789", 5).Return(storage.ErrOrderFinalized)
+			},
+			ExpectedError: ErrOrderFinalized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.SetupMocks()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			err := orders.RequeueOrder(ctx, tc.Number, tc.Priority)
+
+			if err != nil && tc.ExpectedError == nil {
+				t.Errorf("Expected no error, got: '%v'", err)
+			} else if err == nil && tc.ExpectedError != nil {
+				t.Errorf("Expected error, got none")
+			} else if err != nil && err.Error() != tc.ExpectedError.Error() {
+				t.Errorf("Expected error '%v', got: '%v'", tc.ExpectedError, err)
+			}
+		})
+	}
+}
+
+func TestOrderService_SetUserPriority(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockOrders := storageMocks.NewMockOrdersStorage(ctrl)
+	mockUsers := storageMocks.NewMockUsersStorage(ctrl)
+	mockAccrual := clientMocks.NewMockAccrualService(ctrl)
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+
+	orders := NewOrders(mockAccrual, mockOrders, mockUsers, config.Tiers)
+
+	testCases := []struct {
+		Name          string
+		SetupMocks    func()
+		ExpectedError error
+	}{
+		{
+			Name: "Error. User not found #1",
+			SetupMocks: func() {
+				mockUsers.EXPECT().SetUserPriority(gomock.Any(), "mda", 10).Return(storage.ErrUserNotFound)
+			},
+			ExpectedError: storage.ErrUserNotFound,
+		},
+		{
+			Name: "Success. #2",
+			SetupMocks: func() {
+				mockUsers.EXPECT().SetUserPriority(gomock.Any(), "mda", 10).Return(nil)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.SetupMocks()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			err := orders.SetUserPriority(ctx, "mda", 10)
+
+			if err != nil && tc.ExpectedError == nil {
+				t.Errorf("Expected no error, got: '%v'", err)
+			} else if err == nil && tc.ExpectedError != nil {
+				t.Errorf("Expected error, got none")
+			} else if err != nil && err.Error() != tc.ExpectedError.Error() {
+				t.Errorf("Expected error '%v', got: '%v'", tc.ExpectedError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,331 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/denmor86/ya-gophermart/internal/client"
+	"github.com/denmor86/ya-gophermart/internal/config"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/denmor86/ya-gophermart/internal/storage"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// ProviderLimiter - создание ограничения частоты запросов к HTTP провайдеру по его имени
+type ProviderLimiter func(name string) client.Limiter
+
+// NewAccrualProviders - создание провайдеров начислений согласно настройкам.
+// Без дополнительных провайдеров возвращается HTTP клиент сервиса по адресу ACCRUAL_SYSTEM_ADDRESS.
+// У каждого HTTP провайдера своё ограничение частоты запросов из providerLimiter.
+func NewAccrualProviders(cfg config.AccrualConfig, limiter client.Limiter, providerLimiter ProviderLimiter, breakers *client.BreakerRegistry, orders storage.OrdersStorage) (client.AccrualService, error) {
+	// по имени провайдера выбирается его бюджет запросов
+	names := make(map[string]struct{}, len(cfg.Providers))
+	for _, providerConfig := range cfg.Providers {
+		if _, ok := names[providerConfig.Name]; ok || providerConfig.Name == "" {
+			return nil, fmt.Errorf("provider name %q is empty or duplicated", providerConfig.Name)
+		}
+		names[providerConfig.Name] = struct{}{}
+	}
+
+	httpClient, err := client.NewHTTPClient(cfg.HTTP, breakers)
+	if err != nil {
+		return nil, fmt.Errorf("accrual http client: %w", err)
+	}
+	defaultProvider := NewAccrualService(cfg.AccrualAddr, httpClient, limiter, cfg.Batch)
+	if len(cfg.Providers) == 0 {
+		return defaultProvider, nil
+	}
+
+	router := &AccrualRouter{Default: defaultProvider, Owners: orders}
+	for _, providerConfig := range cfg.Providers {
+		provider, err := newAccrualProvider(providerConfig, cfg.HTTP, providerLimiter, breakers)
+		if err != nil {
+			return nil, fmt.Errorf("provider %s: %w", providerConfig.Name, err)
+		}
+		if providerConfig.Default {
+			router.Default = provider
+		}
+		router.Routes = append(router.Routes, NewAccrualRoute(providerConfig.Name, provider, providerConfig.Prefixes, providerConfig.Users, providerConfig.Merchants))
+	}
+	return router, nil
+}
+
+func newAccrualProvider(cfg config.ProviderConfig, httpConfig config.HTTPClientConfig, providerLimiter ProviderLimiter, breakers *client.BreakerRegistry) (client.AccrualService, error) {
+	switch cfg.Type {
+	case config.ProviderHTTP:
+		// у каждого сервиса своя авторизация, если задана
+		if cfg.Token != "" {
+			httpConfig.Token = cfg.Token
+		}
+		if cfg.Secret != "" {
+			httpConfig.HMACSecret = cfg.Secret
+		}
+		httpClient, err := client.NewHTTPClient(httpConfig, breakers)
+		if err != nil {
+			return nil, err
+		}
+		// у каждого сервиса свои ограничения частоты запросов
+		return NewAccrualService(cfg.Address, httpClient, providerLimiter(cfg.Name), cfg.Batch), nil
+	case config.ProviderRules:
+		return NewRulesAccrual(cfg.Rules)
+	case config.ProviderStatic:
+		return NewStaticAccrual(cfg.Orders), nil
+	default:
+		return nil, fmt.Errorf("unknown provider type %q", cfg.Type)
+	}
+}
+
+// AccrualRoute - правило выбора провайдера начислений
+type AccrualRoute struct {
+	Name      string
+	Provider  client.AccrualService
+	Prefixes  []string
+	Users     map[string]struct{}
+	Merchants map[string]struct{}
+}
+
+func NewAccrualRoute(name string, provider client.AccrualService, prefixes []string, users []string, merchants []string) AccrualRoute {
+	route := AccrualRoute{
+		Name:      name,
+		Provider:  provider,
+		Prefixes:  prefixes,
+		Users:     make(map[string]struct{}),
+		Merchants: make(map[string]struct{}),
+	}
+	for _, user := range users {
+		route.Users[user] = struct{}{}
+	}
+	for _, merchant := range merchants {
+		route.Merchants[merchant] = struct{}{}
+	}
+	return route
+}
+
+// AccrualRouter - выбор провайдера начислений по пользователю, продавцу или префиксу номера заказа.
+// Правило по пользователю приоритетнее правила по продавцу, оба приоритетнее правил по префиксу,
+// из правил по префиксу выбирается самый длинный префикс.
+type AccrualRouter struct {
+	Routes  []AccrualRoute
+	Default client.AccrualService
+	Owners  storage.OrdersStorage
+}
+
+func (r *AccrualRouter) GetOrderAccrual(ctx context.Context, orderNumber string) (decimal.Decimal, string, error) {
+	return r.Route(ctx, orderNumber).GetOrderAccrual(ctx, orderNumber)
+}
+
+// SupportsBatch - маршрутизатор всегда обрабатывает заказы пакетом: владельцы заказов
+// загружаются одним запросом, провайдеры без пакетного запроса опрашиваются по одному заказу
+func (r *AccrualRouter) SupportsBatch() bool {
+	return true
+}
+
+// GetOrdersAccrual - заказы группируются по провайдерам, провайдеры без пакетного
+// запроса опрашиваются по одному заказу
+func (r *AccrualRouter) GetOrdersAccrual(ctx context.Context, orderNumbers []string) (map[string]client.OrderAccrual, error) {
+	var (
+		providers []client.AccrualService
+		groups    = make(map[client.AccrualService][]string)
+		owners    = r.owners(ctx, orderNumbers)
+	)
+	for _, number := range orderNumbers {
+		var owner *models.OrderOwner
+		if o, ok := owners[number]; ok {
+			owner = &o
+		}
+		provider := r.route(number, owner)
+		if _, ok := groups[provider]; !ok {
+			providers = append(providers, provider)
+		}
+		groups[provider] = append(groups[provider], number)
+	}
+
+	results := make(map[string]client.OrderAccrual, len(orderNumbers))
+	for _, provider := range providers {
+		numbers := groups[provider]
+		if batch, ok := provider.(client.BatchAccrualService); ok && batch.SupportsBatch() {
+			batchResults, err := batch.GetOrdersAccrual(ctx, numbers)
+			if err == nil {
+				for number, result := range batchResults {
+					results[number] = result
+				}
+				continue
+			}
+			if !errors.Is(err, client.ErrBatchNotSupported) {
+				for _, number := range numbers {
+					results[number] = client.OrderAccrual{Status: models.OrderStatusInvalid, Err: err}
+				}
+				continue
+			}
+		}
+		for _, number := range numbers {
+			accrual, status, err := provider.GetOrderAccrual(ctx, number)
+			results[number] = client.OrderAccrual{Accrual: accrual, Status: status, Err: err}
+		}
+	}
+	return results, nil
+}
+
+// Close - освобождение ресурсов всех провайдеров
+func (r *AccrualRouter) Close() error {
+	closed := map[client.AccrualService]struct{}{}
+	var errs []error
+	for _, provider := range append([]client.AccrualService{r.Default}, r.providers()...) {
+		if _, ok := closed[provider]; ok {
+			continue
+		}
+		closed[provider] = struct{}{}
+		errs = append(errs, CloseAccrual(provider))
+	}
+	return errors.Join(errs...)
+}
+
+func (r *AccrualRouter) providers() []client.AccrualService {
+	providers := make([]client.AccrualService, 0, len(r.Routes))
+	for _, route := range r.Routes {
+		providers = append(providers, route.Provider)
+	}
+	return providers
+}
+
+// CloseAccrual - освобождение ресурсов провайдера начислений, если он их удерживает
+func CloseAccrual(provider client.AccrualService) error {
+	if closer, ok := provider.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Route - провайдер, обслуживающий заказ
+func (r *AccrualRouter) Route(ctx context.Context, orderNumber string) client.AccrualService {
+	return r.route(orderNumber, r.owner(ctx, orderNumber))
+}
+
+// route - провайдер заказа по владельцу и продавцу, если они известны, иначе по префиксу номера
+func (r *AccrualRouter) route(orderNumber string, owner *models.OrderOwner) client.AccrualService {
+	if owner != nil {
+		for _, route := range r.Routes {
+			if _, ok := route.Users[owner.Login]; ok {
+				return route.Provider
+			}
+		}
+		for _, route := range r.Routes {
+			if _, ok := route.Merchants[owner.Merchant]; ok && owner.Merchant != "" {
+				return route.Provider
+			}
+		}
+	}
+
+	var (
+		provider = r.Default
+		longest  = 0
+	)
+	for _, route := range r.Routes {
+		for _, prefix := range route.Prefixes {
+			if len(prefix) > longest && strings.HasPrefix(orderNumber, prefix) {
+				provider = route.Provider
+				longest = len(prefix)
+			}
+		}
+	}
+	return provider
+}
+
+// owner - владелец и продавец заказа, запрашиваются только при наличии правил по пользователям или продавцам
+func (r *AccrualRouter) owner(ctx context.Context, orderNumber string) *models.OrderOwner {
+	if !r.routesByOwner() {
+		return nil
+	}
+	owner, err := r.Owners.GetOrderOwner(ctx, orderNumber)
+	if err != nil {
+		logger.Warn("Failed to get order owner", orderNumber, zap.Error(err))
+		return nil
+	}
+	return owner
+}
+
+// owners - владельцы и продавцы заказов пачки одним запросом
+func (r *AccrualRouter) owners(ctx context.Context, orderNumbers []string) map[string]models.OrderOwner {
+	if !r.routesByOwner() {
+		return nil
+	}
+	owners, err := r.Owners.GetOrderOwners(ctx, orderNumbers)
+	if err != nil {
+		logger.Warn("Failed to get owners of", len(orderNumbers), "orders", zap.Error(err))
+		return nil
+	}
+	return owners
+}
+
+// routesByOwner - есть правила по пользователям или продавцам и хранилище заказов для их проверки
+func (r *AccrualRouter) routesByOwner() bool {
+	if r.Owners == nil {
+		return false
+	}
+	for _, route := range r.Routes {
+		if len(route.Users) > 0 || len(route.Merchants) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// RulesAccrual - встроенный провайдер начислений на правилах по номеру заказа
+type RulesAccrual struct {
+	Rules []AccrualRule
+}
+
+// AccrualRule - правило встроенного провайдера начислений
+type AccrualRule struct {
+	Pattern *regexp.Regexp
+	Status  string
+	Accrual decimal.Decimal
+}
+
+func NewRulesAccrual(rules []config.AccrualRuleConfig) (client.AccrualService, error) {
+	provider := &RulesAccrual{}
+	for _, rule := range rules {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule pattern %q: %w", rule.Pattern, err)
+		}
+		if !isKnownStatus(rule.Status) {
+			return nil, fmt.Errorf("undefined rule status %s", rule.Status)
+		}
+		provider.Rules = append(provider.Rules, AccrualRule{Pattern: pattern, Status: rule.Status, Accrual: rule.Accrual})
+	}
+	return provider, nil
+}
+
+// GetOrderAccrual - расчёт по первому подходящему правилу, заказ без правила не зарегистрирован
+func (s *RulesAccrual) GetOrderAccrual(ctx context.Context, orderNumber string) (decimal.Decimal, string, error) {
+	for _, rule := range s.Rules {
+		if rule.Pattern.MatchString(orderNumber) {
+			return rule.Accrual, rule.Status, nil
+		}
+	}
+	return decimal.Zero, models.OrderStatusInvalid, client.ErrOrderNotRegistered
+}
+
+// StaticAccrual - провайдер начислений с фиксированной таблицей заказов
+type StaticAccrual struct {
+	Orders map[string]config.AccrualOrderConfig
+}
+
+func NewStaticAccrual(orders map[string]config.AccrualOrderConfig) client.AccrualService {
+	return &StaticAccrual{Orders: orders}
+}
+
+func (s *StaticAccrual) GetOrderAccrual(ctx context.Context, orderNumber string) (decimal.Decimal, string, error) {
+	order, ok := s.Orders[orderNumber]
+	if !ok {
+		return decimal.Zero, models.OrderStatusInvalid, client.ErrOrderNotRegistered
+	}
+	return order.Accrual, order.Status, nil
+}
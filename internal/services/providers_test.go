@@ -0,0 +1,340 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/client"
+	clientMocks "github.com/denmor86/ya-gophermart/internal/client/mocks"
+	"github.com/denmor86/ya-gophermart/internal/config"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/models"
+	storageMocks "github.com/denmor86/ya-gophermart/internal/storage/mocks"
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
+	"go.uber.org/mock/gomock"
+)
+
+func TestAccrualRouter_GetOrderAccrual(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockOrders := storageMocks.NewMockOrdersStorage(ctrl)
+	mockDefault := clientMocks.NewMockAccrualService(ctrl)
+	mockPartner := clientMocks.NewMockAccrualService(ctrl)
+	mockMerchant := clientMocks.NewMockAccrualService(ctrl)
+	mockAcme := clientMocks.NewMockAccrualService(ctrl)
+
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+
+	router := &AccrualRouter{
+		Routes: []AccrualRoute{
+			NewAccrualRoute("partner", mockPartner, []string{"12", "1234"}, nil, nil),
+			NewAccrualRoute("merchant", mockMerchant, []string{"1"}, []string{"shop"}, nil),
+			NewAccrualRoute("acme", mockAcme, nil, nil, []string{"acme"}),
+		},
+		Default: mockDefault,
+		Owners:  mockOrders,
+	}
+
+	testCases := []struct {
+		TestName        string
+		OrderNumber     string
+		SetupMocks      func()
+		ExpectedAccrual decimal.Decimal
+		ExpectedStatus  string
+		ExpectedError   error
+	}{
+		{
+			TestName:    "Success. Route by user #1",
+			OrderNumber: "123456789",
+			SetupMocks: func() {
+				mockOrders.EXPECT().GetOrderOwner(gomock.Any(), "123456789").Return(&models.OrderOwner{Login: "shop"}, nil)
+				mockMerchant.EXPECT().GetOrderAccrual(gomock.Any(), "123456789").Return(decimal.NewFromInt(10), models.OrderStatusProcessed, nil)
+			},
+			ExpectedAccrual: decimal.NewFromInt(10),
+			ExpectedStatus:  models.OrderStatusProcessed,
+		},
+		{
+			TestName:    "Success. Route by longest prefix #2",
+			OrderNumber: "123456789",
+			SetupMocks: func() {
+				mockOrders.EXPECT().GetOrderOwner(gomock.Any(), "123456789").Return(&models.OrderOwner{Login: "mda"}, nil)
+				mockPartner.EXPECT().GetOrderAccrual(gomock.Any(), "123456789").Return(decimal.NewFromInt(20), models.OrderStatusProcessed, nil)
+			},
+			ExpectedAccrual: decimal.NewFromInt(20),
+			ExpectedStatus:  models.OrderStatusProcessed,
+		},
+		{
+			TestName:    "Success. Route to default #3",
+			OrderNumber: "987654321",
+			SetupMocks: func() {
+				mockOrders.EXPECT().GetOrderOwner(gomock.Any(), "987654321").Return(&models.OrderOwner{Login: "mda"}, nil)
+				mockDefault.EXPECT().GetOrderAccrual(gomock.Any(), "987654321").Return(decimal.Zero, models.OrderStatusProcessing, nil)
+			},
+			ExpectedStatus: models.OrderStatusProcessing,
+		},
+		{
+			TestName:    "Success. Owner lookup failure falls back to prefix #4",
+			OrderNumber: "1999",
+			SetupMocks: func() {
+				mockOrders.EXPECT().GetOrderOwner(gomock.Any(), "1999").Return(nil, errors.New("db error"))
+				mockMerchant.EXPECT().GetOrderAccrual(gomock.Any(), "1999").Return(decimal.NewFromInt(5), models.OrderStatusProcessed, nil)
+			},
+			ExpectedAccrual: decimal.NewFromInt(5),
+			ExpectedStatus:  models.OrderStatusProcessed,
+		},
+		{
+			TestName:    "Success. Route by merchant over prefix #5",
+			OrderNumber: "123456789",
+			SetupMocks: func() {
+				mockOrders.EXPECT().GetOrderOwner(gomock.Any(), "123456789").Return(&models.OrderOwner{Login: "mda", Merchant: "acme"}, nil)
+				mockAcme.EXPECT().GetOrderAccrual(gomock.Any(), "123456789").Return(decimal.NewFromInt(30), models.OrderStatusProcessed, nil)
+			},
+			ExpectedAccrual: decimal.NewFromInt(30),
+			ExpectedStatus:  models.OrderStatusProcessed,
+		},
+		{
+			TestName:    "Success. Route by user over merchant #6",
+			OrderNumber: "987654321",
+			SetupMocks: func() {
+				mockOrders.EXPECT().GetOrderOwner(gomock.Any(), "987654321").Return(&models.OrderOwner{Login: "shop", Merchant: "acme"}, nil)
+				mockMerchant.EXPECT().GetOrderAccrual(gomock.Any(), "987654321").Return(decimal.NewFromInt(10), models.OrderStatusProcessed, nil)
+			},
+			ExpectedAccrual: decimal.NewFromInt(10),
+			ExpectedStatus:  models.OrderStatusProcessed,
+		},
+		{
+			TestName:    "Success. Unknown merchant routes to default #7",
+			OrderNumber: "987654321",
+			SetupMocks: func() {
+				mockOrders.EXPECT().GetOrderOwner(gomock.Any(), "987654321").Return(&models.OrderOwner{Login: "mda", Merchant: "other"}, nil)
+				mockDefault.EXPECT().GetOrderAccrual(gomock.Any(), "987654321").Return(decimal.Zero, models.OrderStatusProcessing, nil)
+			},
+			ExpectedStatus: models.OrderStatusProcessing,
+		},
+		{
+			TestName:    "Error. Provider failure #8",
+			OrderNumber: "987654321",
+			SetupMocks: func() {
+				mockOrders.EXPECT().GetOrderOwner(gomock.Any(), "987654321").Return(&models.OrderOwner{Login: "mda"}, nil)
+				mockDefault.EXPECT().GetOrderAccrual(gomock.Any(), "987654321").Return(decimal.Zero, models.OrderStatusInvalid, client.ErrOrderNotRegistered)
+			},
+			ExpectedStatus: models.OrderStatusInvalid,
+			ExpectedError:  client.ErrOrderNotRegistered,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.TestName, func(t *testing.T) {
+			tc.SetupMocks()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			accrual, status, err := router.GetOrderAccrual(ctx, tc.OrderNumber)
+
+			if !errors.Is(err, tc.ExpectedError) {
+				t.Errorf("Expected error: '%v', got: '%v'", tc.ExpectedError, err)
+			}
+			if !accrual.Equal(tc.ExpectedAccrual) {
+				t.Errorf("Expected accrual: %v, got: %v", tc.ExpectedAccrual, accrual)
+			}
+			if status != tc.ExpectedStatus {
+				t.Errorf("Expected status: %s, got: %s", tc.ExpectedStatus, status)
+			}
+		})
+	}
+}
+
+func TestAccrualProviders_RulesAndStatic(t *testing.T) {
+	rules, err := NewRulesAccrual([]config.AccrualRuleConfig{
+		{Pattern: "^1[0-9]*0$", Status: models.OrderStatusProcessed, Accrual: decimal.NewFromInt(100)},
+		{Pattern: "^1", Status: models.OrderStatusProcessing},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	static := NewStaticAccrual(map[string]config.AccrualOrderConfig{
+		"4000": {Status: models.OrderStatusProcessed, Accrual: decimal.RequireFromString("42.5")},
+	})
+
+	testCases := []struct {
+		TestName        string
+		Provider        client.AccrualService
+		OrderNumber     string
+		ExpectedAccrual decimal.Decimal
+		ExpectedStatus  string
+		ExpectedError   error
+	}{
+		{
+			TestName:        "Success. First matching rule #1",
+			Provider:        rules,
+			OrderNumber:     "12340",
+			ExpectedAccrual: decimal.NewFromInt(100),
+			ExpectedStatus:  models.OrderStatusProcessed,
+		},
+		{
+			TestName:       "Success. Next matching rule #2",
+			Provider:       rules,
+			OrderNumber:    "12345",
+			ExpectedStatus: models.OrderStatusProcessing,
+		},
+		{
+			TestName:       "Error. No matching rule #3",
+			Provider:       rules,
+			OrderNumber:    "22345",
+			ExpectedStatus: models.OrderStatusInvalid,
+			ExpectedError:  client.ErrOrderNotRegistered,
+		},
+		{
+			TestName:        "Success. Static order #4",
+			Provider:        static,
+			OrderNumber:     "4000",
+			ExpectedAccrual: decimal.RequireFromString("42.5"),
+			ExpectedStatus:  models.OrderStatusProcessed,
+		},
+		{
+			TestName:       "Error. Static order not found #5",
+			Provider:       static,
+			OrderNumber:    "4001",
+			ExpectedStatus: models.OrderStatusInvalid,
+			ExpectedError:  client.ErrOrderNotRegistered,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.TestName, func(t *testing.T) {
+			accrual, status, err := tc.Provider.GetOrderAccrual(context.Background(), tc.OrderNumber)
+
+			if !errors.Is(err, tc.ExpectedError) {
+				t.Errorf("Expected error: '%v', got: '%v'", tc.ExpectedError, err)
+			}
+			if !accrual.Equal(tc.ExpectedAccrual) {
+				t.Errorf("Expected accrual: %v, got: %v", tc.ExpectedAccrual, accrual)
+			}
+			if status != tc.ExpectedStatus {
+				t.Errorf("Expected status: %s, got: %s", tc.ExpectedStatus, status)
+			}
+		})
+	}
+
+	if _, err := NewRulesAccrual([]config.AccrualRuleConfig{{Pattern: "(", Status: models.OrderStatusProcessed}}); err == nil {
+		t.Errorf("Expected error for invalid pattern, got none")
+	}
+}
+
+// Владельцы заказов пачки загружаются одним запросом, провайдер без пакетного запроса опрашивается по заказу
+func TestAccrualRouter_GetOrdersAccrual(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockOrders := storageMocks.NewMockOrdersStorage(ctrl)
+	mockDefault := clientMocks.NewMockBatchAccrualService(ctrl)
+	mockAcme := clientMocks.NewMockAccrualService(ctrl)
+
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+
+	router := &AccrualRouter{
+		Routes:  []AccrualRoute{NewAccrualRoute("acme", mockAcme, nil, nil, []string{"acme"})},
+		Default: mockDefault,
+		Owners:  mockOrders,
+	}
+	numbers := []string{"123456789", "987654321", "1999"}
+
+	mockOrders.EXPECT().GetOrderOwners(gomock.Any(), numbers).Return(map[string]models.OrderOwner{
+		"123456789": {Login: "mda", Merchant: "acme"},
+		"987654321": {Login: "mda"},
+	}, nil)
+	mockAcme.EXPECT().GetOrderAccrual(gomock.Any(), "123456789").Return(decimal.NewFromInt(30), models.OrderStatusProcessed, nil)
+	mockDefault.EXPECT().SupportsBatch().Return(true)
+	mockDefault.EXPECT().GetOrdersAccrual(gomock.Any(), []string{"987654321", "1999"}).Return(map[string]client.OrderAccrual{
+		"987654321": {Status: models.OrderStatusProcessing},
+		"1999":      {Status: models.OrderStatusInvalid, Err: client.ErrOrderNotRegistered},
+	}, nil)
+
+	if !router.SupportsBatch() {
+		t.Fatalf("Expected router to support batch")
+	}
+	results, err := router.GetOrdersAccrual(context.Background(), numbers)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	if result := results["123456789"]; result.Status != models.OrderStatusProcessed || !result.Accrual.Equal(decimal.NewFromInt(30)) {
+		t.Errorf("Expected order routed by merchant, got: '%+v'", result)
+	}
+	if result := results["987654321"]; result.Status != models.OrderStatusProcessing {
+		t.Errorf("Expected order routed to default, got: '%+v'", result)
+	}
+	if result := results["1999"]; !errors.Is(result.Err, client.ErrOrderNotRegistered) {
+		t.Errorf("Expected error: '%v', got: '%v'", client.ErrOrderNotRegistered, result.Err)
+	}
+}
+
+// У каждого HTTP провайдера своё ограничение частоты запросов по имени
+func TestNewAccrualProviders_Limiters(t *testing.T) {
+	cfg := config.DefaultConfig()
+	if err := logger.Initialize(cfg.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+
+	testCases := []struct {
+		TestName         string
+		Providers        []config.ProviderConfig
+		ExpectedLimiters []string
+		ExpectedError    bool
+	}{
+		{
+			TestName: "Success. Limiter per http provider #1",
+			Providers: []config.ProviderConfig{
+				{Name: "partner", Type: config.ProviderHTTP, Address: "http://partner"},
+				{Name: "rules", Type: config.ProviderRules},
+				{Name: "market", Type: config.ProviderHTTP, Address: "http://market"},
+			},
+			ExpectedLimiters: []string{"partner", "market"},
+		},
+		{
+			TestName:      "Error. Empty provider name #2",
+			Providers:     []config.ProviderConfig{{Type: config.ProviderHTTP, Address: "http://partner"}},
+			ExpectedError: true,
+		},
+		{
+			TestName: "Error. Duplicated provider name #3",
+			Providers: []config.ProviderConfig{
+				{Name: "partner", Type: config.ProviderHTTP, Address: "http://partner"},
+				{Name: "partner", Type: config.ProviderStatic},
+			},
+			ExpectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.TestName, func(t *testing.T) {
+			accrualConfig := cfg.Accrual
+			accrualConfig.Providers = tc.Providers
+
+			var limiters []string
+			providerLimiter := func(name string) client.Limiter {
+				limiters = append(limiters, name)
+				return client.NewRateLimiter()
+			}
+
+			provider, err := NewAccrualProviders(accrualConfig, client.NewRateLimiter(), providerLimiter, nil, nil)
+			if (err != nil) != tc.ExpectedError {
+				t.Fatalf("Expected error: '%v', got: '%v'", tc.ExpectedError, err)
+			}
+			if err == nil {
+				defer CloseAccrual(provider)
+			}
+			if diff := cmp.Diff(tc.ExpectedLimiters, limiters); diff != "" {
+				t.Errorf("Limiters mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
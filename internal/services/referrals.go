@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/denmor86/ya-gophermart/internal/storage"
+	"go.uber.org/zap"
+)
+
+type ReferralService interface {
+	GetReferrals(ctx context.Context, login string) (*models.Referrals, error)
+}
+
+type Referrals struct {
+	UsersStorage storage.UsersStorage
+}
+
+// Создание сервиса
+func NewReferrals(users storage.UsersStorage) ReferralService {
+	return &Referrals{UsersStorage: users}
+}
+
+// GetReferrals код приглашения пользователя и приглашённые им пользователи
+func (s *Referrals) GetReferrals(ctx context.Context, login string) (*models.Referrals, error) {
+	user, err := s.UsersStorage.GetUser(ctx, login)
+	if err != nil {
+		logger.Error("Failed to get user", zap.Error(err))
+		return nil, err
+	}
+	referrals, err := s.UsersStorage.GetReferrals(ctx, user.UserID)
+	if err != nil {
+		logger.Error("Failed to get referrals", zap.Error(err))
+		return nil, err
+	}
+	return &models.Referrals{Code: user.ReferralCode, Referrals: referrals}, nil
+}
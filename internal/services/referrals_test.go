@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/config"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/denmor86/ya-gophermart/internal/storage"
+	"github.com/denmor86/ya-gophermart/internal/storage/mocks"
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
+	"go.uber.org/mock/gomock"
+)
+
+func TestReferralService_GetReferrals(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsers := mocks.NewMockUsersStorage(ctrl)
+
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+
+	referrals := NewReferrals(mockUsers)
+	createdAt := time.Date(2025, 6, 27, 10, 0, 0, 0, time.UTC)
+	list := []models.ReferralData{
+		{Login: "friend", Status: models.ReferralRewarded, Bonus: decimal.NewFromInt(100), CreatedAt: createdAt, CompletedAt: createdAt.Add(time.Hour)},
+		{Login: "other", Status: models.ReferralPending, Bonus: decimal.NewFromInt(100), CreatedAt: createdAt},
+	}
+
+	testCases := []struct {
+		Name              string
+		SetupMocks        func()
+		ExpectedError     error
+		ExpectedReferrals *models.Referrals
+	}{
+		{
+			Name: "Success. #1",
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(&models.UserData{UserID: "1", Login: "mda", ReferralCode: "ABCDEF1234"}, nil)
+				mockUsers.EXPECT().GetReferrals(gomock.Any(), "1").Return(list, nil)
+			},
+			ExpectedReferrals: &models.Referrals{Code: "ABCDEF1234", Referrals: list},
+		},
+		{
+			Name: "Error. User not found #2",
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(nil, storage.ErrUserNotFound)
+			},
+			ExpectedError: storage.ErrUserNotFound,
+		},
+		{
+			Name: "Error. Storage failure #3",
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(&models.UserData{UserID: "1", Login: "mda"}, nil)
+				mockUsers.EXPECT().GetReferrals(gomock.Any(), "1").Return(nil, errors.New("failed to get referrals"))
+			},
+			ExpectedError: errors.New("failed to get referrals"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.SetupMocks()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			result, err := referrals.GetReferrals(ctx, "mda")
+
+			if err != nil && tc.ExpectedError == nil {
+				t.Errorf("Expected no error, got: '%v'", err)
+			} else if err == nil && tc.ExpectedError != nil {
+				t.Errorf("Expected error, got none")
+			} else if err != nil && err.Error() != tc.ExpectedError.Error() {
+				t.Errorf("Expected error '%v', got: '%v'", tc.ExpectedError, err)
+			}
+			if diff := cmp.Diff(tc.ExpectedReferrals, result); diff != "" {
+				t.Errorf("Referrals mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
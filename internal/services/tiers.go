@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/config"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/denmor86/ya-gophermart/internal/storage"
+	"go.uber.org/zap"
+)
+
+type TierService interface {
+	RecalculateTiers(ctx context.Context, now time.Time) (int64, error)
+}
+
+type Tiers struct {
+	UsersStorage storage.UsersStorage
+	Config       config.TiersConfig
+}
+
+// Создание сервиса
+func NewTiers(users storage.UsersStorage, config config.TiersConfig) TierService {
+	return &Tiers{UsersStorage: users, Config: config}
+}
+
+// RecalculateTiers пересчёт уровней пользователей по начислениям за период,
+// возвращает количество пользователей со сменившимся уровнем
+func (s *Tiers) RecalculateTiers(ctx context.Context, now time.Time) (int64, error) {
+	tiers := make([]models.Tier, 0, len(s.Config.Tiers))
+	for _, tier := range s.Config.Tiers {
+		tiers = append(tiers, models.Tier{Name: tier.Name, Threshold: tier.Threshold})
+	}
+	updated, err := s.UsersStorage.RecalculateTiers(ctx, now.Add(-s.Config.Window), tiers)
+	if err != nil {
+		logger.Error("Failed to recalculate tiers", zap.Error(err))
+		return 0, err
+	}
+	logger.Info("Tiers recalculated, updated users:", updated)
+	return updated, nil
+}
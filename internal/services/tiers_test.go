@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/config"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/denmor86/ya-gophermart/internal/storage/mocks"
+	"github.com/shopspring/decimal"
+	"go.uber.org/mock/gomock"
+)
+
+func TestTierService_RecalculateTiers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsers := mocks.NewMockUsersStorage(ctrl)
+
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+
+	tiers := NewTiers(mockUsers, testTiersConfig())
+	now := time.Date(2025, 6, 25, 3, 0, 0, 0, time.UTC)
+	expectedTiers := []models.Tier{
+		{Name: "SILVER", Threshold: decimal.NewFromInt(1000)},
+		{Name: "GOLD", Threshold: decimal.NewFromInt(5000)},
+		{Name: "PLATINUM", Threshold: decimal.NewFromInt(20000)},
+	}
+
+	testCases := []struct {
+		Name            string
+		SetupMocks      func()
+		ExpectedError   error
+		ExpectedUpdated int64
+	}{
+		{
+			Name: "Success. #1",
+			SetupMocks: func() {
+				mockUsers.EXPECT().RecalculateTiers(gomock.Any(), now.Add(-config.Tiers.Window), expectedTiers).Return(int64(4), nil)
+			},
+			ExpectedUpdated: 4,
+		},
+		{
+			Name: "Error. Storage failure #2",
+			SetupMocks: func() {
+				mockUsers.EXPECT().RecalculateTiers(gomock.Any(), gomock.Any(), gomock.Any()).Return(int64(0), errors.New("failed to recalculate tiers"))
+			},
+			ExpectedError: errors.New("failed to recalculate tiers"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.SetupMocks()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			updated, err := tiers.RecalculateTiers(ctx, now)
+
+			if err != nil && tc.ExpectedError == nil {
+				t.Errorf("Expected no error, got: '%v'", err)
+			} else if err == nil && tc.ExpectedError != nil {
+				t.Errorf("Expected error, got none")
+			} else if err != nil && err.Error() != tc.ExpectedError.Error() {
+				t.Errorf("Expected error '%v', got: '%v'", tc.ExpectedError, err)
+			}
+			if updated != tc.ExpectedUpdated {
+				t.Errorf("Expected %d updated users, got: %d", tc.ExpectedUpdated, updated)
+			}
+		})
+	}
+}
+
+// testTiersConfig - настройки уровней участников для тестов, по умолчанию уровни отключены
+func testTiersConfig() config.TiersConfig {
+	tiers, err := config.ParseTiers("SILVER:1000:1.1,GOLD:5000:1.25,PLATINUM:20000:1.5")
+	if err != nil {
+		panic(err)
+	}
+	cfg := config.DefaultConfig().Tiers
+	cfg.Tiers = tiers
+	return cfg
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/denmor86/ya-gophermart/internal/config"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/denmor86/ya-gophermart/internal/storage"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidTransferAmount = errors.New("invalid transfer amount")
+	ErrTransferLimitExceeded = errors.New("transfer limit exceeded")
+	ErrRecipientNotFound     = errors.New("recipient not found")
+	ErrSelfTransfer          = errors.New("transfer to self")
+)
+
+type TransferService interface {
+	Transfer(ctx context.Context, login string, recipient string, amount decimal.Decimal, comment string) (*models.TransferData, error)
+}
+
+type Transfer struct {
+	TransfersStorage storage.TransfersStorage
+	UsersStorage     storage.UsersStorage
+	Config           config.TransfersConfig
+}
+
+// Создание сервиса
+func NewTransfer(transfers storage.TransfersStorage, users storage.UsersStorage, config config.TransfersConfig) TransferService {
+	return &Transfer{TransfersStorage: transfers, UsersStorage: users, Config: config}
+}
+
+// fee комиссия за перевод суммы, округлённая до копеек
+func (s *Transfer) fee(amount decimal.Decimal) decimal.Decimal {
+	fee := amount.Mul(s.Config.FeePercent).Div(decimal.NewFromInt(100)).Add(s.Config.FeeFixed)
+	return fee.Round(2)
+}
+
+// Transfer перевод баллов пользователя другому пользователю по логину
+func (s *Transfer) Transfer(ctx context.Context, login string, recipient string, amount decimal.Decimal, comment string) (*models.TransferData, error) {
+	// Сумма перевода положительна и в пределах лимитов, дробная часть - не точнее копеек
+	if !amount.IsPositive() || !amount.Equal(amount.Round(2)) || amount.LessThan(s.Config.MinAmount) {
+		return nil, ErrInvalidTransferAmount
+	}
+	if s.Config.MaxAmount.IsPositive() && amount.GreaterThan(s.Config.MaxAmount) {
+		return nil, ErrTransferLimitExceeded
+	}
+	if login == recipient {
+		return nil, ErrSelfTransfer
+	}
+
+	sender, err := s.UsersStorage.GetUser(ctx, login)
+	if err != nil {
+		logger.Error("Failed to get user", zap.Error(err))
+		return nil, err
+	}
+	receiver, err := s.UsersStorage.GetUser(ctx, recipient)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, ErrRecipientNotFound
+		}
+		logger.Error("Failed to get recipient", zap.Error(err))
+		return nil, err
+	}
+
+	transfer, err := s.TransfersStorage.AddTransfer(ctx, models.TransferData{
+		FromUserID: sender.UserID,
+		ToUserID:   receiver.UserID,
+		FromLogin:  sender.Login,
+		ToLogin:    receiver.Login,
+		Amount:     amount,
+		Fee:        s.fee(amount),
+		Comment:    comment,
+	}, s.Config.DailyLimit)
+	switch {
+	case errors.Is(err, storage.ErrInsufficientFunds):
+		return nil, ErrInsufficientFunds
+	case errors.Is(err, storage.ErrTransferLimitExceeded):
+		return nil, ErrTransferLimitExceeded
+	case errors.Is(err, storage.ErrUserNotFound):
+		return nil, ErrRecipientNotFound
+	case err != nil:
+		logger.Error("Failed to transfer points", zap.Error(err))
+		return nil, err
+	}
+	logger.Info("Points transferred", login, recipient, amount.String())
+	return transfer, nil
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/config"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/denmor86/ya-gophermart/internal/storage"
+	"github.com/denmor86/ya-gophermart/internal/storage/mocks"
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
+	"go.uber.org/mock/gomock"
+)
+
+func TestTransferService_Transfer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockTransfers := mocks.NewMockTransfersStorage(ctrl)
+	mockUsers := mocks.NewMockUsersStorage(ctrl)
+
+	config := config.DefaultConfig()
+	if err := logger.Initialize(config.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+
+	limits := config.Transfers
+	limits.MaxAmount = decimal.NewFromInt(1000)
+	limits.DailyLimit = decimal.NewFromInt(2000)
+	limits.FeePercent = decimal.RequireFromString("1.5")
+	limits.FeeFixed = decimal.NewFromInt(1)
+	transfer := NewTransfer(mockTransfers, mockUsers, limits)
+
+	sender := &models.UserData{UserID: "1", Login: "mda"}
+	receiver := &models.UserData{UserID: "2", Login: "kid"}
+	createdAt := time.Date(2025, 6, 24, 10, 0, 0, 0, time.UTC)
+	stored := &models.TransferData{
+		ID:         3,
+		FromUserID: "1",
+		ToUserID:   "2",
+		FromLogin:  "mda",
+		ToLogin:    "kid",
+		Amount:     decimal.RequireFromString("100.10"),
+		Fee:        decimal.RequireFromString("2.50"),
+		Comment:    "pocket money",
+		CreatedAt:  createdAt,
+	}
+
+	testCases := []struct {
+		Name             string
+		Recipient        string
+		Amount           decimal.Decimal
+		SetupMocks       func()
+		ExpectedError    error
+		ExpectedTransfer *models.TransferData
+	}{
+		{
+			Name:          "Error. Below minimum amount #1",
+			Recipient:     "kid",
+			Amount:        decimal.RequireFromString("0.5"),
+			SetupMocks:    func() {},
+			ExpectedError: ErrInvalidTransferAmount,
+		},
+		{
+			Name:          "Error. Fractional cents #2",
+			Recipient:     "kid",
+			Amount:        decimal.RequireFromString("10.005"),
+			SetupMocks:    func() {},
+			ExpectedError: ErrInvalidTransferAmount,
+		},
+		{
+			Name:          "Error. Above maximum amount #3",
+			Recipient:     "kid",
+			Amount:        decimal.NewFromInt(1001),
+			SetupMocks:    func() {},
+			ExpectedError: ErrTransferLimitExceeded,
+		},
+		{
+			Name:          "Error. Transfer to self #4",
+			Recipient:     "mda",
+			Amount:        decimal.NewFromInt(10),
+			SetupMocks:    func() {},
+			ExpectedError: ErrSelfTransfer,
+		},
+		{
+			Name:      "Error. Recipient not found #5",
+			Recipient: "kid",
+			Amount:    decimal.NewFromInt(10),
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(sender, nil)
+				mockUsers.EXPECT().GetUser(gomock.Any(), "kid").Return(nil, storage.ErrUserNotFound)
+			},
+			ExpectedError: ErrRecipientNotFound,
+		},
+		{
+			Name:      "Error. Insufficient funds #6",
+			Recipient: "kid",
+			Amount:    decimal.NewFromInt(10),
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(sender, nil)
+				mockUsers.EXPECT().GetUser(gomock.Any(), "kid").Return(receiver, nil)
+				mockTransfers.EXPECT().AddTransfer(gomock.Any(), gomock.Any(), limits.DailyLimit).Return(nil, storage.ErrInsufficientFunds)
+			},
+			ExpectedError: ErrInsufficientFunds,
+		},
+		{
+			Name:      "Error. Daily limit exceeded #7",
+			Recipient: "kid",
+			Amount:    decimal.NewFromInt(10),
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(sender, nil)
+				mockUsers.EXPECT().GetUser(gomock.Any(), "kid").Return(receiver, nil)
+				mockTransfers.EXPECT().AddTransfer(gomock.Any(), gomock.Any(), limits.DailyLimit).Return(nil, storage.ErrTransferLimitExceeded)
+			},
+			ExpectedError: ErrTransferLimitExceeded,
+		},
+		{
+			Name:      "Success. Transfer with fee #8",
+			Recipient: "kid",
+			Amount:    decimal.RequireFromString("100.10"),
+			SetupMocks: func() {
+				mockUsers.EXPECT().GetUser(gomock.Any(), "mda").Return(sender, nil)
+				mockUsers.EXPECT().GetUser(gomock.Any(), "kid").Return(receiver, nil)
+				mockTransfers.EXPECT().AddTransfer(gomock.Any(), gomock.Any(), limits.DailyLimit).
+					DoAndReturn(func(_ context.Context, data models.TransferData, _ decimal.Decimal) (*models.TransferData, error) {
+						// 1.5% от 100.10 = 1.5015, с фиксированной частью 2.5015 -> 2.50
+						if !data.Fee.Equal(decimal.RequireFromString("2.50")) {
+							return nil, errors.New("unexpected fee " + data.Fee.String())
+						}
+						if data.FromUserID != "1" || data.ToUserID != "2" || data.ToLogin != "kid" {
+							return nil, errors.New("unexpected parties")
+						}
+						return stored, nil
+					})
+			},
+			ExpectedTransfer: stored,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.SetupMocks()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			result, err := transfer.Transfer(ctx, "mda", tc.Recipient, tc.Amount, "pocket money")
+
+			if err != nil && tc.ExpectedError == nil {
+				t.Errorf("Expected no error, got: '%v'", err)
+			} else if err == nil && tc.ExpectedError != nil {
+				t.Errorf("Expected error, got none")
+			} else if err != nil && err.Error() != tc.ExpectedError.Error() {
+				t.Errorf("Expected error '%v', got: '%v'", tc.ExpectedError, err)
+			}
+			diff := cmp.Diff(tc.ExpectedTransfer, result)
+			if len(diff) != 0 {
+				t.Errorf("expected transfer mismatch:\n %s", diff)
+			}
+		})
+	}
+}
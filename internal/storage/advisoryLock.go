@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	TryAdvisoryLock = `SELECT pg_try_advisory_lock($1);`
+	AdvisoryUnlock  = `SELECT pg_advisory_unlock($1);`
+)
+
+// AdvisoryLock - сессионная advisory-блокировка Postgres на выделенном соединении.
+// Блокировка живёт, пока живо соединение: при его обрыве Postgres снимает её сам.
+type AdvisoryLock struct {
+	DB   *Database
+	Key  int64
+	conn *pgx.Conn
+}
+
+// Создание блокировки
+func NewAdvisoryLock(db *Database, key int64) LeaderLock {
+	return &AdvisoryLock{DB: db, Key: key}
+}
+
+// TryLock - попытка захвата блокировки без ожидания
+func (l *AdvisoryLock) TryLock(ctx context.Context) (bool, error) {
+	if l.conn == nil {
+		pooled, err := l.DB.Pool.Acquire(ctx)
+		if err != nil {
+			return false, fmt.Errorf("failed to acquire connection: %w", err)
+		}
+		// блокировка привязана к сессии, поэтому соединение забирается из пула
+		l.conn = pooled.Hijack()
+	}
+	var locked bool
+	if err := l.conn.QueryRow(ctx, TryAdvisoryLock, l.Key).Scan(&locked); err != nil {
+		l.close()
+		return false, fmt.Errorf("failed to try advisory lock: %w", err)
+	}
+	return locked, nil
+}
+
+// Check - проверка, что сессия, удерживающая блокировку, всё ещё жива
+func (l *AdvisoryLock) Check(ctx context.Context) error {
+	if l.conn == nil {
+		return fmt.Errorf("advisory lock connection closed")
+	}
+	if err := l.conn.Ping(ctx); err != nil {
+		l.close()
+		return fmt.Errorf("advisory lock connection lost: %w", err)
+	}
+	return nil
+}
+
+// Unlock - освобождение блокировки и закрытие соединения
+func (l *AdvisoryLock) Unlock(ctx context.Context) error {
+	if l.conn == nil {
+		return nil
+	}
+	defer l.close()
+	if _, err := l.conn.Exec(ctx, AdvisoryUnlock, l.Key); err != nil {
+		return fmt.Errorf("failed to release advisory lock: %w", err)
+	}
+	return nil
+}
+
+func (l *AdvisoryLock) close() {
+	if l.conn != nil {
+		l.conn.Close(context.Background())
+		l.conn = nil
+	}
+}
@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	InsertCampaign = `INSERT INTO CAMPAIGNS (name, starts_at, ends_at, first_order, min_accrual, tier, bonus_percent, bonus_fixed, bonus_max)
+					  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+					  RETURNING id, active, created_at;`
+	UpdateCampaign = `UPDATE CAMPAIGNS
+					  SET name = $2, starts_at = $3, ends_at = $4, first_order = $5, min_accrual = $6,
+					      tier = $7, bonus_percent = $8, bonus_fixed = $9, bonus_max = $10
+					  WHERE id = $1
+					  RETURNING active, created_at;`
+	DeactivateCampaign = `UPDATE CAMPAIGNS SET active = FALSE WHERE id = $1 RETURNING id;`
+	selectCampaigns    = `SELECT id, name, starts_at, ends_at, active, first_order, min_accrual, tier,
+						         bonus_percent, bonus_fixed, bonus_max, created_at
+						  FROM CAMPAIGNS`
+	GetCampaigns = selectCampaigns + ` ORDER BY id;`
+	// кампании, действующие в момент обработки заказа
+	GetActiveCampaigns = selectCampaigns + `
+						  WHERE active AND starts_at <= CURRENT_TIMESTAMP
+						    AND (ends_at IS NULL OR ends_at > CURRENT_TIMESTAMP)
+						  ORDER BY id;`
+	LockUserTier = `SELECT tier FROM USERS WHERE id = $1 FOR UPDATE;`
+	// заказ первый, если других обработанных заказов у пользователя нет
+	IsFirstOrder = `SELECT NOT EXISTS (
+					    SELECT 1 FROM ORDERS WHERE user_id = $1 AND status = 'PROCESSED' AND number <> $2
+					);`
+)
+
+type CampaignDatabase struct {
+	DB *Database
+}
+
+// Создание хранилища
+func NewCampaignsStorage(db *Database) CampaignsStorage {
+	return &CampaignDatabase{DB: db}
+}
+
+// AddCampaign - создание кампании
+func (s *CampaignDatabase) AddCampaign(ctx context.Context, campaign models.Campaign) (*models.Campaign, error) {
+	err := s.DB.Pool.QueryRow(ctx, InsertCampaign,
+		campaign.Name,
+		campaign.StartsAt,
+		nullTime(campaign.EndsAt),
+		campaign.Conditions.FirstOrder,
+		campaign.Conditions.MinAccrual,
+		campaign.Conditions.Tier,
+		campaign.Bonus.Percent,
+		campaign.Bonus.Fixed,
+		campaign.Bonus.Max,
+	).Scan(&campaign.ID, &campaign.Active, &campaign.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert campaign: %w", err)
+	}
+	return &campaign, nil
+}
+
+// UpdateCampaign - изменение периода, условий и бонуса кампании. Начисленные бонусы не пересчитываются
+func (s *CampaignDatabase) UpdateCampaign(ctx context.Context, campaign models.Campaign) (*models.Campaign, error) {
+	err := s.DB.Pool.QueryRow(ctx, UpdateCampaign,
+		campaign.ID,
+		campaign.Name,
+		campaign.StartsAt,
+		nullTime(campaign.EndsAt),
+		campaign.Conditions.FirstOrder,
+		campaign.Conditions.MinAccrual,
+		campaign.Conditions.Tier,
+		campaign.Bonus.Percent,
+		campaign.Bonus.Fixed,
+		campaign.Bonus.Max,
+	).Scan(&campaign.Active, &campaign.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrCampaignNotFound
+		}
+		return nil, fmt.Errorf("failed to update campaign: %w", err)
+	}
+	return &campaign, nil
+}
+
+// DeactivateCampaign - остановка кампании. Кампания остаётся в журнале начислений
+func (s *CampaignDatabase) DeactivateCampaign(ctx context.Context, id int64) error {
+	err := s.DB.Pool.QueryRow(ctx, DeactivateCampaign, id).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrCampaignNotFound
+		}
+		return fmt.Errorf("failed to deactivate campaign: %w", err)
+	}
+	return nil
+}
+
+// GetCampaigns - список всех кампаний
+func (s *CampaignDatabase) GetCampaigns(ctx context.Context) ([]models.Campaign, error) {
+	rows, err := s.DB.Pool.Query(ctx, GetCampaigns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get campaigns: %w", err)
+	}
+	return scanCampaigns(rows)
+}
+
+func scanCampaigns(rows pgx.Rows) ([]models.Campaign, error) {
+	defer rows.Close()
+
+	campaigns := []models.Campaign{}
+	for rows.Next() {
+		var campaign models.Campaign
+		var endsAt *time.Time
+		if err := rows.Scan(
+			&campaign.ID,
+			&campaign.Name,
+			&campaign.StartsAt,
+			&endsAt,
+			&campaign.Active,
+			&campaign.Conditions.FirstOrder,
+			&campaign.Conditions.MinAccrual,
+			&campaign.Conditions.Tier,
+			&campaign.Bonus.Percent,
+			&campaign.Bonus.Fixed,
+			&campaign.Bonus.Max,
+			&campaign.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan campaign: %w", err)
+		}
+		if endsAt != nil {
+			campaign.EndsAt = *endsAt
+		}
+		campaigns = append(campaigns, campaign)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return campaigns, nil
+}
+
+// applyCampaigns - начисление бонусов действующих кампаний по обработанному заказу
+// отдельными записями журнала в транзакции обработки заказа. Пользователь блокируется,
+// чтобы одновременно обработанные заказы не получили бонус первого заказа оба.
+func applyCampaigns(ctx context.Context, tx pgx.Tx, userID string, number string, accrual decimal.Decimal) error {
+	rows, err := tx.Query(ctx, GetActiveCampaigns)
+	if err != nil {
+		return fmt.Errorf("get active campaigns: %w", err)
+	}
+	campaigns, err := scanCampaigns(rows)
+	if err != nil {
+		return err
+	}
+	if len(campaigns) == 0 {
+		return nil
+	}
+
+	order := models.CampaignOrder{Accrual: accrual}
+	if err := tx.QueryRow(ctx, LockUserTier, userID).Scan(&order.Tier); err != nil {
+		return fmt.Errorf("lock user: %w", err)
+	}
+	if err := tx.QueryRow(ctx, IsFirstOrder, userID, number).Scan(&order.FirstOrder); err != nil {
+		return fmt.Errorf("check first order: %w", err)
+	}
+
+	for _, campaign := range campaigns {
+		bonus := campaign.Award(order)
+		if !bonus.IsPositive() {
+			continue
+		}
+		_, err := appendLedgerEntry(ctx, tx, models.LedgerEntry{
+			UserID:      userID,
+			Kind:        models.LedgerCampaign,
+			Amount:      bonus,
+			OrderNumber: number,
+			CampaignID:  campaign.ID,
+			Comment:     "campaign " + campaign.Name,
+		})
+		// бонус по заказу уже начислен
+		if errors.Is(err, ErrAlreadyExists) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("campaign %d: %w", campaign.ID, err)
+		}
+	}
+	return nil
+}
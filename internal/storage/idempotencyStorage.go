@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// ключ с истёкшим сроком хранения занимается заново, ключ незавершённого запроса
+	// занимается тем же запросом после истечения блокировки: выполнявший его экземпляр мог упасть
+	InsertIdempotencyKey = `INSERT INTO IDEMPOTENCY_KEYS (scope, key, request_hash, locked_until)
+							VALUES ($1, $2, $3, CURRENT_TIMESTAMP + $5::FLOAT8 * INTERVAL '1 second')
+							ON CONFLICT (scope, key) DO UPDATE
+							SET request_hash = EXCLUDED.request_hash,
+							    status = NULL,
+							    content_type = '',
+							    body = NULL,
+							    created_at = CURRENT_TIMESTAMP,
+							    completed_at = NULL,
+							    locked_until = EXCLUDED.locked_until
+							WHERE IDEMPOTENCY_KEYS.created_at < CURRENT_TIMESTAMP - $4::FLOAT8 * INTERVAL '1 second'
+							   OR (IDEMPOTENCY_KEYS.status IS NULL
+							       AND IDEMPOTENCY_KEYS.locked_until < CURRENT_TIMESTAMP
+							       AND IDEMPOTENCY_KEYS.request_hash = EXCLUDED.request_hash)
+							RETURNING key;`
+	GetIdempotencyKey = `SELECT request_hash, status, content_type, body
+						 FROM IDEMPOTENCY_KEYS
+						 WHERE scope = $1 AND key = $2;`
+	CompleteIdempotencyKey = `UPDATE IDEMPOTENCY_KEYS
+							  SET status = $3, content_type = $4, body = $5, completed_at = CURRENT_TIMESTAMP
+							  WHERE scope = $1 AND key = $2;`
+	DeleteIdempotencyKey = `DELETE FROM IDEMPOTENCY_KEYS WHERE scope = $1 AND key = $2;`
+	// ключ выполняемого запроса не удаляется до истечения блокировки
+	DeleteExpiredIdempotencyKeys = `DELETE FROM IDEMPOTENCY_KEYS
+									WHERE (scope, key) IN (
+										SELECT scope, key FROM IDEMPOTENCY_KEYS
+										WHERE created_at < CURRENT_TIMESTAMP - $1::FLOAT8 * INTERVAL '1 second'
+										  AND (status IS NOT NULL OR locked_until < CURRENT_TIMESTAMP)
+										ORDER BY created_at
+										LIMIT $2);`
+)
+
+type IdempotencyDatabase struct {
+	DB *Database
+}
+
+// Создание хранилища
+func NewIdempotencyStorage(db *Database) IdempotencyStorage {
+	return &IdempotencyDatabase{DB: db}
+}
+
+// BeginRequest - занятие ключа запросом. Возвращает nil, если запрос выполняется впервые
+// или незавершённый запрос не освободил ключ за lockTimeout, и сохранённый ответ,
+// если запрос с этим ключом уже выполнен.
+func (s *IdempotencyDatabase) BeginRequest(ctx context.Context, scope string, key string, requestHash string, ttl time.Duration, lockTimeout time.Duration) (*models.IdempotentResponse, error) {
+	var inserted string
+	err := s.DB.Pool.QueryRow(ctx, InsertIdempotencyKey, scope, key, requestHash, ttl.Seconds(), lockTimeout.Seconds()).Scan(&inserted)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to insert idempotency key: %w", err)
+	}
+
+	var (
+		storedHash string
+		status     *int
+		response   models.IdempotentResponse
+	)
+	err = s.DB.Pool.QueryRow(ctx, GetIdempotencyKey, scope, key).Scan(&storedHash, &status, &response.ContentType, &response.Body)
+	if err != nil {
+		// ключ освобождён между запросами, повтор клиента займёт его заново
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRequestInProgress
+		}
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	if storedHash != requestHash {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if status == nil {
+		return nil, ErrRequestInProgress
+	}
+	response.Status = *status
+	return &response, nil
+}
+
+// CompleteRequest - сохранение ответа на запрос с ключом
+func (s *IdempotencyDatabase) CompleteRequest(ctx context.Context, scope string, key string, response models.IdempotentResponse) error {
+	_, err := s.DB.Pool.Exec(ctx, CompleteIdempotencyKey, scope, key, response.Status, response.ContentType, response.Body)
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+// ReleaseRequest - освобождение ключа, запрос с ним можно повторить
+func (s *IdempotencyDatabase) ReleaseRequest(ctx context.Context, scope string, key string) error {
+	_, err := s.DB.Pool.Exec(ctx, DeleteIdempotencyKey, scope, key)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredKeys - удаление ключей старше ttl, не более limit за вызов.
+// Возвращает количество удалённых ключей.
+func (s *IdempotencyDatabase) DeleteExpiredKeys(ctx context.Context, ttl time.Duration, limit int) (int, error) {
+	tag, err := s.DB.Pool.Exec(ctx, DeleteExpiredIdempotencyKeys, ttl.Seconds(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
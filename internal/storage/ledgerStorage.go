@@ -0,0 +1,407 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+const (
+	InsertLedgerEntry = `INSERT INTO LEDGER (user_id, kind, amount, order_number, reversal_of, campaign_id, comment)
+						 VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5::BIGINT, 0), NULLIF($6::BIGINT, 0), $7)
+						 ON CONFLICT DO NOTHING
+						 RETURNING id, created_at;`
+	InsertPointLot = `INSERT INTO POINT_LOTS (user_id, ledger_id, amount, remaining, created_at)
+					  VALUES ($1, $2, $3, $3, $4);`
+	// списание расходует партии начиная с самых старых: остаток партии - часть нарастающего итога сверх суммы списания
+	ConsumePointLots = `UPDATE POINT_LOTS
+						SET remaining = LEAST(lots.remaining, GREATEST(lots.running - $2, 0))
+						FROM (
+						    SELECT id, remaining, SUM(remaining) OVER (ORDER BY created_at, id) AS running
+						    FROM POINT_LOTS
+						    WHERE user_id = $1 AND remaining > 0
+						) AS lots
+						WHERE POINT_LOTS.id = lots.id AND lots.running - lots.remaining < $2;`
+	// сгорают только свободные баллы: партии пользователей, у которых весь баланс зарезервирован, пропускаются
+	GetExpiredPointLots = `SELECT POINT_LOTS.id, POINT_LOTS.user_id
+						   FROM POINT_LOTS
+						   JOIN USERS ON USERS.id = POINT_LOTS.user_id
+						   WHERE POINT_LOTS.remaining > 0 AND POINT_LOTS.created_at < $1 AND USERS.balance > USERS.held
+						   ORDER BY POINT_LOTS.created_at, POINT_LOTS.id
+						   LIMIT $2;`
+	// пользователь блокируется раньше партии, как и при списаниях
+	LockUser = `SELECT id FROM USERS WHERE id = $1 FOR UPDATE;`
+	// LockUserAvailable - блокировка пользователя с получением незарезервированных баллов
+	LockUserAvailable = `SELECT id, balance - held FROM USERS WHERE id = $1 FOR UPDATE;`
+	// партия сгорает не больше чем на $2, несгоревший остаток обеспечивает резерв и сгорит после его снятия
+	ExpirePointLot = `UPDATE POINT_LOTS
+					   SET remaining = lot.remaining - LEAST(lot.remaining, $2)
+					   FROM (SELECT id, remaining FROM POINT_LOTS WHERE id = $1 AND remaining > 0 FOR UPDATE) AS lot
+					   WHERE POINT_LOTS.id = lot.id
+					   RETURNING LEAST(lot.remaining, $2);`
+	GetPointLots = `SELECT id, user_id, amount, remaining, created_at
+					FROM POINT_LOTS
+					WHERE user_id = $1 AND remaining > 0 AND created_at < $2
+					ORDER BY created_at, id;`
+	GetLedgerEntryForUpdate = `SELECT user_id, amount FROM LEDGER WHERE id = $1 FOR UPDATE;`
+	GetLedger               = `SELECT id, user_id, kind, amount, COALESCE(order_number, ''), COALESCE(reversal_of, 0), COALESCE(campaign_id, 0), comment, created_at
+							   FROM LEDGER
+							   WHERE user_id = $1
+							   ORDER BY id;`
+	// баланс считается нарастающим итогом по всем записям пользователя, затем выбирается период
+	GetBalanceHistory = `SELECT id, kind, amount, balance, order_number, comment, created_at
+						 FROM (
+						     SELECT id, kind, amount, COALESCE(order_number, '') AS order_number, comment, created_at,
+						            SUM(amount) OVER (ORDER BY created_at, id) AS balance
+						     FROM LEDGER
+						     WHERE user_id = $1
+						 ) AS history
+						 WHERE ($2::TIMESTAMP IS NULL OR created_at >= $2)
+						   AND ($3::TIMESTAMP IS NULL OR created_at < $3)
+						 ORDER BY created_at, id
+						 LIMIT $4 OFFSET $5;`
+	GetBalanceAt = `SELECT
+						(SELECT COALESCE(SUM(amount), 0) FROM LEDGER WHERE user_id = $1 AND created_at <= $2),
+						(SELECT COALESCE(SUM(amount), 0) FROM LOYALTY WHERE user_id = $1 AND processed_at <= $2);`
+	CheckLedger = `SELECT USERS.id, USERS.login, USERS.balance, COALESCE(SUM(LEDGER.amount), 0)
+				   FROM USERS
+				   LEFT JOIN LEDGER ON LEDGER.user_id = USERS.id
+				   GROUP BY USERS.id, USERS.login, USERS.balance
+				   HAVING USERS.balance <> COALESCE(SUM(LEDGER.amount), 0)
+				   ORDER BY USERS.login;`
+	RebuildBalances = `UPDATE USERS
+					   SET balance = sums.amount
+					   FROM (
+					       SELECT USERS.id AS user_id, COALESCE(SUM(LEDGER.amount), 0) AS amount
+					       FROM USERS
+					       LEFT JOIN LEDGER ON LEDGER.user_id = USERS.id
+					       GROUP BY USERS.id
+					   ) AS sums
+					   WHERE USERS.id = sums.user_id AND USERS.balance <> sums.amount;`
+)
+
+var (
+	errPointLotSpent = errors.New("point lot spent")
+	errPointsHeld    = errors.New("points held")
+)
+
+type LedgerDatabase struct {
+	DB *Database
+}
+
+// Создание хранилища
+func NewLedgerStorage(db *Database) LedgerStorage {
+	return &LedgerDatabase{DB: db}
+}
+
+// appendLedgerEntry - добавление записи журнала и обновление кэша баланса пользователя в транзакции.
+// Зачисление создаёт партию баллов, списание расходует партии начиная с самых старых.
+// Повторная запись по тому же заказу или отмена уже отменённой записи возвращает ErrAlreadyExists,
+// списание больше доступного баланса без зарезервированных баллов - ErrInsufficientFunds.
+func appendLedgerEntry(ctx context.Context, tx pgx.Tx, entry models.LedgerEntry) (*models.LedgerEntry, error) {
+	err := tx.QueryRow(ctx, InsertLedgerEntry,
+		entry.UserID,
+		entry.Kind,
+		entry.Amount,
+		entry.OrderNumber,
+		entry.ReversalOf,
+		entry.CampaignID,
+		entry.Comment,
+	).Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAlreadyExists
+		}
+		return nil, fmt.Errorf("insert ledger entry: %w", err)
+	}
+
+	tag, err := tx.Exec(ctx, UpdateUserBalance, entry.Amount, entry.UserID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23514" {
+			return nil, ErrInsufficientFunds
+		}
+		return nil, fmt.Errorf("update balance: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		// баланс не изменён: пользователя нет или баллов недостаточно
+		var exists bool
+		if err := tx.QueryRow(ctx, CheckUserExists, entry.UserID).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("check user: %w", err)
+		}
+		if !exists {
+			return nil, ErrUserNotFound
+		}
+		return nil, ErrInsufficientFunds
+	}
+
+	// строка пользователя заблокирована обновлением баланса, партии пользователя меняются последовательно
+	switch {
+	case entry.Amount.IsPositive():
+		_, err = tx.Exec(ctx, InsertPointLot, entry.UserID, entry.ID, entry.Amount, entry.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("insert point lot: %w", err)
+		}
+	case entry.Kind != models.LedgerExpiration:
+		_, err = tx.Exec(ctx, ConsumePointLots, entry.UserID, entry.Amount.Neg())
+		if err != nil {
+			return nil, fmt.Errorf("consume point lots: %w", err)
+		}
+	}
+	return &entry, nil
+}
+
+// AddLedgerEntry - добавление записи журнала с обновлением баланса пользователя
+func (s *LedgerDatabase) AddLedgerEntry(ctx context.Context, entry models.LedgerEntry) (*models.LedgerEntry, error) {
+	var result *models.LedgerEntry
+	err := inTx(ctx, s.DB, "AddLedgerEntry", func(tx pgx.Tx) error {
+		var err error
+		result, err = appendLedgerEntry(ctx, tx, entry)
+		return err
+	})
+	return result, err
+}
+
+// ReverseLedgerEntry - отмена записи журнала встречной записью на ту же сумму с обратным знаком
+func (s *LedgerDatabase) ReverseLedgerEntry(ctx context.Context, id int64, comment string) (*models.LedgerEntry, error) {
+	var result *models.LedgerEntry
+	err := inTx(ctx, s.DB, "ReverseLedgerEntry", func(tx pgx.Tx) error {
+		var (
+			userID string
+			amount decimal.Decimal
+		)
+		err := tx.QueryRow(ctx, GetLedgerEntryForUpdate, id).Scan(&userID, &amount)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrLedgerEntryNotFound
+			}
+			return fmt.Errorf("failed to get ledger entry: %w", err)
+		}
+		result, err = appendLedgerEntry(ctx, tx, models.LedgerEntry{
+			UserID:     userID,
+			Kind:       models.LedgerReversal,
+			Amount:     amount.Neg(),
+			ReversalOf: id,
+			Comment:    comment,
+		})
+		return err
+	})
+	return result, err
+}
+
+// GetLedger - записи журнала пользователя в порядке добавления
+func (s *LedgerDatabase) GetLedger(ctx context.Context, userID string) ([]models.LedgerEntry, error) {
+	rows, err := s.DB.Pool.Query(ctx, GetLedger, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ledger: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []models.LedgerEntry
+	for rows.Next() {
+		var entry models.LedgerEntry
+		err := rows.Scan(
+			&entry.ID,
+			&entry.UserID,
+			&entry.Kind,
+			&entry.Amount,
+			&entry.OrderNumber,
+			&entry.ReversalOf,
+			&entry.CampaignID,
+			&entry.Comment,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return entries, fmt.Errorf("failed scan ledger data: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// GetBalanceHistory - движения баллов пользователя за период с балансом после каждого движения
+func (s *LedgerDatabase) GetBalanceHistory(ctx context.Context, userID string, filter models.BalanceHistoryFilter) ([]models.BalanceHistoryEntry, error) {
+	rows, err := s.DB.Pool.Query(ctx, GetBalanceHistory, userID, nullTime(filter.From), nullTime(filter.To), filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []models.BalanceHistoryEntry
+	for rows.Next() {
+		var entry models.BalanceHistoryEntry
+		err := rows.Scan(
+			&entry.ID,
+			&entry.Kind,
+			&entry.Amount,
+			&entry.Balance,
+			&entry.OrderNumber,
+			&entry.Comment,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return history, fmt.Errorf("failed scan balance history: %w", err)
+		}
+		history = append(history, entry)
+	}
+	return history, rows.Err()
+}
+
+// GetBalanceAt - баланс и сумма списаний пользователя на момент времени
+func (s *LedgerDatabase) GetBalanceAt(ctx context.Context, userID string, at time.Time) (*models.UserBalance, error) {
+	var balance models.UserBalance
+	err := s.DB.Pool.QueryRow(ctx, GetBalanceAt, userID, at.UTC()).Scan(&balance.Current, &balance.Withdrawn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance: %w", err)
+	}
+	return &balance, nil
+}
+
+// nullTime - NULL для нулевого времени
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+// ExpirePoints - сгорание остатков партий, начисленных раньше before, не более limit партий за вызов.
+// Каждая партия сгорает в отдельной транзакции записью журнала EXPIRATION, возвращает количество сгоревших партий.
+// Сгорает не больше незарезервированных баллов пользователя, поэтому резерв всегда обеспечен.
+func (s *LedgerDatabase) ExpirePoints(ctx context.Context, before time.Time, limit int) (int, error) {
+	rows, err := s.DB.Pool.Query(ctx, GetExpiredPointLots, before.UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get expired point lots: %w", err)
+	}
+	type expiredLot struct {
+		id     int64
+		userID string
+	}
+	var lots []expiredLot
+	for rows.Next() {
+		var lot expiredLot
+		if err := rows.Scan(&lot.id, &lot.userID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed scan point lot: %w", err)
+		}
+		lots = append(lots, lot)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to get expired point lots: %w", err)
+	}
+
+	expired := 0
+	for _, lot := range lots {
+		err := inTx(ctx, s.DB, "ExpirePoints", func(tx pgx.Tx) error {
+			var (
+				userID    string
+				available decimal.Decimal
+			)
+			if err := tx.QueryRow(ctx, LockUserAvailable, lot.userID).Scan(&userID, &available); err != nil {
+				return fmt.Errorf("lock user: %w", err)
+			}
+			if !available.IsPositive() {
+				return errPointsHeld
+			}
+			var remaining decimal.Decimal
+			err := tx.QueryRow(ctx, ExpirePointLot, lot.id, available).Scan(&remaining)
+			if err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					return errPointLotSpent
+				}
+				return fmt.Errorf("expire point lot: %w", err)
+			}
+			_, err = appendLedgerEntry(ctx, tx, models.LedgerEntry{
+				UserID:  userID,
+				Kind:    models.LedgerExpiration,
+				Amount:  remaining.Neg(),
+				Comment: fmt.Sprintf("point lot #%d expired", lot.id),
+			})
+			return err
+		})
+		if errors.Is(err, errPointLotSpent) || errors.Is(err, errPointsHeld) {
+			// партия израсходована списанием или баллы зарезервированы после выборки
+			continue
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// GetPointLots - неизрасходованные партии баллов пользователя, начисленные раньше before, начиная с самых старых
+func (s *LedgerDatabase) GetPointLots(ctx context.Context, userID string, before time.Time) ([]models.PointLot, error) {
+	rows, err := s.DB.Pool.Query(ctx, GetPointLots, userID, before.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get point lots: %w", err)
+	}
+	defer rows.Close()
+
+	var lots []models.PointLot
+	for rows.Next() {
+		var lot models.PointLot
+		if err := rows.Scan(&lot.ID, &lot.UserID, &lot.Amount, &lot.Remaining, &lot.CreatedAt); err != nil {
+			return lots, fmt.Errorf("failed scan point lot: %w", err)
+		}
+		lots = append(lots, lot)
+	}
+	return lots, rows.Err()
+}
+
+// CheckLedger - пользователи, у которых баланс не совпадает с суммой записей журнала
+func (s *LedgerDatabase) CheckLedger(ctx context.Context) ([]models.LedgerMismatch, error) {
+	rows, err := s.DB.Pool.Query(ctx, CheckLedger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check ledger: %w", err)
+	}
+	defer rows.Close()
+
+	var mismatches []models.LedgerMismatch
+	for rows.Next() {
+		var mismatch models.LedgerMismatch
+		if err := rows.Scan(&mismatch.UserID, &mismatch.Login, &mismatch.Balance, &mismatch.Ledger); err != nil {
+			return mismatches, fmt.Errorf("failed scan ledger mismatch: %w", err)
+		}
+		mismatches = append(mismatches, mismatch)
+	}
+	return mismatches, rows.Err()
+}
+
+// RebuildBalances - пересчёт кэша балансов по журналу, возвращает количество исправленных пользователей
+func (s *LedgerDatabase) RebuildBalances(ctx context.Context) (int64, error) {
+	tag, err := s.DB.Pool.Exec(ctx, RebuildBalances)
+	if err != nil {
+		return 0, fmt.Errorf("failed to rebuild balances: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// inTx - выполнение функции в транзакции с откатом при ошибке
+func inTx(ctx context.Context, db *Database, name string, fn func(tx pgx.Tx) error) error {
+	tx, err := db.Pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(ctx); rbErr != nil {
+			logger.Error(name+". Rollback failed:", zap.Error(rbErr))
+		}
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s. Commit failed: %w", name, err)
+	}
+	return nil
+}
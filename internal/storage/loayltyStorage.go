@@ -9,7 +9,6 @@ import (
 	"github.com/denmor86/ya-gophermart/internal/logger"
 	"github.com/denmor86/ya-gophermart/internal/models"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 )
@@ -19,7 +18,30 @@ const (
 							VALUES ($1, $2, $3) 
 							ON CONFLICT (order_number) DO NOTHING
 							RETURNING order_number;`
-	GetWithdrawal = `SELECT order_number, user_id, amount, processed_at FROM LOYALTY WHERE user_id=$1 ORDER BY processed_at;`
+	GetWithdrawalByOrder = `SELECT user_id, amount FROM LOYALTY WHERE order_number=$1;`
+	GetWithdrawal        = `SELECT order_number, user_id, amount, processed_at FROM LOYALTY WHERE user_id=$1 ORDER BY processed_at;`
+
+	ReserveUserBalance = `UPDATE USERS SET held = held + $1 WHERE id = $2 AND balance - held >= $1;`
+	ReleaseUserBalance = `UPDATE USERS SET held = held - $1 WHERE id = $2;`
+	CheckWithdrawal    = `SELECT EXISTS(SELECT 1 FROM LOYALTY WHERE order_number = $1);`
+	CheckActiveHold    = `SELECT EXISTS(SELECT 1 FROM HOLDS WHERE order_number = $1 AND status = 'HELD');`
+	// списание и резерв по одному заказу выполняются по очереди до конца транзакции
+	LockOrderPayment = `SELECT pg_advisory_xact_lock(hashtext('payment:' || $1));`
+	InsertHold       = `INSERT INTO HOLDS (user_id, order_number, amount, expires_at)
+						  VALUES ($1, $2, $3, CURRENT_TIMESTAMP + $4::FLOAT8 * INTERVAL '1 second')
+						  ON CONFLICT DO NOTHING
+						  RETURNING id, status, created_at, expires_at;`
+	GetHoldOwner = `SELECT user_id FROM HOLDS WHERE id = $1;`
+	// резерв завершается однократно, просроченный резерв не списывается
+	CompleteHold = `UPDATE HOLDS
+					SET status = $2, completed_at = CURRENT_TIMESTAMP
+					WHERE id = $1 AND status = 'HELD' AND ($2 <> 'CAPTURED' OR expires_at > CURRENT_TIMESTAMP)
+					RETURNING id, user_id, order_number, amount, status, created_at, expires_at;`
+	GetExpiredHolds = `SELECT id, user_id
+					   FROM HOLDS
+					   WHERE status = 'HELD' AND expires_at <= CURRENT_TIMESTAMP
+					   ORDER BY expires_at, id
+					   LIMIT $1;`
 )
 
 type LoyaltyDatabase struct {
@@ -31,7 +53,8 @@ func NewLoyaltysStorage(db *Database) LoyaltysStorage {
 	return &LoyaltyDatabase{DB: db}
 }
 
-// AddWithdrawal — добавление записи о выводе средств и обновление баланса пользователя в одной транзакции
+// AddWithdrawal — добавление записи о выводе средств и обновление баланса пользователя в одной транзакции.
+// Списание по заказу с действующим резервом возвращает ErrAlreadyExists: заказ оплачивается списанием резерва.
 func (s *LoyaltyDatabase) AddWithdrawal(ctx context.Context, loyalty models.WithdrawalData) error {
 	// Начинаем транзакцию
 	tx, err := s.DB.Pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
@@ -48,11 +71,17 @@ func (s *LoyaltyDatabase) AddWithdrawal(ctx context.Context, loyalty models.With
 		}
 	}()
 
-	// 1. Уменьшаем баланс пользователя (amount передаётся как отрицательное значение)
-	_, err = tx.Exec(ctx, UpdateUserBalance, loyalty.Amount.Neg(), loyalty.UserID)
-	if err != nil {
-		logger.Error("Failed to update user balance", zap.Error(err))
-		return fmt.Errorf("update balance: %w", err)
+	// 1. Заказ с действующим резервом оплачивается только списанием резерва
+	if _, err = tx.Exec(ctx, LockOrderPayment, loyalty.OrderNumber); err != nil {
+		return fmt.Errorf("lock order payment: %w", err)
+	}
+	var held bool
+	if err = tx.QueryRow(ctx, CheckActiveHold, loyalty.OrderNumber).Scan(&held); err != nil {
+		return fmt.Errorf("check hold: %w", err)
+	}
+	if held {
+		err = ErrAlreadyExists
+		return err
 	}
 
 	// 2. Добавляем запись о выводе
@@ -64,24 +93,42 @@ func (s *LoyaltyDatabase) AddWithdrawal(ctx context.Context, loyalty models.With
 		loyalty.OrderNumber,
 		loyalty.Amount,
 	).Scan(&prevNumber)
-
-	// Обработка ошибок вставки
-	if err == nil {
-		// Успешная вставка → коммитим транзакцию
-		if err = tx.Commit(ctx); err != nil {
-			return fmt.Errorf("commit failed: %w", err)
+	if err != nil {
+		// Заказ уже оплачен баллами: повтор того же списания успешен без изменения баланса,
+		// другое списание по этому заказу - конфликт. Транзакция откатывается в обоих случаях.
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = ErrAlreadyExists
+			var (
+				userID string
+				amount decimal.Decimal
+			)
+			if scanErr := tx.QueryRow(ctx, GetWithdrawalByOrder, loyalty.OrderNumber).Scan(&userID, &amount); scanErr != nil {
+				return fmt.Errorf("get withdrawal: %w", scanErr)
+			}
+			if userID == loyalty.UserID && amount.Equal(loyalty.Amount) {
+				return nil
+			}
+			return ErrAlreadyExists
 		}
-		return nil
+		return fmt.Errorf("insert withdrawal: %w", err)
 	}
 
-	// Проверяем нарушение уникальности
-	var pgErr *pgconn.PgError
-	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
-		return ErrAlreadyExists
+	// 3. Списываем баллы записью журнала (сумма в журнале отрицательная)
+	_, err = appendLedgerEntry(ctx, tx, models.LedgerEntry{
+		UserID:      loyalty.UserID,
+		Kind:        models.LedgerWithdrawal,
+		Amount:      loyalty.Amount.Neg(),
+		OrderNumber: loyalty.OrderNumber,
+	})
+	if err != nil {
+		logger.Error("Failed to update user balance", zap.Error(err))
+		return fmt.Errorf("update balance: %w", err)
 	}
 
-	// Все остальные ошибки
-	return fmt.Errorf("insert withdrawal: %w", err)
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit failed: %w", err)
+	}
+	return nil
 }
 
 func (s *LoyaltyDatabase) GetWithdrawals(ctx context.Context, userID string) ([]models.WithdrawalData, error) {
@@ -115,3 +162,178 @@ func (s *LoyaltyDatabase) GetWithdrawals(ctx context.Context, userID string) ([]
 	}
 	return withdrawals, err
 }
+
+// AddHold - резервирование баллов под списание за заказ на время ttl.
+// Резерв больше доступного баланса возвращает ErrInsufficientFunds,
+// резерв по заказу, уже оплаченному или зарезервированному, - ErrAlreadyExists.
+func (s *LoyaltyDatabase) AddHold(ctx context.Context, hold models.HoldData, ttl time.Duration) (*models.HoldData, error) {
+	err := inTx(ctx, s.DB, "AddHold", func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, ReserveUserBalance, hold.Amount, hold.UserID)
+		if err != nil {
+			return fmt.Errorf("reserve balance: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			var exists bool
+			if err := tx.QueryRow(ctx, CheckUserExists, hold.UserID).Scan(&exists); err != nil {
+				return fmt.Errorf("check user: %w", err)
+			}
+			if !exists {
+				return ErrUserNotFound
+			}
+			return ErrInsufficientFunds
+		}
+
+		if _, err := tx.Exec(ctx, LockOrderPayment, hold.OrderNumber); err != nil {
+			return fmt.Errorf("lock order payment: %w", err)
+		}
+		var paid bool
+		if err := tx.QueryRow(ctx, CheckWithdrawal, hold.OrderNumber).Scan(&paid); err != nil {
+			return fmt.Errorf("check withdrawal: %w", err)
+		}
+		if paid {
+			return ErrAlreadyExists
+		}
+
+		err = tx.QueryRow(ctx, InsertHold, hold.UserID, hold.OrderNumber, hold.Amount, ttl.Seconds()).
+			Scan(&hold.ID, &hold.Status, &hold.CreatedAt, &hold.ExpiresAt)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrAlreadyExists
+			}
+			return fmt.Errorf("insert hold: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// CaptureHold - списание зарезервированных баллов: резерв снимается, списание записывается в историю и журнал
+func (s *LoyaltyDatabase) CaptureHold(ctx context.Context, userID string, id int64) (*models.HoldData, error) {
+	var hold *models.HoldData
+	err := inTx(ctx, s.DB, "CaptureHold", func(tx pgx.Tx) error {
+		var err error
+		hold, err = completeHold(ctx, tx, userID, id, models.HoldCaptured)
+		if err != nil {
+			return err
+		}
+
+		var number string
+		err = tx.QueryRow(ctx, InsertWithdrawal, hold.UserID, hold.OrderNumber, hold.Amount).Scan(&number)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrAlreadyExists
+			}
+			return fmt.Errorf("insert withdrawal: %w", err)
+		}
+		_, err = appendLedgerEntry(ctx, tx, models.LedgerEntry{
+			UserID:      hold.UserID,
+			Kind:        models.LedgerWithdrawal,
+			Amount:      hold.Amount.Neg(),
+			OrderNumber: hold.OrderNumber,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// ReleaseHold - снятие резерва, баллы снова доступны пользователю
+func (s *LoyaltyDatabase) ReleaseHold(ctx context.Context, userID string, id int64) (*models.HoldData, error) {
+	var hold *models.HoldData
+	err := inTx(ctx, s.DB, "ReleaseHold", func(tx pgx.Tx) error {
+		var err error
+		hold, err = completeHold(ctx, tx, userID, id, models.HoldReleased)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// ReleaseExpiredHolds - снятие просроченных резервов, не более limit за вызов.
+// Каждый резерв снимается в отдельной транзакции, возвращает количество снятых резервов.
+func (s *LoyaltyDatabase) ReleaseExpiredHolds(ctx context.Context, limit int) (int, error) {
+	rows, err := s.DB.Pool.Query(ctx, GetExpiredHolds, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get expired holds: %w", err)
+	}
+	type expiredHold struct {
+		id     int64
+		userID string
+	}
+	var holds []expiredHold
+	for rows.Next() {
+		var hold expiredHold
+		if err := rows.Scan(&hold.id, &hold.userID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed scan hold: %w", err)
+		}
+		holds = append(holds, hold)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to get expired holds: %w", err)
+	}
+
+	released := 0
+	for _, hold := range holds {
+		err := inTx(ctx, s.DB, "ReleaseExpiredHolds", func(tx pgx.Tx) error {
+			_, err := completeHold(ctx, tx, hold.userID, hold.id, models.HoldExpired)
+			return err
+		})
+		if errors.Is(err, ErrHoldNotActive) {
+			// резерв завершён после выборки
+			continue
+		}
+		if err != nil {
+			return released, err
+		}
+		released++
+	}
+	return released, nil
+}
+
+// completeHold - завершение действующего резерва пользователя с возвратом зарезервированных баллов в доступный баланс.
+// Пользователь блокируется раньше резерва, как и при списаниях.
+func completeHold(ctx context.Context, tx pgx.Tx, userID string, id int64, status string) (*models.HoldData, error) {
+	var owner string
+	if err := tx.QueryRow(ctx, GetHoldOwner, id).Scan(&owner); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrHoldNotFound
+		}
+		return nil, fmt.Errorf("get hold: %w", err)
+	}
+	if owner != userID {
+		return nil, ErrHoldNotFound
+	}
+	if _, err := tx.Exec(ctx, LockUser, userID); err != nil {
+		return nil, fmt.Errorf("lock user: %w", err)
+	}
+
+	var hold models.HoldData
+	err := tx.QueryRow(ctx, CompleteHold, id, status).Scan(
+		&hold.ID,
+		&hold.UserID,
+		&hold.OrderNumber,
+		&hold.Amount,
+		&hold.Status,
+		&hold.CreatedAt,
+		&hold.ExpiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrHoldNotActive
+		}
+		return nil, fmt.Errorf("complete hold: %w", err)
+	}
+	if _, err := tx.Exec(ctx, ReleaseUserBalance, hold.Amount, hold.UserID); err != nil {
+		return nil, fmt.Errorf("release balance: %w", err)
+	}
+	return &hold, nil
+}
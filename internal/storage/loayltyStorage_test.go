@@ -0,0 +1,705 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/denmor86/ya-gophermart/internal/storage"
+	"github.com/shopspring/decimal"
+)
+
+// TestDatabaseEnv - строка подключения к тестовой БД, без неё тесты с БД пропускаются
+const TestDatabaseEnv = "TEST_DATABASE_URI"
+
+func newTestStorage(t *testing.T) storage.Storage {
+	t.Helper()
+	dsn := os.Getenv(TestDatabaseEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", TestDatabaseEnv)
+	}
+	db, err := storage.NewDatabase(dsn)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	return storage.NewStorage(db)
+}
+
+// Одновременные списания не уводят баланс в минус: проходят ровно те, на которые хватает баллов
+func TestAddWithdrawal_Concurrent(t *testing.T) {
+	s := newTestStorage(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	login := fmt.Sprintf("withdraw-%d", time.Now().UnixNano())
+	if err := s.Users.AddUser(ctx, login, "hash", nil); err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	user, err := s.Users.GetUser(ctx, login)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	_, err = s.Ledger.AddLedgerEntry(ctx, models.LedgerEntry{
+		UserID: user.UserID,
+		Kind:   models.LedgerAdjustment,
+		Amount: decimal.NewFromInt(100),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+
+	const attempts = 25
+	var (
+		wg           sync.WaitGroup
+		mu           sync.Mutex
+		succeeded    int
+		insufficient int
+	)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := s.Loyaltys.AddWithdrawal(ctx, models.WithdrawalData{
+				OrderNumber: fmt.Sprintf("%s-%d", login, i),
+				UserID:      user.UserID,
+				Amount:      decimal.RequireFromString("9.99"),
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				succeeded++
+			case errors.Is(err, storage.ErrInsufficientFunds):
+				insufficient++
+			default:
+				t.Errorf("Unexpected error: '%v'", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if succeeded != 10 || insufficient != attempts-10 {
+		t.Errorf("Expected 10 withdrawals, got: %d succeeded, %d insufficient", succeeded, insufficient)
+	}
+
+	balance, err := s.Users.GetUserBalance(ctx, login)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	if !balance.Current.Equal(decimal.RequireFromString("0.1")) || !balance.Withdrawn.Equal(decimal.RequireFromString("99.9")) {
+		t.Errorf("Expected balance 0.1 and withdrawn 99.9, got: '%v' and '%v'", balance.Current, balance.Withdrawn)
+	}
+
+	mismatches, err := s.Ledger.CheckLedger(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	for _, mismatch := range mismatches {
+		if mismatch.UserID == user.UserID {
+			t.Errorf("Expected balance to match ledger, got: '%+v'", mismatch)
+		}
+	}
+}
+
+// Повтор того же списания по заказу успешен без изменения баланса, другое списание - конфликт
+func TestAddWithdrawal_Duplicate(t *testing.T) {
+	s := newTestStorage(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	login := fmt.Sprintf("duplicate-%d", time.Now().UnixNano())
+	if err := s.Users.AddUser(ctx, login, "hash", nil); err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	user, err := s.Users.GetUser(ctx, login)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	_, err = s.Ledger.AddLedgerEntry(ctx, models.LedgerEntry{
+		UserID: user.UserID,
+		Kind:   models.LedgerAdjustment,
+		Amount: decimal.NewFromInt(100),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+
+	withdrawal := models.WithdrawalData{OrderNumber: login, UserID: user.UserID, Amount: decimal.NewFromInt(30)}
+	for i := 0; i < 2; i++ {
+		if err := s.Loyaltys.AddWithdrawal(ctx, withdrawal); err != nil {
+			t.Fatalf("Attempt #%d: expected no error, got: '%v'", i+1, err)
+		}
+	}
+	withdrawal.Amount = decimal.NewFromInt(40)
+	if err := s.Loyaltys.AddWithdrawal(ctx, withdrawal); !errors.Is(err, storage.ErrAlreadyExists) {
+		t.Errorf("Expected error '%v', got: '%v'", storage.ErrAlreadyExists, err)
+	}
+
+	balance, err := s.Users.GetUserBalance(ctx, login)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	if !balance.Current.Equal(decimal.NewFromInt(70)) {
+		t.Errorf("Expected balance 70, got: '%v'", balance.Current)
+	}
+}
+
+// Списание расходует самые старые партии баллов первыми
+func TestAddWithdrawal_ConsumesOldestLots(t *testing.T) {
+	s := newTestStorage(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	login := fmt.Sprintf("lots-%d", time.Now().UnixNano())
+	if err := s.Users.AddUser(ctx, login, "hash", nil); err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	user, err := s.Users.GetUser(ctx, login)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	for _, amount := range []int64{30, 50} {
+		_, err = s.Ledger.AddLedgerEntry(ctx, models.LedgerEntry{
+			UserID: user.UserID,
+			Kind:   models.LedgerAdjustment,
+			Amount: decimal.NewFromInt(amount),
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got: '%v'", err)
+		}
+	}
+
+	withdrawal := models.WithdrawalData{OrderNumber: login, UserID: user.UserID, Amount: decimal.NewFromInt(40)}
+	if err := s.Loyaltys.AddWithdrawal(ctx, withdrawal); err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+
+	lots, err := s.Ledger.GetPointLots(ctx, user.UserID, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	if len(lots) != 1 || !lots[0].Amount.Equal(decimal.NewFromInt(50)) || !lots[0].Remaining.Equal(decimal.NewFromInt(40)) {
+		t.Errorf("Expected one lot of 50 with 40 remaining, got: '%+v'", lots)
+	}
+}
+
+// Зарезервированные баллы недоступны для списаний до списания или снятия резерва
+func TestHold_CaptureAndRelease(t *testing.T) {
+	s := newTestStorage(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	login := fmt.Sprintf("hold-%d", time.Now().UnixNano())
+	if err := s.Users.AddUser(ctx, login, "hash", nil); err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	user, err := s.Users.GetUser(ctx, login)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	_, err = s.Ledger.AddLedgerEntry(ctx, models.LedgerEntry{
+		UserID: user.UserID,
+		Kind:   models.LedgerAdjustment,
+		Amount: decimal.NewFromInt(100),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+
+	first, err := s.Loyaltys.AddHold(ctx, models.HoldData{UserID: user.UserID, OrderNumber: login + "-1", Amount: decimal.NewFromInt(60)}, time.Minute)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	// на списание остаётся 40 баллов
+	err = s.Loyaltys.AddWithdrawal(ctx, models.WithdrawalData{OrderNumber: login + "-2", UserID: user.UserID, Amount: decimal.NewFromInt(50)})
+	if !errors.Is(err, storage.ErrInsufficientFunds) {
+		t.Errorf("Expected error '%v', got: '%v'", storage.ErrInsufficientFunds, err)
+	}
+	second, err := s.Loyaltys.AddHold(ctx, models.HoldData{UserID: user.UserID, OrderNumber: login + "-3", Amount: decimal.NewFromInt(40)}, time.Minute)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	// заказ с действующим резервом оплачивается только списанием резерва
+	err = s.Loyaltys.AddWithdrawal(ctx, models.WithdrawalData{OrderNumber: first.OrderNumber, UserID: user.UserID, Amount: decimal.NewFromInt(60)})
+	if !errors.Is(err, storage.ErrAlreadyExists) {
+		t.Errorf("Expected error '%v', got: '%v'", storage.ErrAlreadyExists, err)
+	}
+
+	balance, err := s.Users.GetUserBalance(ctx, login)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	if !balance.Current.IsZero() || !balance.Held.Equal(decimal.NewFromInt(100)) {
+		t.Errorf("Expected balance 0 and held 100, got: '%v' and '%v'", balance.Current, balance.Held)
+	}
+
+	if _, err := s.Loyaltys.CaptureHold(ctx, user.UserID, first.ID); err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	if _, err := s.Loyaltys.ReleaseHold(ctx, user.UserID, second.ID); err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	if _, err := s.Loyaltys.ReleaseHold(ctx, user.UserID, first.ID); !errors.Is(err, storage.ErrHoldNotActive) {
+		t.Errorf("Expected error '%v', got: '%v'", storage.ErrHoldNotActive, err)
+	}
+
+	balance, err = s.Users.GetUserBalance(ctx, login)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	if !balance.Current.Equal(decimal.NewFromInt(40)) || !balance.Held.IsZero() || !balance.Withdrawn.Equal(decimal.NewFromInt(60)) {
+		t.Errorf("Expected balance 40, held 0 and withdrawn 60, got: '%v', '%v' and '%v'", balance.Current, balance.Held, balance.Withdrawn)
+	}
+}
+
+// Баллы пользователя с резервом сгорают, кроме зарезервированных: резерв остаётся обеспеченным
+func TestExpirePoints_WithHold(t *testing.T) {
+	s := newTestStorage(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	login := fmt.Sprintf("expire-hold-%d", time.Now().UnixNano())
+	if err := s.Users.AddUser(ctx, login, "hash", nil); err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	user, err := s.Users.GetUser(ctx, login)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	_, err = s.Ledger.AddLedgerEntry(ctx, models.LedgerEntry{
+		UserID: user.UserID,
+		Kind:   models.LedgerAdjustment,
+		Amount: decimal.NewFromInt(100),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	hold, err := s.Loyaltys.AddHold(ctx, models.HoldData{UserID: user.UserID, OrderNumber: login + "-1", Amount: decimal.NewFromInt(60)}, time.Minute)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+
+	before := time.Now().Add(time.Second)
+	if _, err := s.Ledger.ExpirePoints(ctx, before, 10000); err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	balance, err := s.Users.GetUserBalance(ctx, login)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	if !balance.Current.IsZero() || !balance.Held.Equal(decimal.NewFromInt(60)) {
+		t.Errorf("Expected balance 0 and held 60, got: '%v' and '%v'", balance.Current, balance.Held)
+	}
+
+	// резерв обеспечен несгоревшим остатком партии
+	if _, err := s.Loyaltys.CaptureHold(ctx, user.UserID, hold.ID); err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	balance, err = s.Users.GetUserBalance(ctx, login)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	if !balance.Current.IsZero() || !balance.Held.IsZero() || !balance.Withdrawn.Equal(decimal.NewFromInt(60)) {
+		t.Errorf("Expected balance 0, held 0 and withdrawn 60, got: '%v', '%v' and '%v'", balance.Current, balance.Held, balance.Withdrawn)
+	}
+}
+
+// Встречные переводы не блокируют друг друга и сохраняют общую сумму баллов
+func TestAddTransfer_Opposite(t *testing.T) {
+	s := newTestStorage(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	users := make([]*models.UserData, 2)
+	for i := range users {
+		login := fmt.Sprintf("transfer-%d-%d", i, time.Now().UnixNano())
+		if err := s.Users.AddUser(ctx, login, "hash", nil); err != nil {
+			t.Fatalf("Expected no error, got: '%v'", err)
+		}
+		user, err := s.Users.GetUser(ctx, login)
+		if err != nil {
+			t.Fatalf("Expected no error, got: '%v'", err)
+		}
+		_, err = s.Ledger.AddLedgerEntry(ctx, models.LedgerEntry{
+			UserID: user.UserID,
+			Kind:   models.LedgerAdjustment,
+			Amount: decimal.NewFromInt(100),
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got: '%v'", err)
+		}
+		users[i] = user
+	}
+
+	const attempts = 20
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			from, to := users[i%2], users[(i+1)%2]
+			_, err := s.Transfers.AddTransfer(ctx, models.TransferData{
+				FromUserID: from.UserID,
+				ToUserID:   to.UserID,
+				FromLogin:  from.Login,
+				ToLogin:    to.Login,
+				Amount:     decimal.NewFromInt(5),
+				Fee:        decimal.NewFromInt(1),
+			}, decimal.Zero)
+			if err != nil {
+				t.Errorf("Unexpected error: '%v'", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// каждый отправил и получил поровну, комиссия - по баллу за перевод
+	for _, user := range users {
+		balance, err := s.Users.GetUserBalance(ctx, user.Login)
+		if err != nil {
+			t.Fatalf("Expected no error, got: '%v'", err)
+		}
+		if !balance.Current.Equal(decimal.NewFromInt(100 - attempts/2)) {
+			t.Errorf("Expected balance %d, got: '%v'", 100-attempts/2, balance.Current)
+		}
+	}
+}
+
+// Бонусы кампаний начисляются по обработанному заказу отдельными записями журнала и только один раз
+func TestUpdateOrderAndBalance_AppliesCampaigns(t *testing.T) {
+	s := newTestStorage(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	login := fmt.Sprintf("campaign-%d", time.Now().UnixNano())
+	if err := s.Users.AddUser(ctx, login, "hash", nil); err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	user, err := s.Users.GetUser(ctx, login)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+
+	now := time.Now()
+	var ids []int64
+	bonuses := map[int64]decimal.Decimal{}
+	for _, campaign := range []models.Campaign{
+		{
+			Name:       "first order",
+			StartsAt:   now.Add(-time.Hour),
+			Conditions: models.CampaignConditions{FirstOrder: true},
+			Bonus:      models.CampaignBonus{Fixed: decimal.NewFromInt(100)},
+		},
+		{
+			Name:     "double points",
+			StartsAt: now.Add(-time.Hour),
+			EndsAt:   now.Add(time.Hour),
+			Bonus:    models.CampaignBonus{Percent: decimal.NewFromInt(100)},
+		},
+	} {
+		created, err := s.Campaigns.AddCampaign(ctx, campaign)
+		if err != nil {
+			t.Fatalf("Expected no error, got: '%v'", err)
+		}
+		t.Cleanup(func() { _ = s.Campaigns.DeactivateCampaign(context.Background(), created.ID) })
+		ids = append(ids, created.ID)
+		bonuses[created.ID] = decimal.Zero
+	}
+
+	first, second := login+"-1", login+"-2"
+	for _, number := range []string{first, second} {
+		if err := s.Orders.AddOrder(ctx, number, user.UserID, "", now); err != nil {
+			t.Fatalf("Expected no error, got: '%v'", err)
+		}
+	}
+	// повторная обработка заказа не начисляет бонусы повторно
+	for _, order := range []struct {
+		number  string
+		accrual int64
+	}{{first, 50}, {first, 50}, {second, 10}} {
+		err := s.Orders.UpdateOrderAndBalance(ctx, order.number, models.OrderStatusProcessed, decimal.NewFromInt(order.accrual), decimal.Zero)
+		if err != nil {
+			t.Fatalf("Expected no error, got: '%v'", err)
+		}
+	}
+
+	entries, err := s.Ledger.GetLedger(ctx, user.UserID)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	for _, entry := range entries {
+		if bonus, ok := bonuses[entry.CampaignID]; ok && entry.Kind == models.LedgerCampaign {
+			bonuses[entry.CampaignID] = bonus.Add(entry.Amount)
+		}
+	}
+	// бонус первого заказа - 100, удвоение - 50 и 10
+	for i, expected := range []int64{100, 60} {
+		if !bonuses[ids[i]].Equal(decimal.NewFromInt(expected)) {
+			t.Errorf("Expected campaign %d bonus %d, got: '%s'", ids[i], expected, bonuses[ids[i]])
+		}
+	}
+}
+
+// Бонусы приглашения начисляются обоим пользователям по первому обработанному заказу приглашённого,
+// приглашения сверх ограничения сохраняются без бонусов
+func TestUpdateOrderAndBalance_RewardsReferral(t *testing.T) {
+	s := newTestStorage(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	referrer := fmt.Sprintf("referrer-%d", time.Now().UnixNano())
+	if err := s.Users.AddUser(ctx, referrer, "hash", nil); err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	inviter, err := s.Users.GetUser(ctx, referrer)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	terms := models.ReferralTerms{
+		Code:          strings.ToLower(inviter.ReferralCode),
+		ReferrerBonus: decimal.NewFromInt(100),
+		ReferredBonus: decimal.NewFromInt(50),
+		MinAccrual:    decimal.NewFromInt(1),
+		MaxReferrals:  1,
+	}
+
+	if err := s.Users.AddUser(ctx, referrer+"-x", "hash", &models.ReferralTerms{Code: "UNKNOWN"}); !errors.Is(err, storage.ErrReferralCodeNotFound) {
+		t.Fatalf("Expected error '%v', got: '%v'", storage.ErrReferralCodeNotFound, err)
+	}
+	friends := []string{referrer + "-1", referrer + "-2"}
+	for _, login := range friends {
+		if err := s.Users.AddUser(ctx, login, "hash", &terms); err != nil {
+			t.Fatalf("Expected no error, got: '%v'", err)
+		}
+	}
+
+	friend, err := s.Users.GetUser(ctx, friends[0])
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	// бонусы начисляются только по первому заказу
+	for _, number := range []string{friends[0] + "-a", friends[0] + "-b"} {
+		if err := s.Orders.AddOrder(ctx, number, friend.UserID, "", time.Now()); err != nil {
+			t.Fatalf("Expected no error, got: '%v'", err)
+		}
+		if err := s.Orders.UpdateOrderAndBalance(ctx, number, models.OrderStatusProcessed, decimal.NewFromInt(10), decimal.Zero); err != nil {
+			t.Fatalf("Expected no error, got: '%v'", err)
+		}
+	}
+
+	referrals, err := s.Users.GetReferrals(ctx, inviter.UserID)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	statuses := map[string]string{}
+	for _, referral := range referrals {
+		statuses[referral.Login] = referral.Status
+	}
+	if statuses[friends[0]] != models.ReferralRewarded || statuses[friends[1]] != models.ReferralLimited {
+		t.Errorf("Expected REWARDED and LIMITED referrals, got: '%v'", statuses)
+	}
+
+	for login, expected := range map[string]int64{referrer: 100, friends[0]: 50} {
+		user, err := s.Users.GetUser(ctx, login)
+		if err != nil {
+			t.Fatalf("Expected no error, got: '%v'", err)
+		}
+		entries, err := s.Ledger.GetLedger(ctx, user.UserID)
+		if err != nil {
+			t.Fatalf("Expected no error, got: '%v'", err)
+		}
+		bonus := decimal.Zero
+		for _, entry := range entries {
+			if entry.Kind == models.LedgerReferral {
+				bonus = bonus.Add(entry.Amount)
+			}
+		}
+		if !bonus.Equal(decimal.NewFromInt(expected)) {
+			t.Errorf("Expected %s referral bonus %d, got: '%s'", login, expected, bonus)
+		}
+	}
+}
+
+// Заказ хранит начисление сервиса начислений, надбавка уровня зачисляется отдельной записью журнала
+func TestUpdateOrderAndBalance_BooksTierBonus(t *testing.T) {
+	s := newTestStorage(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	login := fmt.Sprintf("tier-%d", time.Now().UnixNano())
+	if err := s.Users.AddUser(ctx, login, "hash", nil); err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	user, err := s.Users.GetUser(ctx, login)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	if err := s.Orders.AddOrder(ctx, login, user.UserID, "", time.Now()); err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	// повторная обработка не зачисляет надбавку повторно
+	for i := 0; i < 2; i++ {
+		err := s.Orders.UpdateOrderAndBalance(ctx, login, models.OrderStatusProcessed, decimal.RequireFromString("33.33"), decimal.RequireFromString("8.33"))
+		if err != nil {
+			t.Fatalf("Expected no error, got: '%v'", err)
+		}
+	}
+
+	order, err := s.Orders.GetOrder(ctx, login)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	if !order.Accrual.Equal(decimal.RequireFromString("33.33")) {
+		t.Errorf("Expected order accrual 33.33, got: '%s'", order.Accrual)
+	}
+	amounts := map[string]decimal.Decimal{}
+	entries, err := s.Ledger.GetLedger(ctx, user.UserID)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	for _, entry := range entries {
+		amounts[entry.Kind] = amounts[entry.Kind].Add(entry.Amount)
+	}
+	if !amounts[models.LedgerAccrual].Equal(decimal.RequireFromString("33.33")) || !amounts[models.LedgerTier].Equal(decimal.RequireFromString("8.33")) {
+		t.Errorf("Expected accrual 33.33 and tier bonus 8.33, got: '%v'", amounts)
+	}
+}
+
+// Ключ незавершённого запроса занимается тем же запросом после истечения блокировки
+func TestBeginRequest_LockTimeout(t *testing.T) {
+	s := newTestStorage(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	scope := fmt.Sprintf("idempotency-%d", time.Now().UnixNano())
+	const lockTimeout = 200 * time.Millisecond
+
+	if _, err := s.Idempotency.BeginRequest(ctx, scope, "key", "hash", time.Hour, lockTimeout); err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	if _, err := s.Idempotency.BeginRequest(ctx, scope, "key", "hash", time.Hour, lockTimeout); !errors.Is(err, storage.ErrRequestInProgress) {
+		t.Errorf("Expected error '%v', got: '%v'", storage.ErrRequestInProgress, err)
+	}
+
+	time.Sleep(2 * lockTimeout)
+	if _, err := s.Idempotency.BeginRequest(ctx, scope, "key", "other", time.Hour, lockTimeout); !errors.Is(err, storage.ErrIdempotencyKeyReused) {
+		t.Errorf("Expected error '%v', got: '%v'", storage.ErrIdempotencyKeyReused, err)
+	}
+	stored, err := s.Idempotency.BeginRequest(ctx, scope, "key", "hash", time.Hour, lockTimeout)
+	if err != nil || stored != nil {
+		t.Fatalf("Expected key to be taken over, got: '%v' and '%v'", stored, err)
+	}
+	if _, err := s.Idempotency.BeginRequest(ctx, scope, "key", "hash", time.Hour, lockTimeout); !errors.Is(err, storage.ErrRequestInProgress) {
+		t.Errorf("Expected error '%v', got: '%v'", storage.ErrRequestInProgress, err)
+	}
+}
+
+// Удаляются только ключи старше срока хранения, ключ выполняемого запроса остаётся до истечения блокировки
+func TestDeleteExpiredKeys(t *testing.T) {
+	s := newTestStorage(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	scope := fmt.Sprintf("cleanup-%d", time.Now().UnixNano())
+	const ttl = 200 * time.Millisecond
+
+	if _, err := s.Idempotency.BeginRequest(ctx, scope, "completed", "hash", ttl, time.Hour); err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	if err := s.Idempotency.CompleteRequest(ctx, scope, "completed", models.IdempotentResponse{Status: 200}); err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	if _, err := s.Idempotency.BeginRequest(ctx, scope, "in-progress", "hash", ttl, time.Hour); err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	time.Sleep(2 * ttl)
+	if _, err := s.Idempotency.BeginRequest(ctx, scope, "fresh", "hash", ttl, time.Hour); err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	if err := s.Idempotency.CompleteRequest(ctx, scope, "fresh", models.IdempotentResponse{Status: 200}); err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+
+	// ключи других тестов тоже могут быть удалены, поэтому проверяется состояние ключей этого теста
+	for {
+		deleted, err := s.Idempotency.DeleteExpiredKeys(ctx, ttl, 100)
+		if err != nil {
+			t.Fatalf("Expected no error, got: '%v'", err)
+		}
+		if deleted < 100 {
+			break
+		}
+	}
+
+	// удалённый ключ занимается заново
+	if stored, err := s.Idempotency.BeginRequest(ctx, scope, "completed", "other", time.Hour, time.Hour); err != nil || stored != nil {
+		t.Errorf("Expected expired key to be deleted, got: '%v' and '%v'", stored, err)
+	}
+	if _, err := s.Idempotency.BeginRequest(ctx, scope, "in-progress", "hash", time.Hour, time.Hour); !errors.Is(err, storage.ErrRequestInProgress) {
+		t.Errorf("Expected error '%v', got: '%v'", storage.ErrRequestInProgress, err)
+	}
+	if stored, err := s.Idempotency.BeginRequest(ctx, scope, "fresh", "hash", time.Hour, time.Hour); err != nil || stored == nil {
+		t.Errorf("Expected stored response, got: '%v' and '%v'", stored, err)
+	}
+}
+
+// Продавец, указанный при загрузке заказа, возвращается вместе с владельцем для выбора провайдера начислений
+func TestGetOrderOwner_Merchant(t *testing.T) {
+	s := newTestStorage(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	login := fmt.Sprintf("merchant-%d", time.Now().UnixNano())
+	if err := s.Users.AddUser(ctx, login, "hash", nil); err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	user, err := s.Users.GetUser(ctx, login)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	if err := s.Orders.AddOrder(ctx, login, user.UserID, "acme", time.Now()); err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+
+	owner, err := s.Orders.GetOrderOwner(ctx, login)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	if owner.Login != login || owner.Merchant != "acme" {
+		t.Errorf("Expected owner %s of merchant acme, got: '%+v'", login, owner)
+	}
+	if _, err := s.Orders.GetOrderOwner(ctx, login+"0"); !errors.Is(err, storage.ErrOrderNotFound) {
+		t.Errorf("Expected error: '%v', got: '%v'", storage.ErrOrderNotFound, err)
+	}
+
+	// владельцы пачки загружаются одним запросом, отсутствующие заказы пропускаются
+	owners, err := s.Orders.GetOrderOwners(ctx, []string{login, login + "0"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	if len(owners) != 1 || owners[login] != *owner {
+		t.Errorf("Expected owners of one order '%+v', got: '%+v'", *owner, owners)
+	}
+}
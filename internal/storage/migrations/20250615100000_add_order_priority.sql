@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE USERS
+ADD priority INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE ORDERS
+ADD priority INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_orders_queue ON ORDERS (user_id, priority DESC, created_at)
+WHERE status IN ('NEW', 'REGISTERED', 'PROCESSING');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_orders_queue;
+
+ALTER TABLE ORDERS
+DROP COLUMN priority;
+
+ALTER TABLE USERS
+DROP COLUMN priority;
+-- +goose StatementEnd
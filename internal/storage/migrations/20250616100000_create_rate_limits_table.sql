@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS RATE_LIMITS (
+   name TEXT PRIMARY KEY NOT NULL,
+   rate_limit DOUBLE PRECISION,
+   burst INTEGER NOT NULL DEFAULT 1,
+   tokens DOUBLE PRECISION NOT NULL DEFAULT 0,
+   blocked_until TIMESTAMPTZ,
+   updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE RATE_LIMITS;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE ORDERS
+ADD outcome TEXT,
+ADD outcome_detail TEXT,
+ADD outcome_count INTEGER NOT NULL DEFAULT 0,
+ADD outcome_at TIMESTAMP;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE ORDERS
+DROP COLUMN outcome,
+DROP COLUMN outcome_detail,
+DROP COLUMN outcome_count,
+DROP COLUMN outcome_at;
+-- +goose StatementEnd
@@ -0,0 +1,51 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS LEDGER (
+    id BIGSERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('ACCRUAL', 'WITHDRAWAL', 'ADJUSTMENT', 'REVERSAL', 'EXPIRATION')),
+    amount DECIMAL(10, 2) NOT NULL CHECK (amount <> 0),
+    order_number TEXT,
+    reversal_of BIGINT REFERENCES LEDGER (id),
+    comment TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- заказ зачисляется и оплачивается баллами не более одного раза, запись отменяется не более одного раза
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ledger_order ON LEDGER (kind, order_number);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ledger_reversal ON LEDGER (reversal_of);
+CREATE INDEX IF NOT EXISTS idx_ledger_user ON LEDGER (user_id, created_at);
+
+-- перенос истории: начисления по заказам, списания и остаток баланса как корректировка
+INSERT INTO LEDGER (user_id, kind, amount, order_number, created_at)
+SELECT user_id, 'ACCRUAL', accrual, number, updated_at
+FROM ORDERS
+WHERE status = 'PROCESSED' AND accrual > 0;
+
+INSERT INTO LEDGER (user_id, kind, amount, order_number, created_at)
+SELECT user_id, 'WITHDRAWAL', -amount, order_number, COALESCE(processed_at, CURRENT_TIMESTAMP)
+FROM LOYALTY
+WHERE amount > 0;
+
+UPDATE USERS SET balance = 0 WHERE balance IS NULL;
+
+INSERT INTO LEDGER (user_id, kind, amount, comment)
+SELECT USERS.id, 'ADJUSTMENT', USERS.balance - COALESCE(SUM(LEDGER.amount), 0), 'opening balance'
+FROM USERS
+LEFT JOIN LEDGER ON LEDGER.user_id = USERS.id
+GROUP BY USERS.id, USERS.balance
+HAVING USERS.balance <> COALESCE(SUM(LEDGER.amount), 0);
+
+ALTER TABLE USERS
+ALTER COLUMN balance SET NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE USERS
+ALTER COLUMN balance DROP NOT NULL;
+DROP INDEX idx_ledger_user;
+DROP INDEX idx_ledger_reversal;
+DROP INDEX idx_ledger_order;
+DROP TABLE LEDGER;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE USERS
+ADD CONSTRAINT users_balance_non_negative CHECK (balance >= 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE USERS
+DROP CONSTRAINT users_balance_non_negative;
+-- +goose StatementEnd
@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+-- партии начисленных баллов, списания расходуют самые старые партии первыми
+CREATE TABLE IF NOT EXISTS POINT_LOTS (
+    id BIGSERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    ledger_id BIGINT NOT NULL REFERENCES LEDGER (id),
+    amount DECIMAL(10, 2) NOT NULL CHECK (amount > 0),
+    remaining DECIMAL(10, 2) NOT NULL CHECK (remaining >= 0 AND remaining <= amount),
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_point_lots_user ON POINT_LOTS (user_id, created_at, id) WHERE remaining > 0;
+CREATE INDEX IF NOT EXISTS idx_point_lots_created_at ON POINT_LOTS (created_at) WHERE remaining > 0;
+
+-- текущий баланс переносится одной партией с датой последнего зачисления
+INSERT INTO POINT_LOTS (user_id, ledger_id, amount, remaining, created_at)
+SELECT USERS.id, credits.ledger_id, USERS.balance, USERS.balance, credits.created_at
+FROM USERS
+JOIN (
+    SELECT DISTINCT ON (user_id) user_id, id AS ledger_id, created_at
+    FROM LEDGER
+    WHERE amount > 0
+    ORDER BY user_id, created_at DESC, id DESC
+) AS credits ON credits.user_id = USERS.id
+WHERE USERS.balance > 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_point_lots_created_at;
+DROP INDEX idx_point_lots_user;
+DROP TABLE POINT_LOTS;
+-- +goose StatementEnd
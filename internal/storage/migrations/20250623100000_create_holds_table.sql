@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+-- зарезервированные под незавершённые списания баллы не превышают баланс
+ALTER TABLE USERS
+ADD COLUMN held DECIMAL(10, 2) NOT NULL DEFAULT 0,
+ADD CONSTRAINT users_held_available CHECK (held >= 0 AND held <= balance);
+
+CREATE TABLE IF NOT EXISTS HOLDS (
+    id BIGSERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    order_number TEXT NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL CHECK (amount > 0),
+    status TEXT NOT NULL DEFAULT 'HELD' CHECK (status IN ('HELD', 'CAPTURED', 'RELEASED', 'EXPIRED')),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    completed_at TIMESTAMP
+);
+
+-- по заказу может быть только один действующий или списанный резерв, снятый резерв можно поставить заново
+CREATE UNIQUE INDEX IF NOT EXISTS idx_holds_order ON HOLDS (order_number) WHERE status IN ('HELD', 'CAPTURED');
+CREATE INDEX IF NOT EXISTS idx_holds_user ON HOLDS (user_id);
+CREATE INDEX IF NOT EXISTS idx_holds_expires_at ON HOLDS (expires_at) WHERE status = 'HELD';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_holds_expires_at;
+DROP INDEX idx_holds_user;
+DROP INDEX idx_holds_order;
+DROP TABLE HOLDS;
+ALTER TABLE USERS
+DROP CONSTRAINT users_held_available,
+DROP COLUMN held;
+-- +goose StatementEnd
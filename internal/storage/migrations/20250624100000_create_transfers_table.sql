@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS TRANSFERS (
+    id BIGSERIAL PRIMARY KEY,
+    from_user_id TEXT NOT NULL,
+    to_user_id TEXT NOT NULL CHECK (to_user_id <> from_user_id),
+    amount DECIMAL(10, 2) NOT NULL CHECK (amount > 0),
+    fee DECIMAL(10, 2) NOT NULL DEFAULT 0 CHECK (fee >= 0),
+    comment TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_transfers_from_user ON TRANSFERS (from_user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_transfers_to_user ON TRANSFERS (to_user_id, created_at);
+
+-- переводы записываются в журнал обеим сторонам, комиссия - отдельной записью отправителю
+ALTER TABLE LEDGER DROP CONSTRAINT ledger_kind_check;
+ALTER TABLE LEDGER ADD CONSTRAINT ledger_kind_check
+    CHECK (kind IN ('ACCRUAL', 'WITHDRAWAL', 'ADJUSTMENT', 'REVERSAL', 'EXPIRATION', 'TRANSFER', 'FEE'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE LEDGER DROP CONSTRAINT ledger_kind_check;
+ALTER TABLE LEDGER ADD CONSTRAINT ledger_kind_check
+    CHECK (kind IN ('ACCRUAL', 'WITHDRAWAL', 'ADJUSTMENT', 'REVERSAL', 'EXPIRATION'));
+DROP INDEX idx_transfers_to_user;
+DROP INDEX idx_transfers_from_user;
+DROP TABLE TRANSFERS;
+-- +goose StatementEnd
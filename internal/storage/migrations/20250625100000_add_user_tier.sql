@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- уровень участника пересчитывается заданием по сумме начислений за период
+ALTER TABLE USERS
+ADD COLUMN tier TEXT NOT NULL DEFAULT '',
+ADD COLUMN tier_updated_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_ledger_kind_created_at ON LEDGER (kind, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_ledger_kind_created_at;
+ALTER TABLE USERS
+DROP COLUMN tier_updated_at,
+DROP COLUMN tier;
+-- +goose StatementEnd
@@ -0,0 +1,45 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS CAMPAIGNS (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP CHECK (ends_at IS NULL OR ends_at > starts_at),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    first_order BOOLEAN NOT NULL DEFAULT FALSE,
+    min_accrual DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    tier TEXT NOT NULL DEFAULT '',
+    bonus_percent DECIMAL(10, 2) NOT NULL DEFAULT 0 CHECK (bonus_percent >= 0),
+    bonus_fixed DECIMAL(10, 2) NOT NULL DEFAULT 0 CHECK (bonus_fixed >= 0),
+    bonus_max DECIMAL(10, 2) NOT NULL DEFAULT 0 CHECK (bonus_max >= 0),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_campaigns_active ON CAMPAIGNS (starts_at, ends_at) WHERE active;
+
+-- бонус кампании - отдельная запись журнала со ссылкой на кампанию, по заказу начисляется не более одного раза
+ALTER TABLE LEDGER ADD COLUMN campaign_id BIGINT REFERENCES CAMPAIGNS (id);
+
+DROP INDEX idx_ledger_order;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ledger_order ON LEDGER (kind, order_number) WHERE campaign_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ledger_campaign ON LEDGER (campaign_id, order_number) WHERE campaign_id IS NOT NULL;
+
+ALTER TABLE LEDGER DROP CONSTRAINT ledger_kind_check;
+ALTER TABLE LEDGER ADD CONSTRAINT ledger_kind_check
+    CHECK (kind IN ('ACCRUAL', 'WITHDRAWAL', 'ADJUSTMENT', 'REVERSAL', 'EXPIRATION', 'TRANSFER', 'FEE', 'CAMPAIGN'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE LEDGER DROP CONSTRAINT ledger_kind_check;
+ALTER TABLE LEDGER ADD CONSTRAINT ledger_kind_check
+    CHECK (kind IN ('ACCRUAL', 'WITHDRAWAL', 'ADJUSTMENT', 'REVERSAL', 'EXPIRATION', 'TRANSFER', 'FEE'));
+
+DROP INDEX idx_ledger_campaign;
+DROP INDEX idx_ledger_order;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ledger_order ON LEDGER (kind, order_number);
+
+ALTER TABLE LEDGER DROP COLUMN campaign_id;
+DROP INDEX idx_campaigns_active;
+DROP TABLE CAMPAIGNS;
+-- +goose StatementEnd
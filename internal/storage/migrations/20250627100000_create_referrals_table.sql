@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+-- код приглашения генерируется для каждого пользователя, в том числе уже зарегистрированных
+ALTER TABLE USERS ADD COLUMN referral_code TEXT NOT NULL
+    DEFAULT upper(substr(md5(random()::text || clock_timestamp()::text), 1, 10));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_referral_code ON USERS (referral_code);
+
+CREATE TABLE IF NOT EXISTS REFERRALS (
+    id BIGSERIAL PRIMARY KEY,
+    referrer_id TEXT NOT NULL REFERENCES USERS (id),
+    referred_id TEXT NOT NULL UNIQUE REFERENCES USERS (id),
+    status TEXT NOT NULL CHECK (status IN ('PENDING', 'REWARDED', 'REJECTED', 'LIMITED')),
+    referrer_bonus DECIMAL(10, 2) NOT NULL DEFAULT 0 CHECK (referrer_bonus >= 0),
+    referred_bonus DECIMAL(10, 2) NOT NULL DEFAULT 0 CHECK (referred_bonus >= 0),
+    min_accrual DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    order_number TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_referrals_referrer ON REFERRALS (referrer_id, created_at);
+
+ALTER TABLE LEDGER DROP CONSTRAINT ledger_kind_check;
+ALTER TABLE LEDGER ADD CONSTRAINT ledger_kind_check
+    CHECK (kind IN ('ACCRUAL', 'WITHDRAWAL', 'ADJUSTMENT', 'REVERSAL', 'EXPIRATION', 'TRANSFER', 'FEE', 'CAMPAIGN', 'REFERRAL'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE LEDGER DROP CONSTRAINT ledger_kind_check;
+ALTER TABLE LEDGER ADD CONSTRAINT ledger_kind_check
+    CHECK (kind IN ('ACCRUAL', 'WITHDRAWAL', 'ADJUSTMENT', 'REVERSAL', 'EXPIRATION', 'TRANSFER', 'FEE', 'CAMPAIGN'));
+
+DROP INDEX idx_referrals_referrer;
+DROP TABLE REFERRALS;
+DROP INDEX idx_users_referral_code;
+ALTER TABLE USERS DROP COLUMN referral_code;
+-- +goose StatementEnd
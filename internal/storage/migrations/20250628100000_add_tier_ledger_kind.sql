@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- надбавка уровня к начислению по заказу хранится отдельной записью журнала
+ALTER TABLE LEDGER DROP CONSTRAINT ledger_kind_check;
+ALTER TABLE LEDGER ADD CONSTRAINT ledger_kind_check
+    CHECK (kind IN ('ACCRUAL', 'WITHDRAWAL', 'ADJUSTMENT', 'REVERSAL', 'EXPIRATION', 'TRANSFER', 'FEE', 'CAMPAIGN', 'REFERRAL', 'TIER'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE LEDGER DROP CONSTRAINT ledger_kind_check;
+ALTER TABLE LEDGER ADD CONSTRAINT ledger_kind_check
+    CHECK (kind IN ('ACCRUAL', 'WITHDRAWAL', 'ADJUSTMENT', 'REVERSAL', 'EXPIRATION', 'TRANSFER', 'FEE', 'CAMPAIGN', 'REFERRAL'));
+-- +goose StatementEnd
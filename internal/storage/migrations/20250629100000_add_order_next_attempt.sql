@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- время, раньше которого заказ не захватывается: аренда на время обработки и отсрочка после ошибок
+ALTER TABLE ORDERS
+ADD next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_orders_next_attempt ON ORDERS (next_attempt_at)
+WHERE status IN ('NEW', 'REGISTERED', 'PROCESSING');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_orders_next_attempt;
+
+ALTER TABLE ORDERS
+DROP COLUMN next_attempt_at;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- время, до которого ключ занят выполняемым запросом
+ALTER TABLE IDEMPOTENCY_KEYS
+ADD locked_until TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE IDEMPOTENCY_KEYS
+DROP COLUMN locked_until;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- продавец, у которого совершена покупка: используется для выбора провайдера начислений
+ALTER TABLE ORDERS
+ADD merchant VARCHAR(255) NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE ORDERS
+DROP COLUMN merchant;
+-- +goose StatementEnd
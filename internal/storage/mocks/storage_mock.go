@@ -15,8 +15,10 @@ import (
 	time "time"
 
 	models "github.com/denmor86/ya-gophermart/internal/models"
+	storage "github.com/denmor86/ya-gophermart/internal/storage"
 	decimal "github.com/shopspring/decimal"
 	gomock "go.uber.org/mock/gomock"
+	rate "golang.org/x/time/rate"
 )
 
 // MockUsersStorage is a mock of UsersStorage interface.
@@ -44,17 +46,32 @@ func (m *MockUsersStorage) EXPECT() *MockUsersStorageMockRecorder {
 }
 
 // AddUser mocks base method.
-func (m *MockUsersStorage) AddUser(ctx context.Context, login, password string) error {
+func (m *MockUsersStorage) AddUser(ctx context.Context, login, password string, referral *models.ReferralTerms) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddUser", ctx, login, password)
+	ret := m.ctrl.Call(m, "AddUser", ctx, login, password, referral)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AddUser indicates an expected call of AddUser.
-func (mr *MockUsersStorageMockRecorder) AddUser(ctx, login, password any) *gomock.Call {
+func (mr *MockUsersStorageMockRecorder) AddUser(ctx, login, password, referral any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUser", reflect.TypeOf((*MockUsersStorage)(nil).AddUser), ctx, login, password)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUser", reflect.TypeOf((*MockUsersStorage)(nil).AddUser), ctx, login, password, referral)
+}
+
+// GetReferrals mocks base method.
+func (m *MockUsersStorage) GetReferrals(ctx context.Context, userID string) ([]models.ReferralData, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReferrals", ctx, userID)
+	ret0, _ := ret[0].([]models.ReferralData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReferrals indicates an expected call of GetReferrals.
+func (mr *MockUsersStorageMockRecorder) GetReferrals(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReferrals", reflect.TypeOf((*MockUsersStorage)(nil).GetReferrals), ctx, userID)
 }
 
 // GetUser mocks base method.
@@ -87,6 +104,35 @@ func (mr *MockUsersStorageMockRecorder) GetUserBalance(ctx, login any) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserBalance", reflect.TypeOf((*MockUsersStorage)(nil).GetUserBalance), ctx, login)
 }
 
+// RecalculateTiers mocks base method.
+func (m *MockUsersStorage) RecalculateTiers(ctx context.Context, since time.Time, tiers []models.Tier) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecalculateTiers", ctx, since, tiers)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecalculateTiers indicates an expected call of RecalculateTiers.
+func (mr *MockUsersStorageMockRecorder) RecalculateTiers(ctx, since, tiers any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecalculateTiers", reflect.TypeOf((*MockUsersStorage)(nil).RecalculateTiers), ctx, since, tiers)
+}
+
+// SetUserPriority mocks base method.
+func (m *MockUsersStorage) SetUserPriority(ctx context.Context, login string, priority int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUserPriority", ctx, login, priority)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetUserPriority indicates an expected call of SetUserPriority.
+func (mr *MockUsersStorageMockRecorder) SetUserPriority(ctx, login, priority any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserPriority", reflect.TypeOf((*MockUsersStorage)(nil).SetUserPriority), ctx, login, priority)
+}
+
 // MockOrdersStorage is a mock of OrdersStorage interface.
 type MockOrdersStorage struct {
 	ctrl     *gomock.Controller
@@ -112,32 +158,47 @@ func (m *MockOrdersStorage) EXPECT() *MockOrdersStorageMockRecorder {
 }
 
 // AddOrder mocks base method.
-func (m *MockOrdersStorage) AddOrder(ctx context.Context, number, userID string, createdAt time.Time) error {
+func (m *MockOrdersStorage) AddOrder(ctx context.Context, number, userID, merchant string, createdAt time.Time) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddOrder", ctx, number, userID, createdAt)
+	ret := m.ctrl.Call(m, "AddOrder", ctx, number, userID, merchant, createdAt)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AddOrder indicates an expected call of AddOrder.
-func (mr *MockOrdersStorageMockRecorder) AddOrder(ctx, number, userID, createdAt any) *gomock.Call {
+func (mr *MockOrdersStorageMockRecorder) AddOrder(ctx, number, userID, merchant, createdAt any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddOrder", reflect.TypeOf((*MockOrdersStorage)(nil).AddOrder), ctx, number, userID, createdAt)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddOrder", reflect.TypeOf((*MockOrdersStorage)(nil).AddOrder), ctx, number, userID, merchant, createdAt)
 }
 
 // ClaimOrdersForProcessing mocks base method.
-func (m *MockOrdersStorage) ClaimOrdersForProcessing(ctx context.Context, count int) ([]string, error) {
+func (m *MockOrdersStorage) ClaimOrdersForProcessing(ctx context.Context, count int, lease time.Duration) ([]string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ClaimOrdersForProcessing", ctx, count)
+	ret := m.ctrl.Call(m, "ClaimOrdersForProcessing", ctx, count, lease)
 	ret0, _ := ret[0].([]string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ClaimOrdersForProcessing indicates an expected call of ClaimOrdersForProcessing.
-func (mr *MockOrdersStorageMockRecorder) ClaimOrdersForProcessing(ctx, count any) *gomock.Call {
+func (mr *MockOrdersStorageMockRecorder) ClaimOrdersForProcessing(ctx, count, lease any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimOrdersForProcessing", reflect.TypeOf((*MockOrdersStorage)(nil).ClaimOrdersForProcessing), ctx, count, lease)
+}
+
+// CountOrdersByStatus mocks base method.
+func (m *MockOrdersStorage) CountOrdersByStatus(ctx context.Context) (map[string]int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountOrdersByStatus", ctx)
+	ret0, _ := ret[0].(map[string]int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountOrdersByStatus indicates an expected call of CountOrdersByStatus.
+func (mr *MockOrdersStorageMockRecorder) CountOrdersByStatus(ctx any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimOrdersForProcessing", reflect.TypeOf((*MockOrdersStorage)(nil).ClaimOrdersForProcessing), ctx, count)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountOrdersByStatus", reflect.TypeOf((*MockOrdersStorage)(nil).CountOrdersByStatus), ctx)
 }
 
 // GetOrder mocks base method.
@@ -155,6 +216,51 @@ func (mr *MockOrdersStorageMockRecorder) GetOrder(ctx, number any) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrder", reflect.TypeOf((*MockOrdersStorage)(nil).GetOrder), ctx, number)
 }
 
+// GetOrderOwner mocks base method.
+func (m *MockOrdersStorage) GetOrderOwner(ctx context.Context, number string) (*models.OrderOwner, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderOwner", ctx, number)
+	ret0, _ := ret[0].(*models.OrderOwner)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderOwner indicates an expected call of GetOrderOwner.
+func (mr *MockOrdersStorageMockRecorder) GetOrderOwner(ctx, number any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderOwner", reflect.TypeOf((*MockOrdersStorage)(nil).GetOrderOwner), ctx, number)
+}
+
+// GetOrderOwners mocks base method.
+func (m *MockOrdersStorage) GetOrderOwners(ctx context.Context, numbers []string) (map[string]models.OrderOwner, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderOwners", ctx, numbers)
+	ret0, _ := ret[0].(map[string]models.OrderOwner)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderOwners indicates an expected call of GetOrderOwners.
+func (mr *MockOrdersStorageMockRecorder) GetOrderOwners(ctx, numbers any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderOwners", reflect.TypeOf((*MockOrdersStorage)(nil).GetOrderOwners), ctx, numbers)
+}
+
+// GetOrderTier mocks base method.
+func (m *MockOrdersStorage) GetOrderTier(ctx context.Context, number string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderTier", ctx, number)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderTier indicates an expected call of GetOrderTier.
+func (mr *MockOrdersStorageMockRecorder) GetOrderTier(ctx, number any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderTier", reflect.TypeOf((*MockOrdersStorage)(nil).GetOrderTier), ctx, number)
+}
+
 // GetOrders mocks base method.
 func (m *MockOrdersStorage) GetOrders(ctx context.Context, userID string) ([]models.OrderData, error) {
 	m.ctrl.T.Helper()
@@ -170,18 +276,47 @@ func (mr *MockOrdersStorageMockRecorder) GetOrders(ctx, userID any) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrders", reflect.TypeOf((*MockOrdersStorage)(nil).GetOrders), ctx, userID)
 }
 
+// RecordOrderFailure mocks base method.
+func (m *MockOrdersStorage) RecordOrderFailure(ctx context.Context, number string, failure models.OrderFailure) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordOrderFailure", ctx, number, failure)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordOrderFailure indicates an expected call of RecordOrderFailure.
+func (mr *MockOrdersStorageMockRecorder) RecordOrderFailure(ctx, number, failure any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordOrderFailure", reflect.TypeOf((*MockOrdersStorage)(nil).RecordOrderFailure), ctx, number, failure)
+}
+
+// RequeueOrder mocks base method.
+func (m *MockOrdersStorage) RequeueOrder(ctx context.Context, number string, priority int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequeueOrder", ctx, number, priority)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RequeueOrder indicates an expected call of RequeueOrder.
+func (mr *MockOrdersStorageMockRecorder) RequeueOrder(ctx, number, priority any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequeueOrder", reflect.TypeOf((*MockOrdersStorage)(nil).RequeueOrder), ctx, number, priority)
+}
+
 // UpdateOrderAndBalance mocks base method.
-func (m *MockOrdersStorage) UpdateOrderAndBalance(ctx context.Context, number, status string, accrual decimal.Decimal) error {
+func (m *MockOrdersStorage) UpdateOrderAndBalance(ctx context.Context, number, status string, accrual, tierBonus decimal.Decimal) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateOrderAndBalance", ctx, number, status, accrual)
+	ret := m.ctrl.Call(m, "UpdateOrderAndBalance", ctx, number, status, accrual, tierBonus)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateOrderAndBalance indicates an expected call of UpdateOrderAndBalance.
-func (mr *MockOrdersStorageMockRecorder) UpdateOrderAndBalance(ctx, number, status, accrual any) *gomock.Call {
+func (mr *MockOrdersStorageMockRecorder) UpdateOrderAndBalance(ctx, number, status, accrual, tierBonus any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrderAndBalance", reflect.TypeOf((*MockOrdersStorage)(nil).UpdateOrderAndBalance), ctx, number, status, accrual)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrderAndBalance", reflect.TypeOf((*MockOrdersStorage)(nil).UpdateOrderAndBalance), ctx, number, status, accrual, tierBonus)
 }
 
 // MockLoyaltysStorage is a mock of LoyaltysStorage interface.
@@ -208,6 +343,21 @@ func (m *MockLoyaltysStorage) EXPECT() *MockLoyaltysStorageMockRecorder {
 	return m.recorder
 }
 
+// AddHold mocks base method.
+func (m *MockLoyaltysStorage) AddHold(ctx context.Context, hold models.HoldData, ttl time.Duration) (*models.HoldData, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddHold", ctx, hold, ttl)
+	ret0, _ := ret[0].(*models.HoldData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddHold indicates an expected call of AddHold.
+func (mr *MockLoyaltysStorageMockRecorder) AddHold(ctx, hold, ttl any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddHold", reflect.TypeOf((*MockLoyaltysStorage)(nil).AddHold), ctx, hold, ttl)
+}
+
 // AddWithdrawal mocks base method.
 func (m *MockLoyaltysStorage) AddWithdrawal(ctx context.Context, loyalty models.WithdrawalData) error {
 	m.ctrl.T.Helper()
@@ -222,6 +372,21 @@ func (mr *MockLoyaltysStorageMockRecorder) AddWithdrawal(ctx, loyalty any) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWithdrawal", reflect.TypeOf((*MockLoyaltysStorage)(nil).AddWithdrawal), ctx, loyalty)
 }
 
+// CaptureHold mocks base method.
+func (m *MockLoyaltysStorage) CaptureHold(ctx context.Context, userID string, id int64) (*models.HoldData, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CaptureHold", ctx, userID, id)
+	ret0, _ := ret[0].(*models.HoldData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CaptureHold indicates an expected call of CaptureHold.
+func (mr *MockLoyaltysStorageMockRecorder) CaptureHold(ctx, userID, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CaptureHold", reflect.TypeOf((*MockLoyaltysStorage)(nil).CaptureHold), ctx, userID, id)
+}
+
 // GetWithdrawals mocks base method.
 func (m *MockLoyaltysStorage) GetWithdrawals(ctx context.Context, userID string) ([]models.WithdrawalData, error) {
 	m.ctrl.T.Helper()
@@ -236,3 +401,597 @@ func (mr *MockLoyaltysStorageMockRecorder) GetWithdrawals(ctx, userID any) *gomo
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawals", reflect.TypeOf((*MockLoyaltysStorage)(nil).GetWithdrawals), ctx, userID)
 }
+
+// ReleaseExpiredHolds mocks base method.
+func (m *MockLoyaltysStorage) ReleaseExpiredHolds(ctx context.Context, limit int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseExpiredHolds", ctx, limit)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReleaseExpiredHolds indicates an expected call of ReleaseExpiredHolds.
+func (mr *MockLoyaltysStorageMockRecorder) ReleaseExpiredHolds(ctx, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseExpiredHolds", reflect.TypeOf((*MockLoyaltysStorage)(nil).ReleaseExpiredHolds), ctx, limit)
+}
+
+// ReleaseHold mocks base method.
+func (m *MockLoyaltysStorage) ReleaseHold(ctx context.Context, userID string, id int64) (*models.HoldData, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseHold", ctx, userID, id)
+	ret0, _ := ret[0].(*models.HoldData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReleaseHold indicates an expected call of ReleaseHold.
+func (mr *MockLoyaltysStorageMockRecorder) ReleaseHold(ctx, userID, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseHold", reflect.TypeOf((*MockLoyaltysStorage)(nil).ReleaseHold), ctx, userID, id)
+}
+
+// MockLedgerStorage is a mock of LedgerStorage interface.
+type MockLedgerStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockLedgerStorageMockRecorder
+	isgomock struct{}
+}
+
+// MockLedgerStorageMockRecorder is the mock recorder for MockLedgerStorage.
+type MockLedgerStorageMockRecorder struct {
+	mock *MockLedgerStorage
+}
+
+// NewMockLedgerStorage creates a new mock instance.
+func NewMockLedgerStorage(ctrl *gomock.Controller) *MockLedgerStorage {
+	mock := &MockLedgerStorage{ctrl: ctrl}
+	mock.recorder = &MockLedgerStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLedgerStorage) EXPECT() *MockLedgerStorageMockRecorder {
+	return m.recorder
+}
+
+// AddLedgerEntry mocks base method.
+func (m *MockLedgerStorage) AddLedgerEntry(ctx context.Context, entry models.LedgerEntry) (*models.LedgerEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddLedgerEntry", ctx, entry)
+	ret0, _ := ret[0].(*models.LedgerEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddLedgerEntry indicates an expected call of AddLedgerEntry.
+func (mr *MockLedgerStorageMockRecorder) AddLedgerEntry(ctx, entry any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddLedgerEntry", reflect.TypeOf((*MockLedgerStorage)(nil).AddLedgerEntry), ctx, entry)
+}
+
+// CheckLedger mocks base method.
+func (m *MockLedgerStorage) CheckLedger(ctx context.Context) ([]models.LedgerMismatch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckLedger", ctx)
+	ret0, _ := ret[0].([]models.LedgerMismatch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CheckLedger indicates an expected call of CheckLedger.
+func (mr *MockLedgerStorageMockRecorder) CheckLedger(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckLedger", reflect.TypeOf((*MockLedgerStorage)(nil).CheckLedger), ctx)
+}
+
+// ExpirePoints mocks base method.
+func (m *MockLedgerStorage) ExpirePoints(ctx context.Context, before time.Time, limit int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpirePoints", ctx, before, limit)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpirePoints indicates an expected call of ExpirePoints.
+func (mr *MockLedgerStorageMockRecorder) ExpirePoints(ctx, before, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpirePoints", reflect.TypeOf((*MockLedgerStorage)(nil).ExpirePoints), ctx, before, limit)
+}
+
+// GetBalanceAt mocks base method.
+func (m *MockLedgerStorage) GetBalanceAt(ctx context.Context, userID string, at time.Time) (*models.UserBalance, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBalanceAt", ctx, userID, at)
+	ret0, _ := ret[0].(*models.UserBalance)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBalanceAt indicates an expected call of GetBalanceAt.
+func (mr *MockLedgerStorageMockRecorder) GetBalanceAt(ctx, userID, at any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalanceAt", reflect.TypeOf((*MockLedgerStorage)(nil).GetBalanceAt), ctx, userID, at)
+}
+
+// GetBalanceHistory mocks base method.
+func (m *MockLedgerStorage) GetBalanceHistory(ctx context.Context, userID string, filter models.BalanceHistoryFilter) ([]models.BalanceHistoryEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBalanceHistory", ctx, userID, filter)
+	ret0, _ := ret[0].([]models.BalanceHistoryEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBalanceHistory indicates an expected call of GetBalanceHistory.
+func (mr *MockLedgerStorageMockRecorder) GetBalanceHistory(ctx, userID, filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalanceHistory", reflect.TypeOf((*MockLedgerStorage)(nil).GetBalanceHistory), ctx, userID, filter)
+}
+
+// GetLedger mocks base method.
+func (m *MockLedgerStorage) GetLedger(ctx context.Context, userID string) ([]models.LedgerEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLedger", ctx, userID)
+	ret0, _ := ret[0].([]models.LedgerEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLedger indicates an expected call of GetLedger.
+func (mr *MockLedgerStorageMockRecorder) GetLedger(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLedger", reflect.TypeOf((*MockLedgerStorage)(nil).GetLedger), ctx, userID)
+}
+
+// GetPointLots mocks base method.
+func (m *MockLedgerStorage) GetPointLots(ctx context.Context, userID string, before time.Time) ([]models.PointLot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPointLots", ctx, userID, before)
+	ret0, _ := ret[0].([]models.PointLot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPointLots indicates an expected call of GetPointLots.
+func (mr *MockLedgerStorageMockRecorder) GetPointLots(ctx, userID, before any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPointLots", reflect.TypeOf((*MockLedgerStorage)(nil).GetPointLots), ctx, userID, before)
+}
+
+// RebuildBalances mocks base method.
+func (m *MockLedgerStorage) RebuildBalances(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RebuildBalances", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RebuildBalances indicates an expected call of RebuildBalances.
+func (mr *MockLedgerStorageMockRecorder) RebuildBalances(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RebuildBalances", reflect.TypeOf((*MockLedgerStorage)(nil).RebuildBalances), ctx)
+}
+
+// ReverseLedgerEntry mocks base method.
+func (m *MockLedgerStorage) ReverseLedgerEntry(ctx context.Context, id int64, comment string) (*models.LedgerEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReverseLedgerEntry", ctx, id, comment)
+	ret0, _ := ret[0].(*models.LedgerEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReverseLedgerEntry indicates an expected call of ReverseLedgerEntry.
+func (mr *MockLedgerStorageMockRecorder) ReverseLedgerEntry(ctx, id, comment any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReverseLedgerEntry", reflect.TypeOf((*MockLedgerStorage)(nil).ReverseLedgerEntry), ctx, id, comment)
+}
+
+// MockTransfersStorage is a mock of TransfersStorage interface.
+type MockTransfersStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockTransfersStorageMockRecorder
+	isgomock struct{}
+}
+
+// MockTransfersStorageMockRecorder is the mock recorder for MockTransfersStorage.
+type MockTransfersStorageMockRecorder struct {
+	mock *MockTransfersStorage
+}
+
+// NewMockTransfersStorage creates a new mock instance.
+func NewMockTransfersStorage(ctrl *gomock.Controller) *MockTransfersStorage {
+	mock := &MockTransfersStorage{ctrl: ctrl}
+	mock.recorder = &MockTransfersStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTransfersStorage) EXPECT() *MockTransfersStorageMockRecorder {
+	return m.recorder
+}
+
+// AddTransfer mocks base method.
+func (m *MockTransfersStorage) AddTransfer(ctx context.Context, transfer models.TransferData, dailyLimit decimal.Decimal) (*models.TransferData, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddTransfer", ctx, transfer, dailyLimit)
+	ret0, _ := ret[0].(*models.TransferData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddTransfer indicates an expected call of AddTransfer.
+func (mr *MockTransfersStorageMockRecorder) AddTransfer(ctx, transfer, dailyLimit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTransfer", reflect.TypeOf((*MockTransfersStorage)(nil).AddTransfer), ctx, transfer, dailyLimit)
+}
+
+// MockCampaignsStorage is a mock of CampaignsStorage interface.
+type MockCampaignsStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockCampaignsStorageMockRecorder
+	isgomock struct{}
+}
+
+// MockCampaignsStorageMockRecorder is the mock recorder for MockCampaignsStorage.
+type MockCampaignsStorageMockRecorder struct {
+	mock *MockCampaignsStorage
+}
+
+// NewMockCampaignsStorage creates a new mock instance.
+func NewMockCampaignsStorage(ctrl *gomock.Controller) *MockCampaignsStorage {
+	mock := &MockCampaignsStorage{ctrl: ctrl}
+	mock.recorder = &MockCampaignsStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCampaignsStorage) EXPECT() *MockCampaignsStorageMockRecorder {
+	return m.recorder
+}
+
+// AddCampaign mocks base method.
+func (m *MockCampaignsStorage) AddCampaign(ctx context.Context, campaign models.Campaign) (*models.Campaign, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddCampaign", ctx, campaign)
+	ret0, _ := ret[0].(*models.Campaign)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddCampaign indicates an expected call of AddCampaign.
+func (mr *MockCampaignsStorageMockRecorder) AddCampaign(ctx, campaign any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddCampaign", reflect.TypeOf((*MockCampaignsStorage)(nil).AddCampaign), ctx, campaign)
+}
+
+// DeactivateCampaign mocks base method.
+func (m *MockCampaignsStorage) DeactivateCampaign(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeactivateCampaign", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeactivateCampaign indicates an expected call of DeactivateCampaign.
+func (mr *MockCampaignsStorageMockRecorder) DeactivateCampaign(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeactivateCampaign", reflect.TypeOf((*MockCampaignsStorage)(nil).DeactivateCampaign), ctx, id)
+}
+
+// GetCampaigns mocks base method.
+func (m *MockCampaignsStorage) GetCampaigns(ctx context.Context) ([]models.Campaign, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCampaigns", ctx)
+	ret0, _ := ret[0].([]models.Campaign)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCampaigns indicates an expected call of GetCampaigns.
+func (mr *MockCampaignsStorageMockRecorder) GetCampaigns(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCampaigns", reflect.TypeOf((*MockCampaignsStorage)(nil).GetCampaigns), ctx)
+}
+
+// UpdateCampaign mocks base method.
+func (m *MockCampaignsStorage) UpdateCampaign(ctx context.Context, campaign models.Campaign) (*models.Campaign, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateCampaign", ctx, campaign)
+	ret0, _ := ret[0].(*models.Campaign)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateCampaign indicates an expected call of UpdateCampaign.
+func (mr *MockCampaignsStorageMockRecorder) UpdateCampaign(ctx, campaign any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCampaign", reflect.TypeOf((*MockCampaignsStorage)(nil).UpdateCampaign), ctx, campaign)
+}
+
+// MockIdempotencyStorage is a mock of IdempotencyStorage interface.
+type MockIdempotencyStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockIdempotencyStorageMockRecorder
+	isgomock struct{}
+}
+
+// MockIdempotencyStorageMockRecorder is the mock recorder for MockIdempotencyStorage.
+type MockIdempotencyStorageMockRecorder struct {
+	mock *MockIdempotencyStorage
+}
+
+// NewMockIdempotencyStorage creates a new mock instance.
+func NewMockIdempotencyStorage(ctrl *gomock.Controller) *MockIdempotencyStorage {
+	mock := &MockIdempotencyStorage{ctrl: ctrl}
+	mock.recorder = &MockIdempotencyStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIdempotencyStorage) EXPECT() *MockIdempotencyStorageMockRecorder {
+	return m.recorder
+}
+
+// BeginRequest mocks base method.
+func (m *MockIdempotencyStorage) BeginRequest(ctx context.Context, scope, key, requestHash string, ttl, lockTimeout time.Duration) (*models.IdempotentResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BeginRequest", ctx, scope, key, requestHash, ttl, lockTimeout)
+	ret0, _ := ret[0].(*models.IdempotentResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BeginRequest indicates an expected call of BeginRequest.
+func (mr *MockIdempotencyStorageMockRecorder) BeginRequest(ctx, scope, key, requestHash, ttl, lockTimeout any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginRequest", reflect.TypeOf((*MockIdempotencyStorage)(nil).BeginRequest), ctx, scope, key, requestHash, ttl, lockTimeout)
+}
+
+// CompleteRequest mocks base method.
+func (m *MockIdempotencyStorage) CompleteRequest(ctx context.Context, scope, key string, response models.IdempotentResponse) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteRequest", ctx, scope, key, response)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompleteRequest indicates an expected call of CompleteRequest.
+func (mr *MockIdempotencyStorageMockRecorder) CompleteRequest(ctx, scope, key, response any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteRequest", reflect.TypeOf((*MockIdempotencyStorage)(nil).CompleteRequest), ctx, scope, key, response)
+}
+
+// DeleteExpiredKeys mocks base method.
+func (m *MockIdempotencyStorage) DeleteExpiredKeys(ctx context.Context, ttl time.Duration, limit int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredKeys", ctx, ttl, limit)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpiredKeys indicates an expected call of DeleteExpiredKeys.
+func (mr *MockIdempotencyStorageMockRecorder) DeleteExpiredKeys(ctx, ttl, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredKeys", reflect.TypeOf((*MockIdempotencyStorage)(nil).DeleteExpiredKeys), ctx, ttl, limit)
+}
+
+// ReleaseRequest mocks base method.
+func (m *MockIdempotencyStorage) ReleaseRequest(ctx context.Context, scope, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseRequest", ctx, scope, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReleaseRequest indicates an expected call of ReleaseRequest.
+func (mr *MockIdempotencyStorageMockRecorder) ReleaseRequest(ctx, scope, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseRequest", reflect.TypeOf((*MockIdempotencyStorage)(nil).ReleaseRequest), ctx, scope, key)
+}
+
+// MockOrdersListener is a mock of OrdersListener interface.
+type MockOrdersListener struct {
+	ctrl     *gomock.Controller
+	recorder *MockOrdersListenerMockRecorder
+	isgomock struct{}
+}
+
+// MockOrdersListenerMockRecorder is the mock recorder for MockOrdersListener.
+type MockOrdersListenerMockRecorder struct {
+	mock *MockOrdersListener
+}
+
+// NewMockOrdersListener creates a new mock instance.
+func NewMockOrdersListener(ctrl *gomock.Controller) *MockOrdersListener {
+	mock := &MockOrdersListener{ctrl: ctrl}
+	mock.recorder = &MockOrdersListenerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockOrdersListener) EXPECT() *MockOrdersListenerMockRecorder {
+	return m.recorder
+}
+
+// Listen mocks base method.
+func (m *MockOrdersListener) Listen(ctx context.Context) <-chan string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Listen", ctx)
+	ret0, _ := ret[0].(<-chan string)
+	return ret0
+}
+
+// Listen indicates an expected call of Listen.
+func (mr *MockOrdersListenerMockRecorder) Listen(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Listen", reflect.TypeOf((*MockOrdersListener)(nil).Listen), ctx)
+}
+
+// MockLeaderLock is a mock of LeaderLock interface.
+type MockLeaderLock struct {
+	ctrl     *gomock.Controller
+	recorder *MockLeaderLockMockRecorder
+	isgomock struct{}
+}
+
+// MockLeaderLockMockRecorder is the mock recorder for MockLeaderLock.
+type MockLeaderLockMockRecorder struct {
+	mock *MockLeaderLock
+}
+
+// NewMockLeaderLock creates a new mock instance.
+func NewMockLeaderLock(ctrl *gomock.Controller) *MockLeaderLock {
+	mock := &MockLeaderLock{ctrl: ctrl}
+	mock.recorder = &MockLeaderLockMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLeaderLock) EXPECT() *MockLeaderLockMockRecorder {
+	return m.recorder
+}
+
+// Check mocks base method.
+func (m *MockLeaderLock) Check(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Check", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Check indicates an expected call of Check.
+func (mr *MockLeaderLockMockRecorder) Check(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*MockLeaderLock)(nil).Check), ctx)
+}
+
+// TryLock mocks base method.
+func (m *MockLeaderLock) TryLock(ctx context.Context) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TryLock", ctx)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TryLock indicates an expected call of TryLock.
+func (mr *MockLeaderLockMockRecorder) TryLock(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TryLock", reflect.TypeOf((*MockLeaderLock)(nil).TryLock), ctx)
+}
+
+// Unlock mocks base method.
+func (m *MockLeaderLock) Unlock(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unlock", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unlock indicates an expected call of Unlock.
+func (mr *MockLeaderLockMockRecorder) Unlock(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unlock", reflect.TypeOf((*MockLeaderLock)(nil).Unlock), ctx)
+}
+
+// MockRateLimitStorage is a mock of RateLimitStorage interface.
+type MockRateLimitStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockRateLimitStorageMockRecorder
+	isgomock struct{}
+}
+
+// MockRateLimitStorageMockRecorder is the mock recorder for MockRateLimitStorage.
+type MockRateLimitStorageMockRecorder struct {
+	mock *MockRateLimitStorage
+}
+
+// NewMockRateLimitStorage creates a new mock instance.
+func NewMockRateLimitStorage(ctrl *gomock.Controller) *MockRateLimitStorage {
+	mock := &MockRateLimitStorage{ctrl: ctrl}
+	mock.recorder = &MockRateLimitStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRateLimitStorage) EXPECT() *MockRateLimitStorageMockRecorder {
+	return m.recorder
+}
+
+// Block mocks base method.
+func (m *MockRateLimitStorage) Block(ctx context.Context, duration time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Block", ctx, duration)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Block indicates an expected call of Block.
+func (mr *MockRateLimitStorageMockRecorder) Block(ctx, duration any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Block", reflect.TypeOf((*MockRateLimitStorage)(nil).Block), ctx, duration)
+}
+
+// Bucket mocks base method.
+func (m *MockRateLimitStorage) Bucket(name string) storage.RateLimitStorage {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Bucket", name)
+	ret0, _ := ret[0].(storage.RateLimitStorage)
+	return ret0
+}
+
+// Bucket indicates an expected call of Bucket.
+func (mr *MockRateLimitStorageMockRecorder) Bucket(name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Bucket", reflect.TypeOf((*MockRateLimitStorage)(nil).Bucket), name)
+}
+
+// GetLimit mocks base method.
+func (m *MockRateLimitStorage) GetLimit(ctx context.Context) (rate.Limit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLimit", ctx)
+	ret0, _ := ret[0].(rate.Limit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLimit indicates an expected call of GetLimit.
+func (mr *MockRateLimitStorageMockRecorder) GetLimit(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLimit", reflect.TypeOf((*MockRateLimitStorage)(nil).GetLimit), ctx)
+}
+
+// Reserve mocks base method.
+func (m *MockRateLimitStorage) Reserve(ctx context.Context) (time.Duration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reserve", ctx)
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Reserve indicates an expected call of Reserve.
+func (mr *MockRateLimitStorageMockRecorder) Reserve(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reserve", reflect.TypeOf((*MockRateLimitStorage)(nil).Reserve), ctx)
+}
+
+// SetLimit mocks base method.
+func (m *MockRateLimitStorage) SetLimit(ctx context.Context, limit rate.Limit, burst int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetLimit", ctx, limit, burst)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetLimit indicates an expected call of SetLimit.
+func (mr *MockRateLimitStorageMockRecorder) SetLimit(ctx, limit, burst any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLimit", reflect.TypeOf((*MockRateLimitStorage)(nil).SetLimit), ctx, limit, burst)
+}
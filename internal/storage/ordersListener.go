@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	NewOrderChannel = "new_order"
+	ListenNewOrder  = `LISTEN ` + NewOrderChannel + `;`
+	NotifyNewOrder  = `SELECT pg_notify('` + NewOrderChannel + `', $1);`
+
+	// ListenerReconnectDelay - пауза перед повторным подключением после обрыва LISTEN соединения
+	ListenerReconnectDelay = 5 * time.Second
+)
+
+type OrderListener struct {
+	DB             *Database
+	ReconnectDelay time.Duration
+}
+
+// Создание подписчика
+func NewOrdersListener(db *Database) OrdersListener {
+	return &OrderListener{DB: db, ReconnectDelay: ListenerReconnectDelay}
+}
+
+// Listen - подписка на уведомления о новых заказах.
+// Канал возвращает номера добавленных заказов и закрывается при отмене контекста.
+// Уведомления, которые не успели прочитать, схлопываются: воркеру достаточно одного сигнала на пачку.
+func (l *OrderListener) Listen(ctx context.Context) <-chan string {
+	notifications := make(chan string, 1)
+	go func() {
+		defer close(notifications)
+		for {
+			err := l.listen(ctx, notifications)
+			if ctx.Err() != nil {
+				return
+			}
+			logger.Warn("Orders listener connection lost:", zap.Error(err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(l.ReconnectDelay):
+			}
+		}
+	}()
+	return notifications
+}
+
+// listen - ожидание уведомлений на выделенном соединении до первой ошибки
+func (l *OrderListener) listen(ctx context.Context, notifications chan<- string) error {
+	pooled, err := l.DB.Pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	// забираем соединение из пула: LISTEN привязан к сессии и не должен вернуться в общий пул
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err = conn.Exec(ctx, ListenNewOrder); err != nil {
+		return fmt.Errorf("failed to listen channel: %w", err)
+	}
+	logger.Info("Orders listener subscribed to channel", NewOrderChannel)
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to wait notification: %w", err)
+		}
+		select {
+		case notifications <- notification.Payload:
+		default:
+		}
+	}
+}
@@ -17,34 +17,114 @@ import (
 const (
 	GetOrder         = `SELECT user_id, status, created_at, accrual FROM ORDERS WHERE number=$1;`
 	GetUserIDByOrder = `SELECT user_id FROM ORDERS WHERE number=$1;`
-	InsertOrder      = `INSERT INTO ORDERS (number, user_id, status, accrual, retry_count, created_at, updated_at) 
-						VALUES ($1, $2, $3, $4, $5, $6, $7) 
+	GetOrderOwner    = `SELECT USERS.login, ORDERS.merchant FROM ORDERS JOIN USERS ON USERS.id = ORDERS.user_id WHERE ORDERS.number=$1;`
+	GetOrderOwners   = `SELECT ORDERS.number, USERS.login, ORDERS.merchant FROM ORDERS JOIN USERS ON USERS.id = ORDERS.user_id WHERE ORDERS.number = ANY($1);`
+	GetOrderTier     = `SELECT USERS.tier FROM ORDERS JOIN USERS ON USERS.id = ORDERS.user_id WHERE ORDERS.number=$1;`
+	InsertOrder      = `INSERT INTO ORDERS (number, user_id, status, accrual, retry_count, created_at, updated_at, merchant, priority) 
+						VALUES ($1, $2, $3, $4, $5, $6, $7, $8, COALESCE((SELECT priority FROM USERS WHERE id = $2), 0)) 
 						ON CONFLICT (number) DO NOTHING
 						RETURNING number;`
-	GetOrders                = `SELECT number, status, created_at, accrual FROM ORDERS WHERE user_id=$1;`
+	GetOrders = `SELECT number, status, created_at, accrual FROM ORDERS WHERE user_id=$1;`
+	// ClaimOrdersForProcessing - захват пачки заказов с честным распределением между пользователями.
+	// Сначала выбираются пользователи с ожидающими заказами (не больше размера пачки),
+	// у каждого берётся не больше пачки его старейших заказов, после чего заказы
+	// выдаются по кругу: первый заказ каждого пользователя, затем второй и т.д.
+	// Заказы с большим приоритетом (VIP, ручная постановка в очередь) идут первыми.
+	// Кандидаты выбираются без блокировок, блокируются только захватываемые заказы
+	// с пропуском занятых, поэтому одновременные захваты не ждут друг друга и
+	// получают следующие по очереди заказы вместо пустой пачки.
+	// Захватываются только заказы с наступившим next_attempt_at, захваченный заказ скрывается
+	// на время аренды $2 секунд, чтобы не попасть в следующую пачку до завершения обработки.
+	// Заказы в обработке запрашиваются, пока таблица решений не переведёт их в конечный статус.
 	ClaimOrdersForProcessing = `UPDATE ORDERS 
 								SET status = 'PROCESSING',
 								    retry_count = retry_count + 1,
+								    next_attempt_at = NOW() + make_interval(secs => $2::float8),
 								    updated_at = NOW()
 								WHERE number IN (
-								    SELECT number FROM ORDERS 
-								    WHERE status = 'NEW' OR status = 'REGISTERED' OR (status = 'PROCESSING' AND retry_count < 3)
-								    ORDER BY created_at 
+								    SELECT p.number
+								    FROM ORDERS p
+								    JOIN (
+								        SELECT c.number, c.priority, c.created_at,
+								               ROW_NUMBER() OVER (PARTITION BY u.user_id ORDER BY c.priority DESC, c.created_at) AS turn
+								        FROM (
+								            SELECT user_id FROM ORDERS
+								            WHERE status IN ('NEW', 'REGISTERED', 'PROCESSING')
+								              AND next_attempt_at <= NOW()
+								            GROUP BY user_id
+								            ORDER BY MAX(priority) DESC, MIN(created_at)
+								            LIMIT $1
+								        ) u
+								        CROSS JOIN LATERAL (
+								            SELECT o.number, o.priority, o.created_at FROM ORDERS o
+								            WHERE o.user_id = u.user_id
+								              AND o.status IN ('NEW', 'REGISTERED', 'PROCESSING')
+								              AND o.next_attempt_at <= NOW()
+								            ORDER BY o.priority DESC, o.created_at
+								            LIMIT $1
+								        ) c
+								    ) q ON q.number = p.number
+								    WHERE p.status IN ('NEW', 'REGISTERED', 'PROCESSING')
+								      AND p.next_attempt_at <= NOW()
+								    ORDER BY q.priority DESC, q.turn, q.created_at
 								    LIMIT $1
-								    FOR UPDATE SKIP LOCKED
+								    FOR UPDATE OF p SKIP LOCKED
 								)
 								RETURNING number;`
+	// RequeueOrder - повторная постановка в очередь заказа, не достигшего конечного статуса,
+	// или отклонённого после исчерпания попыток запроса начисления. Повторный расчёт заказа,
+	// конечный статус которого выдал сервис начислений, разошёлся бы с журналом баллов
+	RequeueOrder = `UPDATE ORDERS 
+					SET status = 'NEW',
+					    retry_count = 0,
+					    outcome_count = 0,
+					    next_attempt_at = NOW(),
+					    priority = $2,
+					    updated_at = NOW()
+					WHERE number = $1
+					  AND (status NOT IN ('PROCESSED', 'INVALID')
+					       OR (status = 'INVALID' AND outcome IS NOT NULL AND outcome <> 'ok'))
+					RETURNING number;`
+	GetOrderStatus = `SELECT status FROM ORDERS WHERE number = $1;`
 
 	UpdateOrdersStatus = `UPDATE ORDERS 
 						  SET 
 						      status = $1,
 						      accrual = $2,
-						      retry_count = retry_count + 1,
+						      outcome = 'ok',
+						      outcome_detail = NULL,
+						      outcome_count = 0,
+						      outcome_at = NOW(),
+						      next_attempt_at = NOW(),
 						      updated_at = NOW()
 						  WHERE number = $3;`
+	// RecordOrderFailure - запись ошибки запроса начисления. Подряд идущие неудачные запросы
+	// считаются независимо от класса ошибки, по достижении $4 заказ переводится в конечный статус $5.
+	// Следующая попытка откладывается на $6 секунд, удваиваемых с каждой ошибкой, но не больше $7.
+	RecordOrderFailure = `UPDATE ORDERS 
+						  SET 
+						      status = CASE
+						          WHEN $4 > 0 AND failures.count >= $4 THEN $5
+						          ELSE 'PROCESSING'
+						      END,
+						      outcome = $2,
+						      outcome_detail = $3,
+						      outcome_count = failures.count,
+						      outcome_at = NOW(),
+						      next_attempt_at = NOW() + make_interval(secs => LEAST($6::float8 * power(2, failures.count - 1), $7::float8)),
+						      updated_at = NOW()
+						  FROM (
+						      SELECT CASE WHEN outcome IS NOT NULL AND outcome <> 'ok' THEN outcome_count + 1 ELSE 1 END AS count
+						      FROM ORDERS WHERE number = $1
+						  ) failures
+						  WHERE number = $1
+						  RETURNING status;`
+	CountOrdersByStatus = `SELECT status, COUNT(*) FROM ORDERS GROUP BY status;`
+	// списание выполняется только при достаточном балансе, проверка и изменение атомарны
 	UpdateUserBalance = `UPDATE USERS 
 						  SET balance = balance + $1
-						  WHERE id = $2;`
+						  WHERE id = $2 AND balance + $1 >= held;`
+	CheckUserExists = `SELECT EXISTS(SELECT 1 FROM USERS WHERE id = $1);`
 )
 
 type OrderDatabase struct {
@@ -86,6 +166,54 @@ func (s *OrderDatabase) GetOrder(ctx context.Context, number string) (*models.Or
 	}, nil
 }
 
+// GetOrderOwner - логин пользователя, загрузившего заказ, и продавец заказа
+func (s *OrderDatabase) GetOrderOwner(ctx context.Context, number string) (*models.OrderOwner, error) {
+	var owner models.OrderOwner
+	err := s.DB.Pool.QueryRow(ctx, GetOrderOwner, number).Scan(&owner.Login, &owner.Merchant)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("failed to get order owner: %w", err)
+	}
+	return &owner, nil
+}
+
+// GetOrderOwners - владельцы и продавцы заказов по списку номеров, отсутствующие заказы в ответ не входят
+func (s *OrderDatabase) GetOrderOwners(ctx context.Context, numbers []string) (map[string]models.OrderOwner, error) {
+	rows, err := s.DB.Pool.Query(ctx, GetOrderOwners, numbers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order owners: %w", err)
+	}
+	defer rows.Close()
+
+	owners := make(map[string]models.OrderOwner, len(numbers))
+	for rows.Next() {
+		var (
+			number string
+			owner  models.OrderOwner
+		)
+		if err := rows.Scan(&number, &owner.Login, &owner.Merchant); err != nil {
+			return nil, fmt.Errorf("failed scan order owner: %w", err)
+		}
+		owners[number] = owner
+	}
+	return owners, rows.Err()
+}
+
+// GetOrderTier - уровень владельца заказа
+func (s *OrderDatabase) GetOrderTier(ctx context.Context, number string) (string, error) {
+	var tier string
+	err := s.DB.Pool.QueryRow(ctx, GetOrderTier, number).Scan(&tier)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrOrderNotFound
+		}
+		return "", fmt.Errorf("failed to get order tier: %w", err)
+	}
+	return tier, nil
+}
+
 func (s *OrderDatabase) GetOrders(ctx context.Context, userID string) ([]models.OrderData, error) {
 	var orders []models.OrderData
 	rows, err := s.DB.Pool.Query(ctx, GetOrders, userID)
@@ -117,10 +245,10 @@ func (s *OrderDatabase) GetOrders(ctx context.Context, userID string) ([]models.
 	return orders, err
 }
 
-func (s *OrderDatabase) ClaimOrdersForProcessing(ctx context.Context, count int) ([]string, error) {
+func (s *OrderDatabase) ClaimOrdersForProcessing(ctx context.Context, count int, lease time.Duration) ([]string, error) {
 
 	var numbers []string
-	rows, err := s.DB.Pool.Query(ctx, ClaimOrdersForProcessing, count)
+	rows, err := s.DB.Pool.Query(ctx, ClaimOrdersForProcessing, count, lease.Seconds())
 	if err != nil {
 		return nil, fmt.Errorf("failed to get processing orders: %w", err)
 	}
@@ -136,11 +264,35 @@ func (s *OrderDatabase) ClaimOrdersForProcessing(ctx context.Context, count int)
 	return numbers, err
 }
 
-func (s *OrderDatabase) AddOrder(ctx context.Context, number string, userID string, createdAt time.Time) error {
+// CountOrdersByStatus - количество заказов в каждом статусе
+func (s *OrderDatabase) CountOrdersByStatus(ctx context.Context) (map[string]int, error) {
+	counts := make(map[string]int)
+	rows, err := s.DB.Pool.Query(ctx, CountOrdersByStatus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count orders: %w", err)
+	}
+	for rows.Next() {
+		var (
+			status string
+			count  int
+		)
+		if err := rows.Scan(&status, &count); err != nil {
+			return counts, fmt.Errorf("failed scan orders count: %w", err)
+		}
+		counts[status] = count
+	}
+	return counts, rows.Err()
+}
+
+func (s *OrderDatabase) AddOrder(ctx context.Context, number string, userID string, merchant string, createdAt time.Time) error {
 	var prevNumber string
-	err := s.DB.Pool.QueryRow(ctx, InsertOrder, number, userID, models.OrderStatusNew, 0, 0, createdAt, createdAt).Scan(&prevNumber)
+	err := s.DB.Pool.QueryRow(ctx, InsertOrder, number, userID, models.OrderStatusNew, 0, 0, createdAt, createdAt, merchant).Scan(&prevNumber)
 
 	if err == nil {
+		// будим воркер, при ошибке заказ будет обработан по таймеру
+		if _, notifyErr := s.DB.Pool.Exec(ctx, NotifyNewOrder, number); notifyErr != nil {
+			logger.Warn("Failed to notify new order", number, zap.Error(notifyErr))
+		}
 		return nil
 	}
 
@@ -154,8 +306,55 @@ func (s *OrderDatabase) AddOrder(ctx context.Context, number string, userID stri
 	return fmt.Errorf("failed to add order: %w", err)
 }
 
-// UpdateOrderAndBalance - Обновление статуса заказа и баланса пользователя в одной транзакции
-func (s *OrderDatabase) UpdateOrderAndBalance(ctx context.Context, number string, status string, accrual decimal.Decimal) error {
+// RequeueOrder - повторная постановка заказа в очередь обработки с заданным приоритетом
+func (s *OrderDatabase) RequeueOrder(ctx context.Context, number string, priority int) error {
+	var requeued string
+	err := s.DB.Pool.QueryRow(ctx, RequeueOrder, number, priority).Scan(&requeued)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// заказ не найден или уже в конечном статусе
+			var status string
+			err = s.DB.Pool.QueryRow(ctx, GetOrderStatus, number).Scan(&status)
+			switch {
+			case err == nil:
+				return ErrOrderFinalized
+			case errors.Is(err, pgx.ErrNoRows):
+				return ErrOrderNotFound
+			}
+			return fmt.Errorf("failed to get order status: %w", err)
+		}
+		return fmt.Errorf("failed to requeue order: %w", err)
+	}
+	if _, err = s.DB.Pool.Exec(ctx, NotifyNewOrder, number); err != nil {
+		logger.Warn("Failed to notify requeued order", number, zap.Error(err))
+	}
+	return nil
+}
+
+// RecordOrderFailure - запись ошибки запроса начисления, возвращает новый статус заказа
+func (s *OrderDatabase) RecordOrderFailure(ctx context.Context, number string, failure models.OrderFailure) (string, error) {
+	var status string
+	err := s.DB.Pool.QueryRow(ctx, RecordOrderFailure,
+		number,
+		failure.Class,
+		failure.Detail,
+		failure.MaxAttempts,
+		failure.FinalStatus,
+		failure.Backoff.Seconds(),
+		failure.MaxBackoff.Seconds(),
+	).Scan(&status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrOrderNotFound
+		}
+		return "", fmt.Errorf("failed to record order failure: %w", err)
+	}
+	return status, nil
+}
+
+// UpdateOrderAndBalance - Обновление статуса заказа и баланса пользователя в одной транзакции.
+// Заказ хранит начисление сервиса начислений, надбавка уровня зачисляется отдельной записью журнала
+func (s *OrderDatabase) UpdateOrderAndBalance(ctx context.Context, number string, status string, accrual decimal.Decimal, tierBonus decimal.Decimal) error {
 	// Начинаем транзакцию
 	tx, err := s.DB.Pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
 	if err != nil {
@@ -177,16 +376,60 @@ func (s *OrderDatabase) UpdateOrderAndBalance(ctx context.Context, number string
 		return fmt.Errorf("failed to update order status: %w", err)
 	}
 
-	// Обновляем баланс пользователя (только если есть начисление)
-	if accrual.GreaterThan(decimal.Zero) {
+	processed := status == models.OrderStatusProcessed
+	if processed || accrual.GreaterThan(decimal.Zero) {
 		var userID string
 		err = tx.QueryRow(ctx, GetUserIDByOrder, number).Scan(&userID)
 		if err != nil {
 			return fmt.Errorf("failed to get user: %w", err)
 		}
-		_, err = tx.Exec(ctx, UpdateUserBalance, accrual, userID)
-		if err != nil {
-			return fmt.Errorf("failed to update user balance: %w", err)
+
+		// Завершаем приглашение пользователя по первому обработанному заказу
+		if processed {
+			if err = applyReferral(ctx, tx, userID, number, accrual); err != nil {
+				return fmt.Errorf("failed to apply referral: %w", err)
+			}
+		}
+
+		// Зачисляем баллы записью журнала (только если есть начисление)
+		if accrual.GreaterThan(decimal.Zero) {
+			_, err = appendLedgerEntry(ctx, tx, models.LedgerEntry{
+				UserID:      userID,
+				Kind:        models.LedgerAccrual,
+				Amount:      accrual,
+				OrderNumber: number,
+			})
+			// заказ уже зачислен, повторное зачисление пропускается
+			if errors.Is(err, ErrAlreadyExists) {
+				err = nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to update user balance: %w", err)
+			}
+		}
+
+		// Зачисляем надбавку уровня участника
+		if processed && tierBonus.GreaterThan(decimal.Zero) {
+			_, err = appendLedgerEntry(ctx, tx, models.LedgerEntry{
+				UserID:      userID,
+				Kind:        models.LedgerTier,
+				Amount:      tierBonus,
+				OrderNumber: number,
+				Comment:     "tier bonus",
+			})
+			if errors.Is(err, ErrAlreadyExists) {
+				err = nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to add tier bonus: %w", err)
+			}
+		}
+
+		// Начисляем бонусы промо-кампаний за обработанный заказ
+		if processed {
+			if err = applyCampaigns(ctx, tx, userID, number, accrual); err != nil {
+				return fmt.Errorf("failed to apply campaigns: %w", err)
+			}
 		}
 	}
 
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// AccrualRateLimit - имя общего бюджета запросов к сервису начислений
+	AccrualRateLimit = "accrual"
+
+	InsertRateLimit = `INSERT INTO RATE_LIMITS (name) VALUES ($1) ON CONFLICT (name) DO NOTHING;`
+	LockRateLimit   = `SELECT rate_limit, burst, tokens, blocked_until, updated_at, NOW()
+					   FROM RATE_LIMITS WHERE name = $1 FOR UPDATE;`
+	UpdateRateTokens = `UPDATE RATE_LIMITS SET tokens = $2, updated_at = $3 WHERE name = $1;`
+	GetRateLimit     = `SELECT rate_limit, blocked_until > NOW() FROM RATE_LIMITS WHERE name = $1;`
+	SetRateLimit     = `INSERT INTO RATE_LIMITS (name, rate_limit, burst, tokens) VALUES ($1, $2, $3, $3)
+					   ON CONFLICT (name) DO UPDATE
+					   SET rate_limit = EXCLUDED.rate_limit,
+					       burst = EXCLUDED.burst,
+					       tokens = LEAST(RATE_LIMITS.tokens, EXCLUDED.burst);`
+	BlockRateLimit = `INSERT INTO RATE_LIMITS (name, blocked_until) VALUES ($1, NOW() + $2 * INTERVAL '1 millisecond')
+					  ON CONFLICT (name) DO UPDATE
+					  SET blocked_until = GREATEST(COALESCE(RATE_LIMITS.blocked_until, NOW()), EXCLUDED.blocked_until);`
+)
+
+// RateLimitDatabase - бюджет запросов (token bucket), общий для всех реплик сервиса
+type RateLimitDatabase struct {
+	DB   *Database
+	Name string
+}
+
+// Создание хранилища
+func NewRateLimitStorage(db *Database, name string) RateLimitStorage {
+	return &RateLimitDatabase{DB: db, Name: name}
+}
+
+// Bucket - отдельный общий бюджет запросов с тем же подключением к БД
+func (s *RateLimitDatabase) Bucket(name string) RateLimitStorage {
+	return &RateLimitDatabase{DB: s.DB, Name: name}
+}
+
+// Reserve - попытка забрать токен из общего бюджета.
+// Возвращает 0, если токен получен, иначе время, через которое стоит повторить попытку.
+func (s *RateLimitDatabase) Reserve(ctx context.Context) (time.Duration, error) {
+	tx, err := s.DB.Pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Гарантированный откат при ошибке
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(ctx); rbErr != nil {
+				logger.Error("Reserve. Rollback failed:", zap.Error(rbErr))
+			}
+		}
+	}()
+
+	if _, err = tx.Exec(ctx, InsertRateLimit, s.Name); err != nil {
+		return 0, fmt.Errorf("failed to init rate limit: %w", err)
+	}
+
+	var (
+		limit        *float64
+		burst        int
+		tokens       float64
+		blockedUntil *time.Time
+		updatedAt    time.Time
+		now          time.Time
+	)
+	err = tx.QueryRow(ctx, LockRateLimit, s.Name).Scan(&limit, &burst, &tokens, &blockedUntil, &updatedAt, &now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock rate limit: %w", err)
+	}
+
+	tokens, wait := takeToken(limit, burst, tokens, blockedUntil, updatedAt, now)
+
+	if _, err = tx.Exec(ctx, UpdateRateTokens, s.Name, tokens, now); err != nil {
+		return 0, fmt.Errorf("failed to update rate tokens: %w", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("Reserve. Commit failed: %w", err)
+	}
+	return wait, nil
+}
+
+// GetLimit - текущее ограничение запросов в секунду
+func (s *RateLimitDatabase) GetLimit(ctx context.Context) (rate.Limit, error) {
+	var (
+		limit   *float64
+		blocked *bool
+	)
+	err := s.DB.Pool.QueryRow(ctx, GetRateLimit, s.Name).Scan(&limit, &blocked)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return rate.Inf, nil
+		}
+		return 0, fmt.Errorf("failed to get rate limit: %w", err)
+	}
+	if blocked != nil && *blocked {
+		return 0, nil
+	}
+	if limit == nil {
+		return rate.Inf, nil
+	}
+	return rate.Limit(*limit), nil
+}
+
+// SetLimit - установка ограничения запросов в секунду, rate.Inf снимает ограничение
+func (s *RateLimitDatabase) SetLimit(ctx context.Context, limit rate.Limit, burst int) error {
+	var value *float64
+	if limit != rate.Inf {
+		v := float64(limit)
+		value = &v
+	}
+	if _, err := s.DB.Pool.Exec(ctx, SetRateLimit, s.Name, value, burst); err != nil {
+		return fmt.Errorf("failed to set rate limit: %w", err)
+	}
+	return nil
+}
+
+// Block - запрет запросов для всех реплик на указанное время
+func (s *RateLimitDatabase) Block(ctx context.Context, duration time.Duration) error {
+	if _, err := s.DB.Pool.Exec(ctx, BlockRateLimit, s.Name, duration.Milliseconds()); err != nil {
+		return fmt.Errorf("failed to block rate limit: %w", err)
+	}
+	return nil
+}
+
+// takeToken - расчёт корзины токенов на момент now.
+// Возвращает оставшееся количество токенов и время ожидания (0 - токен выдан).
+func takeToken(limit *float64, burst int, tokens float64, blockedUntil *time.Time, updatedAt time.Time, now time.Time) (float64, time.Duration) {
+	if blockedUntil != nil && blockedUntil.After(now) {
+		return tokens, blockedUntil.Sub(now)
+	}
+	// ограничение не задано
+	if limit == nil || math.IsInf(*limit, 1) {
+		return tokens, 0
+	}
+	if *limit <= 0 {
+		return tokens, time.Second
+	}
+	elapsed := now.Sub(updatedAt).Seconds()
+	if elapsed > 0 {
+		tokens = math.Min(float64(burst), tokens+elapsed*(*limit))
+	}
+	if tokens >= 1 {
+		return tokens - 1, 0
+	}
+	return tokens, time.Duration((1 - tokens) / *limit * float64(time.Second))
+}
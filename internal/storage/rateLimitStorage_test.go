@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTakeToken(t *testing.T) {
+	now := time.Date(2025, 6, 20, 10, 0, 0, 0, time.UTC)
+	limit := func(v float64) *float64 { return &v }
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	testCases := []struct {
+		Name           string
+		Limit          *float64
+		Burst          int
+		Tokens         float64
+		BlockedUntil   *time.Time
+		UpdatedAt      time.Time
+		ExpectedTokens float64
+		ExpectedWait   time.Duration
+	}{
+		{
+			Name:           "Success. Blocked until Retry-After #1",
+			Limit:          limit(1),
+			Burst:          1,
+			Tokens:         1,
+			BlockedUntil:   at(30 * time.Second),
+			UpdatedAt:      now,
+			ExpectedTokens: 1,
+			ExpectedWait:   30 * time.Second,
+		},
+		{
+			Name:           "Success. Block expired #2",
+			Limit:          limit(1),
+			Burst:          1,
+			Tokens:         1,
+			BlockedUntil:   at(-time.Second),
+			UpdatedAt:      now,
+			ExpectedTokens: 0,
+			ExpectedWait:   0,
+		},
+		{
+			Name:           "Success. No limit #3",
+			Limit:          nil,
+			Tokens:         0,
+			UpdatedAt:      now,
+			ExpectedTokens: 0,
+			ExpectedWait:   0,
+		},
+		{
+			Name:           "Success. Infinite limit #4",
+			Limit:          limit(math.Inf(1)),
+			Tokens:         0,
+			UpdatedAt:      now,
+			ExpectedTokens: 0,
+			ExpectedWait:   0,
+		},
+		{
+			Name:           "Success. Zero limit waits #5",
+			Limit:          limit(0),
+			Tokens:         5,
+			UpdatedAt:      now,
+			ExpectedTokens: 5,
+			ExpectedWait:   time.Second,
+		},
+		{
+			Name:           "Success. Token taken #6",
+			Limit:          limit(1),
+			Burst:          3,
+			Tokens:         2,
+			UpdatedAt:      now,
+			ExpectedTokens: 1,
+			ExpectedWait:   0,
+		},
+		{
+			Name:           "Success. Refill capped by burst #7",
+			Limit:          limit(1),
+			Burst:          2,
+			Tokens:         0,
+			UpdatedAt:      now.Add(-time.Minute),
+			ExpectedTokens: 1,
+			ExpectedWait:   0,
+		},
+		{
+			Name:           "Success. Wait for partial token #8",
+			Limit:          limit(2),
+			Burst:          1,
+			Tokens:         0.25,
+			UpdatedAt:      now.Add(-125 * time.Millisecond),
+			ExpectedTokens: 0.5,
+			ExpectedWait:   250 * time.Millisecond,
+		},
+		{
+			Name:           "Success. Clock skew does not drain bucket #9",
+			Limit:          limit(1),
+			Burst:          1,
+			Tokens:         0.5,
+			UpdatedAt:      now.Add(time.Second),
+			ExpectedTokens: 0.5,
+			ExpectedWait:   500 * time.Millisecond,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tokens, wait := takeToken(tc.Limit, tc.Burst, tc.Tokens, tc.BlockedUntil, tc.UpdatedAt, now)
+			if math.Abs(tokens-tc.ExpectedTokens) > 1e-9 {
+				t.Errorf("Expected tokens: %v, got: %v", tc.ExpectedTokens, tokens)
+			}
+			if wait != tc.ExpectedWait {
+				t.Errorf("Expected wait: %v, got: %v", tc.ExpectedWait, wait)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// пригласивший блокируется, чтобы одновременные регистрации не превысили число приглашений с бонусом
+	LockReferrer = `SELECT id FROM USERS WHERE referral_code = $1 FOR UPDATE;`
+	// приглашения, учитываемые в ограничении числа приглашений с бонусом
+	CountReferrals = `SELECT COUNT(*) FROM REFERRALS WHERE referrer_id = $1 AND status <> 'LIMITED';`
+	InsertReferral = `INSERT INTO REFERRALS (referrer_id, referred_id, status, referrer_bonus, referred_bonus, min_accrual)
+					  VALUES ($1, $2, $3, $4, $5, $6);`
+	GetReferrals = `SELECT USERS.login, REFERRALS.status, REFERRALS.referrer_bonus, REFERRALS.created_at, REFERRALS.completed_at
+					FROM REFERRALS JOIN USERS ON USERS.id = REFERRALS.referred_id
+					WHERE REFERRALS.referrer_id = $1
+					ORDER BY REFERRALS.created_at DESC;`
+	LockPendingReferral = `SELECT id, referrer_id, referrer_bonus, referred_bonus, min_accrual
+						   FROM REFERRALS
+						   WHERE referred_id = $1 AND status = 'PENDING'
+						   FOR UPDATE;`
+	CompleteReferral = `UPDATE REFERRALS
+						SET status = $2, order_number = $3, completed_at = CURRENT_TIMESTAMP
+						WHERE id = $1;`
+)
+
+// addReferral - запись приглашения зарегистрированного пользователя по коду пригласившего.
+// Приглашения сверх ограничения сохраняются без бонусов со статусом LIMITED.
+func addReferral(ctx context.Context, tx pgx.Tx, userID string, terms models.ReferralTerms) error {
+	var referrerID string
+	err := tx.QueryRow(ctx, LockReferrer, strings.ToUpper(strings.TrimSpace(terms.Code))).Scan(&referrerID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrReferralCodeNotFound
+		}
+		return fmt.Errorf("lock referrer: %w", err)
+	}
+
+	status := models.ReferralPending
+	if terms.MaxReferrals > 0 {
+		var count int
+		if err := tx.QueryRow(ctx, CountReferrals, referrerID).Scan(&count); err != nil {
+			return fmt.Errorf("count referrals: %w", err)
+		}
+		if count >= terms.MaxReferrals {
+			status = models.ReferralLimited
+			terms.ReferrerBonus = decimal.Zero
+			terms.ReferredBonus = decimal.Zero
+		}
+	}
+
+	_, err = tx.Exec(ctx, InsertReferral, referrerID, userID, status, terms.ReferrerBonus, terms.ReferredBonus, terms.MinAccrual)
+	if err != nil {
+		return fmt.Errorf("insert referral: %w", err)
+	}
+	return nil
+}
+
+// GetReferrals - приглашённые пользователем, новые первыми
+func (s *UserDatabase) GetReferrals(ctx context.Context, userID string) ([]models.ReferralData, error) {
+	rows, err := s.DB.Pool.Query(ctx, GetReferrals, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get referrals: %w", err)
+	}
+	defer rows.Close()
+
+	referrals := []models.ReferralData{}
+	for rows.Next() {
+		var referral models.ReferralData
+		var completedAt *time.Time
+		if err := rows.Scan(
+			&referral.Login,
+			&referral.Status,
+			&referral.Bonus,
+			&referral.CreatedAt,
+			&completedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan referral: %w", err)
+		}
+		if completedAt != nil {
+			referral.CompletedAt = *completedAt
+		}
+		referrals = append(referrals, referral)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return referrals, nil
+}
+
+// applyReferral - завершение приглашения по первому обработанному заказу приглашённого:
+// бонусы обоим пользователям, если начисление по заказу не меньше минимального, иначе отказ.
+// Вызывается до других записей журнала по заказу, чтобы пользователи блокировались
+// в порядке идентификаторов, как при переводах.
+func applyReferral(ctx context.Context, tx pgx.Tx, userID string, number string, accrual decimal.Decimal) error {
+	var (
+		id            int64
+		referrerID    string
+		referrerBonus decimal.Decimal
+		referredBonus decimal.Decimal
+		minAccrual    decimal.Decimal
+	)
+	err := tx.QueryRow(ctx, LockPendingReferral, userID).Scan(&id, &referrerID, &referrerBonus, &referredBonus, &minAccrual)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("lock referral: %w", err)
+	}
+	if _, err := tx.Exec(ctx, LockUsers, []string{referrerID, userID}); err != nil {
+		return fmt.Errorf("lock users: %w", err)
+	}
+
+	var first bool
+	if err := tx.QueryRow(ctx, IsFirstOrder, userID, number).Scan(&first); err != nil {
+		return fmt.Errorf("check first order: %w", err)
+	}
+	status := models.ReferralRewarded
+	if !first || accrual.LessThan(minAccrual) {
+		status = models.ReferralRejected
+	}
+
+	if status == models.ReferralRewarded {
+		for _, entry := range []models.LedgerEntry{
+			{UserID: userID, Kind: models.LedgerReferral, Amount: referredBonus, OrderNumber: number, Comment: "referral bonus"},
+			{UserID: referrerID, Kind: models.LedgerReferral, Amount: referrerBonus, Comment: fmt.Sprintf("referral #%d bonus", id)},
+		} {
+			if !entry.Amount.IsPositive() {
+				continue
+			}
+			if _, err := appendLedgerEntry(ctx, tx, entry); err != nil {
+				return fmt.Errorf("referral %d: %w", id, err)
+			}
+		}
+	}
+
+	if _, err := tx.Exec(ctx, CompleteReferral, id, status, number); err != nil {
+		return fmt.Errorf("complete referral: %w", err)
+	}
+	return nil
+}
@@ -7,41 +7,135 @@ import (
 
 	"github.com/denmor86/ya-gophermart/internal/models"
 	"github.com/shopspring/decimal"
+	"golang.org/x/time/rate"
 )
 
 type UsersStorage interface {
-	AddUser(ctx context.Context, login string, password string) error
+	AddUser(ctx context.Context, login string, password string, referral *models.ReferralTerms) error
 	GetUser(ctx context.Context, login string) (*models.UserData, error)
 	GetUserBalance(ctx context.Context, login string) (*models.UserBalance, error)
+	RecalculateTiers(ctx context.Context, since time.Time, tiers []models.Tier) (int64, error)
+	GetReferrals(ctx context.Context, userID string) ([]models.ReferralData, error)
+	SetUserPriority(ctx context.Context, login string, priority int) error
 }
 
 type OrdersStorage interface {
 	GetOrder(ctx context.Context, number string) (*models.OrderData, error)
+	GetOrderOwner(ctx context.Context, number string) (*models.OrderOwner, error)
+	GetOrderOwners(ctx context.Context, numbers []string) (map[string]models.OrderOwner, error)
+	GetOrderTier(ctx context.Context, number string) (string, error)
 	GetOrders(ctx context.Context, userID string) ([]models.OrderData, error)
-	ClaimOrdersForProcessing(ctx context.Context, count int) ([]string, error)
-	AddOrder(ctx context.Context, number string, userID string, createdAt time.Time) error
-	UpdateOrderAndBalance(ctx context.Context, number string, status string, accrual decimal.Decimal) error
+	ClaimOrdersForProcessing(ctx context.Context, count int, lease time.Duration) ([]string, error)
+	CountOrdersByStatus(ctx context.Context) (map[string]int, error)
+	AddOrder(ctx context.Context, number string, userID string, merchant string, createdAt time.Time) error
+	RequeueOrder(ctx context.Context, number string, priority int) error
+	UpdateOrderAndBalance(ctx context.Context, number string, status string, accrual decimal.Decimal, tierBonus decimal.Decimal) error
+	RecordOrderFailure(ctx context.Context, number string, failure models.OrderFailure) (string, error)
 }
 
 type LoyaltysStorage interface {
 	AddWithdrawal(ctx context.Context, loyalty models.WithdrawalData) error
 	GetWithdrawals(ctx context.Context, userID string) ([]models.WithdrawalData, error)
+	AddHold(ctx context.Context, hold models.HoldData, ttl time.Duration) (*models.HoldData, error)
+	CaptureHold(ctx context.Context, userID string, id int64) (*models.HoldData, error)
+	ReleaseHold(ctx context.Context, userID string, id int64) (*models.HoldData, error)
+	ReleaseExpiredHolds(ctx context.Context, limit int) (int, error)
+}
+
+type LedgerStorage interface {
+	AddLedgerEntry(ctx context.Context, entry models.LedgerEntry) (*models.LedgerEntry, error)
+	ReverseLedgerEntry(ctx context.Context, id int64, comment string) (*models.LedgerEntry, error)
+	GetLedger(ctx context.Context, userID string) ([]models.LedgerEntry, error)
+	GetBalanceHistory(ctx context.Context, userID string, filter models.BalanceHistoryFilter) ([]models.BalanceHistoryEntry, error)
+	GetBalanceAt(ctx context.Context, userID string, at time.Time) (*models.UserBalance, error)
+	CheckLedger(ctx context.Context) ([]models.LedgerMismatch, error)
+	RebuildBalances(ctx context.Context) (int64, error)
+	ExpirePoints(ctx context.Context, before time.Time, limit int) (int, error)
+	GetPointLots(ctx context.Context, userID string, before time.Time) ([]models.PointLot, error)
+}
+
+type TransfersStorage interface {
+	AddTransfer(ctx context.Context, transfer models.TransferData, dailyLimit decimal.Decimal) (*models.TransferData, error)
+}
+
+type CampaignsStorage interface {
+	AddCampaign(ctx context.Context, campaign models.Campaign) (*models.Campaign, error)
+	UpdateCampaign(ctx context.Context, campaign models.Campaign) (*models.Campaign, error)
+	DeactivateCampaign(ctx context.Context, id int64) error
+	GetCampaigns(ctx context.Context) ([]models.Campaign, error)
+}
+
+type IdempotencyStorage interface {
+	BeginRequest(ctx context.Context, scope string, key string, requestHash string, ttl time.Duration, lockTimeout time.Duration) (*models.IdempotentResponse, error)
+	CompleteRequest(ctx context.Context, scope string, key string, response models.IdempotentResponse) error
+	ReleaseRequest(ctx context.Context, scope string, key string) error
+	DeleteExpiredKeys(ctx context.Context, ttl time.Duration, limit int) (int, error)
+}
+
+type OrdersListener interface {
+	Listen(ctx context.Context) <-chan string
+}
+
+type LeaderLock interface {
+	TryLock(ctx context.Context) (bool, error)
+	Check(ctx context.Context) error
+	Unlock(ctx context.Context) error
+}
+
+type RateLimitStorage interface {
+	Reserve(ctx context.Context) (time.Duration, error)
+	GetLimit(ctx context.Context) (rate.Limit, error)
+	SetLimit(ctx context.Context, limit rate.Limit, burst int) error
+	Block(ctx context.Context, duration time.Duration) error
+	Bucket(name string) RateLimitStorage
 }
 
 type Storage struct {
-	Users    UsersStorage
-	Orders   OrdersStorage
-	Loyaltys LoyaltysStorage
+	Users       UsersStorage
+	Orders      OrdersStorage
+	Loyaltys    LoyaltysStorage
+	Ledger      LedgerStorage
+	Transfers   TransfersStorage
+	Campaigns   CampaignsStorage
+	Idempotency IdempotencyStorage
+	Listener    OrdersListener
+	RateLimits  RateLimitStorage
 }
 
 // Создание хранилища
 func NewStorage(db *Database) Storage {
-	return Storage{Users: NewUsersStorage(db), Orders: NewOrdersStorage(db), Loyaltys: NewLoyaltysStorage(db)}
+	return Storage{
+		Users:       NewUsersStorage(db),
+		Orders:      NewOrdersStorage(db),
+		Loyaltys:    NewLoyaltysStorage(db),
+		Ledger:      NewLedgerStorage(db),
+		Transfers:   NewTransfersStorage(db),
+		Campaigns:   NewCampaignsStorage(db),
+		Idempotency: NewIdempotencyStorage(db),
+		Listener:    NewOrdersListener(db),
+		RateLimits:  NewRateLimitStorage(db, AccrualRateLimit),
+	}
 }
 
 var (
-	ErrUserNotFound  = errors.New("user not found")
-	ErrOrderNotFound = errors.New("order not found")
+	ErrUserNotFound   = errors.New("user not found")
+	ErrOrderNotFound  = errors.New("order not found")
+	ErrOrderFinalized = errors.New("order is in final status")
+
+	ErrLedgerEntryNotFound = errors.New("ledger entry not found")
+	ErrInsufficientFunds   = errors.New("insufficient funds")
+
+	ErrTransferLimitExceeded = errors.New("transfer limit exceeded")
+
+	ErrCampaignNotFound = errors.New("campaign not found")
+
+	ErrReferralCodeNotFound = errors.New("referral code not found")
+
+	ErrHoldNotFound  = errors.New("hold not found")
+	ErrHoldNotActive = errors.New("hold is not active")
+
+	ErrIdempotencyKeyReused = errors.New("idempotency key reused with different request")
+	ErrRequestInProgress    = errors.New("request with idempotency key in progress")
 
 	ErrAlreadyExists = errors.New("already exists")
 )
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// пользователи блокируются в порядке идентификаторов, встречные переводы не блокируют друг друга
+	LockUsers = `SELECT id FROM USERS WHERE id = ANY($1) ORDER BY id FOR UPDATE;`
+	// сумма переводов отправителя за последние сутки
+	GetDailyTransfers = `SELECT COALESCE(SUM(amount), 0)
+						 FROM TRANSFERS
+						 WHERE from_user_id = $1 AND created_at > CURRENT_TIMESTAMP - INTERVAL '1 day';`
+	InsertTransfer = `INSERT INTO TRANSFERS (from_user_id, to_user_id, amount, fee, comment)
+					  VALUES ($1, $2, $3, $4, $5)
+					  RETURNING id, created_at;`
+)
+
+type TransferDatabase struct {
+	DB *Database
+}
+
+// Создание хранилища
+func NewTransfersStorage(db *Database) TransfersStorage {
+	return &TransferDatabase{DB: db}
+}
+
+// AddTransfer - перевод баллов в одной транзакции: списание суммы и комиссии у отправителя,
+// зачисление суммы получателю. Перевод сверх суточного лимита возвращает ErrTransferLimitExceeded,
+// сверх доступного баланса - ErrInsufficientFunds. Нулевой лимит - без ограничения.
+func (s *TransferDatabase) AddTransfer(ctx context.Context, transfer models.TransferData, dailyLimit decimal.Decimal) (*models.TransferData, error) {
+	err := inTx(ctx, s.DB, "AddTransfer", func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, LockUsers, []string{transfer.FromUserID, transfer.ToUserID})
+		if err != nil {
+			return fmt.Errorf("lock users: %w", err)
+		}
+		locked := 0
+		for rows.Next() {
+			locked++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("lock users: %w", err)
+		}
+		if locked != 2 {
+			return ErrUserNotFound
+		}
+
+		if dailyLimit.IsPositive() {
+			var sent decimal.Decimal
+			if err := tx.QueryRow(ctx, GetDailyTransfers, transfer.FromUserID).Scan(&sent); err != nil {
+				return fmt.Errorf("get daily transfers: %w", err)
+			}
+			if sent.Add(transfer.Amount).GreaterThan(dailyLimit) {
+				return ErrTransferLimitExceeded
+			}
+		}
+
+		err = tx.QueryRow(ctx, InsertTransfer,
+			transfer.FromUserID,
+			transfer.ToUserID,
+			transfer.Amount,
+			transfer.Fee,
+			transfer.Comment,
+		).Scan(&transfer.ID, &transfer.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("insert transfer: %w", err)
+		}
+
+		entries := []models.LedgerEntry{
+			{
+				UserID:  transfer.FromUserID,
+				Kind:    models.LedgerTransfer,
+				Amount:  transfer.Amount.Neg(),
+				Comment: fmt.Sprintf("transfer #%d to %s", transfer.ID, transfer.ToLogin),
+			},
+			{
+				UserID:  transfer.ToUserID,
+				Kind:    models.LedgerTransfer,
+				Amount:  transfer.Amount,
+				Comment: fmt.Sprintf("transfer #%d from %s", transfer.ID, transfer.FromLogin),
+			},
+		}
+		if transfer.Fee.IsPositive() {
+			entries = append(entries, models.LedgerEntry{
+				UserID:  transfer.FromUserID,
+				Kind:    models.LedgerFee,
+				Amount:  transfer.Fee.Neg(),
+				Comment: fmt.Sprintf("transfer #%d fee", transfer.ID),
+			})
+		}
+		for _, entry := range entries {
+			if _, err := appendLedgerEntry(ctx, tx, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
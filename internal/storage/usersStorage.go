@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/denmor86/ya-gophermart/internal/models"
 	"github.com/google/uuid"
@@ -17,9 +18,11 @@ const (
 						VALUES ($1, $2, $3) 
 						ON CONFLICT (login) DO NOTHING
 						RETURNING login;`
-	GetUser = `SELECT id, password, login, balance FROM USERS WHERE login=$1;`
+	GetUser = `SELECT id, password, login, balance, referral_code FROM USERS WHERE login=$1;`
+	// приоритет пользователя копируется в загружаемые им заказы
+	SetUserPriority = `UPDATE USERS SET priority = $2 WHERE login = $1 RETURNING id;`
 
-	GetUserBalance = `SELECT users.balance AS balance, COALESCE(SUM(LOYALTY.amount), 0) AS withdrawn
+	GetUserBalance = `SELECT users.balance - users.held AS balance, users.held AS held, users.tier AS tier, COALESCE(SUM(LOYALTY.amount), 0) AS withdrawn
 					  FROM 
 					      USERS
 					  LEFT JOIN 
@@ -27,7 +30,28 @@ const (
 					  WHERE 
 					      USERS.login = $1
 					  GROUP BY 
-					      USERS.balance;`
+					      USERS.balance, USERS.held, USERS.tier;`
+	// уровень - наибольший, порог которого не превышает сумму начислений пользователя с $1
+	RecalculateTiers = `UPDATE USERS
+						SET tier = ranked.tier, tier_updated_at = CURRENT_TIMESTAMP
+						FROM (
+						    SELECT USERS.id AS user_id,
+						           COALESCE((
+						               SELECT t.name
+						               FROM unnest($2::TEXT[], $3::TEXT[]) AS t(name, threshold)
+						               WHERE t.threshold::NUMERIC <= COALESCE(sums.total, 0)
+						               ORDER BY t.threshold::NUMERIC DESC
+						               LIMIT 1
+						           ), '') AS tier
+						    FROM USERS
+						    LEFT JOIN (
+						        SELECT user_id, SUM(amount) AS total
+						        FROM LEDGER
+						        WHERE kind = 'ACCRUAL' AND created_at >= $1
+						        GROUP BY user_id
+						    ) AS sums ON sums.user_id = USERS.id
+						) AS ranked
+						WHERE USERS.id = ranked.user_id AND USERS.tier <> ranked.tier;`
 )
 
 type UserDatabase struct {
@@ -45,8 +69,9 @@ func (s *UserDatabase) GetUser(ctx context.Context, login string) (*models.UserD
 		password string
 		dbLogin  string
 		balance  decimal.Decimal
+		code     string
 	)
-	err := s.DB.Pool.QueryRow(ctx, GetUser, login).Scan(&userID, &password, &dbLogin, &balance)
+	err := s.DB.Pool.QueryRow(ctx, GetUser, login).Scan(&userID, &password, &dbLogin, &balance, &code)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrUserNotFound
@@ -59,14 +84,27 @@ func (s *UserDatabase) GetUser(ctx context.Context, login string) (*models.UserD
 		Login:        dbLogin,
 		PasswordHash: password,
 		Balance:      balance,
+		ReferralCode: code,
 	}, nil
 }
 
-func (s *UserDatabase) AddUser(ctx context.Context, login string, password string) error {
+// AddUser - добавление пользователя. При заданном приглашении пользователь регистрируется
+// приглашённым владельцем кода, неизвестный код возвращает ErrReferralCodeNotFound
+func (s *UserDatabase) AddUser(ctx context.Context, login string, password string, referral *models.ReferralTerms) error {
 	var prevLogin string
 	userID := uuid.New().String()
 
-	err := s.DB.Pool.QueryRow(ctx, InsertUser, userID, login, password).Scan(&prevLogin)
+	var err error
+	if referral != nil {
+		err = inTx(ctx, s.DB, "AddUser", func(tx pgx.Tx) error {
+			if err := tx.QueryRow(ctx, InsertUser, userID, login, password).Scan(&prevLogin); err != nil {
+				return err
+			}
+			return addReferral(ctx, tx, userID, *referral)
+		})
+	} else {
+		err = s.DB.Pool.QueryRow(ctx, InsertUser, userID, login, password).Scan(&prevLogin)
+	}
 
 	// Успешное добавление
 	if err == nil {
@@ -78,20 +116,40 @@ func (s *UserDatabase) AddUser(ctx context.Context, login string, password strin
 	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
 		return ErrAlreadyExists
 	}
+	if errors.Is(err, ErrReferralCodeNotFound) {
+		return err
+	}
 
 	// Все остальные ошибки
 	return fmt.Errorf("failed to add user: %w", err)
 }
 
+// SetUserPriority - Изменение приоритета пользователя в очереди обработки заказов
+func (s *UserDatabase) SetUserPriority(ctx context.Context, login string, priority int) error {
+	var userID string
+	err := s.DB.Pool.QueryRow(ctx, SetUserPriority, login, priority).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("failed to set user priority: %w", err)
+	}
+	return nil
+}
+
 // GetUserBalance - Получение баланса и потраченных баллов пользователя
 func (s *UserDatabase) GetUserBalance(ctx context.Context, login string) (*models.UserBalance, error) {
 	var (
-		current   float64
-		withdrawn float64
+		current   decimal.Decimal
+		held      decimal.Decimal
+		tier      string
+		withdrawn decimal.Decimal
 	)
 
 	err := s.DB.Pool.QueryRow(ctx, GetUserBalance, login).Scan(
 		&current,
+		&held,
+		&tier,
 		&withdrawn,
 	)
 
@@ -104,6 +162,24 @@ func (s *UserDatabase) GetUserBalance(ctx context.Context, login string) (*model
 
 	return &models.UserBalance{
 		Current:   current,
+		Held:      held,
+		Tier:      tier,
 		Withdrawn: withdrawn,
 	}, nil
 }
+
+// RecalculateTiers - пересчёт уровней пользователей по сумме начислений с since,
+// возвращает количество пользователей со сменившимся уровнем
+func (s *UserDatabase) RecalculateTiers(ctx context.Context, since time.Time, tiers []models.Tier) (int64, error) {
+	names := make([]string, 0, len(tiers))
+	thresholds := make([]string, 0, len(tiers))
+	for _, tier := range tiers {
+		names = append(names, tier.Name)
+		thresholds = append(thresholds, tier.Threshold.String())
+	}
+	tag, err := s.DB.Pool.Exec(ctx, RecalculateTiers, since.UTC(), names, thresholds)
+	if err != nil {
+		return 0, fmt.Errorf("failed to recalculate tiers: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
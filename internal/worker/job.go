@@ -0,0 +1,88 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/leader"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"go.uber.org/zap"
+)
+
+// BatchFunc - обработка одной пачки, возвращает количество обработанных записей
+type BatchFunc func(ctx context.Context) (int, error)
+
+// BatchJob - периодическое задание, обрабатывающее записи пачками, пока находятся записи.
+// Выполняется только на реплике-лидере.
+type BatchJob struct {
+	Name      string
+	Leader    leader.Elector
+	WaitGroup sync.WaitGroup
+	QuitChan  chan struct{}
+	interval  time.Duration
+	batch     int
+	process   BatchFunc
+}
+
+func NewBatchJob(name string, elector leader.Elector, interval time.Duration, batch int, process BatchFunc) *BatchJob {
+	return &BatchJob{
+		Name:     name,
+		Leader:   elector,
+		QuitChan: make(chan struct{}),
+		interval: interval,
+		batch:    batch,
+		process:  process,
+	}
+}
+
+func (j *BatchJob) Start(ctx context.Context) {
+	// задание отключено
+	if j.interval <= 0 {
+		return
+	}
+	j.WaitGroup.Add(1)
+	go j.Run(ctx)
+}
+
+func (j *BatchJob) Stop() {
+	close(j.QuitChan)
+	j.WaitGroup.Wait()
+}
+
+func (j *BatchJob) Run(ctx context.Context) {
+	defer j.WaitGroup.Done()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-j.QuitChan:
+			logger.Info(j.Name + " stopped by quit signal")
+			return
+		case <-ctx.Done():
+			logger.Info(j.Name + " stopped by context cancellation")
+			return
+		case <-ticker.C:
+			j.runBatches(ctx)
+		}
+	}
+}
+
+// runBatches - обработка пачек, пока пачка заполнена целиком. Без размера пачки - один проход.
+func (j *BatchJob) runBatches(ctx context.Context) {
+	if j.Leader != nil && !j.Leader.IsLeader() {
+		return
+	}
+	for {
+		processed, err := j.process(ctx)
+		if err != nil {
+			logger.Error(j.Name+" failed:", zap.Error(err))
+			return
+		}
+		if j.batch <= 0 || processed == 0 || processed < j.batch || ctx.Err() != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/config"
+	"github.com/denmor86/ya-gophermart/internal/leader"
+	"github.com/denmor86/ya-gophermart/internal/services"
+)
+
+// NewExpirationJob - задание сгорания баллов с истёкшим сроком жизни
+func NewExpirationJob(ledger services.LedgerService, elector leader.Elector, config config.PointsConfig) *BatchJob {
+	interval := config.ExpireInterval
+	// баллы не сгорают
+	if config.TTL <= 0 {
+		interval = 0
+	}
+	return NewBatchJob("ExpirationJob", elector, interval, config.ExpireBatch, func(ctx context.Context) (int, error) {
+		return ledger.ExpirePoints(ctx, time.Now())
+	})
+}
+
+// NewHoldReleaseJob - задание снятия резервов баллов, не завершённых за отведённое время
+func NewHoldReleaseJob(loyalty services.LoyaltyService, elector leader.Elector, config config.HoldsConfig) *BatchJob {
+	return NewBatchJob("HoldReleaseJob", elector, config.ReleaseInterval, config.ReleaseBatch, loyalty.ReleaseExpiredHolds)
+}
+
+// NewTierRecalcJob - задание пересчёта уровней пользователей
+func NewTierRecalcJob(tiers services.TierService, elector leader.Elector, config config.TiersConfig) *BatchJob {
+	interval := config.RecalcInterval
+	// уровни не используются
+	if len(config.Tiers) == 0 {
+		interval = 0
+	}
+	return NewBatchJob("TierRecalcJob", elector, interval, 0, func(ctx context.Context) (int, error) {
+		updated, err := tiers.RecalculateTiers(ctx, time.Now())
+		return int(updated), err
+	})
+}
+
+// NewIdempotencyCleanupJob - задание удаления ключей идемпотентности старше срока хранения
+func NewIdempotencyCleanupJob(idempotency services.IdempotencyService, elector leader.Elector, config config.ServerConfig) *BatchJob {
+	return NewBatchJob("IdempotencyCleanupJob", elector, config.IdempotencyCleanup, config.IdempotencyBatch, func(ctx context.Context) (int, error) {
+		return idempotency.DeleteExpired(ctx, config.IdempotencyBatch)
+	})
+}
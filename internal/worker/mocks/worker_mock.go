@@ -0,0 +1,134 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal\worker\worker.go
+//
+// Generated by this command:
+//
+//	mockgen -source=internal\worker\worker.go -destination=internal\worker\mocks\worker_mock.go -package=mocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	models "github.com/denmor86/ya-gophermart/internal/models"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockController is a mock of Controller interface.
+type MockController struct {
+	ctrl     *gomock.Controller
+	recorder *MockControllerMockRecorder
+	isgomock struct{}
+}
+
+// MockControllerMockRecorder is the mock recorder for MockController.
+type MockControllerMockRecorder struct {
+	mock *MockController
+}
+
+// NewMockController creates a new mock instance.
+func NewMockController(ctrl *gomock.Controller) *MockController {
+	mock := &MockController{ctrl: ctrl}
+	mock.recorder = &MockControllerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockController) EXPECT() *MockControllerMockRecorder {
+	return m.recorder
+}
+
+// Breakers mocks base method.
+func (m *MockController) Breakers() models.BreakersStatus {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Breakers")
+	ret0, _ := ret[0].(models.BreakersStatus)
+	return ret0
+}
+
+// Breakers indicates an expected call of Breakers.
+func (mr *MockControllerMockRecorder) Breakers() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Breakers", reflect.TypeOf((*MockController)(nil).Breakers))
+}
+
+// Drain mocks base method.
+func (m *MockController) Drain(ctx context.Context) (*models.WorkerStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Drain", ctx)
+	ret0, _ := ret[0].(*models.WorkerStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Drain indicates an expected call of Drain.
+func (mr *MockControllerMockRecorder) Drain(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Drain", reflect.TypeOf((*MockController)(nil).Drain), ctx)
+}
+
+// Pause mocks base method.
+func (m *MockController) Pause() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Pause")
+}
+
+// Pause indicates an expected call of Pause.
+func (mr *MockControllerMockRecorder) Pause() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pause", reflect.TypeOf((*MockController)(nil).Pause))
+}
+
+// ResetBreaker mocks base method.
+func (m *MockController) ResetBreaker() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ResetBreaker")
+}
+
+// ResetBreaker indicates an expected call of ResetBreaker.
+func (mr *MockControllerMockRecorder) ResetBreaker() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetBreaker", reflect.TypeOf((*MockController)(nil).ResetBreaker))
+}
+
+// Resume mocks base method.
+func (m *MockController) Resume() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Resume")
+}
+
+// Resume indicates an expected call of Resume.
+func (mr *MockControllerMockRecorder) Resume() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Resume", reflect.TypeOf((*MockController)(nil).Resume))
+}
+
+// Status mocks base method.
+func (m *MockController) Status(ctx context.Context) (*models.WorkerStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Status", ctx)
+	ret0, _ := ret[0].(*models.WorkerStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Status indicates an expected call of Status.
+func (mr *MockControllerMockRecorder) Status(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Status", reflect.TypeOf((*MockController)(nil).Status), ctx)
+}
+
+// Trigger mocks base method.
+func (m *MockController) Trigger() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Trigger")
+}
+
+// Trigger indicates an expected call of Trigger.
+func (mr *MockControllerMockRecorder) Trigger() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Trigger", reflect.TypeOf((*MockController)(nil).Trigger))
+}
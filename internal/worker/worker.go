@@ -7,36 +7,60 @@ import (
 	"sync"
 	"time"
 
+	"github.com/denmor86/ya-gophermart/internal/client"
 	"github.com/denmor86/ya-gophermart/internal/config"
+	"github.com/denmor86/ya-gophermart/internal/leader"
 	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/models"
 	"github.com/denmor86/ya-gophermart/internal/services"
-	"github.com/sony/gobreaker"
+	"github.com/denmor86/ya-gophermart/internal/storage"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 )
 
+// Controller - представляет интерфейс управления воркером обработки заказов
+type Controller interface {
+	Pause()
+	Resume()
+	Drain(ctx context.Context) (*models.WorkerStatus, error)
+	Trigger()
+	ResetBreaker()
+	Breakers() models.BreakersStatus
+	Status(ctx context.Context) (*models.WorkerStatus, error)
+}
+
 type OrderWorker struct {
-	Orders    services.OrdersService
-	Breaker   *gobreaker.CircuitBreaker
-	WaitGroup sync.WaitGroup
-	QuitChan  chan struct{}
-	config    config.AccrualConfig
+	Orders      services.OrdersService
+	Listener    storage.OrdersListener
+	Leader      leader.Elector
+	Limiter     client.Limiter
+	Breaker     *client.BreakerRegistry
+	WaitGroup   sync.WaitGroup
+	QuitChan    chan struct{}
+	TriggerChan chan struct{}
+	config      config.AccrualConfig
+
+	mu          sync.RWMutex
+	paused      bool
+	draining    bool
+	inFlight    sync.WaitGroup
+	running     bool
+	lastBatchAt time.Time
 }
 
-func NewOrderWorker(orders services.OrdersService, config config.AccrualConfig) *OrderWorker {
+func NewOrderWorker(orders services.OrdersService, listener storage.OrdersListener, elector leader.Elector, limiter client.Limiter, breakers *client.BreakerRegistry, config config.AccrualConfig) *OrderWorker {
+	if breakers == nil {
+		breakers = client.NewBreakerRegistry(config)
+	}
 	return &OrderWorker{
-		Orders: orders,
-		Breaker: gobreaker.NewCircuitBreaker(gobreaker.Settings{
-			Name:    "accrual-service",
-			Timeout: config.CircuitBreakerTimeout,
-			ReadyToTrip: func(counts gobreaker.Counts) bool {
-				return counts.ConsecutiveFailures >= uint32(config.CircuitBreakerFailures)
-			},
-			OnStateChange: func(name string, from, to gobreaker.State) {
-				logger.Info("Circuit Breaker '%s': %s → %s", name, from, to)
-			},
-		}),
-		QuitChan: make(chan struct{}),
-		config:   config,
+		Orders:      orders,
+		Listener:    listener,
+		Leader:      elector,
+		Limiter:     limiter,
+		Breaker:     breakers,
+		QuitChan:    make(chan struct{}),
+		TriggerChan: make(chan struct{}, 1),
+		config:      config,
 	}
 }
 
@@ -56,6 +80,12 @@ func (w *OrderWorker) Run(ctx context.Context) {
 	ticker := time.NewTicker(w.config.PollInterval)
 	defer ticker.Stop()
 
+	// уведомления о новых заказах, при обрыве соединения остаётся опрос по таймеру
+	var notifications <-chan string
+	if w.Listener != nil {
+		notifications = w.Listener.Listen(ctx)
+	}
+
 	for {
 		select {
 		case <-w.QuitChan:
@@ -65,47 +95,203 @@ func (w *OrderWorker) Run(ctx context.Context) {
 			logger.Info("OrderWorker stopped by context cancellation")
 			return
 		case <-ticker.C:
+			w.processScheduledBatch(ctx)
+		case _, ok := <-notifications:
+			if !ok {
+				notifications = nil
+				continue
+			}
+			w.processScheduledBatch(ctx)
+		case <-w.TriggerChan:
+			// запуск по команде оператора выполняется и на приостановленном воркере
 			w.processBatch(ctx)
 		}
 	}
 }
+
+// Pause - приостановка опроса сервиса начислений
+func (w *OrderWorker) Pause() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.paused = true
+	logger.Info("OrderWorker paused")
+}
+
+// Resume - возобновление опроса сервиса начислений, в том числе после Drain
+func (w *OrderWorker) Resume() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.paused = false
+	w.draining = false
+	logger.Info("OrderWorker resumed")
+}
+
+// Drain - остановка захвата заказов, в том числе по команде Trigger, и ожидание
+// завершения обработки уже захваченной пачки. Возвращает состояние после завершения;
+// при отмене ctx захват остаётся остановленным до Resume.
+func (w *OrderWorker) Drain(ctx context.Context) (*models.WorkerStatus, error) {
+	w.mu.Lock()
+	w.draining = true
+	w.mu.Unlock()
+	logger.Info("OrderWorker draining")
+
+	done := make(chan struct{})
+	go func() {
+		w.inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	logger.Info("OrderWorker drained")
+	return w.Status(ctx)
+}
+
+// Trigger - немедленный запуск обработки пачки заказов
+func (w *OrderWorker) Trigger() {
+	select {
+	case w.TriggerChan <- struct{}{}:
+	default:
+		// запуск уже запланирован
+	}
+}
+
+// ResetBreaker - принудительный сброс circuit breaker сервисов начислений в закрытое состояние
+func (w *OrderWorker) ResetBreaker() {
+	w.Breaker.Reset()
+	logger.Info("Accrual circuit breakers reset")
+}
+
+// Breakers - состояние circuit breaker сервисов начислений и последние смены состояний
+func (w *OrderWorker) Breakers() models.BreakersStatus {
+	return w.Breaker.Status()
+}
+
+// Status - состояние воркера, очереди заказов и ограничений сервиса начислений
+func (w *OrderWorker) Status(ctx context.Context) (*models.WorkerStatus, error) {
+	queue, err := w.Orders.GetQueueStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	breakers := w.Breaker.Status()
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	status := &models.WorkerStatus{
+		State:    models.WorkerStateRunning,
+		Leader:   w.Leader == nil || w.Leader.IsLeader(),
+		Breaker:  w.Breaker.State(),
+		Breakers: breakers.Breakers,
+		Queue:    queue,
+	}
+	switch {
+	case w.draining && w.running:
+		status.State = models.WorkerStateDraining
+	case w.draining:
+		status.State = models.WorkerStateDrained
+	case w.paused:
+		status.State = models.WorkerStatePaused
+	}
+	if !w.lastBatchAt.IsZero() {
+		status.LastBatchAt = w.lastBatchAt.Format(time.RFC3339)
+	}
+	if w.Limiter != nil && w.Limiter.Limit() != rate.Inf {
+		limit := float64(w.Limiter.Limit())
+		status.RateLimit = &limit
+	}
+	return status, nil
+}
+
+// processScheduledBatch - обработка пачки по таймеру или уведомлению, пропускается на паузе
+func (w *OrderWorker) processScheduledBatch(ctx context.Context) {
+	w.mu.RLock()
+	paused := w.paused
+	w.mu.RUnlock()
+	if paused {
+		return
+	}
+	w.processBatch(ctx)
+}
+
 func (w *OrderWorker) processBatch(ctx context.Context) {
-	// Используем Circuit Breaker для всей операции
-	_, err := w.Breaker.Execute(func() (interface{}, error) {
-		// Получаем заказы для обработки
-		orders, err := w.Orders.ClaimOrdersForProcessing(ctx, w.config.BatchSize)
-		if err != nil {
-			logger.Error("Failed to claim orders:", zap.Error(err))
-			if errors.Is(err, context.Canceled) {
-				return nil, err
-			}
-			return nil, fmt.Errorf("failed to claim orders: %w", err)
-		}
+	// опрос сервиса начислений выполняет только лидер
+	if w.Leader != nil && !w.Leader.IsLeader() {
+		return
+	}
 
-		// Последовательная обработка заказов
-		var lastErr error
-		for _, orderNum := range orders {
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			default:
-				// Обрабатываем заказ с индивидуальным таймаутом
-				processCtx, cancel := context.WithTimeout(ctx, w.config.ProcessingTimeout)
-				defer cancel()
-
-				processErr := w.Orders.ProcessOrder(processCtx, orderNum)
-				if processErr != nil {
-					logger.Error("Failed to process order", orderNum, "Error:", zap.Error(processErr))
-					lastErr = processErr
-					// Продолжаем обработку следующих заказов
-				}
-			}
+	// после Drain новые заказы не захватываются, захваченная пачка учитывается до завершения
+	w.mu.Lock()
+	if w.draining {
+		w.mu.Unlock()
+		return
+	}
+	w.inFlight.Add(1)
+	w.running = true
+	w.lastBatchAt = time.Now()
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		w.running = false
+		w.mu.Unlock()
+		w.inFlight.Done()
+	}()
+
+	// circuit breaker находится в клиенте сервиса начислений, поэтому сбой захвата
+	// заказов в БД его не размыкает, а недоступность сервиса не мешает захвату
+	if err := w.processOrders(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		logger.Error("Order batch processing failed:", zap.Error(err))
+	}
+}
+
+func (w *OrderWorker) processOrders(ctx context.Context) error {
+	// Получаем заказы для обработки, аренда покрывает обработку всей пачки по одному заказу
+	lease := w.config.ProcessingTimeout * time.Duration(w.config.BatchSize)
+	orders, err := w.Orders.ClaimOrdersForProcessing(ctx, w.config.BatchSize, lease)
+	if err != nil {
+		logger.Error("Failed to claim orders:", zap.Error(err))
+		if errors.Is(err, context.Canceled) {
+			return err
 		}
+		return fmt.Errorf("failed to claim orders: %w", err)
+	}
 
-		return nil, lastErr
-	})
+	if len(orders) == 0 {
+		return nil
+	}
 
-	if err != nil && !errors.Is(err, context.Canceled) {
-		logger.Error("Order batch processing failed:", zap.Error(err))
+	// обработка пачки не выходит за аренду, после неё заказы могут быть захвачены повторно
+	leaseCtx, cancel := context.WithTimeout(ctx, lease)
+	defer cancel()
+
+	// Пакетная обработка, если сервис начислений её поддерживает: у запроса
+	// и записи каждого заказа свой таймаут
+	batchErr := w.Orders.ProcessOrders(leaseCtx, orders, w.config.ProcessingTimeout)
+	if !errors.Is(batchErr, client.ErrBatchNotSupported) {
+		return batchErr
 	}
+
+	// Последовательная обработка заказов
+	var lastErr error
+	for _, orderNum := range orders {
+		select {
+		case <-leaseCtx.Done():
+			return leaseCtx.Err()
+		default:
+			// Обрабатываем заказ с индивидуальным таймаутом
+			processCtx, cancel := context.WithTimeout(leaseCtx, w.config.ProcessingTimeout)
+			processErr := w.Orders.ProcessOrder(processCtx, orderNum)
+			cancel()
+			if processErr != nil {
+				logger.Error("Failed to process order", orderNum, "Error:", zap.Error(processErr))
+				lastErr = processErr
+				// Продолжаем обработку следующих заказов
+			}
+		}
+	}
+
+	return lastErr
 }
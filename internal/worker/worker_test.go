@@ -0,0 +1,96 @@
+package worker
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/denmor86/ya-gophermart/internal/config"
+	"github.com/denmor86/ya-gophermart/internal/logger"
+	"github.com/denmor86/ya-gophermart/internal/models"
+	"github.com/denmor86/ya-gophermart/internal/services"
+)
+
+// blockingOrders - сервис заказов, обработка пачки которого ждёт release
+type blockingOrders struct {
+	services.OrdersService
+	claims  atomic.Int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (o *blockingOrders) ClaimOrdersForProcessing(ctx context.Context, count int, lease time.Duration) ([]string, error) {
+	o.claims.Add(1)
+	return []string{"123456789"}, nil
+}
+
+func (o *blockingOrders) ProcessOrders(ctx context.Context, numbers []string, timeout time.Duration) error {
+	o.started <- struct{}{}
+	<-o.release
+	return nil
+}
+
+func (o *blockingOrders) GetQueueStats(ctx context.Context) (map[string]int, error) {
+	return map[string]int{models.OrderStatusProcessing: 1}, nil
+}
+
+func TestOrderWorker_Drain(t *testing.T) {
+	cfg := config.DefaultConfig()
+	if err := logger.Initialize(cfg.Server.LogLevel); err != nil {
+		logger.Panic(err)
+	}
+	cfg.Accrual.PollInterval = time.Hour
+
+	orders := &blockingOrders{started: make(chan struct{}), release: make(chan struct{})}
+	w := NewOrderWorker(orders, nil, nil, nil, nil, cfg.Accrual)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w.Start(ctx)
+	defer w.Stop()
+
+	w.Trigger()
+	<-orders.started
+
+	drained := make(chan *models.WorkerStatus)
+	go func() {
+		status, err := w.Drain(ctx)
+		if err != nil {
+			t.Errorf("Expected no error, got: '%v'", err)
+		}
+		drained <- status
+	}()
+
+	// пока пачка обрабатывается, Drain не завершается
+	select {
+	case <-drained:
+		t.Fatal("Drain returned before in-flight batch finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+	status, err := w.Status(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got: '%v'", err)
+	}
+	if status.State != models.WorkerStateDraining {
+		t.Errorf("Expected state: '%v', got: '%v'", models.WorkerStateDraining, status.State)
+	}
+
+	orders.release <- struct{}{}
+	status = <-drained
+	if status == nil || status.State != models.WorkerStateDrained {
+		t.Fatalf("Expected state: '%v', got: '%+v'", models.WorkerStateDrained, status)
+	}
+
+	// после Drain новые заказы не захватываются даже по команде
+	w.Trigger()
+	time.Sleep(50 * time.Millisecond)
+	if claims := orders.claims.Load(); claims != 1 {
+		t.Errorf("Expected 1 claim, got: %d", claims)
+	}
+
+	timeout, cancelTimeout := context.WithTimeout(ctx, time.Second)
+	defer cancelTimeout()
+	if _, err := w.Drain(timeout); err != nil {
+		t.Errorf("Expected idle worker to drain, got: '%v'", err)
+	}
+}